@@ -0,0 +1,216 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+func init() {
+	RegisterExternalSignerBuildComponents(InitRegistrationContext)
+}
+
+var PrepareForTestWithExternalSigner = FixtureRegisterWithContext(RegisterExternalSignerBuildComponents)
+
+func RegisterExternalSignerBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("external_signer", ExternalSignerFactory)
+}
+
+type externalSignerProperties struct {
+	// Host tool that signs with the private key, e.g. a PKCS#11 or signing service client. It is
+	// used as the signing helper of avbtool, and is invoked as
+	// `<tool> <algorithm> <public_key> <file>`, where file contains the data to sign and must be
+	// overwritten with the signature.
+	Tool *string
+
+	// Public key in PEM format, matching the private key held by the signer. Used to sign AVB
+	// images and APEX payloads.
+	Public_key *string `android:"path"`
+
+	// Certificate in x509.pem format, matching the private key held by the signer. Used to sign
+	// APKs and APEX containers.
+	Certificate *string `android:"path"`
+
+	// Java security provider through which signapk accesses the private key to sign APKs and
+	// APEX containers, e.g. sun.security.pkcs11.SunPKCS11.
+	Provider_class *string
+
+	// Argument to the provider, e.g. the configuration file of the PKCS#11 token.
+	Provider_arg *string `android:"path"`
+
+	// Type of the key store provided by provider_class. Default is "PKCS11".
+	Keystore *string
+
+	// Alias of the private key in the key store. Defaults to the name of this module.
+	Key_alias *string
+}
+
+type externalSigner struct {
+	ModuleBase
+
+	properties externalSignerProperties
+}
+
+// ExternalSignerInfo is provided by external_signer modules to the modules that sign with them.
+// Paths that are not configured in the external_signer module are nil.
+type ExternalSignerInfo struct {
+	// Name of the external_signer module
+	Name string
+
+	// Signing helper passed to avbtool
+	Tool Path
+
+	// Public key in PEM format
+	PublicKey Path
+
+	// Certificate in x509.pem format
+	Certificate Path
+
+	// Flags of signapk to load the private key from the key store of a security provider.
+	// Empty if the signer can't sign APKs.
+	SignapkFlags []string
+
+	// Implicit inputs of SignapkFlags
+	SignapkDeps Paths
+
+	// Alias of the private key, passed to signapk in place of the private key file
+	KeyAlias string
+}
+
+var ExternalSignerInfoProvider = blueprint.NewProvider(ExternalSignerInfo{})
+
+// AvbtoolFlags returns the flags to pass to avbtool, in addition to --key PublicKey, to sign with
+// the external signer.
+func (s ExternalSignerInfo) AvbtoolFlags() string {
+	return "--signing_helper_with_files " + s.Tool.String()
+}
+
+type externalSignerToolDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+var externalSignerToolTag = externalSignerToolDependencyTag{}
+
+type externalSignerDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+var externalSignerTag = externalSignerDependencyTag{}
+
+// ExternalSignerPrefix marks the value of a private key or certificate property as the name of an
+// external_signer module rather than a path, e.g. `private_key: "external:release_signer"`.
+const ExternalSignerPrefix = "external:"
+
+// ExternalSignerName returns the name of the external_signer module referenced by the value of a
+// private key or certificate property, or "" if the value doesn't reference one.
+func ExternalSignerName(value *string) string {
+	if name, ok := strings.CutPrefix(proptools.String(value), ExternalSignerPrefix); ok {
+		return name
+	}
+	return ""
+}
+
+// AddExternalSignerDependency adds a dependency on the external_signer module referenced by the
+// value of a private key or certificate property, if it references one.
+func AddExternalSignerDependency(ctx BottomUpMutatorContext, value *string) {
+	if name := ExternalSignerName(value); name != "" {
+		ctx.AddDependency(ctx.Module(), externalSignerTag, name)
+	}
+}
+
+// external_signer describes a private key that is held outside of the build machine, e.g. in a
+// hardware security module or a signing service, so that release builds can sign APKs, APEXes
+// and AVB images without private key files. Modules sign with it by setting their private key
+// or certificate property to "external:<name>".
+func ExternalSignerFactory() Module {
+	module := &externalSigner{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func (s *externalSigner) DepsMutator(ctx BottomUpMutatorContext) {
+	if tool := proptools.String(s.properties.Tool); tool != "" {
+		ctx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), externalSignerToolTag, tool)
+	}
+}
+
+func (s *externalSigner) GenerateAndroidBuildActions(ctx ModuleContext) {
+	info := ExternalSignerInfo{
+		Name:     ctx.ModuleName(),
+		KeyAlias: proptools.StringDefault(s.properties.Key_alias, ctx.ModuleName()),
+	}
+
+	ctx.VisitDirectDepsWithTag(externalSignerToolTag, func(m Module) {
+		t, ok := m.(HostToolProvider)
+		if !ok {
+			ctx.PropertyErrorf("tool", "%q is not a host tool", ctx.OtherModuleName(m))
+			return
+		}
+		if path := t.HostToolPath(); path.Valid() {
+			info.Tool = path.Path()
+		} else {
+			ctx.PropertyErrorf("tool", "host tool %q missing output file", ctx.OtherModuleName(m))
+		}
+	})
+
+	if s.properties.Public_key != nil {
+		info.PublicKey = PathForModuleSrc(ctx, proptools.String(s.properties.Public_key))
+	}
+	if s.properties.Certificate != nil {
+		info.Certificate = PathForModuleSrc(ctx, proptools.String(s.properties.Certificate))
+	}
+
+	if providerClass := proptools.String(s.properties.Provider_class); providerClass != "" {
+		info.SignapkFlags = append(info.SignapkFlags, "-providerClass", providerClass)
+		if s.properties.Provider_arg != nil {
+			arg := PathForModuleSrc(ctx, proptools.String(s.properties.Provider_arg))
+			info.SignapkFlags = append(info.SignapkFlags, "-providerArg", arg.String())
+			info.SignapkDeps = append(info.SignapkDeps, arg)
+		}
+		info.SignapkFlags = append(info.SignapkFlags, "-loadPrivateKeysFromKeyStore",
+			proptools.StringDefault(s.properties.Keystore, "PKCS11"))
+	}
+
+	ctx.SetProvider(ExternalSignerInfoProvider, info)
+}
+
+// ExternalSignerFromDeps returns the external signer added with AddExternalSignerDependency, or nil
+// if there is none. Errors are reported against property, the one that referenced the signer, when
+// the dependency is not an external_signer module, or when the signer is missing what's needed to
+// sign an AVB image (forAvb) or an APK (!forAvb).
+func ExternalSignerFromDeps(ctx ModuleContext, property string, forAvb bool) *ExternalSignerInfo {
+	var ret *ExternalSignerInfo
+	ctx.VisitDirectDepsWithTag(externalSignerTag, func(m Module) {
+		if !ctx.OtherModuleHasProvider(m, ExternalSignerInfoProvider) {
+			ctx.PropertyErrorf(property, "%q is not an external_signer module", ctx.OtherModuleName(m))
+			return
+		}
+		info := ctx.OtherModuleProvider(m, ExternalSignerInfoProvider).(ExternalSignerInfo)
+		if forAvb && (info.Tool == nil || info.PublicKey == nil) {
+			ctx.PropertyErrorf(property, "external_signer %q must set tool and public_key to sign AVB images", info.Name)
+			return
+		}
+		if !forAvb && (info.Certificate == nil || len(info.SignapkFlags) == 0) {
+			ctx.PropertyErrorf(property, "external_signer %q must set certificate and provider_class to sign APKs", info.Name)
+			return
+		}
+		ret = &info
+	})
+	return ret
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+var prepareForExternalSignerTest = GroupFixturePreparers(
+	PrepareForTestWithAndroidBuildComponents,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("prebuilt_build_tool", NewPrebuiltBuildTool)
+	}),
+)
+
+func TestExternalSigner(t *testing.T) {
+	result := prepareForExternalSignerTest.RunTestWithBp(t, `
+		prebuilt_build_tool {
+			name: "stub_signer",
+			src: "stub_signer.sh",
+		}
+
+		external_signer {
+			name: "release_key",
+			tool: "stub_signer",
+			public_key: "release_key.pem",
+			certificate: "release_key.x509.pem",
+			provider_class: "sun.security.pkcs11.SunPKCS11",
+			provider_arg: "pkcs11.cfg",
+		}
+	`)
+
+	signer := result.ModuleForTests("release_key", "").Module()
+	info := result.ModuleProvider(signer, ExternalSignerInfoProvider).(ExternalSignerInfo)
+
+	AssertStringEquals(t, "key alias", "release_key", info.KeyAlias)
+	AssertPathRelativeToTopEquals(t, "tool", "out/soong/.intermediates/stub_signer/linux_glibc_x86_64/stub_signer", info.Tool)
+	AssertPathRelativeToTopEquals(t, "public key", "release_key.pem", info.PublicKey)
+	AssertPathRelativeToTopEquals(t, "certificate", "release_key.x509.pem", info.Certificate)
+	AssertDeepEquals(t, "signapk flags", []string{
+		"-providerClass", "sun.security.pkcs11.SunPKCS11",
+		"-providerArg", "pkcs11.cfg",
+		"-loadPrivateKeysFromKeyStore", "PKCS11",
+	}, info.SignapkFlags)
+	AssertStringEquals(t, "avbtool flags",
+		"--signing_helper_with_files out/soong/.intermediates/stub_signer/linux_glibc_x86_64/stub_signer",
+		StringRelativeToTop(result.Config, info.AvbtoolFlags()))
+}
@@ -0,0 +1,222 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// An IDE workspace is a set of IDE project files limited to the transitive closure of a set of
+// modules, rather than covering the whole tree. It is generated by running
+//
+//	soong_ui --ide-workspace-mode --module=<name> --dir=<path> ...
+//
+// which sets the environment variables below and builds the ide-workspace phony target. The
+// language specific generators (cc/compdb.go, rust/project_json.go and java/ide_workspace.go) use
+// IdeWorkspaceScopeFor to decide which modules to include and write their files to
+// ${OUT_DIR}/soong/development/ide/workspace. This file writes the VS Code and clangd
+// configuration that points the editors at them.
+
+func init() {
+	RegisterParallelSingletonType("ide_workspace", ideWorkspaceSingletonFactory)
+}
+
+const (
+	// Environment variables that select the modules of the IDE workspace. Each is a comma or
+	// space separated list.
+	EnvVariableIdeWorkspaceModules = "SOONG_IDE_WORKSPACE_MODULES"
+	EnvVariableIdeWorkspaceDirs    = "SOONG_IDE_WORKSPACE_DIRS"
+
+	// The directory of the IDE workspace, relative to the soong output directory.
+	IdeWorkspaceDir = "development/ide/workspace"
+
+	// The phony target that depends on all the files of the IDE workspace.
+	IdeWorkspacePhony = "ide-workspace"
+)
+
+// IdeWorkspaceScope is the set of modules that an IDE workspace covers.
+type IdeWorkspaceScope struct {
+	// The module variants in the transitive closure of the requested modules.
+	modules map[Module]bool
+
+	// The names of the modules in modules.
+	names map[string]bool
+}
+
+// Contains returns true if the module variant is in the transitive closure of the requested
+// modules.
+func (s *IdeWorkspaceScope) Contains(module Module) bool {
+	return s.modules[module]
+}
+
+// ContainsName returns true if any variant of the named module is in the transitive closure of
+// the requested modules.
+func (s *IdeWorkspaceScope) ContainsName(name string) bool {
+	return s.names[name]
+}
+
+// IdeWorkspaceEnabled returns true if an IDE workspace has been requested.
+func IdeWorkspaceEnabled(config Config) bool {
+	return len(ideWorkspaceList(config, EnvVariableIdeWorkspaceModules)) > 0 ||
+		len(ideWorkspaceList(config, EnvVariableIdeWorkspaceDirs)) > 0
+}
+
+// PathForIdeWorkspace returns a path to a file in the IDE workspace directory.
+func PathForIdeWorkspace(ctx PathContext, paths ...string) OutputPath {
+	return PathForOutput(ctx, append([]string{IdeWorkspaceDir}, paths...)...)
+}
+
+func ideWorkspaceList(config Config, env string) []string {
+	return strings.FieldsFunc(config.Getenv(env), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+var ideWorkspaceScopeKey = NewOnceKey("ideWorkspaceScope")
+
+// IdeWorkspaceScopeFor returns the modules that the IDE workspace covers, or nil if no IDE
+// workspace has been requested. The scope is computed once and shared by all the generators.
+func IdeWorkspaceScopeFor(ctx SingletonContext) *IdeWorkspaceScope {
+	if !IdeWorkspaceEnabled(ctx.Config()) {
+		return nil
+	}
+	return ctx.Config().Once(ideWorkspaceScopeKey, func() interface{} {
+		return computeIdeWorkspaceScope(ctx)
+	}).(*IdeWorkspaceScope)
+}
+
+func computeIdeWorkspaceScope(ctx SingletonContext) *IdeWorkspaceScope {
+	names := ideWorkspaceList(ctx.Config(), EnvVariableIdeWorkspaceModules)
+	var dirs []string
+	for _, dir := range ideWorkspaceList(ctx.Config(), EnvVariableIdeWorkspaceDirs) {
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+
+	requested := make(map[string]bool)
+	for _, name := range names {
+		requested[name] = true
+	}
+	found := make(map[string]bool)
+	inRequestedDir := func(dir string) bool {
+		for _, d := range dirs {
+			if d == "." || dir == d || strings.HasPrefix(dir, d+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	scope := &IdeWorkspaceScope{
+		modules: make(map[Module]bool),
+		names:   make(map[string]bool),
+	}
+	add := func(module Module) {
+		scope.modules[module] = true
+		scope.names[ctx.ModuleName(module)] = true
+	}
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+		name := ctx.ModuleName(module)
+		if !requested[name] && !inRequestedDir(ctx.ModuleDir(module)) {
+			return
+		}
+		found[name] = true
+		if scope.modules[module] {
+			return
+		}
+		add(module)
+		ctx.VisitDepsDepthFirst(module, func(dep Module) {
+			if dep.Enabled() {
+				add(dep)
+			}
+		})
+	})
+
+	for _, name := range names {
+		if !found[name] {
+			ctx.Errorf("%s: unknown module %q", EnvVariableIdeWorkspaceModules, name)
+		}
+	}
+	return scope
+}
+
+func ideWorkspaceSingletonFactory() Singleton {
+	return &ideWorkspaceSingleton{}
+}
+
+type ideWorkspaceSingleton struct{}
+
+// vscodeSettings is the subset of .vscode/settings.json that points clangd and rust-analyzer at
+// the files of the IDE workspace.
+type vscodeSettings struct {
+	ClangdArguments           []string `json:"clangd.arguments"`
+	RustAnalyzerLinkedProject []string `json:"rust-analyzer.linkedProjects"`
+}
+
+func (s *ideWorkspaceSingleton) GenerateBuildActions(ctx SingletonContext) {
+	scope := IdeWorkspaceScopeFor(ctx)
+	if scope == nil {
+		return
+	}
+
+	// The editors are run from the top of the source tree, which is not where the workspace is, so
+	// use absolute paths.
+	workspaceDir := PathForIdeWorkspace(ctx).String()
+	if !filepath.IsAbs(workspaceDir) {
+		workspaceDir = filepath.Join(AbsSrcDirForExistingUseCases(), workspaceDir)
+	}
+
+	settings := vscodeSettings{
+		ClangdArguments: []string{
+			"--compile-commands-dir=" + workspaceDir,
+			"--background-index",
+		},
+		RustAnalyzerLinkedProject: []string{filepath.Join(workspaceDir, "rust-project.json")},
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal the IDE workspace settings: %s", err)
+		return
+	}
+	settingsFile := PathForIdeWorkspace(ctx, ".vscode", "settings.json")
+	if err := WriteFileToOutputDir(settingsFile, data, 0666); err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+
+	// clangd looks for a .clangd file in the parent directories of the files it opens, so this one
+	// is for copying or linking to the top of the source tree.
+	clangdFile := PathForIdeWorkspace(ctx, ".clangd")
+	clangdConfig := fmt.Sprintf("CompileFlags:\n  CompilationDatabase: %s\n", workspaceDir)
+	if err := WriteFileToOutputDir(clangdFile, []byte(clangdConfig), 0666); err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+
+	for _, file := range []WritablePath{settingsFile, clangdFile} {
+		// This is necessary to satisfy the dangling rules check as this file is written by Soong
+		// rather than a rule.
+		ctx.Build(pctx, BuildParams{
+			Rule:   Touch,
+			Output: file,
+		})
+	}
+	ctx.Phony(IdeWorkspacePhony, settingsFile, clangdFile)
+}
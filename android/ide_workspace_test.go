@@ -0,0 +1,68 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var prepareForIdeWorkspaceTest = GroupFixturePreparers(
+	PrepareForTestWithFilegroup,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterParallelSingletonType("ide_workspace", ideWorkspaceSingletonFactory)
+	}),
+)
+
+func TestIdeWorkspace(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForIdeWorkspaceTest,
+		FixtureMergeEnv(map[string]string{EnvVariableIdeWorkspaceModules: "foo"}),
+	).RunTestWithBp(t, `
+		filegroup {
+			name: "foo",
+			srcs: ["a.txt"],
+		}
+	`)
+
+	workspaceDir := filepath.Join(result.Config.SoongOutDir(), IdeWorkspaceDir)
+	settings, err := os.ReadFile(filepath.Join(workspaceDir, ".vscode", "settings.json"))
+	if err != nil {
+		t.Fatalf("settings.json has not been generated: %s", err)
+	}
+	AssertStringDoesContain(t, "clangd arguments", string(settings), `"--compile-commands-dir=`+workspaceDir+`"`)
+	AssertStringDoesContain(t, "rust-analyzer project", string(settings), `"`+filepath.Join(workspaceDir, "rust-project.json")+`"`)
+
+	clangd, err := os.ReadFile(filepath.Join(workspaceDir, ".clangd"))
+	if err != nil {
+		t.Fatalf(".clangd has not been generated: %s", err)
+	}
+	AssertStringEquals(t, ".clangd", "CompileFlags:\n  CompilationDatabase: "+workspaceDir+"\n", string(clangd))
+}
+
+func TestIdeWorkspaceUnknownModule(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForIdeWorkspaceTest,
+		FixtureMergeEnv(map[string]string{EnvVariableIdeWorkspaceModules: "foo,bar"}),
+	).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`SOONG_IDE_WORKSPACE_MODULES: unknown module "bar"`)).
+		RunTestWithBp(t, `
+			filegroup {
+				name: "foo",
+				srcs: ["a.txt"],
+			}
+		`)
+}
@@ -493,6 +493,7 @@ func (m *moduleContext) packageFile(fullInstallPath InstallPath, srcPath Path, e
 		executable:            executable,
 		effectiveLicenseFiles: &licenseFiles,
 		partition:             fullInstallPath.partition,
+		owner:                 m.ModuleName(),
 	}
 	m.packagingSpecs = append(m.packagingSpecs, spec)
 	return spec
@@ -614,6 +615,7 @@ func (m *moduleContext) InstallSymlink(installPath InstallPath, name string, src
 		symlinkTarget:    relPath,
 		executable:       false,
 		partition:        fullInstallPath.partition,
+		owner:            m.ModuleName(),
 	})
 
 	return fullInstallPath
@@ -655,6 +657,7 @@ func (m *moduleContext) InstallAbsoluteSymlink(installPath InstallPath, name str
 		symlinkTarget:    absPath,
 		executable:       false,
 		partition:        fullInstallPath.partition,
+		owner:            m.ModuleName(),
 	})
 
 	return fullInstallPath
@@ -42,6 +42,9 @@ type PackagingSpec struct {
 	effectiveLicenseFiles *Paths
 
 	partition string
+
+	// Name of the module that requested this artifact to be packaged
+	owner string
 }
 
 // Get file name of installed package
@@ -73,6 +76,25 @@ func (p *PackagingSpec) Partition() string {
 	return p.partition
 }
 
+// The path to the built artifact. Nil if this is a symlink.
+func (p *PackagingSpec) SrcPath() Path {
+	return p.srcPath
+}
+
+// The target of the symlink. Empty if this is not a symlink.
+func (p *PackagingSpec) SymlinkTarget() string {
+	return p.symlinkTarget
+}
+
+func (p *PackagingSpec) Executable() bool {
+	return p.executable
+}
+
+// Name of the module that installed this artifact
+func (p *PackagingSpec) Owner() string {
+	return p.owner
+}
+
 type PackageModule interface {
 	Module
 	packagingBase() *PackagingBase
@@ -144,6 +144,7 @@ var PrepareForTestWithAndroidBuildComponents = GroupFixturePreparers(
 	PrepareForTestWithArchMutator,
 	PrepareForTestWithComponentsMutator,
 	PrepareForTestWithDefaults,
+	PrepareForTestWithExternalSigner,
 	PrepareForTestWithFilegroup,
 	PrepareForTestWithOverrides,
 	PrepareForTestWithPackageModule,
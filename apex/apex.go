@@ -34,6 +34,7 @@ import (
 	prebuilt_etc "android/soong/etc"
 	"android/soong/filesystem"
 	"android/soong/java"
+	"android/soong/linkerconfig"
 	"android/soong/multitree"
 	"android/soong/rust"
 	"android/soong/sh"
@@ -402,6 +403,11 @@ type apexBundle struct {
 	publicKeyFile  android.Path
 	privateKeyFile android.Path
 
+	// External signers that hold the private keys, if any. See the private_key property of
+	// apex_key and the certificate property of android_app_certificate.
+	payloadSigner   *android.ExternalSignerInfo
+	containerSigner *android.ExternalSignerInfo
+
 	// Cert/priv-key for the zip container
 	containerCertificateFile android.Path
 	containerPrivateKeyFile  android.Path
@@ -421,6 +427,10 @@ type apexBundle struct {
 	// GenerateAndroidBuildActions.
 	filesInfo []apexFile
 
+	// The source linker configuration file in JSON of the linker_config module included in this
+	// APEX, if any
+	linkerConfigSrc android.Path
+
 	// List of other module names that should be installed when this APEX gets installed (LOCAL_REQUIRED_MODULES).
 	makeModulesToInstall []string
 
@@ -470,6 +480,12 @@ type apexBundle struct {
 	// Used for debugging purpose.
 	android.ApexBundleDepsInfo
 
+	// Dependency graph of the payload, and the listing of the files in the APEX with their sizes
+	// and the dependency chains that pulled them in. Used to explain the contents of the APEX and
+	// new entries in allowed_deps.txt.
+	depsGraphFile android.WritablePath
+	contentsFile  android.WritablePath
+
 	// Optional list of lint report zip files for apexes that contain java or app modules
 	lintReports android.Paths
 
@@ -1350,6 +1366,11 @@ func (a *apexBundle) OutputFiles(tag string) (android.Paths, error) {
 	case "", android.DefaultDistTag:
 		// This is the default dist path.
 		return android.Paths{a.outputFile}, nil
+	case ".contents.json":
+		if a.contentsFile != nil {
+			return android.Paths{a.contentsFile}, nil
+		}
+		return nil, nil
 	case imageApexSuffix:
 		// uncompressed one
 		if a.outputApexFile != nil {
@@ -1883,6 +1904,9 @@ type visitorContext struct {
 	provideNativeLibs []string
 	requireNativeLibs []string
 
+	// linker configuration of the APEX
+	linkerConfigSrc android.Path
+
 	handleSpecialLibs bool
 
 	// if true, raise error on duplicate apexFile
@@ -2079,6 +2103,9 @@ func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext,
 		case prebuiltTag:
 			if prebuilt, ok := child.(prebuilt_etc.PrebuiltEtcModule); ok {
 				vctx.filesInfo = append(vctx.filesInfo, apexFileForPrebuiltEtc(ctx, prebuilt, depName))
+				if ctx.OtherModuleHasProvider(child, linkerconfig.LinkerConfigInfoProvider) {
+					vctx.linkerConfigSrc = ctx.OtherModuleProvider(child, linkerconfig.LinkerConfigInfoProvider).(linkerconfig.LinkerConfigInfo).Src
+				}
 			} else {
 				ctx.PropertyErrorf("prebuilts", "%q is not a prebuilt_etc module", depName)
 			}
@@ -2111,6 +2138,7 @@ func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext,
 			if key, ok := child.(*apexKey); ok {
 				a.privateKeyFile = key.privateKeyFile
 				a.publicKeyFile = key.publicKeyFile
+				a.payloadSigner = key.signer
 			} else {
 				ctx.PropertyErrorf("key", "%q is not an apex_key module", depName)
 			}
@@ -2118,6 +2146,7 @@ func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext,
 			if dep, ok := child.(*java.AndroidAppCertificate); ok {
 				a.containerCertificateFile = dep.Certificate.Pem
 				a.containerPrivateKeyFile = dep.Certificate.Key
+				a.containerSigner = dep.Certificate.Signer
 			} else {
 				ctx.ModuleErrorf("certificate dependency %q must be an android_app_certificate module", depName)
 			}
@@ -2359,6 +2388,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// 3) some fields in apexBundle struct are configured
 	a.installDir = android.PathForModuleInstall(ctx, "apex")
 	a.filesInfo = vctx.filesInfo
+	a.linkerConfigSrc = vctx.linkerConfigSrc
 	a.aconfigFiles = android.FirstUniquePaths(vctx.aconfigFiles)
 
 	a.setPayloadFsType(ctx)
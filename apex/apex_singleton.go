@@ -17,6 +17,8 @@
 package apex
 
 import (
+	"strings"
+
 	"github.com/google/blueprint"
 
 	"android/soong/android"
@@ -62,6 +64,9 @@ var (
 				echo "ERROR: go/apex-allowed-deps-error contains more information";
 				echo "******************************";
 				echo "Detected changes to allowed dependencies in updatable modules.";
+				echo "";
+				${apex_contents} explain_allowed_deps --allowed_deps ${allowed_deps} --new_allowed_deps ${new_allowed_deps} ${deps_graphs};
+				echo "";
 				echo "To fix and update packages/modules/common/build/allowed_deps.txt, please run:";
 				echo -e "$$ (croot && packages/modules/common/build/update-apex-allowed-deps.sh)\n";
 				echo "When submitting the generated CL, you must include the following information";
@@ -76,17 +81,23 @@ var (
 				exit 1;
 			fi;
 		`,
-	}, "allowed_deps", "new_allowed_deps")
+		CommandDeps: []string{"${apex_contents}"},
+	}, "allowed_deps", "new_allowed_deps", "deps_graphs")
 )
 
 func (s *apexDepsInfoSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 	updatableFlatLists := android.Paths{}
+	// Dependency graphs of the updatable APEXes, used to explain new entries in allowed_deps.txt
+	var depsGraphs android.Paths
 	ctx.VisitAllModules(func(module android.Module) {
 		if binaryInfo, ok := module.(android.ApexBundleDepsInfoIntf); ok {
 			apexInfo := ctx.ModuleProvider(module, android.ApexInfoProvider).(android.ApexInfo)
 			if path := binaryInfo.FlatListPath(); path != nil {
 				if binaryInfo.Updatable() || apexInfo.Updatable {
 					updatableFlatLists = append(updatableFlatLists, path)
+					if a, ok := module.(*apexBundle); ok && a.depsGraphFile != nil {
+						depsGraphs = append(depsGraphs, a.depsGraphFile)
+					}
 				}
 			}
 		}
@@ -112,12 +123,14 @@ func (s *apexDepsInfoSingleton) GenerateBuildActions(ctx android.SingletonContex
 		})
 
 		ctx.Build(pctx, android.BuildParams{
-			Rule:   diffAllowedApexDepsInfoRule,
-			Input:  newAllowedDeps,
-			Output: s.allowedApexDepsInfoCheckResult,
+			Rule:      diffAllowedApexDepsInfoRule,
+			Input:     newAllowedDeps,
+			Implicits: depsGraphs,
+			Output:    s.allowedApexDepsInfoCheckResult,
 			Args: map[string]string{
 				"allowed_deps":     allowedDeps.String(),
 				"new_allowed_deps": newAllowedDeps.String(),
+				"deps_graphs":      strings.Join(depsGraphs.Strings(), " "),
 			},
 		})
 	}
@@ -15,6 +15,7 @@
 package apex
 
 import (
+	"encoding/json"
 	"fmt"
 	"path"
 	"path/filepath"
@@ -37,6 +38,7 @@ import (
 	prebuilt_etc "android/soong/etc"
 	"android/soong/filesystem"
 	"android/soong/java"
+	"android/soong/linkerconfig"
 	"android/soong/rust"
 	"android/soong/sh"
 )
@@ -2073,6 +2075,85 @@ func TestTrackAllowedDeps(t *testing.T) {
 		flatlist, "yourlib(minSdkVersion:29)")
 }
 
+func TestApexContents(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			updatable: true,
+			native_shared_libs: ["mylib"],
+			min_sdk_version: "29",
+		}
+
+		apex {
+			name: "myapex2",
+			key: "myapex.key",
+			updatable: false,
+			native_shared_libs: ["yourlib"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["yourlib", "libbar"],
+			min_sdk_version: "29",
+			apex_available: ["myapex"],
+		}
+
+		cc_library {
+			name: "libbar",
+			stubs: { versions: ["29", "30"] },
+		}
+
+		cc_library {
+			name: "yourlib",
+			srcs: ["mylib.cpp"],
+			min_sdk_version: "29",
+			apex_available: ["myapex", "myapex2", "//apex_available:platform"],
+		}
+	`, withFiles(android.MockFS{
+		"packages/modules/common/build/allowed_deps.txt": nil,
+	}))
+
+	myapex := ctx.ModuleForTests("myapex", "android_common_myapex")
+	var graph apexDepsGraph
+	if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, ctx,
+		myapex.Output("depsinfo/deps.json"))), &graph); err != nil {
+		t.Fatal(err)
+	}
+	deps := make(map[string]apexDepsGraphNode)
+	for _, d := range graph.Deps {
+		deps[d.Module] = *d
+	}
+	android.AssertDeepEquals(t, "mylib", apexDepsGraphNode{Module: "mylib", From: []string{"myapex"}}, deps["mylib"])
+	android.AssertDeepEquals(t, "yourlib",
+		apexDepsGraphNode{Module: "yourlib", From: []string{"mylib"}, AllowedDeps: "yourlib(minSdkVersion:29)"}, deps["yourlib"])
+	android.AssertDeepEquals(t, "libbar",
+		apexDepsGraphNode{Module: "libbar", From: []string{"mylib"}, External: true}, deps["libbar"])
+
+	var files []string
+	for _, f := range graph.Files {
+		files = append(files, f.Path+":"+f.Module)
+	}
+	android.AssertStringListContains(t, "files", files, "lib64/mylib.so:mylib")
+	android.AssertStringListContains(t, "files", files, "lib64/yourlib.so:yourlib")
+
+	contents := myapex.Output("depsinfo/contents.json")
+	android.AssertStringDoesContain(t, "apex_contents command", contents.RuleParams.Command,
+		"apex_contents report --deps out/soong/.intermediates/myapex/android_common_myapex/depsinfo/deps.json "+
+			"--output out/soong/.intermediates/myapex/android_common_myapex/depsinfo/contents.json")
+
+	check := ctx.SingletonForTests("apex_depsinfo_singleton").Rule("diffAllowedApexDepsInfoRule")
+	android.AssertStringEquals(t, "deps graphs of updatable APEXes",
+		"out/soong/.intermediates/myapex/android_common_myapex/depsinfo/deps.json", check.Args["deps_graphs"])
+}
+
 func TestTrackAllowedDeps_SkipWithoutAllowedDepsTxt(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -3330,6 +3411,100 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+func TestKeysFromExternalSigner(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			certificate: ":myapex.certificate",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "external:release_signer",
+		}
+
+		android_app_certificate {
+			name: "myapex.certificate",
+			certificate: "external:release_signer",
+		}
+
+		prebuilt_build_tool {
+			name: "stub_signer",
+			src: "stub_signer.sh",
+		}
+
+		external_signer {
+			name: "release_signer",
+			tool: "stub_signer",
+			public_key: "release.pem",
+			certificate: "release.x509.pem",
+			provider_class: "sun.security.pkcs11.SunPKCS11",
+			key_alias: "release",
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex")
+
+	apexRule := module.Rule("apexRule")
+	android.AssertStringEquals(t, "payload is signed with the public key of the signer",
+		"release.pem", apexRule.Args["key"])
+	android.AssertStringDoesContain(t, "payload is signed through the signing helper",
+		apexRule.Args["opt_flags"],
+		"--signing_args '--signing_helper_with_files out/soong/.intermediates/stub_signer/linux_glibc_x86_64/stub_signer'")
+
+	signapk := module.Rule("signapk")
+	android.AssertStringEquals(t, "container certificates", "release.x509.pem release", signapk.Args["certificates"])
+	android.AssertStringDoesContain(t, "container signing flags", signapk.Args["flags"],
+		"-providerClass sun.security.pkcs11.SunPKCS11 -loadPrivateKeysFromKeyStore PKCS11")
+
+	content := android.ContentFromFileRuleForTests(t, ctx, module.Output("apexkeys.txt"))
+	android.AssertStringDoesContain(t, "apexkeys.txt", content,
+		`private_key="external:release_signer" container_certificate="release.x509.pem" container_private_key="external:release_signer"`)
+	keys := parseApexKeysLikeReleaseTools(t, content)
+	android.AssertStringEquals(t, "payload private key", "external:release_signer", keys["PAYLOAD_PRIVATE_KEY"])
+	android.AssertStringEquals(t, "container private key", "external:release_signer", keys["CONTAINER_PRIVATE_KEY"])
+	android.AssertStringEquals(t, "partition", "system", keys["PARTITION"])
+}
+
+// releaseToolsApexKeysPattern is the pattern with which release tools (ReadApexKeysInfo in
+// sign_target_files_apks.py) parse the lines of apexkeys.txt.
+var releaseToolsApexKeysPattern = regexp.MustCompile(`^name="(?P<NAME>.*)"\s+` +
+	`public_key="(?P<PAYLOAD_PUBLIC_KEY>.*)"\s+` +
+	`private_key="(?P<PAYLOAD_PRIVATE_KEY>.*)"\s+` +
+	`container_certificate="(?P<CONTAINER_CERT>.*)"\s+` +
+	`container_private_key="(?P<CONTAINER_PRIVATE_KEY>.*?)"` +
+	`(\s+partition="(?P<PARTITION>.*?)")?` +
+	`(\s+sign_tool="(?P<SIGN_TOOL>.*?)")?$`)
+
+// parseApexKeysLikeReleaseTools parses a line of apexkeys.txt like release tools do. They also
+// require the container private key to be named after the container certificate, unless it's held
+// by an external signer.
+func parseApexKeysLikeReleaseTools(t *testing.T, line string) map[string]string {
+	t.Helper()
+	matches := releaseToolsApexKeysPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if matches == nil {
+		t.Fatalf("release tools can't parse %q", line)
+	}
+	fields := make(map[string]string)
+	for i, name := range releaseToolsApexKeysPattern.SubexpNames() {
+		if name != "" {
+			fields[name] = matches[i]
+		}
+	}
+	cert, key := fields["CONTAINER_CERT"], fields["CONTAINER_PRIVATE_KEY"]
+	presigned := cert == "PRESIGNED" && key == "PRESIGNED"
+	external := strings.HasPrefix(key, android.ExternalSignerPrefix)
+	paired := strings.HasSuffix(cert, ".x509.pem") && strings.HasSuffix(key, ".pk8") &&
+		strings.TrimSuffix(cert, ".x509.pem") == strings.TrimSuffix(key, ".pk8")
+	if !presigned && !external && !paired {
+		t.Fatalf("release tools can't parse the container keys of %q", line)
+	}
+	return fields
+}
+
 func TestCertificate(t *testing.T) {
 	t.Run("if unspecified, it defaults to DefaultAppCertificate", func(t *testing.T) {
 		ctx := testApex(t, `
@@ -9228,6 +9403,8 @@ func TestApexKeysTxt(t *testing.T) {
 	myapex := ctx.ModuleForTests("myapex", "android_common_myapex")
 	content := android.ContentFromFileRuleForTests(t, ctx, myapex.Output("apexkeys.txt"))
 	ensureContains(t, content, `name="myapex.apex" public_key="vendor/foo/devkeys/testkey.avbpubkey" private_key="vendor/foo/devkeys/testkey.pem" container_certificate="vendor/foo/devkeys/test.x509.pem" container_private_key="vendor/foo/devkeys/test.pk8" partition="system" sign_tool="sign_myapex"`)
+	keys := parseApexKeysLikeReleaseTools(t, content)
+	android.AssertStringEquals(t, "sign tool", "sign_myapex", keys["SIGN_TOOL"])
 }
 
 func TestApexKeysTxtOverrides(t *testing.T) {
@@ -9272,6 +9449,8 @@ func TestApexKeysTxtOverrides(t *testing.T) {
 	content = android.ContentFromFileRuleForTests(t, ctx,
 		ctx.ModuleForTests("myapex_set", "android_common_myapex_set").Output("apexkeys.txt"))
 	ensureContains(t, content, `name="myapex_set.apex" public_key="PRESIGNED" private_key="PRESIGNED" container_certificate="PRESIGNED" container_private_key="PRESIGNED" partition="system"`)
+	keys := parseApexKeysLikeReleaseTools(t, content)
+	android.AssertStringEquals(t, "presigned name", "myapex_set.apex", keys["NAME"])
 }
 
 func TestAllowedFiles(t *testing.T) {
@@ -10859,6 +11038,140 @@ func TestFileSystemShouldSkipApexLibraries(t *testing.T) {
 		"out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/libbar.so")
 }
 
+func TestFileSystemChecksElfDepsOfApexes(t *testing.T) {
+	context := android.GroupFixturePreparers(
+		android.PrepareForIntegrationTestWithAndroid,
+		cc.PrepareForIntegrationTestWithCc,
+		PrepareForTestWithApexBuildComponents,
+		prepareForTestWithMyapex,
+		filesystem.PrepareForTestWithFilesystemBuildComponents,
+	)
+	result := context.RunTestWithBp(t, `
+		android_system_image {
+			name: "myfilesystem",
+			deps: ["mybin"],
+			multilib: {
+				common: {
+					deps: ["myapex"],
+				},
+			},
+			linker_config_src: "linker.config.json",
+			check_elf_deps: true,
+		}
+
+		cc_binary {
+			name: "mybin",
+			shared_libs: ["libbar"],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libbar",
+			stl: "none",
+			system_shared_libs: [],
+			stubs: {
+				symbol_file: "libbar.map.txt",
+				versions: ["1"],
+			},
+			apex_available: ["myapex"],
+		}
+
+		apex {
+			name: "myapex",
+			native_shared_libs: ["libbar"],
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	config := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("elf_deps/config.json"))
+	android.AssertStringDoesContain(t, "the binary of the system partition should be checked",
+		config, `{"partition":"system","path":"bin/mybin",`)
+	android.AssertStringDoesContain(t, "the libraries of the APEX should be checked",
+		config, `{"partition":"apex","path":"myapex/lib64/libbar.so",`)
+	android.AssertStringDoesContain(t, "the APEX should provide its stub libraries",
+		config, `{"name":"myapex","provideLibs":["libbar.so"],"requireLibs":[]}`)
+}
+
+func TestFileSystemChecksLinkerConfigOfApexes(t *testing.T) {
+	context := android.GroupFixturePreparers(
+		android.PrepareForIntegrationTestWithAndroid,
+		cc.PrepareForIntegrationTestWithCc,
+		linkerconfig.PrepareForTestWithLinkerConfig,
+		PrepareForTestWithApexBuildComponents,
+		prepareForTestWithMyapex,
+		filesystem.PrepareForTestWithFilesystemBuildComponents,
+	)
+	result := context.RunTestWithBp(t, `
+		android_system_image {
+			name: "myfilesystem",
+			multilib: {
+				common: {
+					deps: ["myapex"],
+				},
+			},
+			linker_config_src: "linker.config.json",
+			check_linker_config: true,
+		}
+
+		cc_library {
+			name: "libfoo",
+			stl: "none",
+			system_shared_libs: [],
+			shared_libs: ["libbar"],
+			apex_available: ["myapex"],
+		}
+
+		cc_library {
+			name: "libbar",
+			stl: "none",
+			system_shared_libs: [],
+			stubs: {
+				symbol_file: "libbar.map.txt",
+				versions: ["1"],
+			},
+		}
+
+		linker_config {
+			name: "myapex_linker_config",
+			src: "myapex_linker_config.json",
+			installable: false,
+		}
+
+		apex {
+			name: "myapex",
+			native_shared_libs: ["libfoo"],
+			prebuilts: ["myapex_linker_config"],
+			key: "myapex.key",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	config := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("elf_deps/config.json"))
+	android.AssertStringDoesContain(t, "the APEX should require the libraries of its apex_manifest",
+		config, `{"name":"myapex","provideLibs":null,"requireLibs":["libbar.so"]}`)
+
+	check := module.Rule("check_elf_deps")
+	android.AssertStringDoesContain(t, "linker config of the APEX should be used",
+		check.RuleParams.Command, "--linker_config myapex=myapex_linker_config.json")
+	android.AssertStringDoesContain(t, "linker config should be checked",
+		check.RuleParams.Command, "--check_linker_config")
+}
+
 var apex_default_bp = `
 		apex_key {
 			name: "myapex.key",
@@ -26,6 +26,7 @@ import (
 
 	"android/soong/aconfig"
 	"android/soong/android"
+	"android/soong/filesystem"
 	"android/soong/java"
 
 	"github.com/google/blueprint"
@@ -42,6 +43,7 @@ func init() {
 	pctx.Import("android/soong/cc/config")
 	pctx.Import("android/soong/java")
 	pctx.HostBinToolVariable("apexer", "apexer")
+	pctx.HostBinToolVariable("apex_contents", "apex_contents")
 	pctx.HostBinToolVariable("apexer_with_DCLA_preprocessing", "apexer_with_DCLA_preprocessing")
 	pctx.HostBinToolVariable("apexer_with_trim_preprocessing", "apexer_with_trim_preprocessing")
 
@@ -262,6 +264,14 @@ func (a *apexBundle) buildManifest(ctx android.ModuleContext, provideNativeLibs,
 	provideNativeLibs = android.SortedUniqueStrings(provideNativeLibs)
 	requireNativeLibs = android.SortedUniqueStrings(android.RemoveListFromList(requireNativeLibs, provideNativeLibs))
 
+	// Let the images that install this APEX check the native dependencies of its payload
+	ctx.SetProvider(filesystem.ApexNamespaceInfoProvider, filesystem.ApexNamespaceInfo{
+		Name:            a.BaseModuleName(),
+		ProvideLibs:     provideNativeLibs,
+		RequireLibs:     requireNativeLibs,
+		LinkerConfigSrc: a.linkerConfigSrc,
+	})
+
 	// VNDK APEX name is determined at runtime, so update "name" in apex_manifest
 	optCommands := []string{}
 	if a.vndkApex {
@@ -666,6 +676,11 @@ func (a *apexBundle) buildApex(ctx android.ModuleContext) {
 
 	implicitInputs = append(implicitInputs, a.privateKeyFile, a.publicKeyFile)
 	optFlags = append(optFlags, "--pubkey "+a.publicKeyFile.String())
+	if a.payloadSigner != nil {
+		// The payload is signed by avbtool through the signing helper of the external signer.
+		implicitInputs = append(implicitInputs, a.payloadSigner.Tool)
+		optFlags = append(optFlags, "--signing_args "+proptools.ShellEscape(a.payloadSigner.AvbtoolFlags()))
+	}
 
 	manifestPackageName := a.getOverrideManifestPackageName(ctx)
 	if manifestPackageName != "" {
@@ -898,10 +913,18 @@ func (a *apexBundle) buildApex(ctx android.ModuleContext) {
 	pem, key := a.getCertificateAndPrivateKey(ctx)
 	rule := java.Signapk
 	args := map[string]string{
-		"certificates": pem.String() + " " + key.String(),
-		"flags":        "-a 4096 --align-file-size", //alignment
+		"flags": "-a 4096 --align-file-size", //alignment
+	}
+	var implicits android.Paths
+	if a.containerSigner != nil {
+		// The private key is loaded by signapk from the key store of the external signer.
+		args["certificates"] = pem.String() + " " + a.containerSigner.KeyAlias
+		args["flags"] = strings.Join(a.containerSigner.SignapkFlags, " ") + " " + args["flags"]
+		implicits = append(android.Paths{pem}, a.containerSigner.SignapkDeps...)
+	} else {
+		args["certificates"] = pem.String() + " " + key.String()
+		implicits = android.Paths{pem, key}
 	}
-	implicits := android.Paths{pem, key}
 	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_SIGNAPK") {
 		rule = java.SignapkRE
 		args["implicits"] = strings.Join(implicits.Strings(), ",")
@@ -1031,6 +1054,7 @@ func (a *apexBundle) buildApexDependencyInfo(ctx android.ModuleContext) {
 	}
 
 	depInfos := android.DepNameToDepInfoMap{}
+	graph := &apexDepsGraph{Apex: a.Name()}
 	a.WalkPayloadDeps(ctx, func(ctx android.ModuleContext, from blueprint.Module, to android.ApexModule, externalDep bool) bool {
 		if from.Name() == to.Name() {
 			// This can happen for cc.reuseObjTag. We are not interested in tracking this.
@@ -1038,6 +1062,10 @@ func (a *apexBundle) buildApexDependencyInfo(ctx android.ModuleContext) {
 			return !externalDep
 		}
 
+		// The full graph is recorded to explain why modules are in the APEX, including the
+		// dependencies that aren't tracked in allowed_deps.txt.
+		graph.addDep(from.Name(), to.Name(), externalDep)
+
 		// Skip dependencies that are only available to APEXes; they are developed with updatability
 		// in mind and don't need manual approval.
 		if to.(android.ApexModule).NotAvailableForPlatform() {
@@ -1084,6 +1112,7 @@ func (a *apexBundle) buildApexDependencyInfo(ctx android.ModuleContext) {
 	})
 
 	a.ApexBundleDepsInfo.BuildDepsInfoLists(ctx, a.MinSdkVersion(ctx).String(), depInfos)
+	a.buildContents(ctx, graph, depInfos)
 
 	ctx.Build(pctx, android.BuildParams{
 		Rule:   android.Phony,
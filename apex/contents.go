@@ -0,0 +1,117 @@
+// Copyright (C) 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apex
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"android/soong/android"
+)
+
+// apexDepsGraph is the dependency graph of the payload of an APEX. It's written to deps.json and
+// read by apex_contents to list the contents of the APEX with the dependency chains that pulled
+// each file in, and to explain new entries of allowed_deps.txt.
+type apexDepsGraph struct {
+	Apex  string               `json:"apex"`
+	Files []apexDepsGraphFile  `json:"files"`
+	Deps  []*apexDepsGraphNode `json:"deps"`
+
+	nodes map[string]*apexDepsGraphNode
+}
+
+type apexDepsGraphFile struct {
+	Path      string `json:"path"`
+	Module    string `json:"module"`
+	BuiltFile string `json:"built_file"`
+}
+
+type apexDepsGraphNode struct {
+	Module string   `json:"module"`
+	From   []string `json:"from"`
+
+	// Whether the module is outside of the APEX in all the dependencies on it
+	External bool `json:"external,omitempty"`
+
+	// Line in allowed_deps.txt for the module, if it's tracked there
+	AllowedDeps string `json:"allowed_deps,omitempty"`
+}
+
+// addDep records that from depends on to in the graph.
+func (g *apexDepsGraph) addDep(from, to string, externalDep bool) {
+	if g.nodes == nil {
+		g.nodes = make(map[string]*apexDepsGraphNode)
+	}
+	if n, ok := g.nodes[to]; ok {
+		if !android.InList(from, n.From) {
+			n.From = append(n.From, from)
+		}
+		n.External = n.External && externalDep
+		return
+	}
+	n := &apexDepsGraphNode{Module: to, From: []string{from}, External: externalDep}
+	g.nodes[to] = n
+	g.Deps = append(g.Deps, n)
+}
+
+// buildContents writes the dependency graph of the payload of the APEX, and creates the listing
+// of its contents with the sizes of the files and the dependency chains that pulled them in.
+// depInfos are the dependencies tracked in allowed_deps.txt.
+func (a *apexBundle) buildContents(ctx android.ModuleContext, g *apexDepsGraph, depInfos android.DepNameToDepInfoMap) {
+	for _, n := range g.Deps {
+		if info, ok := depInfos[n.Module]; ok && !info.IsExternal {
+			// Matches the lines written by android.ApexBundleDepsInfo.BuildDepsInfoLists.
+			n.AllowedDeps = fmt.Sprintf("%s(minSdkVersion:%s)", info.To, info.MinSdkVersion)
+		}
+	}
+
+	var builtFiles android.Paths
+	for _, fi := range a.filesInfo {
+		module := fi.androidMkModuleName
+		if fi.module != nil {
+			module = fi.module.Name()
+		}
+		g.Files = append(g.Files, apexDepsGraphFile{
+			Path:      fi.path(),
+			Module:    module,
+			BuiltFile: fi.builtFile.String(),
+		})
+		builtFiles = append(builtFiles, fi.builtFile)
+	}
+
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to write dependency graph: %s", err)
+		return
+	}
+	a.depsGraphFile = android.PathForModuleOut(ctx, "depsinfo", "deps.json")
+	android.WriteFileRule(ctx, a.depsGraphFile, string(data))
+
+	a.contentsFile = android.PathForModuleOut(ctx, "depsinfo", "contents.json")
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().
+		BuiltTool("apex_contents").
+		Text("report").
+		FlagWithInput("--deps ", a.depsGraphFile).
+		FlagWithOutput("--output ", a.contentsFile).
+		Implicits(builtFiles)
+	builder.Build("apex_contents", "apex contents "+a.Name())
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.Phony,
+		Output: android.PathForPhony(ctx, a.Name()+"-contents"),
+		Input:  a.contentsFile,
+	})
+}
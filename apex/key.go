@@ -38,6 +38,10 @@ type apexKey struct {
 
 	publicKeyFile  android.Path
 	privateKeyFile android.Path
+
+	// Set when the private key is held by an external signer. privateKeyFile is then the public
+	// key of the signer in PEM format, which avbtool needs in place of the private key.
+	signer *android.ExternalSignerInfo
 }
 
 type apexKeyProperties struct {
@@ -45,6 +49,8 @@ type apexKeyProperties struct {
 	// Base name of the file is used as the ID for the key.
 	Public_key *string `android:"path"`
 	// Path or module to the private key file in pem format. Used to sign APEXs.
+	// "external:<name>" references an external_signer module that holds the private key instead,
+	// for builds that must not have private key files.
 	Private_key *string `android:"path"`
 
 	// Whether this key is installable to one of the partitions. Defualt: true.
@@ -62,6 +68,10 @@ func (m *apexKey) installable() bool {
 	return false
 }
 
+func (m *apexKey) DepsMutator(ctx android.BottomUpMutatorContext) {
+	android.AddExternalSignerDependency(ctx, m.properties.Private_key)
+}
+
 func (m *apexKey) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// If the keys are from other modules (i.e. :module syntax) respect it.
 	// Otherwise, try to locate the key files in the default cert dir or
@@ -76,6 +86,13 @@ func (m *apexKey) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		}
 	}
 
+	if android.ExternalSignerName(m.properties.Private_key) != "" {
+		if m.signer = android.ExternalSignerFromDeps(ctx, "private_key", true); m.signer != nil {
+			m.privateKeyFile = m.signer.PublicKey
+		}
+		return
+	}
+
 	if android.SrcIsModule(String(m.properties.Private_key)) != "" {
 		m.privateKeyFile = android.PathForModuleSrc(ctx, String(m.properties.Private_key))
 	} else {
@@ -95,6 +112,9 @@ func (m *apexKey) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 }
 
+// apexKeyEntry is a line of apexkeys.txt. Release tools parse the lines with a fixed pattern that
+// ends with the optional partition and sign_tool fields, so no other field can be added. Keys that
+// are held by an external signer are listed as "external:<signer>" instead of a path.
 type apexKeyEntry struct {
 	name                 string
 	presigned            bool
@@ -123,16 +143,24 @@ func apexKeyEntryFor(ctx android.ModuleContext, module android.Module) apexKeyEn
 	switch m := module.(type) {
 	case *apexBundle:
 		pem, key := m.getCertificateAndPrivateKey(ctx)
-		return apexKeyEntry{
+		entry := apexKeyEntry{
 			name:                 m.Name() + ".apex",
 			presigned:            false,
 			publicKey:            m.publicKeyFile.String(),
 			privateKey:           m.privateKeyFile.String(),
 			containerCertificate: pem.String(),
-			containerPrivateKey:  key.String(),
 			partition:            m.PartitionTag(ctx.DeviceConfig()),
 			signTool:             proptools.String(m.properties.Custom_sign_tool),
 		}
+		if m.payloadSigner != nil {
+			entry.privateKey = android.ExternalSignerPrefix + m.payloadSigner.Name
+		}
+		if m.containerSigner != nil {
+			entry.containerPrivateKey = android.ExternalSignerPrefix + m.containerSigner.Name
+		} else {
+			entry.containerPrivateKey = key.String()
+		}
+		return entry
 	case *Prebuilt:
 		return apexKeyEntry{
 			name:      m.InstallFilename(),
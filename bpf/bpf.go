@@ -57,10 +57,19 @@ var (
 
 func registerBpfBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("bpf", BpfFactory)
+	ctx.RegisterParallelSingletonType("bpf_shared_maps_check", bpfSharedMapsCheckSingletonFactory)
 }
 
 var PrepareForTestWithBpf = android.FixtureRegisterWithContext(registerBpfBuildComponents)
 
+// BpfManifestInfo is provided by bpf modules that compiled objects.
+type BpfManifestInfo struct {
+	// JSON manifest of the programs and maps of the objects of the module
+	Manifest android.Path
+}
+
+var BpfManifestInfoProvider = blueprint.NewProvider(BpfManifestInfo{})
+
 // BpfModule interface is used by the apex package to gather information from a bpf module.
 type BpfModule interface {
 	android.Module
@@ -100,6 +109,9 @@ type bpf struct {
 	properties BpfProperties
 
 	objs android.Paths
+
+	// JSON manifest of the programs and maps of objs
+	manifest android.Path
 }
 
 var _ android.ImageInterface = (*bpf)(nil)
@@ -203,9 +215,66 @@ func (bpf *bpf) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		}
 
 	}
+
+	if len(bpf.objs) > 0 {
+		bpf.manifest = bpf.buildManifest(ctx)
+		ctx.SetProvider(BpfManifestInfoProvider, BpfManifestInfo{Manifest: bpf.manifest})
+	}
+
 	ctx.SetProvider(blueprint.SrcsFileProviderKey, blueprint.SrcsFileProviderData{SrcPaths: srcs.Strings()})
 }
 
+// buildManifest inspects the compiled objects to check that bpfloader can load them, and writes
+// the manifest of their programs and maps that is installed next to them.
+func (bpf *bpf) buildManifest(ctx android.ModuleContext) android.Path {
+	manifest := android.PathForModuleOut(ctx, strings.TrimSuffix(ctx.ModuleName(), ".o")+".manifest.json")
+
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().
+		BuiltTool("bpf_manifest").
+		FlagWithOutput("--output ", manifest).
+		Inputs(bpf.objs)
+	builder.Build("bpf_manifest", "bpf manifest")
+
+	return manifest
+}
+
+func bpfSharedMapsCheckSingletonFactory() android.Singleton {
+	return &bpfSharedMapsCheckSingleton{}
+}
+
+// bpfSharedMapsCheckSingleton checks that the maps defined by the objects of all of the bpf modules
+// are compatible. Objects in different modules that define a map with the same name share it once
+// loaded, so the check can't be done by each module on its own objects.
+type bpfSharedMapsCheckSingleton struct{}
+
+func (s *bpfSharedMapsCheckSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var manifests android.Paths
+	ctx.VisitAllModules(func(m android.Module) {
+		if ctx.ModuleHasProvider(m, BpfManifestInfoProvider) {
+			info := ctx.ModuleProvider(m, BpfManifestInfoProvider).(BpfManifestInfo)
+			manifests = append(manifests, info.Manifest)
+		}
+	})
+	if len(manifests) == 0 {
+		return
+	}
+
+	timestamp := android.PathForOutput(ctx, "bpf", "shared_maps_check.timestamp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().BuiltTool("bpf_manifest").
+		Flag("--check_shared_maps").
+		Inputs(android.SortedUniquePaths(manifests)).
+		Text("&& touch").Output(timestamp)
+	rule.Build("bpf_shared_maps_check", "check shared bpf maps")
+
+	// The check-bpf-shared-maps phony target depends on the timestamp created if the check succeeds.
+	ctx.Phony("check-bpf-shared-maps", timestamp)
+
+	// The droidcore phony target depends on the check-bpf-shared-maps phony target
+	ctx.Phony("droidcore", android.PathForPhony(ctx, "check-bpf-shared-maps"))
+}
+
 func (bpf *bpf) AndroidMk() android.AndroidMkData {
 	return android.AndroidMkData{
 		Custom: func(w io.Writer, name, prefix, moduleDir string, data android.AndroidMkData) {
@@ -234,6 +303,18 @@ func (bpf *bpf) AndroidMk() android.AndroidMkData {
 				fmt.Fprintln(w, "include $(BUILD_PREBUILT)")
 				fmt.Fprintln(w)
 			}
+			if bpf.manifest != nil {
+				manifestName := name + "_" + bpf.manifest.Base()
+				names = append(names, manifestName)
+				fmt.Fprintln(w, "include $(CLEAR_VARS)", " # bpf.bpf.manifest")
+				fmt.Fprintln(w, "LOCAL_MODULE := ", manifestName)
+				fmt.Fprintln(w, "LOCAL_PREBUILT_MODULE_FILE :=", bpf.manifest.String())
+				fmt.Fprintln(w, "LOCAL_MODULE_STEM :=", bpf.manifest.Base())
+				fmt.Fprintln(w, "LOCAL_MODULE_CLASS := ETC")
+				fmt.Fprintln(w, localModulePath)
+				fmt.Fprintln(w, "include $(BUILD_PREBUILT)")
+				fmt.Fprintln(w)
+			}
 			fmt.Fprintln(w, "include $(CLEAR_VARS)", " # bpf.bpf")
 			fmt.Fprintln(w, "LOCAL_MODULE := ", name)
 			android.AndroidMkEmitAssignList(w, "LOCAL_REQUIRED_MODULES", names)
@@ -248,6 +329,11 @@ func (bpf *bpf) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case "":
 		return bpf.objs, nil
+	case ".manifest":
+		if bpf.manifest == nil {
+			return nil, nil
+		}
+		return android.Paths{bpf.manifest}, nil
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -31,6 +31,7 @@ var prepareForBpfTest = android.GroupFixturePreparers(
 	android.FixtureMergeMockFs(
 		map[string][]byte{
 			"bpf.c":              nil,
+			"other.c":            nil,
 			"bpf_invalid_name.c": nil,
 			"BpfTest.cpp":        nil,
 		},
@@ -71,3 +72,46 @@ func TestBpfSourceName(t *testing.T) {
 		`\QAndroid.bp:2:3: module "bpf_invalid_name.o" variant "android_common": invalid character '_' in source name\E`)).
 		RunTestWithBp(t, bp)
 }
+
+func TestBpfManifest(t *testing.T) {
+	bp := `
+		bpf {
+			name: "bpf.o",
+			srcs: ["bpf.c"],
+		}
+	`
+	result := prepareForBpfTest.RunTestWithBp(t, bp)
+
+	module := result.ModuleForTests("bpf.o", "android_common")
+	manifest := module.Output("bpf.manifest.json")
+	android.AssertStringDoesContain(t, "bpf_manifest command", manifest.RuleParams.Command,
+		"bpf_manifest --output out/soong/.intermediates/bpf.o/android_common/bpf.manifest.json "+
+			"out/soong/.intermediates/bpf.o/android_common/unstripped/bpf.o")
+
+	outputs, err := module.Module().(*bpf).OutputFiles(".manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "manifest output",
+		[]string{"out/soong/.intermediates/bpf.o/android_common/bpf.manifest.json"}, outputs)
+}
+
+func TestBpfSharedMapsCheck(t *testing.T) {
+	bp := `
+		bpf {
+			name: "bpf.o",
+			srcs: ["bpf.c"],
+		}
+
+		bpf {
+			name: "other.o",
+			srcs: ["other.c"],
+		}
+	`
+	result := prepareForBpfTest.RunTestWithBp(t, bp)
+
+	check := result.SingletonForTests("bpf_shared_maps_check").Output("out/soong/bpf/shared_maps_check.timestamp")
+	android.AssertStringDoesContain(t, "bpf_manifest command", check.RuleParams.Command,
+		"bpf_manifest --check_shared_maps out/soong/.intermediates/bpf.o/android_common/bpf.manifest.json "+
+			"out/soong/.intermediates/other.o/android_common/other.manifest.json")
+}
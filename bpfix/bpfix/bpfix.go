@@ -195,6 +195,21 @@ func (r FixRequest) AddBase() (result FixRequest) {
 	return result
 }
 
+// UnusedLibs maps the names of modules to the libraries they do not use, by property, as
+// reported by the unused-libs build target.
+type UnusedLibs map[string]map[string][]string
+
+// AddRemoveUnusedLibs returns a FixRequest that also removes the given unused libraries from the
+// modules that list them.
+func (r FixRequest) AddRemoveUnusedLibs(unused UnusedLibs) (result FixRequest) {
+	result.steps = append([]FixStep(nil), r.steps...)
+	result.steps = append(result.steps, FixStep{
+		Name: "removeUnusedLibs",
+		Fix:  removeUnusedLibs(unused),
+	})
+	return result
+}
+
 func (r FixRequest) AddMatchingExtensions(pattern string) (result FixRequest) {
 	result.steps = append([]FixStep(nil), r.steps...)
 	for _, extension := range fixStepsExtensions {
@@ -803,6 +818,55 @@ func removeEmptyLibDependencies(f *Fixer) error {
 	return nil
 }
 
+// Removes the libraries that modules do not use from their shared_libs, static_libs and
+// whole_static_libs
+func removeUnusedLibs(unused UnusedLibs) func(f *Fixer) error {
+	relevantFields := []string{
+		"shared_libs",
+		"static_libs",
+		"whole_static_libs",
+	}
+	return func(f *Fixer) error {
+		for _, def := range f.tree.Defs {
+			mod, ok := def.(*parser.Module)
+			if !ok {
+				continue
+			}
+			name, ok := getLiteralStringPropertyValue(mod, "name")
+			if !ok || unused[name] == nil {
+				continue
+			}
+			for _, field := range relevantFields {
+				unusedLibs := unused[name][field]
+				if len(unusedLibs) == 0 {
+					continue
+				}
+				listValue, ok := getLiteralListProperty(mod, field)
+				if !ok {
+					continue
+				}
+				newValues := []parser.Expression{}
+				for _, v := range listValue.Values {
+					stringValue, ok := v.(*parser.String)
+					if !ok {
+						return fmt.Errorf("Expecting string for %s.%s fields", mod.Type, field)
+					}
+					if inList(stringValue.Value, unusedLibs) {
+						continue
+					}
+					newValues = append(newValues, stringValue)
+				}
+				if len(newValues) == 0 && len(listValue.Values) != 0 {
+					removeProperty(mod, field)
+				} else {
+					listValue.Values = newValues
+				}
+			}
+		}
+		return nil
+	}
+}
+
 // Removes hidl_interface 'types' which are no longer needed
 func removeHidlInterfaceTypes(f *Fixer) error {
 	for _, def := range f.tree.Defs {
@@ -1013,6 +1013,66 @@ func TestRemoveEmptyLibDependencies(t *testing.T) {
 	}
 }
 
+func TestRemoveUnusedLibs(t *testing.T) {
+	unused := UnusedLibs{
+		"foo": {
+			"shared_libs": []string{"libunused"},
+			"static_libs": []string{"libunused_static"},
+		},
+	}
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			name: "remove unused libs",
+			in: `
+				cc_binary {
+					name: "foo",
+					shared_libs: [
+						"libused",
+						"libunused",
+					],
+					static_libs: ["libunused_static"],
+					whole_static_libs: ["libunused"],
+				}
+			`,
+			out: `
+				cc_binary {
+					name: "foo",
+					shared_libs: [
+						"libused",
+
+					],
+
+					whole_static_libs: ["libunused"],
+				}
+			`,
+		},
+		{
+			name: "other module",
+			in: `
+				cc_binary {
+					name: "bar",
+					shared_libs: ["libunused"],
+				}
+			`,
+			out: `
+				cc_binary {
+					name: "bar",
+					shared_libs: ["libunused"],
+				}
+			`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runPass(t, test.in, test.out, removeUnusedLibs(unused))
+		})
+	}
+}
+
 func TestRemoveHidlInterfaceTypes(t *testing.T) {
 	tests := []struct {
 		name string
@@ -20,6 +20,7 @@ package cmd_lib
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -38,6 +39,8 @@ var (
 	list   = flag.Bool("l", false, "list files whose formatting differs from bpfmt's")
 	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
 	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
+
+	unusedLibs = flag.String("unused_libs", "", "only remove the unused libraries listed in this report written by the unused-libs target")
 )
 
 var (
@@ -132,6 +135,14 @@ func Run() {
 	flag.Parse()
 
 	fixRequest := bpfix.NewFixRequest().AddAll()
+	if *unusedLibs != "" {
+		unused, err := readUnusedLibs(*unusedLibs)
+		if err != nil {
+			report(err)
+			return
+		}
+		fixRequest = bpfix.NewFixRequest().AddRemoveUnusedLibs(unused)
+	}
 
 	if flag.NArg() == 0 {
 		if *write {
@@ -160,6 +171,36 @@ func Run() {
 	}
 }
 
+// readUnusedLibs reads the report written by link_report merge-unused-libs.
+func readUnusedLibs(filename string) (bpfix.UnusedLibs, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var summary struct {
+		Modules []struct {
+			Module string
+			Unused []struct {
+				Name     string
+				Property string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	unused := make(bpfix.UnusedLibs)
+	for _, m := range summary.Modules {
+		if unused[m.Module] == nil {
+			unused[m.Module] = make(map[string][]string)
+		}
+		for _, lib := range m.Unused {
+			unused[m.Module][lib.Property] = append(unused[m.Module][lib.Property], lib.Name)
+		}
+	}
+	return unused, nil
+}
+
 func diff(b1, b2 []byte) (data []byte, err error) {
 	f1, err := ioutil.TempFile("", "bpfix")
 	if err != nil {
@@ -67,6 +67,14 @@ func (afdo *afdo) afdoEnabled() bool {
 	return afdo != nil && afdo.Properties.Afdo
 }
 
+// profile returns the AFDO profile the module is built with, if any.
+func (afdo *afdo) profile(ctx android.PathContext) android.OptionalPath {
+	if afdo == nil || afdo.Properties.FdoProfilePath == nil {
+		return android.OptionalPath{}
+	}
+	return android.OptionalPathForPath(android.PathForSource(ctx, *afdo.Properties.FdoProfilePath))
+}
+
 func (afdo *afdo) flags(ctx ModuleContext, flags Flags) Flags {
 	if afdo.Properties.Afdo {
 		// We use `-funique-internal-linkage-names` to associate profiles to the right internal
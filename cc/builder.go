@@ -235,6 +235,31 @@ var (
 			Platform: map[string]string{remoteexec.PoolKey: "${config.REClangTidyPool}"},
 		}, []string{"cFlags", "ccCmd", "clangCmd", "tidyCmd", "tidyFlags", "tidyVars"}, []string{})
 
+	// Rule for invoking clang-tidy and exporting its fixes. It always runs locally, and always
+	// creates the fixes file, which clang-tidy does not write when it has nothing to fix.
+	clangTidyExportFixes = pctx.AndroidStaticRule("clangTidyExportFixes",
+		blueprint.RuleParams{
+			Depfile: "${out}.d",
+			Deps:    blueprint.DepsGCC,
+			Command: "rm -f $fixes && CLANG_CMD=$clangCmd TIDY_FILE=$out " +
+				"$tidyVars${config.ClangBin}/clang-tidy.sh $in $tidyFlags -export-fixes=$fixes -- $cFlags && " +
+				"touch $fixes",
+			CommandDeps: []string{"${config.ClangBin}/clang-tidy.sh", "$ccCmd", "$tidyCmd"},
+		},
+		"cFlags", "ccCmd", "clangCmd", "fixes", "tidyCmd", "tidyFlags", "tidyVars")
+
+	// Rule for invoking include-what-you-use, which writes its analysis of a translation unit
+	// as a JSON report.
+	iwyuRule = pctx.AndroidStaticRule("iwyu",
+		blueprint.RuleParams{
+			Depfile: "${out}.d",
+			Deps:    blueprint.DepsGCC,
+			Command: "$iwyuReportCmd run --source $in --output $out -- " +
+				"${config.IwyuCmd} $iwyuFlags $cFlags -MD -MF ${out}.d -c $in",
+			CommandDeps: []string{"$iwyuReportCmd", "${config.IwyuCmd}"},
+		},
+		"cFlags", "iwyuFlags")
+
 	_ = pctx.SourcePathVariable("yasmCmd", "prebuilts/misc/${config.HostPrebuiltTag}/yasm/yasm")
 
 	// Rule for invoking yasm to compile .asm assembly files.
@@ -378,22 +403,27 @@ type builderFlags struct {
 	libFlags      string // Flags to add to the linker directly after specifying libraries to link.
 	extraLibFlags string // Flags to add to the linker last.
 	tidyFlags     string // Flags that apply to clang-tidy
+	iwyuFlags     string // Flags that apply to include-what-you-use
 	sAbiFlags     string // Flags that apply to header-abi-dumps
 	aidlFlags     string // Flags that apply to aidl source files
 	rsFlags       string // Flags that apply to renderscript source files
 	toolchain     config.Toolchain
 
 	// True if these extra features are enabled.
-	tidy          bool
-	needTidyFiles bool
-	gcovCoverage  bool
-	sAbiDump      bool
-	emitXrefs     bool
+	tidy            bool
+	needTidyFiles   bool
+	tidyExportFixes bool
+	iwyu            bool
+	gcovCoverage    bool
+	sAbiDump        bool
+	emitXrefs       bool
 
 	assemblerWithCpp bool // True if .s files should be processed with the c preprocessor.
 
 	systemIncludeFlags string
 
+	iwyuDeps android.Paths // Files depended on by iwyuFlags
+
 	proto            android.ProtoFlags
 	protoC           bool // If true, compile protos as `.c` files. Otherwise, output as `.cc`.
 	protoOptionsFile bool // If true, output a proto options file.
@@ -416,33 +446,39 @@ type StripFlags struct {
 
 // Objects is a collection of file paths corresponding to outputs for C++ related build statements.
 type Objects struct {
-	objFiles      android.Paths
-	tidyFiles     android.Paths
-	tidyDepFiles  android.Paths // link dependent .tidy files
-	coverageFiles android.Paths
-	sAbiDumpFiles android.Paths
-	kytheFiles    android.Paths
+	objFiles       android.Paths
+	tidyFiles      android.Paths
+	tidyDepFiles   android.Paths // link dependent .tidy files
+	tidyFixesFiles android.Paths // fixes exported by clang-tidy
+	iwyuFiles      android.Paths // include-what-you-use reports
+	coverageFiles  android.Paths
+	sAbiDumpFiles  android.Paths
+	kytheFiles     android.Paths
 }
 
 func (a Objects) Copy() Objects {
 	return Objects{
-		objFiles:      append(android.Paths{}, a.objFiles...),
-		tidyFiles:     append(android.Paths{}, a.tidyFiles...),
-		tidyDepFiles:  append(android.Paths{}, a.tidyDepFiles...),
-		coverageFiles: append(android.Paths{}, a.coverageFiles...),
-		sAbiDumpFiles: append(android.Paths{}, a.sAbiDumpFiles...),
-		kytheFiles:    append(android.Paths{}, a.kytheFiles...),
+		objFiles:       append(android.Paths{}, a.objFiles...),
+		tidyFiles:      append(android.Paths{}, a.tidyFiles...),
+		tidyDepFiles:   append(android.Paths{}, a.tidyDepFiles...),
+		tidyFixesFiles: append(android.Paths{}, a.tidyFixesFiles...),
+		iwyuFiles:      append(android.Paths{}, a.iwyuFiles...),
+		coverageFiles:  append(android.Paths{}, a.coverageFiles...),
+		sAbiDumpFiles:  append(android.Paths{}, a.sAbiDumpFiles...),
+		kytheFiles:     append(android.Paths{}, a.kytheFiles...),
 	}
 }
 
 func (a Objects) Append(b Objects) Objects {
 	return Objects{
-		objFiles:      append(a.objFiles, b.objFiles...),
-		tidyFiles:     append(a.tidyFiles, b.tidyFiles...),
-		tidyDepFiles:  append(a.tidyDepFiles, b.tidyDepFiles...),
-		coverageFiles: append(a.coverageFiles, b.coverageFiles...),
-		sAbiDumpFiles: append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
-		kytheFiles:    append(a.kytheFiles, b.kytheFiles...),
+		objFiles:       append(a.objFiles, b.objFiles...),
+		tidyFiles:      append(a.tidyFiles, b.tidyFiles...),
+		tidyDepFiles:   append(a.tidyDepFiles, b.tidyDepFiles...),
+		tidyFixesFiles: append(a.tidyFixesFiles, b.tidyFixesFiles...),
+		iwyuFiles:      append(a.iwyuFiles, b.iwyuFiles...),
+		coverageFiles:  append(a.coverageFiles, b.coverageFiles...),
+		sAbiDumpFiles:  append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
+		kytheFiles:     append(a.kytheFiles, b.kytheFiles...),
 	}
 }
 
@@ -452,6 +488,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 	// Source files are one-to-one with tidy, coverage, or kythe files, if enabled.
 	objFiles := make(android.Paths, len(srcFiles))
 	var tidyFiles android.Paths
+	var tidyFixesFiles android.Paths
 	noTidySrcsMap := make(map[string]bool)
 	var tidyVars string
 	if flags.tidy {
@@ -468,6 +505,10 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			}
 		}
 	}
+	var iwyuFiles android.Paths
+	if flags.iwyu {
+		iwyuFiles = make(android.Paths, 0, len(srcFiles))
+	}
 	var coverageFiles android.Paths
 	if flags.gcovCoverage {
 		coverageFiles = make(android.Paths, 0, len(srcFiles))
@@ -597,6 +638,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 		var ccCmd string
 		tidy := flags.tidy
+		iwyu := flags.iwyu
 		coverage := flags.gcovCoverage
 		dump := flags.sAbiDump
 		rule := cc
@@ -612,6 +654,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			ccCmd = "clang"
 			moduleFlags = asflags
 			tidy = false
+			iwyu = false
 			coverage = false
 			dump = false
 			emitXref = false
@@ -688,21 +731,51 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			sharedCFlags := shareFlags("cFlags", moduleFlags)
 			srcRelPath := srcFile.Rel()
 
+			args := map[string]string{
+				"cFlags":    sharedCFlags,
+				"ccCmd":     ccCmd,
+				"clangCmd":  ccDesc,
+				"tidyCmd":   tidyCmd,
+				"tidyFlags": shareFlags("tidyFlags", config.TidyFlagsForSrcFile(srcFile, flags.tidyFlags)),
+				"tidyVars":  tidyVars, // short and not shared
+			}
+			var tidyOutputs android.WritablePaths
+			if flags.tidyExportFixes {
+				// Fixes modify the source tree, so they are exported by a local rule instead.
+				rule = clangTidyExportFixes
+				fixesFile := android.ObjPathWithExt(ctx, subdir, srcFile, "tidy.yaml")
+				tidyFixesFiles = append(tidyFixesFiles, fixesFile)
+				tidyOutputs = append(tidyOutputs, fixesFile)
+				args["fixes"] = fixesFile.String()
+			}
+
 			// Add the .tidy rule
 			ctx.Build(pctx, android.BuildParams{
-				Rule:        rule,
-				Description: "clang-tidy " + srcRelPath,
-				Output:      tidyFile,
+				Rule:            rule,
+				Description:     "clang-tidy " + srcRelPath,
+				Output:          tidyFile,
+				ImplicitOutputs: tidyOutputs,
+				Input:           srcFile,
+				Implicits:       cFlagsDeps,
+				OrderOnly:       pathDeps,
+				Args:            args,
+			})
+		}
+
+		if iwyu {
+			iwyuFile := android.ObjPathWithExt(ctx, subdir, srcFile, "iwyu")
+			iwyuFiles = append(iwyuFiles, iwyuFile)
+
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        iwyuRule,
+				Description: "include-what-you-use " + srcFile.Rel(),
+				Output:      iwyuFile,
 				Input:       srcFile,
-				Implicits:   cFlagsDeps,
+				Implicits:   append(append(android.Paths{}, cFlagsDeps...), flags.iwyuDeps...),
 				OrderOnly:   pathDeps,
 				Args: map[string]string{
-					"cFlags":    sharedCFlags,
-					"ccCmd":     ccCmd,
-					"clangCmd":  ccDesc,
-					"tidyCmd":   tidyCmd,
-					"tidyFlags": shareFlags("tidyFlags", config.TidyFlagsForSrcFile(srcFile, flags.tidyFlags)),
-					"tidyVars":  tidyVars, // short and not shared
+					"cFlags":    shareFlags("cFlags", moduleFlags),
+					"iwyuFlags": flags.iwyuFlags,
 				},
 			})
 		}
@@ -737,12 +810,14 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		tidyDepFiles = tidyFiles
 	}
 	return Objects{
-		objFiles:      objFiles,
-		tidyFiles:     tidyFiles,
-		tidyDepFiles:  tidyDepFiles,
-		coverageFiles: coverageFiles,
-		sAbiDumpFiles: sAbiDumpFiles,
-		kytheFiles:    kytheFiles,
+		objFiles:       objFiles,
+		tidyFiles:      tidyFiles,
+		tidyDepFiles:   tidyDepFiles,
+		tidyFixesFiles: tidyFixesFiles,
+		iwyuFiles:      iwyuFiles,
+		coverageFiles:  coverageFiles,
+		sAbiDumpFiles:  sAbiDumpFiles,
+		kytheFiles:     kytheFiles,
 	}
 }
 
@@ -226,18 +226,25 @@ type Flags struct {
 	libFlags      []string // Flags to add libraries early to the link order
 	extraLibFlags []string // Flags to add libraries late in the link order after LdFlags
 	TidyFlags     []string // Flags that apply to clang-tidy
+	IwyuFlags     []string // Flags that apply to include-what-you-use
 	SAbiFlags     []string // Flags that apply to header-abi-dumper
 
 	// Global include flags that apply to C, C++, and assembly source files
 	// These must be after any module include flags, which will be in CommonFlags.
 	SystemIncludeFlags []string
 
-	Toolchain     config.Toolchain
-	Tidy          bool // True if ninja .tidy rules should be generated.
-	NeedTidyFiles bool // True if module link should depend on .tidy files
-	GcovCoverage  bool // True if coverage files should be generated.
-	SAbiDump      bool // True if header abi dumps should be generated.
-	EmitXrefs     bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
+	Toolchain       config.Toolchain
+	Tidy            bool // True if ninja .tidy rules should be generated.
+	NeedTidyFiles   bool // True if module link should depend on .tidy files
+	TidyExportFixes bool // True if the .tidy rules should also export fixes to .tidy.yaml files
+	Iwyu            bool // True if ninja .iwyu rules should be generated.
+	CheckUnusedLibs bool // True if the link should be checked for unused libraries.
+	LinkerMap       bool // True if the link should write a linker map to attribute its size.
+	ThinLtoCache    bool // True if the link shares the ThinLTO cache.
+	LtoDiagnostics  bool // True if the link should report the time spent in LTO and its imports.
+	GcovCoverage    bool // True if coverage files should be generated.
+	SAbiDump        bool // True if header abi dumps should be generated.
+	EmitXrefs       bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
 
 	// The instruction set required for clang ("arm" or "thumb").
 	RequiredInstructionSet string
@@ -245,6 +252,7 @@ type Flags struct {
 	DynamicLinker string
 
 	CFlagsDeps  android.Paths // Files depended on by compiler flags
+	IwyuDeps    android.Paths // Files depended on by include-what-you-use flags
 	LdFlagsDeps android.Paths // Files depended on by linker flags
 
 	// True if .s files should be processed with the c preprocessor.
@@ -901,6 +909,22 @@ type Module struct {
 	objFiles android.Paths
 	// Tidy .tidy file output paths for this compilation module
 	tidyFiles android.Paths
+	// Fixes exported by clang-tidy for this compilation module
+	tidyFixesFiles android.Paths
+	// include-what-you-use .iwyu report paths for this compilation module
+	iwyuFiles android.Paths
+
+	// Report of the libraries that the link of this module does not use
+	unusedLibsReport android.OptionalPath
+
+	// Attribution of the size of the link of this module to its inputs
+	linkSizesReport android.OptionalPath
+
+	// LTO time and import statistics of the link of this module
+	ltoStatsReport android.OptionalPath
+
+	// Coverage of the AFDO profile and orderfile of this module
+	profileCoverageReport android.OptionalPath
 
 	// For apex variants, this is set as apex.min_sdk_version
 	apexSdkVersion android.ApiLevel
@@ -1795,6 +1819,9 @@ func newModule(hod android.HostOrDeviceSupported, multilib android.Multilib) *Mo
 	module := newBaseModule(hod, multilib)
 	module.features = []feature{
 		&tidyFeature{},
+		&iwyuFeature{},
+		&unusedLibsFeature{},
+		&linkerMapFeature{},
 	}
 	module.stl = &stl{}
 	module.sanitize = &sanitize{}
@@ -2111,6 +2138,8 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		c.kytheFiles = objs.kytheFiles
 		c.objFiles = objs.objFiles
 		c.tidyFiles = objs.tidyFiles
+		c.tidyFixesFiles = objs.tidyFixesFiles
+		c.iwyuFiles = objs.iwyuFiles
 	}
 
 	if c.linker != nil {
@@ -2120,6 +2149,11 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		}
 		c.outputFile = android.OptionalPathForPath(outputFile)
 
+		c.checkUnusedLibs(ctx, deps)
+		c.attributeLinkSizes(ctx, deps, objs)
+		c.reportLtoStats(ctx)
+		c.reportProfileCoverage(ctx)
+
 		c.maybeUnhideFromMake()
 
 		// glob exported headers for snapshot, if BOARD_VNDK_VERSION is current or
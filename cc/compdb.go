@@ -29,7 +29,12 @@ import (
 // or mmma is called. It will only create a single compile_commands.json file
 // at ${OUT_DIR}/soong/development/ide/compdb/compile_commands.json. It will also symlink it
 // to ${SOONG_LINK_COMPDB_TO} if set. In general this should be created by running
-// make SOONG_GEN_COMPDB=1 nothing to get all targets.
+// make SOONG_GEN_COMPDB=1 nothing to get all targets. Entries for the headers of the modules are
+// only added to it with SOONG_GEN_COMPDB_HEADERS=1, as globbing the include directories of all the
+// modules is expensive. The entries of headers use flags of their module, not of a translation
+// unit that includes them. It also writes a compile_commands.json
+// file limited to the modules of the IDE workspace when one is requested, see
+// android/ide_workspace.go.
 
 func init() {
 	android.RegisterParallelSingletonType("compdb_generator", compDBGeneratorSingleton)
@@ -49,6 +54,13 @@ const (
 	envVariableGenerateCompdb          = "SOONG_GEN_COMPDB"
 	envVariableGenerateCompdbDebugInfo = "SOONG_GEN_COMPDB_DEBUG"
 	envVariableCompdbLink              = "SOONG_LINK_COMPDB_TO"
+	envVariableCompdbHeaders           = "SOONG_GEN_COMPDB_HEADERS"
+
+	// Environment variables that select the variant of the modules to use for the entries, see
+	// compdbVariantSelection.
+	envVariableCompdbArch      = "SOONG_GEN_COMPDB_ARCH"
+	envVariableCompdbImage     = "SOONG_GEN_COMPDB_IMAGE"
+	envVariableCompdbSanitizer = "SOONG_GEN_COMPDB_SANITIZER"
 )
 
 // A compdb entry. The compile_commands.json file is a list of these.
@@ -60,6 +72,10 @@ type compDbEntry struct {
 }
 
 func (c *compdbGeneratorSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if scope := android.IdeWorkspaceScopeFor(ctx); scope != nil {
+		generateIdeWorkspaceCompdb(ctx, scope)
+	}
+
 	if !ctx.Config().IsEnvTrue(envVariableGenerateCompdb) {
 		return
 	}
@@ -67,15 +83,7 @@ func (c *compdbGeneratorSingleton) GenerateBuildActions(ctx android.SingletonCon
 	// Instruct the generator to indent the json file for easier debugging.
 	outputCompdbDebugInfo := ctx.Config().IsEnvTrue(envVariableGenerateCompdbDebugInfo)
 
-	// We only want one entry per file. We don't care what module/isa it's from
-	m := make(map[string]compDbEntry)
-	ctx.VisitAllModules(func(module android.Module) {
-		if ccModule, ok := module.(*Module); ok {
-			if compiledModule, ok := ccModule.compiler.(CompiledInterface); ok {
-				generateCompdbProject(compiledModule, ctx, ccModule, m)
-			}
-		}
-	})
+	v := collectCompdbEntries(ctx, nil, ctx.Config().IsEnvTrue(envVariableCompdbHeaders))
 
 	// Create the output file.
 	dir := android.PathForOutput(ctx, compdbOutputProjectsDirectory)
@@ -87,11 +95,6 @@ func (c *compdbGeneratorSingleton) GenerateBuildActions(ctx android.SingletonCon
 	}
 	defer f.Close()
 
-	v := make([]compDbEntry, 0, len(m))
-
-	for _, value := range m {
-		v = append(v, value)
-	}
 	var dat []byte
 	if outputCompdbDebugInfo {
 		dat, err = json.MarshalIndent(v, "", " ")
@@ -112,6 +115,188 @@ func (c *compdbGeneratorSingleton) GenerateBuildActions(ctx android.SingletonCon
 	}
 }
 
+// collectCompdbEntries returns the compdb entries of the cc modules, or of the cc modules in scope
+// if it is not nil. Entries for the headers of the modules are added if includeHeaders is true.
+func collectCompdbEntries(ctx android.SingletonContext, scope *android.IdeWorkspaceScope, includeHeaders bool) []compDbEntry {
+	selection := compdbVariantSelectionFromEnv(ctx.Config())
+
+	var ccModules []*Module
+	ctx.VisitAllModules(func(module android.Module) {
+		if scope != nil && !scope.Contains(module) {
+			return
+		}
+		if ccModule, ok := module.(*Module); ok {
+			if _, ok := ccModule.compiler.(CompiledInterface); ok {
+				ccModules = append(ccModules, ccModule)
+			}
+		}
+	})
+
+	// Generated sources have a different path in each variant, so only the best matching variant
+	// of each module contributes them.
+	bestVariants := make(map[string]*Module)
+	for _, ccModule := range ccModules {
+		name := ctx.ModuleName(ccModule)
+		if best, ok := bestVariants[name]; !ok || selection.rank(ccModule) > selection.rank(best) {
+			bestVariants[name] = ccModule
+		}
+	}
+
+	// We only want one entry per file, from the variant that best matches the selection.
+	m := make(map[string]compdbCandidate)
+	var headerGlobs map[string][]string
+	if includeHeaders {
+		headerGlobs = make(map[string][]string)
+	}
+	for _, ccModule := range ccModules {
+		includeGenerated := bestVariants[ctx.ModuleName(ccModule)] == ccModule
+		generateCompdbProject(ccModule.compiler.(CompiledInterface), ctx, ccModule,
+			selection.rank(ccModule), includeGenerated, headerGlobs, m)
+	}
+
+	v := make([]compDbEntry, 0, len(m))
+	for _, key := range android.SortedKeys(m) {
+		v = append(v, m[key].entry)
+	}
+	return v
+}
+
+// compdbCandidate is an entry for a file from one of the module variants that compile it or have
+// it in their include directories.
+type compdbCandidate struct {
+	entry compDbEntry
+
+	// How well the variant matches the selection, see compdbVariantSelection.rank.
+	rank int
+
+	// True if the file is a header and the entry uses the flags of a source of the module.
+	header bool
+}
+
+// better returns true if c should replace other as the entry of the file. Entries for sources
+// always win over entries for headers, and the first variant wins between equally ranked ones.
+func (c compdbCandidate) better(other compdbCandidate) bool {
+	if c.header != other.header {
+		return !c.header
+	}
+	return c.rank > other.rank
+}
+
+func addCompdbCandidate(builds map[string]compdbCandidate, c compdbCandidate) {
+	if existing, ok := builds[c.entry.File]; !ok || c.better(existing) {
+		builds[c.entry.File] = c
+	}
+}
+
+// compdbVariantSelection is the variant of the modules to use for the compdb entries, as selected
+// with the SOONG_GEN_COMPDB_{ARCH,IMAGE,SANITIZER} environment variables. Modules that have no
+// matching variant still get entries from their best matching one.
+type compdbVariantSelection struct {
+	// The arch type name, e.g. arm64 or x86_64. Defaults to the primary device arch.
+	arch string
+
+	// The image, one of core, vendor, product, recovery, ramdisk and vendor_ramdisk. Defaults to
+	// core.
+	image string
+
+	// The variation name of a sanitizer, e.g. asan or hwasan, or none. Defaults to none.
+	sanitizer string
+}
+
+func compdbVariantSelectionFromEnv(config android.Config) compdbVariantSelection {
+	s := compdbVariantSelection{
+		arch:      config.Getenv(envVariableCompdbArch),
+		image:     config.Getenv(envVariableCompdbImage),
+		sanitizer: config.Getenv(envVariableCompdbSanitizer),
+	}
+	if s.arch == "" && len(config.Targets[android.Android]) > 0 {
+		s.arch = config.Targets[android.Android][0].Arch.ArchType.Name
+	}
+	if s.image == "" {
+		s.image = "core"
+	}
+	if s.sanitizer == "" {
+		s.sanitizer = "none"
+	}
+	return s
+}
+
+// rank returns how well the variant of ccModule matches the selection; higher is better. The arch
+// matters most, then the image, then the sanitizer, and device variants are preferred over host
+// variants.
+func (s compdbVariantSelection) rank(ccModule *Module) int {
+	rank := 0
+	if ccModule.Target().Arch.ArchType.Name == s.arch {
+		rank += 8
+	}
+	if compdbImage(ccModule) == s.image {
+		rank += 4
+	}
+	if s.matchesSanitizer(ccModule) {
+		rank += 2
+	}
+	if ccModule.Target().Os.Class == android.Device {
+		rank += 1
+	}
+	return rank
+}
+
+// compdbImage returns the name of the image of the variant of ccModule.
+func compdbImage(ccModule *Module) string {
+	switch {
+	case ccModule.InVendor():
+		return "vendor"
+	case ccModule.InProduct():
+		return "product"
+	case ccModule.InRecovery():
+		return "recovery"
+	case ccModule.InVendorRamdisk():
+		return "vendor_ramdisk"
+	case ccModule.InRamdisk():
+		return "ramdisk"
+	}
+	return "core"
+}
+
+// matchesSanitizer returns true if the selected sanitizer is enabled for the variant of ccModule,
+// or if none is selected and no sanitizer is enabled.
+func (s compdbVariantSelection) matchesSanitizer(ccModule *Module) bool {
+	for _, t := range Sanitizers {
+		if ccModule.IsSanitizerEnabled(t) {
+			if t.variationName() == s.sanitizer {
+				return true
+			}
+			if s.sanitizer == "none" {
+				return false
+			}
+		}
+	}
+	return s.sanitizer == "none"
+}
+
+// generateIdeWorkspaceCompdb writes the compile_commands.json file of the IDE workspace, see
+// android/ide_workspace.go.
+func generateIdeWorkspaceCompdb(ctx android.SingletonContext, scope *android.IdeWorkspaceScope) {
+	// The workspace is limited to a few modules, so globbing their headers is cheap.
+	dat, err := json.MarshalIndent(collectCompdbEntries(ctx, scope, true), "", " ")
+	if err != nil {
+		ctx.Errorf("Failed to marshal: %s", err)
+		return
+	}
+	compDBFile := android.PathForIdeWorkspace(ctx, compdbFilename)
+	if err := android.WriteFileToOutputDir(compDBFile, dat, 0666); err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+
+	// This is necessary to satisfy the dangling rules check as this file is written by Soong rather than a rule.
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.Touch,
+		Output: compDBFile,
+	})
+	ctx.Phony(android.IdeWorkspacePhony, compDBFile)
+}
+
 func expandAllVars(ctx android.SingletonContext, args []string) []string {
 	var out []string
 	for _, arg := range args {
@@ -168,11 +353,16 @@ func getArguments(src android.Path, ctx android.SingletonContext, ccModule *Modu
 	return args
 }
 
-func generateCompdbProject(compiledModule CompiledInterface, ctx android.SingletonContext, ccModule *Module, builds map[string]compDbEntry) {
+// generateCompdbProject adds the entries of the sources of the module to builds, and the entries of
+// its headers unless headerGlobs is nil.
+func generateCompdbProject(compiledModule CompiledInterface, ctx android.SingletonContext, ccModule *Module,
+	rank int, includeGenerated bool, headerGlobs map[string][]string, builds map[string]compdbCandidate) {
 	srcs := compiledModule.Srcs()
 	if len(srcs) == 0 {
 		return
 	}
+	objs := compiledModule.ObjFiles()
+	outDir := android.PathForOutput(ctx).String() + "/"
 
 	pathToCC, err := ctx.Eval(pctx, "${config.ClangBin}")
 	ccPath := "/bin/false"
@@ -181,15 +371,111 @@ func generateCompdbProject(compiledModule CompiledInterface, ctx android.Singlet
 		ccPath = filepath.Join(pathToCC, "clang")
 		cxxPath = filepath.Join(pathToCC, "clang++")
 	}
-	for _, src := range srcs {
-		if _, ok := builds[src.String()]; !ok {
-			builds[src.String()] = compDbEntry{
+
+	// The arguments of the source whose flags are used for the headers of the module, preferring
+	// a C++ source. Headers are not mapped to the sources that include them: that is only known
+	// from the depfiles of the sources, which ninja deletes once it has read them. A header gets
+	// the flags of the first C++ source of the module, or of its first C source if it has none,
+	// whether or not that source includes it.
+	var headerArgs []string
+	headerLang := ""
+	for i, src := range srcs {
+		// Prebuilt objects are not compiled.
+		if src.Ext() == ".o" {
+			continue
+		}
+		if !includeGenerated && strings.HasPrefix(src.String(), outDir) {
+			continue
+		}
+		entry := compDbEntry{
+			Directory: android.AbsSrcDirForExistingUseCases(),
+			Arguments: getArguments(src, ctx, ccModule, ccPath, cxxPath),
+			File:      src.String(),
+		}
+		if i < len(objs) {
+			entry.Output = objs[i].String()
+		}
+		addCompdbCandidate(builds, compdbCandidate{entry: entry, rank: rank})
+
+		switch src.Ext() {
+		case ".cpp", ".cc", ".cxx", ".mm":
+			if headerLang != "c++-header" {
+				headerArgs, headerLang = entry.Arguments, "c++-header"
+			}
+		case ".c":
+			if headerLang == "" {
+				headerArgs, headerLang = entry.Arguments, "c-header"
+			}
+		}
+	}
+	if headerArgs == nil || headerGlobs == nil {
+		return
+	}
+
+	for _, header := range compdbModuleHeaders(ctx, ccModule, headerGlobs) {
+		lang := headerLang
+		if filepath.Ext(header) != ".h" {
+			lang = "c++-header"
+		}
+		args := append([]string{headerArgs[0], "-x", lang}, headerArgs[1:len(headerArgs)-1]...)
+		args = append(args, header)
+		addCompdbCandidate(builds, compdbCandidate{
+			entry: compDbEntry{
 				Directory: android.AbsSrcDirForExistingUseCases(),
-				Arguments: getArguments(src, ctx, ccModule, ccPath, cxxPath),
-				File:      src.String(),
+				Arguments: args,
+				File:      header,
+			},
+			rank:   rank,
+			header: true,
+		})
+	}
+}
+
+var compdbHeaderExtensions = []string{".h", ".hh", ".hpp", ".hxx"}
+
+// compdbModuleHeaders returns the headers in the include directories of the module that are in the
+// directory of the module, e.g. its local_include_dirs and export_include_dirs. Each directory is
+// globbed once for all the header extensions, and the globs are cached in headerGlobs as they are
+// the same for all the variants and the modules sharing include directories.
+func compdbModuleHeaders(ctx android.SingletonContext, ccModule *Module, headerGlobs map[string][]string) []string {
+	moduleDir := ctx.ModuleDir(ccModule)
+	outDir := android.PathForOutput(ctx).String()
+	var dirs []string
+	for _, flag := range ccModule.flags.Local.CommonFlags {
+		for _, arg := range strings.Fields(flag) {
+			if !strings.HasPrefix(arg, "-I") {
+				continue
+			}
+			dir := filepath.Clean(strings.TrimPrefix(arg, "-I"))
+			if dir == outDir || strings.HasPrefix(dir, outDir+"/") {
+				continue
+			}
+			if dir == moduleDir || strings.HasPrefix(dir, moduleDir+"/") ||
+				(moduleDir == "." && !filepath.IsAbs(dir) && !strings.HasPrefix(dir, "../")) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+
+	var headers []string
+	for _, dir := range android.FirstUniqueStrings(dirs) {
+		if _, ok := headerGlobs[dir]; !ok {
+			// *.h* matches all the header extensions, the other files are filtered out.
+			matches, err := ctx.GlobWithDeps(filepath.Join(dir, "**", "*.h*"), nil)
+			if err != nil {
+				ctx.Errorf("failed to glob the headers of %s: %s", dir, err)
+			}
+			var files []string
+			for _, match := range matches {
+				if android.InList(filepath.Ext(match), compdbHeaderExtensions) {
+					files = append(files, match)
+				}
 			}
+			headerGlobs[dir] = files
 		}
+		headers = append(headers, headerGlobs[dir]...)
 	}
+	return android.FirstUniqueStrings(headers)
 }
 
 func evalAndSplitVariable(ctx android.SingletonContext, str string) ([]string, error) {
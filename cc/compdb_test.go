@@ -0,0 +1,99 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestCompdbVariantsAndHeaders(t *testing.T) {
+	bp := `
+		cc_library_static {
+			name: "libfoo",
+			srcs: ["foo/foo.cpp"],
+			local_include_dirs: ["foo/include"],
+			stl: "none",
+			system_shared_libs: [],
+		}
+	`
+
+	entriesFor := func(t *testing.T, env map[string]string) map[string]compDbEntry {
+		t.Helper()
+		env[android.EnvVariableIdeWorkspaceModules] = "libfoo"
+		result := android.GroupFixturePreparers(
+			prepareForCcTest,
+			android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+				ctx.RegisterParallelSingletonType("compdb_generator", compDBGeneratorSingleton)
+			}),
+			android.FixtureAddFile("foo/foo.cpp", nil),
+			android.FixtureAddFile("foo/include/foo.h", nil),
+			android.FixtureAddFile("foo/include/bar/bar.hpp", nil),
+			android.FixtureAddFile("foo/include/doc.html", nil),
+			android.FixtureMergeEnv(env),
+		).RunTestWithBp(t, bp)
+
+		content, err := os.ReadFile(filepath.Join(result.Config.SoongOutDir(), android.IdeWorkspaceDir, compdbFilename))
+		if err != nil {
+			t.Fatalf("compile_commands.json has not been generated: %s", err)
+		}
+		var entries []compDbEntry
+		if err := json.Unmarshal(content, &entries); err != nil {
+			t.Fatalf("failed to parse compile_commands.json: %s", err)
+		}
+		m := make(map[string]compDbEntry)
+		for _, entry := range entries {
+			m[entry.File] = entry
+		}
+		return m
+	}
+
+	t.Run("default", func(t *testing.T) {
+		entries := entriesFor(t, map[string]string{})
+
+		src, ok := entries["foo/foo.cpp"]
+		if !ok {
+			t.Fatalf("no entry for foo/foo.cpp in %v", entries)
+		}
+		android.AssertStringDoesContain(t, "output of the primary arch", src.Output, "android_arm64_armv8-a_static")
+		android.AssertStringDoesContain(t, "object file", src.Output, "foo/foo.o")
+
+		header, ok := entries["foo/include/foo.h"]
+		if !ok {
+			t.Fatalf("no entry for foo/include/foo.h in %v", entries)
+		}
+		android.AssertStringEquals(t, "header file", "foo/include/foo.h", header.Arguments[len(header.Arguments)-1])
+		android.AssertStringDoesContain(t, "header language", strings.Join(header.Arguments, " "), "-x c++-header")
+		android.AssertStringEquals(t, "header output", "", header.Output)
+
+		if _, ok := entries["foo/include/bar/bar.hpp"]; !ok {
+			t.Errorf("no entry for foo/include/bar/bar.hpp in %v", entries)
+		}
+		if _, ok := entries["foo/include/doc.html"]; ok {
+			t.Errorf("unexpected entry for foo/include/doc.html, which isn't a header")
+		}
+	})
+
+	t.Run("arch", func(t *testing.T) {
+		entries := entriesFor(t, map[string]string{"SOONG_GEN_COMPDB_ARCH": "arm"})
+		android.AssertStringDoesContain(t, "output of the selected arch", entries["foo/foo.cpp"].Output,
+			"android_arm_armv7-a-neon_static")
+	})
+}
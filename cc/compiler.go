@@ -236,6 +236,9 @@ type baseCompiler struct {
 	// C/C++ (.aidl, .proto, etc.)
 	srcsBeforeGen android.Paths
 
+	// Object files of srcs, in the same order
+	objFiles android.Paths
+
 	generatedSourceInfo
 }
 
@@ -243,12 +246,18 @@ var _ compiler = (*baseCompiler)(nil)
 
 type CompiledInterface interface {
 	Srcs() android.Paths
+	ObjFiles() android.Paths
 }
 
 func (compiler *baseCompiler) Srcs() android.Paths {
 	return append(android.Paths{}, compiler.srcs...)
 }
 
+// ObjFiles returns the object files that Srcs are compiled to, in the same order.
+func (compiler *baseCompiler) ObjFiles() android.Paths {
+	return append(android.Paths{}, compiler.objFiles...)
+}
+
 func (compiler *baseCompiler) appendCflags(flags []string) {
 	compiler.Properties.Cflags = append(compiler.Properties.Cflags, flags...)
 }
@@ -738,6 +747,8 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 		return Objects{}
 	}
 
+	compiler.objFiles = objs.objFiles
+
 	return objs
 }
 
@@ -523,6 +523,10 @@ func init() {
 	pctx.PrefixedExistentPathsForSourcesVariable("RsGlobalIncludes", "-I", rsGlobalIncludes)
 	exportedVars.ExportStringList("RsGlobalIncludes", rsGlobalIncludes)
 
+	// include-what-you-use is not part of the clang prebuilts, IWYU_BINARY can point to a
+	// build of it that matches the clang version.
+	pctx.StaticVariableWithEnvOverride("IwyuCmd", "IWYU_BINARY", "${ClangBin}/include-what-you-use")
+
 	pctx.VariableFunc("CcWrapper", func(ctx android.PackageVarContext) string {
 		if override := ctx.Config().Getenv("CC_WRAPPER"); override != "" {
 			return override + " "
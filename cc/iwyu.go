@@ -0,0 +1,92 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+type IwyuProperties struct {
+	// whether to run include-what-you-use over C-like sources.
+	// Defaults to true if WITH_IWYU is set.
+	Iwyu *bool
+
+	// Extra flags to pass to include-what-you-use, such as --no_fwd_decls.
+	Iwyu_flags []string
+
+	// Mapping files (.imp) describing which headers provide which symbols.
+	Iwyu_mapping_files []string `android:"path"`
+}
+
+type iwyuFeature struct {
+	Properties IwyuProperties
+}
+
+func (iwyu *iwyuFeature) props() []interface{} {
+	return []interface{}{&iwyu.Properties}
+}
+
+func (iwyu *iwyuFeature) flags(ctx ModuleContext, flags Flags) Flags {
+	if !BoolDefault(iwyu.Properties.Iwyu, ctx.Config().IsEnvTrue("WITH_IWYU")) {
+		return flags
+	}
+	// Set flags.Iwyu to generate .iwyu rules. Like .tidy files, .iwyu files are
+	// only built with the iwyu-* phony targets.
+	flags.Iwyu = true
+
+	for _, f := range iwyu.Properties.Iwyu_flags {
+		flags.IwyuFlags = append(flags.IwyuFlags, "-Xiwyu", f)
+	}
+	flags.IwyuFlags = checkNinjaAndShellEscapeList(ctx, "iwyu_flags", flags.IwyuFlags)
+
+	mappingFiles := android.PathsForModuleSrc(ctx, iwyu.Properties.Iwyu_mapping_files)
+	for _, f := range mappingFiles {
+		flags.IwyuFlags = append(flags.IwyuFlags, "-Xiwyu", "--mapping_file="+f.String())
+	}
+	flags.IwyuDeps = append(flags.IwyuDeps, mappingFiles...)
+	return flags
+}
+
+func init() {
+	pctx.HostBinToolVariable("iwyuReportCmd", "iwyu_report")
+}
+
+// Rule for merging the include-what-you-use reports of all translation units.
+var iwyuReportMerge = pctx.AndroidStaticRule("iwyuReportMerge",
+	blueprint.RuleParams{
+		Command:        "$iwyuReportCmd merge --output $out --list $out.rsp",
+		CommandDeps:    []string{"$iwyuReportCmd"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: "$in",
+	})
+
+// Generate the iwyu-report phony target, which merges the include-what-you-use reports of
+// all translation units into out/soong/iwyu/report.json, with the total numbers of missing
+// and unused includes.
+func generateIwyuReportTarget(ctx android.SingletonContext, iwyuFiles android.Paths) {
+	if len(iwyuFiles) == 0 {
+		return
+	}
+	report := android.PathForOutput(ctx, "iwyu", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        iwyuReportMerge,
+		Description: "merge include-what-you-use reports",
+		Inputs:      android.SortedUniquePaths(iwyuFiles),
+		Output:      report,
+	})
+	ctx.Phony("iwyu-report", report)
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestIwyu(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.S"],
+			iwyu: true,
+			iwyu_flags: ["--no_fwd_decls"],
+			iwyu_mapping_files: ["foo.imp"],
+		}
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.c"],
+		}`
+
+	testCases := []struct {
+		name       string
+		withIwyu   bool
+		libbarIwyu bool
+	}{
+		{name: "property"},
+		{name: "WITH_IWYU", withIwyu: true, libbarIwyu: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := map[string]string{}
+			if tc.withIwyu {
+				env["WITH_IWYU"] = "true"
+			}
+			result := android.GroupFixturePreparers(
+				prepareForCcTest,
+				android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+					ctx.RegisterParallelSingletonType("tidy_phony_targets", TidyPhonySingleton)
+				}),
+				android.FixtureAddTextFile("foo.imp", ""),
+				android.FixtureMergeEnv(env),
+			).RunTestWithBp(t, bp)
+
+			variant := "android_arm64_armv8-a_shared"
+			objDir := "out/soong/.intermediates/libfoo/" + variant + "/obj/"
+			libfoo := result.ModuleForTests("libfoo", variant)
+			iwyu := libfoo.Output(objDir + "foo.iwyu")
+			android.AssertStringDoesContain(t, "iwyu flags", iwyu.Args["iwyuFlags"], "-Xiwyu --no_fwd_decls")
+			android.AssertStringDoesContain(t, "iwyu mapping file", iwyu.Args["iwyuFlags"], "-Xiwyu --mapping_file=foo.imp")
+			android.AssertPathsRelativeToTopEquals(t, "libfoo iwyu files",
+				[]string{objDir + "foo.iwyu"},
+				libfoo.Module().(*Module).iwyuFiles)
+			android.AssertBoolEquals(t, "libbar has iwyu files", tc.libbarIwyu,
+				len(result.ModuleForTests("libbar", variant).Module().(*Module).iwyuFiles) > 0)
+
+			report := result.SingletonForTests("tidy_phony_targets").Description("merge include-what-you-use reports")
+			android.AssertPathRelativeToTopEquals(t, "iwyu report", "out/soong/iwyu/report.json", report.Output)
+		})
+	}
+}
@@ -1159,6 +1159,13 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 		implicitOutputs = append(implicitOutputs, importLibraryPath)
 	}
 
+	// Stubs libraries do not link their dependencies.
+	if !library.buildStubs() {
+		var linkReportOutputs android.WritablePaths
+		flags, linkReportOutputs = library.baseLinker.addLinkReportFlags(ctx, flags)
+		implicitOutputs = append(implicitOutputs, linkReportOutputs...)
+	}
+
 	builderFlags := flagsToBuilderFlags(flags)
 
 	if ctx.Darwin() && deps.DarwinSecondArchOutput.Valid() {
@@ -1231,6 +1238,7 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs,
 		deps.StaticLibs, deps.LateStaticLibs, deps.WholeStaticLibs,
 		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, objs.tidyDepFiles)
+	library.baseLinker.setLinkReportOutput(outputFile)
 
 	objs.coverageFiles = append(objs.coverageFiles, deps.StaticLibObjs.coverageFiles...)
 	objs.coverageFiles = append(objs.coverageFiles, deps.WholeStaticLibObjs.coverageFiles...)
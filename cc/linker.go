@@ -265,6 +265,9 @@ type baseLinker struct {
 	}
 
 	sanitize *sanitize
+
+	// The files written by the link for the link reports, if any is enabled.
+	linkReport *linkReportFiles
 }
 
 func (linker *baseLinker) appendLdflags(flags []string) {
@@ -0,0 +1,279 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+type LinkerMapProperties struct {
+	// whether to write a linker map for a binary or shared library, and attribute its size to
+	// the static libraries and object files it is linked from. Defaults to true if
+	// WITH_LINKER_MAP is set.
+	Linker_map *bool
+}
+
+type linkerMapFeature struct {
+	Properties LinkerMapProperties
+}
+
+func (m *linkerMapFeature) props() []interface{} {
+	return []interface{}{&m.Properties}
+}
+
+func (m *linkerMapFeature) flags(ctx ModuleContext, flags Flags) Flags {
+	// The size attribution reads the linker map written by lld.
+	if !ctx.Os().Linux() {
+		return flags
+	}
+	flags.LinkerMap = BoolDefault(m.Properties.Linker_map, ctx.Config().IsEnvTrue("WITH_LINKER_MAP"))
+	return flags
+}
+
+func init() {
+	android.RegisterParallelSingletonType("link_sizes", linkSizesSingletonFactory)
+}
+
+// Rule for attributing the size of a link to its inputs.
+var linkSizesRule = pctx.AndroidStaticRule("linkSizes",
+	blueprint.RuleParams{
+		Command:     "$linkReportCmd sizes --config $in --output $out",
+		CommandDeps: []string{"$linkReportCmd"},
+	})
+
+// Rule for rolling up the size attributions of all modules by partition.
+var linkSizesMerge = pctx.AndroidStaticRule("linkSizesMerge",
+	blueprint.RuleParams{
+		Command:        "$linkReportCmd merge-sizes --output $out --list $out.rsp",
+		CommandDeps:    []string{"$linkReportCmd"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: "$in",
+	})
+
+// linkReportFiles holds the files written by a link for the size attribution, the unused
+// libraries check and the LTO diagnostics.
+type linkReportFiles struct {
+	output  android.Path
+	mapFile android.WritablePath
+	lto     bool
+
+	sizes bool
+
+	checkUnusedLibs                         bool
+	whyExtract                              android.WritablePath
+	sharedLibs, staticLibs, wholeStaticLibs []string
+
+	ltoDiagnostics bool
+	ltoCache       bool
+	timeTrace      android.WritablePath
+	// nil if the module has no bitcode sources, see hasBitcodeSources.
+	ltoStats android.WritablePath
+}
+
+// addLinkReportFlags makes the linker write a linker map if flags.LinkerMap or
+// flags.CheckUnusedLibs is set, the reasons it extracted archive members for the latter, and a
+// time trace and LTO statistics if flags.LtoDiagnostics is set. It returns the files tracked by
+// ninja as implicit outputs of the link, whose output must then be passed to
+// setLinkReportOutput.
+func (linker *baseLinker) addLinkReportFlags(ctx ModuleContext, flags Flags) (Flags, android.WritablePaths) {
+	if !flags.LinkerMap && !flags.CheckUnusedLibs && !flags.LtoDiagnostics {
+		return flags, nil
+	}
+	link := &linkReportFiles{
+		lto:   inList("-flto=thin", flags.Local.LdFlags),
+		sizes: flags.LinkerMap,
+	}
+	var outputs android.WritablePaths
+
+	if flags.LinkerMap || flags.CheckUnusedLibs {
+		link.mapFile = android.PathForModuleOut(ctx, "link.map")
+		flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,-Map="+link.mapFile.String())
+		outputs = append(outputs, link.mapFile)
+	}
+
+	if flags.CheckUnusedLibs {
+		link.checkUnusedLibs = true
+		link.whyExtract = android.PathForModuleOut(ctx, "unused_libs", "why_extract.txt")
+		// Libraries whose headers are reexported are needed by the users of the module even
+		// if the module does not use their symbols.
+		link.sharedLibs = android.RemoveListFromList(android.FirstUniqueStrings(linker.Properties.Shared_libs),
+			linker.Properties.Export_shared_lib_headers)
+		link.staticLibs = android.RemoveListFromList(android.FirstUniqueStrings(linker.Properties.Static_libs),
+			linker.Properties.Export_static_lib_headers)
+		link.wholeStaticLibs = android.RemoveListFromList(android.FirstUniqueStrings(linker.Properties.Whole_static_libs),
+			linker.Properties.Export_static_lib_headers)
+		flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--why-extract="+link.whyExtract.String())
+		outputs = append(outputs, link.whyExtract)
+	}
+
+	if flags.LtoDiagnostics {
+		link.ltoDiagnostics = true
+		link.ltoCache = flags.ThinLtoCache
+		link.timeTrace = android.PathForModuleOut(ctx, "lto", "time_trace.json")
+		flags.Local.LdFlags = append(flags.Local.LdFlags,
+			"-Wl,--time-trace",
+			"-Wl,--time-trace-file="+link.timeTrace.String())
+		outputs = append(outputs, link.timeTrace)
+
+		// lld only writes the statistics if it runs LTO, which needs bitcode inputs. Only
+		// request them, so that they are always written when they are an output of the link,
+		// if the module compiles C or C++ sources, whose objects are bitcode.
+		if hasBitcodeSources(ctx) {
+			link.ltoStats = android.PathForModuleOut(ctx, "lto", "stats.json")
+			flags.Local.LdFlags = append(flags.Local.LdFlags,
+				"-Wl,--plugin-opt=stats-file="+link.ltoStats.String())
+			outputs = append(outputs, link.ltoStats)
+		}
+	}
+
+	linker.linkReport = link
+	return flags, outputs
+}
+
+// hasBitcodeSources returns true if the module compiles C or C++ sources, whose objects are
+// bitcode when the module uses LTO.
+func hasBitcodeSources(ctx ModuleContext) bool {
+	if c, ok := ctx.Module().(*Module); ok {
+		if compiler, ok := c.compiler.(CompiledInterface); ok {
+			for _, src := range compiler.Srcs() {
+				switch src.Ext() {
+				case ".c", ".cpp", ".cc", ".cxx", ".mm":
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (linker *baseLinker) setLinkReportOutput(output android.Path) {
+	if linker.linkReport != nil {
+		linker.linkReport.output = output
+	}
+}
+
+func (linker *baseLinker) getLinkReportFiles() *linkReportFiles {
+	return linker.linkReport
+}
+
+type linkReportLinker interface {
+	getLinkReportFiles() *linkReportFiles
+}
+
+// linkReport returns the files written by the link of the module for the link reports, or nil
+// if none is enabled.
+func (c *Module) linkReport() *linkReportFiles {
+	if l, ok := c.linker.(linkReportLinker); ok {
+		if link := l.getLinkReportFiles(); link != nil && link.output != nil {
+			return link
+		}
+	}
+	return nil
+}
+
+type linkReportStaticLib struct {
+	Name    string   `json:"name"`
+	Archive string   `json:"archive"`
+	Objects []string `json:"objects,omitempty"`
+}
+
+// linkReportLibName returns the name a library dependency was listed with in the properties.
+func linkReportLibName(depName string) string {
+	name := BaseLibName(depName)
+	return strings.TrimSuffix(name, ndkLibrarySuffix)
+}
+
+// The JSON config read by link_report sizes.
+type linkSizesConfig struct {
+	Module          string                `json:"module"`
+	Variant         string                `json:"variant"`
+	Partition       string                `json:"partition"`
+	Map             string                `json:"map"`
+	Lto             bool                  `json:"lto"`
+	Objects         []string              `json:"objects"`
+	WholeStaticLibs []linkReportStaticLib `json:"whole_static_libs"`
+}
+
+// attributeLinkSizes generates a rule that attributes the size of the output of the link to the
+// module itself and to the static libraries and object files it was linked from.
+func (c *Module) attributeLinkSizes(ctx ModuleContext, deps PathDeps, objs Objects) {
+	link := c.linkReport()
+	if link == nil || !link.sizes {
+		return
+	}
+
+	partition := "host"
+	if ctx.Device() {
+		partition = c.PartitionTag(ctx.DeviceConfig())
+	}
+	config := linkSizesConfig{
+		Module:    ctx.ModuleName(),
+		Variant:   ctx.ModuleSubDir(),
+		Partition: partition,
+		Map:       link.mapFile.String(),
+		Lto:       link.lto,
+		Objects:   append(objs.objFiles.Strings(), deps.Objs.objFiles.Strings()...),
+	}
+	// Other static libraries are attributed by the names of their archives, but the objects of
+	// whole static libraries may be linked directly.
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok || !tag.static() || !tag.wholeStatic || !ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider) {
+			return
+		}
+		info := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
+		config.WholeStaticLibs = append(config.WholeStaticLibs, linkReportStaticLib{
+			Name:    linkReportLibName(ctx.OtherModuleName(dep)),
+			Archive: info.StaticLibrary.String(),
+			Objects: info.Objects.objFiles.Strings(),
+		})
+	})
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to write the link sizes config: %s", err)
+		return
+	}
+	configFile := android.PathForModuleOut(ctx, "link_sizes", "config.json")
+	android.WriteFileRule(ctx, configFile, string(data))
+
+	report := android.PathForModuleOut(ctx, "link_sizes", "sizes.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        linkSizesRule,
+		Description: "attribute link sizes " + link.output.Base(),
+		Input:       configFile,
+		Implicit:    link.mapFile,
+		Output:      report,
+	})
+	c.linkSizesReport = android.OptionalPathForPath(report)
+	ctx.Phony(ctx.ModuleName()+"-link-sizes", report)
+}
+
+// linkSizesSingletonFactory returns the singleton generating the link-sizes phony target, which
+// rolls up the size attributions of all modules by partition into out/soong/link_sizes/report.json.
+func linkSizesSingletonFactory() android.Singleton {
+	return &mergeReportsSingleton{
+		rule:        linkSizesMerge,
+		description: "merge link sizes",
+		dir:         "link_sizes",
+		phony:       "link-sizes",
+		report:      func(m *Module) android.OptionalPath { return m.linkSizesReport },
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestLinkerMap(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			static_libs: ["libstatic"],
+			whole_static_libs: ["libwhole"],
+			linker_map: true,
+		}
+		cc_library_static {
+			name: "libstatic",
+			srcs: ["static.c"],
+		}
+		cc_library_static {
+			name: "libwhole",
+			srcs: ["whole.c"],
+		}
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.c"],
+		}`
+
+	testCases := []struct {
+		name          string
+		withLinkerMap bool
+	}{
+		{name: "property"},
+		{name: "WITH_LINKER_MAP", withLinkerMap: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := map[string]string{}
+			if tc.withLinkerMap {
+				env["WITH_LINKER_MAP"] = "true"
+			}
+			result := android.GroupFixturePreparers(
+				prepareForCcTest,
+				android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+					ctx.RegisterParallelSingletonType("link_sizes", linkSizesSingletonFactory)
+				}),
+				android.FixtureMergeEnv(env),
+			).RunTestWithBp(t, bp)
+
+			variant := "android_arm64_armv8-a"
+			fooDir := "out/soong/.intermediates/foo/" + variant + "/"
+			foo := result.ModuleForTests("foo", variant)
+
+			link := foo.Output(fooDir + "link.map")
+			android.AssertStringDoesContain(t, "link map flag", link.Args["ldFlags"], "-Wl,-Map="+fooDir+"link.map")
+			android.AssertStringDoesNotContain(t, "why extract flag", link.Args["ldFlags"], "--why-extract")
+			android.AssertPathsRelativeToTopEquals(t, "link implicit outputs",
+				[]string{fooDir + "link.map"}, link.ImplicitOutputs.Paths())
+
+			var config linkSizesConfig
+			content := android.ContentFromFileRuleForTests(t, result.TestContext, foo.Output(fooDir+"link_sizes/config.json"))
+			if err := json.Unmarshal([]byte(content), &config); err != nil {
+				t.Fatal(err)
+			}
+			android.AssertStringEquals(t, "module", "foo", config.Module)
+			android.AssertStringEquals(t, "partition", "system", config.Partition)
+			android.AssertStringListContains(t, "objects",
+				android.StringsRelativeToTop(result.Config, config.Objects), fooDir+"obj/foo.o")
+			android.AssertIntEquals(t, "whole static libs", 1, len(config.WholeStaticLibs))
+			android.AssertStringEquals(t, "whole static lib", "libwhole", config.WholeStaticLibs[0].Name)
+
+			sizes := foo.Output(fooDir + "link_sizes/sizes.json")
+			android.AssertPathRelativeToTopEquals(t, "sizes input", fooDir+"link_sizes/config.json", sizes.Input)
+			android.AssertPathRelativeToTopEquals(t, "sizes linker map", fooDir+"link.map", sizes.Implicit)
+
+			libbar := result.ModuleForTests("libbar", variant+"_shared").Module().(*Module)
+			android.AssertBoolEquals(t, "libbar has sizes", tc.withLinkerMap, libbar.linkSizesReport.Valid())
+
+			merge := result.SingletonForTests("link_sizes").Description("merge link sizes")
+			android.AssertPathRelativeToTopEquals(t, "merged sizes", "out/soong/link_sizes/report.json", merge.Output)
+		})
+	}
+}
@@ -15,8 +15,11 @@
 package cc
 
 import (
+	"encoding/json"
+
 	"android/soong/android"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -38,12 +41,37 @@ import (
 // This file adds support to soong to automatically propagate LTO options to a
 // new variant of all static dependencies for each module with LTO enabled.
 
+func init() {
+	android.RegisterParallelSingletonType("lto_metrics", ltoMetricsSingletonFactory)
+}
+
+// Rule for reporting the LTO diagnostics of a link from its time trace and statistics.
+var ltoStatsRule = pctx.AndroidStaticRule("ltoStats",
+	blueprint.RuleParams{
+		Command:     "$linkReportCmd lto-stats --config $in --output $out",
+		CommandDeps: []string{"$linkReportCmd"},
+	})
+
+// Rule for summing the LTO diagnostics of all links.
+var ltoStatsMerge = pctx.AndroidStaticRule("ltoStatsMerge",
+	blueprint.RuleParams{
+		Command:        "$linkReportCmd merge-lto-stats --output $out --list $out.rsp",
+		CommandDeps:    []string{"$linkReportCmd"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: "$in",
+	})
+
 type LTOProperties struct {
 	// Lto must violate capitalization style for acronyms so that it can be
 	// referred to in blueprint files as "lto"
 	Lto struct {
 		Never *bool `android:"arch_variant"`
 		Thin  *bool `android:"arch_variant"`
+
+		// Whether to report the time spent in LTO by the link of a binary or shared library,
+		// and the functions imported across modules by ThinLTO. Defaults to true if
+		// LTO_DIAGNOSTICS is set.
+		Diagnostics *bool `android:"arch_variant"`
 	} `android:"arch_variant"`
 
 	LtoEnabled bool `blueprint:"mutated"`
@@ -132,10 +160,26 @@ func (lto *lto) flags(ctx BaseModuleContext, flags Flags) Flags {
 			ltoLdFlags = append(ltoLdFlags, cacheDirFormat+cacheDir)
 
 			// Limit the size of the ThinLTO cache to the lesser of 10% of available
-			// disk space and 10GB.
+			// disk space and 10GB, or THINLTO_CACHE_SIZE_BYTES. The expiration of the
+			// entries and the interval between prunings default to the lld ones of a
+			// week and 20 minutes.
 			cachePolicyFormat := "-Wl,--thinlto-cache-policy="
-			policy := "cache_size=10%:cache_size_bytes=10g"
+			policy := "cache_size=10%:cache_size_bytes=" +
+				ctx.Config().GetenvWithDefault("THINLTO_CACHE_SIZE_BYTES", "10g")
+			if pruneAfter := ctx.Config().Getenv("THINLTO_CACHE_PRUNE_AFTER"); pruneAfter != "" {
+				policy += ":prune_after=" + pruneAfter
+			}
+			if pruneInterval := ctx.Config().Getenv("THINLTO_CACHE_PRUNE_INTERVAL"); pruneInterval != "" {
+				policy += ":prune_interval=" + pruneInterval
+			}
 			ltoLdFlags = append(ltoLdFlags, cachePolicyFormat+policy)
+			flags.ThinLtoCache = true
+		}
+
+		// The diagnostics are read from the time trace written by lld.
+		if ctx.Os().Linux() {
+			flags.LtoDiagnostics = proptools.BoolDefault(lto.Properties.Lto.Diagnostics,
+				ctx.Config().IsEnvTrue("LTO_DIAGNOSTICS"))
 		}
 
 		// Reduce the inlining threshold for a better balance of binary size and
@@ -259,3 +303,66 @@ func ltoMutator(mctx android.BottomUpMutatorContext) {
 		}
 	}
 }
+
+// The JSON config read by link_report lto-stats.
+type ltoStatsConfig struct {
+	Module    string `json:"module"`
+	Variant   string `json:"variant"`
+	Cache     bool   `json:"cache"`
+	TimeTrace string `json:"time_trace"`
+	Stats     string `json:"stats"`
+}
+
+// reportLtoStats generates a rule that reports the time spent in LTO by the link of the module
+// and the functions it imported across modules.
+func (c *Module) reportLtoStats(ctx ModuleContext) {
+	link := c.linkReport()
+	if link == nil || !link.ltoDiagnostics {
+		return
+	}
+
+	config := ltoStatsConfig{
+		Module:    ctx.ModuleName(),
+		Variant:   ctx.ModuleSubDir(),
+		Cache:     link.ltoCache,
+		TimeTrace: link.timeTrace.String(),
+	}
+	implicits := android.Paths{link.timeTrace}
+	if link.ltoStats != nil {
+		config.Stats = link.ltoStats.String()
+		implicits = append(implicits, link.ltoStats)
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to write the LTO stats config: %s", err)
+		return
+	}
+	configFile := android.PathForModuleOut(ctx, "lto", "config.json")
+	android.WriteFileRule(ctx, configFile, string(data))
+
+	report := android.PathForModuleOut(ctx, "lto", "lto_stats.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        ltoStatsRule,
+		Description: "report LTO stats " + link.output.Base(),
+		Input:       configFile,
+		Implicits:   implicits,
+		Output:      report,
+	})
+	c.ltoStatsReport = android.OptionalPathForPath(report)
+	ctx.Phony(ctx.ModuleName()+"-lto-stats", report)
+}
+
+// ltoMetricsSingletonFactory returns the singleton generating the lto-metrics phony target, which
+// sums the LTO diagnostics of all links into out/soong/lto_metrics/report.json. The report is
+// copied to the dist directory by dist builds of lto-metrics or droidcore; it is a separate file,
+// not part of the soong_build_metrics written by soong_build.
+func ltoMetricsSingletonFactory() android.Singleton {
+	return &mergeReportsSingleton{
+		rule:        ltoStatsMerge,
+		description: "merge LTO stats",
+		dir:         "lto_metrics",
+		phony:       "lto-metrics",
+		distGoals:   []string{"lto-metrics", "droidcore"},
+		report:      func(m *Module) android.OptionalPath { return m.ltoStatsReport },
+	}
+}
@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 
@@ -222,3 +223,113 @@ func TestLtoDoesNotPropagateToRuntimeLibs(t *testing.T) {
 	android.AssertStringDoesNotContain(t, "got flag for LTO in runtime_lib",
 		libBar.Args["ldFlags"], "-flto=thin")
 }
+
+func TestLtoCacheAndDiagnostics(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_binary {
+		name: "foo",
+		srcs: ["foo.c"],
+		lto: {
+			diagnostics: true,
+		},
+	}
+
+	cc_binary {
+		name: "baz",
+		srcs: ["baz.S"],
+		lto: {
+			diagnostics: true,
+		},
+	}
+
+	cc_library_shared {
+		name: "libbar",
+		srcs: ["bar.c"],
+	}`
+
+	testCases := []struct {
+		name            string
+		env             map[string]string
+		wantPolicy      string
+		wantLibbarStats bool
+	}{
+		{
+			name: "default policy",
+			env: map[string]string{
+				"USE_THINLTO_CACHE": "true",
+			},
+			wantPolicy: "cache_size=10%:cache_size_bytes=10g",
+		},
+		{
+			name: "env",
+			env: map[string]string{
+				"USE_THINLTO_CACHE":            "true",
+				"THINLTO_CACHE_SIZE_BYTES":     "2g",
+				"THINLTO_CACHE_PRUNE_AFTER":    "72h",
+				"THINLTO_CACHE_PRUNE_INTERVAL": "1h",
+				"LTO_DIAGNOSTICS":              "true",
+			},
+			wantPolicy:      "cache_size=10%:cache_size_bytes=2g:prune_after=72h:prune_interval=1h",
+			wantLibbarStats: true,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			result := android.GroupFixturePreparers(
+				LTOPreparer,
+				android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+					ctx.RegisterParallelSingletonType("lto_metrics", ltoMetricsSingletonFactory)
+				}),
+				android.FixtureMergeEnv(tc.env),
+			).RunTestWithBp(t, bp)
+
+			variant := "android_arm64_armv8-a"
+			fooDir := "out/soong/.intermediates/foo/" + variant + "/"
+			foo := result.ModuleForTests("foo", variant)
+
+			link := foo.Rule("ld")
+			ldFlags := link.Args["ldFlags"]
+			android.AssertStringDoesContain(t, "cache dir", ldFlags, "-Wl,--thinlto-cache-dir=out/soong/thinlto-cache")
+			android.AssertStringDoesContain(t, "cache policy", ldFlags, "-Wl,--thinlto-cache-policy="+tc.wantPolicy)
+			android.AssertStringDoesContain(t, "time trace", ldFlags, "-Wl,--time-trace-file="+fooDir+"lto/time_trace.json")
+			android.AssertStringDoesContain(t, "stats file", ldFlags, "-Wl,--plugin-opt=stats-file="+fooDir+"lto/stats.json")
+			android.AssertStringDoesNotContain(t, "linker map", ldFlags, "-Wl,-Map=")
+			android.AssertPathsRelativeToTopEquals(t, "link implicit outputs",
+				[]string{fooDir + "lto/time_trace.json", fooDir + "lto/stats.json"}, link.ImplicitOutputs.Paths())
+
+			var config ltoStatsConfig
+			content := android.ContentFromFileRuleForTests(t, result.TestContext, foo.Output(fooDir+"lto/config.json"))
+			if err := json.Unmarshal([]byte(content), &config); err != nil {
+				t.Fatal(err)
+			}
+			android.AssertStringEquals(t, "module", "foo", config.Module)
+			android.AssertBoolEquals(t, "cache", true, config.Cache)
+
+			stats := foo.Output(fooDir + "lto/lto_stats.json")
+			android.AssertPathRelativeToTopEquals(t, "stats input", fooDir+"lto/config.json", stats.Input)
+			android.AssertPathsRelativeToTopEquals(t, "stats implicits",
+				[]string{fooDir + "lto/time_trace.json", fooDir + "lto/stats.json"}, stats.Implicits)
+
+			// lld does not write the statistics of links without bitcode inputs.
+			bazDir := "out/soong/.intermediates/baz/" + variant + "/"
+			baz := result.ModuleForTests("baz", variant)
+			bazLink := baz.Rule("ld")
+			android.AssertStringDoesNotContain(t, "baz stats file", bazLink.Args["ldFlags"], "stats-file=")
+			android.AssertPathsRelativeToTopEquals(t, "baz link implicit outputs",
+				[]string{bazDir + "lto/time_trace.json"}, bazLink.ImplicitOutputs.Paths())
+			android.AssertPathsRelativeToTopEquals(t, "baz stats implicits",
+				[]string{bazDir + "lto/time_trace.json"}, baz.Output(bazDir+"lto/lto_stats.json").Implicits)
+
+			libbar := result.ModuleForTests("libbar", variant+"_shared").Module().(*Module)
+			android.AssertBoolEquals(t, "libbar has LTO stats", tc.wantLibbarStats, libbar.ltoStatsReport.Valid())
+			libbarFlags := result.ModuleForTests("libbar", variant+"_shared").Rule("ld").Args["ldFlags"]
+			android.AssertStringDoesContain(t, "libbar uses the cache", libbarFlags, "--thinlto-cache-dir=")
+
+			merge := result.SingletonForTests("lto_metrics").Description("merge LTO stats")
+			android.AssertPathRelativeToTopEquals(t, "merged LTO stats", "out/soong/lto_metrics/report.json", merge.Output)
+		})
+	}
+}
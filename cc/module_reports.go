@@ -0,0 +1,67 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// mergeReportsSingleton merges a report of all the modules that have one, e.g. their unused
+// libraries, into out/soong/<dir>/report.json, built by a phony target.
+type mergeReportsSingleton struct {
+	rule        blueprint.Rule
+	description string
+	dir         string
+	phony       string
+
+	// The goals of the dist builds that copy the merged report to the dist directory, if any.
+	distGoals []string
+
+	// report returns the report of the module, if it has one.
+	report func(m *Module) android.OptionalPath
+
+	outputPath android.Path
+}
+
+func (s *mergeReportsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if m, ok := module.(*Module); ok && s.report(m).Valid() {
+			reports = append(reports, s.report(m).Path())
+		}
+	})
+	if len(reports) == 0 {
+		return
+	}
+	report := android.PathForOutput(ctx, s.dir, "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        s.rule,
+		Description: s.description,
+		Inputs:      android.SortedUniquePaths(reports),
+		Output:      report,
+	})
+	ctx.Phony(s.phony, report)
+	s.outputPath = report
+}
+
+func (s *mergeReportsSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.outputPath == nil || len(s.distGoals) == 0 {
+		return
+	}
+
+	ctx.DistForGoals(s.distGoals, s.outputPath)
+}
@@ -73,6 +73,9 @@ type OrderfileProperties struct {
 
 type orderfile struct {
 	Properties OrderfileProperties
+
+	// The orderfile the module is linked with, if it loads one
+	loadedOrderfile android.OptionalPath
 }
 
 func (props *OrderfileProperties) shouldInstrument() bool {
@@ -134,8 +137,7 @@ func (props *OrderfileProperties) loadOrderfileFlags(ctx ModuleContext, file str
 	return flags
 }
 
-func (props *OrderfileProperties) addLoadFlags(ctx ModuleContext, flags Flags) Flags {
-	orderFile := props.getOrderfile(ctx)
+func (props *OrderfileProperties) addLoadFlags(ctx ModuleContext, flags Flags, orderFile android.OptionalPath) Flags {
 	orderFilePath := orderFile.Path()
 	loadFlags := props.loadOrderfileFlags(ctx, orderFilePath.String())
 
@@ -177,7 +179,8 @@ func (orderfile *orderfile) flags(ctx ModuleContext, flags Flags) Flags {
 	props := orderfile.Properties
 	// Add flags to load the orderfile using the path in its Android.bp
 	if orderfile.Properties.OrderfileLoad {
-		flags = props.addLoadFlags(ctx, flags)
+		orderfile.loadedOrderfile = props.getOrderfile(ctx)
+		flags = props.addLoadFlags(ctx, flags, orderfile.loadedOrderfile)
 		return flags
 	}
 
@@ -0,0 +1,180 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// The AFDO profile and the orderfile of a module are collected from an earlier build of the
+// module. As its sources change, functions of the profiles are renamed or removed, and the
+// profiles silently lose their effect. The coverage of a profile is the fraction of its
+// functions or symbols that are still defined by the linked binary or shared library.
+
+// defaultProfileCoverageThreshold is the threshold used unless PROFILE_COVERAGE_THRESHOLD is set.
+const defaultProfileCoverageThreshold = 0.8
+
+func init() {
+	android.RegisterParallelSingletonType("profile_coverage", profileCoverageSingletonFactory)
+}
+
+var (
+	// Rule for listing the symbols defined by a linked binary or shared library.
+	profileSymbolsRule = pctx.AndroidStaticRule("profileSymbols",
+		blueprint.RuleParams{
+			Command:        "${config.ClangBin}/llvm-nm -P --defined-only @$out.rsp > $out.tmp && mv $out.tmp $out",
+			CommandDeps:    []string{"${config.ClangBin}/llvm-nm"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+
+	// Rule for converting an AFDO profile to the text format.
+	afdoProfileTextRule = pctx.AndroidStaticRule("afdoProfileText",
+		blueprint.RuleParams{
+			Command:     "${config.ClangBin}/llvm-profdata merge --sample --text $in -o $out",
+			CommandDeps: []string{"${config.ClangBin}/llvm-profdata"},
+		})
+
+	// Rule for computing the coverage of the profiles of a module.
+	profileCoverageRule = pctx.AndroidStaticRule("profileCoverage",
+		blueprint.RuleParams{
+			Command:     "$linkReportCmd profile-coverage --config $in --output $out",
+			CommandDeps: []string{"$linkReportCmd"},
+		})
+
+	// Rule for listing the stale profiles of all modules.
+	profileCoverageMerge = pctx.AndroidStaticRule("profileCoverageMerge",
+		blueprint.RuleParams{
+			Command:        "$linkReportCmd merge-profile-coverage --output $out --list $out.rsp",
+			CommandDeps:    []string{"$linkReportCmd"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+)
+
+// The JSON config read by link_report profile-coverage.
+type profileCoverageConfig struct {
+	Module      string  `json:"module"`
+	Variant     string  `json:"variant"`
+	Symbols     string  `json:"symbols"`
+	AfdoProfile string  `json:"afdo_profile,omitempty"`
+	AfdoText    string  `json:"afdo_text,omitempty"`
+	Orderfile   string  `json:"orderfile,omitempty"`
+	Threshold   float64 `json:"threshold"`
+}
+
+// profileCoverageThreshold returns the coverage below which the profiles of the module are
+// reported as stale.
+func profileCoverageThreshold(ctx ModuleContext) float64 {
+	value := ctx.Config().Getenv("PROFILE_COVERAGE_THRESHOLD")
+	if value == "" {
+		return defaultProfileCoverageThreshold
+	}
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		ctx.ModuleErrorf("PROFILE_COVERAGE_THRESHOLD must be a number between 0 and 1, got %q", value)
+		return defaultProfileCoverageThreshold
+	}
+	return threshold
+}
+
+// reportProfileCoverage generates a rule that computes the coverage of the AFDO profile and the
+// orderfile the module is linked with, if any. The symbols are read from the unstripped output of
+// the link, as the static libraries of the link define many functions it doesn't include.
+func (c *Module) reportProfileCoverage(ctx ModuleContext) {
+	if ctx.static() && !ctx.staticBinary() {
+		return
+	}
+	afdoProfile := c.afdo.profile(ctx)
+	var orderfile android.OptionalPath
+	if c.orderfile != nil {
+		orderfile = c.orderfile.loadedOrderfile
+	}
+	if !afdoProfile.Valid() && !orderfile.Valid() {
+		return
+	}
+
+	symbols := android.PathForModuleOut(ctx, "profile_coverage", "symbols.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        profileSymbolsRule,
+		Description: "list profiled symbols " + ctx.ModuleName(),
+		Input:       c.UnstrippedOutputFile(),
+		Output:      symbols,
+	})
+
+	config := profileCoverageConfig{
+		Module:    ctx.ModuleName(),
+		Variant:   ctx.ModuleSubDir(),
+		Symbols:   symbols.String(),
+		Threshold: profileCoverageThreshold(ctx),
+	}
+	implicits := android.Paths{symbols}
+	if afdoProfile.Valid() {
+		afdoText := android.PathForModuleOut(ctx, "profile_coverage", "afdo.txt")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        afdoProfileTextRule,
+			Description: "convert AFDO profile " + afdoProfile.Path().Base(),
+			Input:       afdoProfile.Path(),
+			Output:      afdoText,
+		})
+		config.AfdoProfile = afdoProfile.Path().String()
+		config.AfdoText = afdoText.String()
+		implicits = append(implicits, afdoText)
+	}
+	if orderfile.Valid() {
+		config.Orderfile = orderfile.Path().String()
+		implicits = append(implicits, orderfile.Path())
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to write the profile coverage config: %s", err)
+		return
+	}
+	configFile := android.PathForModuleOut(ctx, "profile_coverage", "config.json")
+	android.WriteFileRule(ctx, configFile, string(data))
+
+	report := android.PathForModuleOut(ctx, "profile_coverage", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        profileCoverageRule,
+		Description: "profile coverage " + ctx.ModuleName(),
+		Input:       configFile,
+		Implicits:   implicits,
+		Output:      report,
+	})
+	c.profileCoverageReport = android.OptionalPathForPath(report)
+	ctx.Phony(ctx.ModuleName()+"-profile-coverage", report)
+}
+
+// profileCoverageSingletonFactory returns the singleton generating the profile-coverage phony
+// target, which lists the stale profiles of all modules in out/soong/profile_coverage/report.json.
+// The report is copied to the dist directory by dist builds of profile-coverage. It is not built
+// by default, as it runs llvm-nm and llvm-profdata for every module with a profile, and is a
+// separate file, not part of the soong_build_metrics written by soong_build.
+func profileCoverageSingletonFactory() android.Singleton {
+	return &mergeReportsSingleton{
+		rule:        profileCoverageMerge,
+		description: "merge profile coverage",
+		dir:         "profile_coverage",
+		phony:       "profile-coverage",
+		distGoals:   []string{"profile-coverage"},
+		report:      func(m *Module) android.OptionalPath { return m.profileCoverageReport },
+	}
+}
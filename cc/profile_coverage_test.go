@@ -0,0 +1,122 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestProfileCoverage(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_library_shared {
+		name: "libTest",
+		srcs: ["test.c"],
+		static_libs: ["libFoo"],
+		afdo: true,
+		orderfile: {
+			instrumentation: true,
+			load_order_file: true,
+			order_file_path: "libTest.orderfile",
+		},
+	}
+
+	cc_library_static {
+		name: "libFoo",
+		srcs: ["foo.c"],
+	}
+
+	cc_library_shared {
+		name: "libBar",
+		srcs: ["bar.c"],
+	}
+	`
+
+	testCases := []struct {
+		name          string
+		env           map[string]string
+		wantThreshold float64
+	}{
+		{name: "default threshold", wantThreshold: 0.8},
+		{
+			name:          "PROFILE_COVERAGE_THRESHOLD",
+			env:           map[string]string{"PROFILE_COVERAGE_THRESHOLD": "0.5"},
+			wantThreshold: 0.5,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			result := android.GroupFixturePreparers(
+				PrepareForTestWithFdoProfile,
+				prepareForCcTest,
+				android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+					ctx.RegisterParallelSingletonType("profile_coverage", profileCoverageSingletonFactory)
+				}),
+				android.FixtureAddTextFile("afdo_profiles_package/libTest.afdo", ""),
+				android.FixtureAddTextFile("toolchain/pgo-profiles/orderfiles/libTest.orderfile", "TEST"),
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.AfdoProfiles = []string{
+						"libTest://afdo_profiles_package:libTest_afdo",
+					}
+				}),
+				android.MockFS{
+					"afdo_profiles_package/Android.bp": []byte(`
+						fdo_profile {
+							name: "libTest_afdo",
+							profile: "libTest.afdo",
+						}
+					`),
+				}.AddToFixture(),
+				android.FixtureMergeEnv(tc.env),
+			).RunTestWithBp(t, bp)
+
+			variant := "android_arm64_armv8-a_shared"
+			testDir := "out/soong/.intermediates/libTest/" + variant + "/"
+			libTest := result.ModuleForTests("libTest", variant)
+
+			symbols := libTest.Output(testDir + "profile_coverage/symbols.txt")
+			android.AssertPathRelativeToTopEquals(t, "symbols input", testDir+"unstripped/libTest.so", symbols.Input)
+
+			afdoText := libTest.Output(testDir + "profile_coverage/afdo.txt")
+			android.AssertPathRelativeToTopEquals(t, "AFDO profile", "afdo_profiles_package/libTest.afdo", afdoText.Input)
+
+			var config profileCoverageConfig
+			content := android.ContentFromFileRuleForTests(t, result.TestContext, libTest.Output(testDir+"profile_coverage/config.json"))
+			if err := json.Unmarshal([]byte(content), &config); err != nil {
+				t.Fatal(err)
+			}
+			android.AssertStringEquals(t, "module", "libTest", config.Module)
+			android.AssertStringEquals(t, "AFDO profile", "afdo_profiles_package/libTest.afdo", config.AfdoProfile)
+			android.AssertStringEquals(t, "orderfile", "toolchain/pgo-profiles/orderfiles/libTest.orderfile", config.Orderfile)
+			if config.Threshold != tc.wantThreshold {
+				t.Errorf("want threshold %v, got %v", tc.wantThreshold, config.Threshold)
+			}
+
+			report := libTest.Output(testDir + "profile_coverage/report.json")
+			android.AssertPathRelativeToTopEquals(t, "report input", testDir+"profile_coverage/config.json", report.Input)
+
+			libBar := result.ModuleForTests("libBar", variant).Module().(*Module)
+			android.AssertBoolEquals(t, "libBar has profile coverage", false, libBar.profileCoverageReport.Valid())
+
+			merge := result.SingletonForTests("profile_coverage").Description("merge profile coverage")
+			android.AssertPathRelativeToTopEquals(t, "merged profile coverage", "out/soong/profile_coverage/report.json", merge.Output)
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
@@ -76,10 +77,13 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 	if tidy.Properties.Tidy != nil && !*tidy.Properties.Tidy {
 		return flags
 	}
+	// Modules listed in TIDY_EXPORT_FIXES run clang-tidy and export its fixes,
+	// even in directories that have clang-tidy disabled by default.
+	exportFixes := android.InList(ctx.ModuleName(), strings.Split(ctx.Config().Getenv("TIDY_EXPORT_FIXES"), ","))
 	// Some projects like external/* and vendor/* have clang-tidy disabled by default,
 	// unless they are enabled explicitly with the "tidy:true" property or
 	// when TIDY_EXTERNAL_VENDOR is set to true.
-	if !proptools.Bool(tidy.Properties.Tidy) &&
+	if !proptools.Bool(tidy.Properties.Tidy) && !exportFixes &&
 		config.NoClangTidyForDir(
 			ctx.Config().IsEnvTrue("TIDY_EXTERNAL_VENDOR"),
 			ctx.ModuleDir()) {
@@ -89,6 +93,7 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 	// Note that libraries and binaries will depend on .tidy files ONLY if
 	// the global WITH_TIDY or module 'tidy' property is true.
 	flags.Tidy = true
+	flags.TidyExportFixes = exportFixes
 
 	// If explicitly enabled, by global WITH_TIDY or local tidy:true property,
 	// set flags.NeedTidyFiles to make this module depend on .tidy files.
@@ -201,24 +206,37 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 
 func init() {
 	android.RegisterParallelSingletonType("tidy_phony_targets", TidyPhonySingleton)
+
+	pctx.HostBinToolVariable("tidyFixesCmd", "tidy_fixes")
 }
 
-// This TidyPhonySingleton generates both tidy-* and obj-* phony targets for C/C++ files.
+// Rule for merging the fixes exported by clang-tidy for each translation unit.
+var tidyFixesMerge = pctx.AndroidStaticRule("tidyFixesMerge",
+	blueprint.RuleParams{
+		Command:        "$tidyFixesCmd merge --output $out --list $out.rsp",
+		CommandDeps:    []string{"$tidyFixesCmd"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: "$in",
+	})
+
+// This TidyPhonySingleton generates tidy-*, iwyu-* and obj-* phony targets for C/C++ files.
 func TidyPhonySingleton() android.Singleton {
 	return &tidyPhonySingleton{}
 }
 
 type tidyPhonySingleton struct{}
 
-// Given a final module, add its tidy/obj phony targets to tidy/objModulesInDirGroup.
+// Given a final module, add its tidy/iwyu/obj phony targets to tidy/iwyu/objModulesInDirGroup.
 func collectTidyObjModuleTargets(ctx android.SingletonContext, module android.Module,
-	tidyModulesInDirGroup, objModulesInDirGroup map[string]map[string]android.Paths) {
+	tidyModulesInDirGroup, iwyuModulesInDirGroup, objModulesInDirGroup map[string]map[string]android.Paths) {
 	allObjFileGroups := make(map[string]android.Paths)     // variant group name => obj file Paths
 	allTidyFileGroups := make(map[string]android.Paths)    // variant group name => tidy file Paths
+	allIwyuFileGroups := make(map[string]android.Paths)    // variant group name => iwyu file Paths
 	subsetObjFileGroups := make(map[string]android.Paths)  // subset group name => obj file Paths
 	subsetTidyFileGroups := make(map[string]android.Paths) // subset group name => tidy file Paths
+	subsetIwyuFileGroups := make(map[string]android.Paths) // subset group name => iwyu file Paths
 
-	// (1) Collect all obj/tidy files into OS-specific groups.
+	// (1) Collect all obj/tidy/iwyu files into OS-specific groups.
 	ctx.VisitAllModuleVariants(module, func(variant android.Module) {
 		if ctx.Config().KatiEnabled() && android.ShouldSkipAndroidMkProcessing(variant) {
 			return
@@ -227,6 +245,7 @@ func collectTidyObjModuleTargets(ctx android.SingletonContext, module android.Mo
 			osName := variant.Target().Os.Name
 			addToOSGroup(osName, m.objFiles, allObjFileGroups, subsetObjFileGroups)
 			addToOSGroup(osName, m.tidyFiles, allTidyFileGroups, subsetTidyFileGroups)
+			addToOSGroup(osName, m.iwyuFiles, allIwyuFileGroups, subsetIwyuFileGroups)
 		}
 	})
 
@@ -235,16 +254,22 @@ func collectTidyObjModuleTargets(ctx android.SingletonContext, module android.Mo
 	addAllOSGroup(ctx, module, allTidyFileGroups, "", "tidy")
 	addAllOSGroup(ctx, module, subsetObjFileGroups, "subset", "obj")
 	addAllOSGroup(ctx, module, subsetTidyFileGroups, "subset", "tidy")
+	addAllOSGroup(ctx, module, allIwyuFileGroups, "", "iwyu")
+	addAllOSGroup(ctx, module, subsetIwyuFileGroups, "subset", "iwyu")
 
 	tidyTargetGroups := make(map[string]android.Path)
+	iwyuTargetGroups := make(map[string]android.Path)
 	objTargetGroups := make(map[string]android.Path)
 	genObjTidyPhonyTargets(ctx, module, "obj", allObjFileGroups, objTargetGroups)
 	genObjTidyPhonyTargets(ctx, module, "obj", subsetObjFileGroups, objTargetGroups)
 	genObjTidyPhonyTargets(ctx, module, "tidy", allTidyFileGroups, tidyTargetGroups)
 	genObjTidyPhonyTargets(ctx, module, "tidy", subsetTidyFileGroups, tidyTargetGroups)
+	genObjTidyPhonyTargets(ctx, module, "iwyu", allIwyuFileGroups, iwyuTargetGroups)
+	genObjTidyPhonyTargets(ctx, module, "iwyu", subsetIwyuFileGroups, iwyuTargetGroups)
 
 	moduleDir := ctx.ModuleDir(module)
 	appendToModulesInDirGroup(tidyTargetGroups, moduleDir, tidyModulesInDirGroup)
+	appendToModulesInDirGroup(iwyuTargetGroups, moduleDir, iwyuModulesInDirGroup)
 	appendToModulesInDirGroup(objTargetGroups, moduleDir, objModulesInDirGroup)
 }
 
@@ -253,13 +278,23 @@ func (m *tidyPhonySingleton) GenerateBuildActions(ctx android.SingletonContext)
 	// tidyModulesInDirGroup[G][D] is for group G, directory D, with Paths
 	// of all phony targets to be included into direct dependents of tidy-D_G.
 	tidyModulesInDirGroup := make(map[string]map[string]android.Paths)
-	// Also for obj-* directory phony targets.
+	// Also for iwyu-* and obj-* directory phony targets.
+	iwyuModulesInDirGroup := make(map[string]map[string]android.Paths)
 	objModulesInDirGroup := make(map[string]map[string]android.Paths)
 
+	// Fixes exported by clang-tidy for the modules listed in TIDY_EXPORT_FIXES.
+	var tidyFixesFiles android.Paths
+	// Reports of include-what-you-use for all translation units.
+	var iwyuFiles android.Paths
+
 	// Collect tidy/obj targets from the 'final' modules.
 	ctx.VisitAllModules(func(module android.Module) {
 		if module == ctx.FinalModule(module) {
-			collectTidyObjModuleTargets(ctx, module, tidyModulesInDirGroup, objModulesInDirGroup)
+			collectTidyObjModuleTargets(ctx, module, tidyModulesInDirGroup, iwyuModulesInDirGroup, objModulesInDirGroup)
+		}
+		if m, ok := module.(*Module); ok {
+			tidyFixesFiles = append(tidyFixesFiles, m.tidyFixesFiles...)
+			iwyuFiles = append(iwyuFiles, m.iwyuFiles...)
 		}
 	})
 
@@ -269,6 +304,26 @@ func (m *tidyPhonySingleton) GenerateBuildActions(ctx android.SingletonContext)
 	}
 	generateObjTidyPhonyTargets(ctx, suffix, "obj", objModulesInDirGroup)
 	generateObjTidyPhonyTargets(ctx, suffix, "tidy", tidyModulesInDirGroup)
+	generateObjTidyPhonyTargets(ctx, suffix, "iwyu", iwyuModulesInDirGroup)
+	generateTidyFixesTarget(ctx, tidyFixesFiles)
+	generateIwyuReportTarget(ctx, iwyuFiles)
+}
+
+// Generate the tidy-fixes phony target, which merges the fixes exported by clang-tidy into
+// out/soong/tidy-fixes/fixes.yaml. A header included by several translation units has its
+// fixes only once in the merged file, which can be applied with "tidy_fixes apply".
+func generateTidyFixesTarget(ctx android.SingletonContext, tidyFixesFiles android.Paths) {
+	if len(tidyFixesFiles) == 0 {
+		return
+	}
+	mergedFixes := android.PathForOutput(ctx, "tidy-fixes", "fixes.yaml")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        tidyFixesMerge,
+		Description: "merge clang-tidy fixes",
+		Inputs:      android.SortedUniquePaths(tidyFixesFiles),
+		Output:      mergedFixes,
+	})
+	ctx.Phony("tidy-fixes", mergedFixes)
 }
 
 // The name for an obj/tidy module variant group phony target is Name_group-obj/tidy,
@@ -244,3 +244,47 @@ func TestWithTidy(t *testing.T) {
 		})
 	}
 }
+
+func TestTidyExportFixes(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.c"],
+		}
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.c"],
+		}
+		cc_library_shared {
+			name: "libbaz",
+			srcs: ["baz.c"],
+			tidy: false,
+		}`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterParallelSingletonType("tidy_phony_targets", TidyPhonySingleton)
+		}),
+		android.FixtureMergeEnv(map[string]string{"TIDY_EXPORT_FIXES": "libfoo,libbaz"}),
+	).RunTestWithBp(t, bp)
+
+	variant := "android_arm64_armv8-a_shared"
+	objDir := "out/soong/.intermediates/libfoo/" + variant + "/obj/"
+	libfoo := result.ModuleForTests("libfoo", variant)
+	tidy := libfoo.Output(objDir + "foo.tidy")
+	android.AssertStringEquals(t, "libfoo tidy rule", "android/soong/cc.clangTidyExportFixes", tidy.Rule.String())
+	android.AssertPathsRelativeToTopEquals(t, "libfoo fixes", []string{objDir + "foo.tidy.yaml"}, tidy.ImplicitOutputs.Paths())
+	android.AssertStringDoesContain(t, "libfoo fixes arg", tidy.Args["fixes"], objDir+"foo.tidy.yaml")
+
+	// libbar is not listed, and libbaz has tidy disabled, so they do not export fixes.
+	libbar := result.ModuleForTests("libbar", variant)
+	android.AssertStringEquals(t, "libbar tidy rule", "android/soong/cc.clangTidy",
+		libbar.Output("out/soong/.intermediates/libbar/"+variant+"/obj/bar.tidy").Rule.String())
+	android.AssertBoolEquals(t, "libbaz has tidy files", false,
+		len(result.ModuleForTests("libbaz", variant).Module().(*Module).tidyFiles) > 0)
+
+	merge := result.SingletonForTests("tidy_phony_targets").Description("merge clang-tidy fixes")
+	android.AssertPathsRelativeToTopEquals(t, "merged fixes inputs",
+		[]string{objDir + "bar.tidy.yaml", objDir + "foo.tidy.yaml"}, merge.Inputs)
+	android.AssertPathRelativeToTopEquals(t, "merged fixes", "out/soong/tidy-fixes/fixes.yaml", merge.Output)
+}
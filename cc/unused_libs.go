@@ -0,0 +1,191 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+type UnusedLibsProperties struct {
+	// whether to report the shared_libs, static_libs and whole_static_libs that the link of
+	// a binary or shared library does not use. Defaults to true if CHECK_UNUSED_LIBS is set.
+	Check_unused_libs *bool
+}
+
+type unusedLibsFeature struct {
+	Properties UnusedLibsProperties
+}
+
+func (u *unusedLibsFeature) props() []interface{} {
+	return []interface{}{&u.Properties}
+}
+
+func (u *unusedLibsFeature) flags(ctx ModuleContext, flags Flags) Flags {
+	// The check reads the linker map and --why-extract output of lld, and the ELF table of
+	// contents of the shared libraries.
+	if !ctx.Os().Linux() {
+		return flags
+	}
+	flags.CheckUnusedLibs = BoolDefault(u.Properties.Check_unused_libs,
+		ctx.Config().IsEnvTrue("CHECK_UNUSED_LIBS"))
+	return flags
+}
+
+func init() {
+	pctx.HostBinToolVariable("linkReportCmd", "link_report")
+	android.RegisterParallelSingletonType("unused_libs", unusedLibsSingletonFactory)
+}
+
+// Rule for reporting the unused libraries of a link.
+var unusedLibsRule = pctx.AndroidStaticRule("unusedLibs",
+	blueprint.RuleParams{
+		Command:     "$linkReportCmd unused-libs --config $in --output $out",
+		CommandDeps: []string{"$linkReportCmd"},
+	})
+
+// Rule for merging the unused library reports of all modules.
+var unusedLibsMerge = pctx.AndroidStaticRule("unusedLibsMerge",
+	blueprint.RuleParams{
+		Command:        "$linkReportCmd merge-unused-libs --output $out --list $out.rsp",
+		CommandDeps:    []string{"$linkReportCmd"},
+		Rspfile:        "$out.rsp",
+		RspfileContent: "$in",
+	})
+
+// The JSON config read by link_report unused-libs.
+type unusedLibsSharedLib struct {
+	Name string `json:"name"`
+	Toc  string `json:"toc"`
+}
+
+type unusedLibsConfig struct {
+	Module          string                `json:"module"`
+	Variant         string                `json:"variant"`
+	Blueprint       string                `json:"blueprint"`
+	Output          string                `json:"output"`
+	Map             string                `json:"map"`
+	WhyExtract      string                `json:"why_extract"`
+	Lto             bool                  `json:"lto"`
+	SharedLibs      []unusedLibsSharedLib `json:"shared_libs"`
+	StaticLibs      []linkReportStaticLib `json:"static_libs"`
+	WholeStaticLibs []linkReportStaticLib `json:"whole_static_libs"`
+}
+
+// checkUnusedLibs generates a rule that reports which of the shared_libs, static_libs and
+// whole_static_libs of the module were not used by its link.
+func (c *Module) checkUnusedLibs(ctx ModuleContext, deps PathDeps) {
+	link := c.linkReport()
+	if link == nil || !link.checkUnusedLibs {
+		return
+	}
+	config := unusedLibsConfig{
+		Module:     ctx.ModuleName(),
+		Variant:    ctx.ModuleSubDir(),
+		Blueprint:  ctx.BlueprintsFile(),
+		Output:     link.output.String(),
+		Map:        link.mapFile.String(),
+		WhyExtract: link.whyExtract.String(),
+		Lto:        link.lto,
+	}
+	implicits := android.Paths{link.output, link.mapFile, link.whyExtract}
+
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok || tag.Order != normalLibraryDependency {
+			return
+		}
+		name := linkReportLibName(ctx.OtherModuleName(dep))
+		switch {
+		case tag.shared() && inList(name, link.sharedLibs):
+			if !ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider) {
+				return
+			}
+			info, _ := ChooseStubOrImpl(ctx, dep)
+			// Static executables do not link their shared libraries, and tables of contents
+			// are needed to know which symbols a library defines.
+			if !inList(info.SharedLibrary.String(), deps.SharedLibs.Strings()) || !info.TableOfContents.Valid() {
+				return
+			}
+			config.SharedLibs = append(config.SharedLibs, unusedLibsSharedLib{
+				Name: name,
+				Toc:  info.TableOfContents.String(),
+			})
+			implicits = append(implicits, info.TableOfContents.Path())
+		case tag.static() && !tag.wholeStatic && inList(name, link.staticLibs):
+			if !ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider) {
+				return
+			}
+			info := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
+			if !inList(info.StaticLibrary.String(), deps.StaticLibs.Strings()) {
+				return
+			}
+			config.StaticLibs = append(config.StaticLibs, linkReportStaticLib{
+				Name:    name,
+				Archive: info.StaticLibrary.String(),
+			})
+		case tag.static() && tag.wholeStatic && inList(name, link.wholeStaticLibs):
+			if !ctx.OtherModuleHasProvider(dep, StaticLibraryInfoProvider) {
+				return
+			}
+			info := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
+			config.WholeStaticLibs = append(config.WholeStaticLibs, linkReportStaticLib{
+				Name:    name,
+				Archive: info.StaticLibrary.String(),
+				Objects: info.Objects.objFiles.Strings(),
+			})
+		}
+	})
+
+	if len(config.SharedLibs)+len(config.StaticLibs)+len(config.WholeStaticLibs) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to write the unused libraries config: %s", err)
+		return
+	}
+	configFile := android.PathForModuleOut(ctx, "unused_libs", "config.json")
+	android.WriteFileRule(ctx, configFile, string(data))
+
+	report := android.PathForModuleOut(ctx, "unused_libs", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        unusedLibsRule,
+		Description: "check unused libs " + link.output.Base(),
+		Input:       configFile,
+		Implicits:   implicits,
+		Output:      report,
+	})
+	c.unusedLibsReport = android.OptionalPathForPath(report)
+	ctx.Phony(ctx.ModuleName()+"-unused-libs", report)
+}
+
+// unusedLibsSingletonFactory returns the singleton generating the unused-libs phony target,
+// which merges the reports of all modules into out/soong/unused_libs/report.json. The merged
+// report lists the libraries that each module does not use in any of its variants, and is the
+// input of bpfix --unused_libs.
+func unusedLibsSingletonFactory() android.Singleton {
+	return &mergeReportsSingleton{
+		rule:        unusedLibsMerge,
+		description: "merge unused libs reports",
+		dir:         "unused_libs",
+		phony:       "unused-libs",
+		report:      func(m *Module) android.OptionalPath { return m.unusedLibsReport },
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestUnusedLibs(t *testing.T) {
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			shared_libs: ["libshared"],
+			static_libs: ["libstatic"],
+			whole_static_libs: ["libwhole"],
+			check_unused_libs: true,
+		}
+		cc_library_shared {
+			name: "libshared",
+			srcs: ["shared.c"],
+		}
+		cc_library_static {
+			name: "libstatic",
+			srcs: ["static.c"],
+		}
+		cc_library_static {
+			name: "libwhole",
+			srcs: ["whole.c"],
+		}
+		cc_library_shared {
+			name: "libunchecked",
+			srcs: ["unchecked.c"],
+			static_libs: ["libstatic"],
+		}`
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterParallelSingletonType("unused_libs", unusedLibsSingletonFactory)
+		}),
+	).RunTestWithBp(t, bp)
+
+	variant := "android_arm64_armv8-a"
+	fooDir := "out/soong/.intermediates/foo/" + variant + "/"
+	outDir := fooDir + "unused_libs/"
+	foo := result.ModuleForTests("foo", variant)
+
+	link := foo.Output(fooDir + "link.map")
+	android.AssertStringDoesContain(t, "link map flag", link.Args["ldFlags"], "-Wl,-Map="+fooDir+"link.map")
+	android.AssertStringDoesContain(t, "why extract flag", link.Args["ldFlags"], "-Wl,--why-extract="+outDir+"why_extract.txt")
+	android.AssertPathsRelativeToTopEquals(t, "link implicit outputs",
+		[]string{fooDir + "link.map", outDir + "why_extract.txt"}, link.ImplicitOutputs.Paths())
+
+	var config unusedLibsConfig
+	content := android.ContentFromFileRuleForTests(t, result.TestContext, foo.Output(outDir+"config.json"))
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		t.Fatal(err)
+	}
+	android.AssertStringEquals(t, "module", "foo", config.Module)
+	android.AssertStringEquals(t, "map", fooDir+"link.map", android.StringRelativeToTop(result.Config, config.Map))
+	android.AssertIntEquals(t, "shared libs", 1, len(config.SharedLibs))
+	android.AssertStringEquals(t, "shared lib", "libshared", config.SharedLibs[0].Name)
+	android.AssertStringEquals(t, "shared lib toc",
+		"out/soong/.intermediates/libshared/"+variant+"_shared/libshared.so.toc",
+		android.StringRelativeToTop(result.Config, config.SharedLibs[0].Toc))
+	android.AssertIntEquals(t, "static libs", 1, len(config.StaticLibs))
+	android.AssertStringEquals(t, "static lib", "libstatic", config.StaticLibs[0].Name)
+	android.AssertIntEquals(t, "whole static libs", 1, len(config.WholeStaticLibs))
+	android.AssertStringEquals(t, "whole static lib", "libwhole", config.WholeStaticLibs[0].Name)
+	android.AssertIntEquals(t, "whole static lib objects", 1, len(config.WholeStaticLibs[0].Objects))
+
+	report := foo.Output(outDir + "report.json")
+	android.AssertPathRelativeToTopEquals(t, "report input", outDir+"config.json", report.Input)
+	android.AssertPathsRelativeToTopEquals(t, "report implicits", []string{
+		fooDir + "unstripped/foo",
+		fooDir + "link.map",
+		outDir + "why_extract.txt",
+		"out/soong/.intermediates/libshared/" + variant + "_shared/libshared.so.toc",
+	}, report.Implicits)
+
+	unchecked := result.ModuleForTests("libunchecked", variant+"_shared")
+	android.AssertBoolEquals(t, "libunchecked has a report", false,
+		unchecked.Module().(*Module).unusedLibsReport.Valid())
+
+	merge := result.SingletonForTests("unused_libs").Description("merge unused libs reports")
+	android.AssertPathRelativeToTopEquals(t, "merged report", "out/soong/unused_libs/report.json", merge.Output)
+	android.AssertPathsRelativeToTopEquals(t, "merged report inputs", []string{outDir + "report.json"}, merge.Inputs)
+}
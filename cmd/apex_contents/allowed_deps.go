@@ -0,0 +1,95 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// readAllowedDeps reads the lines of an allowed_deps.txt file, skipping comments and empty lines.
+func readAllowedDeps(r io.Reader) (map[string]bool, error) {
+	ret := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ret[line] = true
+	}
+	return ret, s.Err()
+}
+
+// explainAllowedDeps writes the entries that are in newAllowedDeps but not in allowedDeps with
+// the dependency chains that pull them into APEXes, and the entries that are no longer needed.
+func explainAllowedDeps(w io.Writer, allowedDeps, newAllowedDeps map[string]bool, graphs []*depsGraph) {
+	var added, removed []string
+	for line := range newAllowedDeps {
+		if !allowedDeps[line] {
+			added = append(added, line)
+		}
+	}
+	for line := range allowedDeps {
+		if !newAllowedDeps[line] {
+			removed = append(removed, line)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	sort.Slice(graphs, func(i, j int) bool { return graphs[i].Apex < graphs[j].Apex })
+	chains := make([]map[string][]string, len(graphs))
+	for i, g := range graphs {
+		chains[i] = g.chains()
+	}
+
+	if len(added) > 0 {
+		fmt.Fprintln(w, "New dependencies that are not in allowed_deps.txt:")
+		for _, line := range added {
+			fmt.Fprintf(w, "  %s\n", line)
+			found := false
+			for i, g := range graphs {
+				for _, d := range g.Deps {
+					if d.AllowedDeps != line {
+						continue
+					}
+					found = true
+					if chain := chains[i][d.Module]; chain != nil {
+						fmt.Fprintf(w, "    %s\n", strings.Join(chain, " -> "))
+					} else {
+						fmt.Fprintf(w, "    %s: <- %s\n", g.Apex, strings.Join(d.From, ", "))
+					}
+				}
+			}
+			if !found {
+				fmt.Fprintln(w, "    not in the dependency graph of any APEX, it may be a dependency of an updatable app")
+			}
+		}
+	}
+
+	if len(removed) > 0 {
+		if len(added) > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, "Dependencies in allowed_deps.txt that are no longer needed:")
+		for _, line := range removed {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apex_contents lists the contents of an APEX with the modules that built each file and the
+// dependency chains that pulled them in, compares the contents of two builds of an APEX, and
+// explains which dependency chains added entries to allowed_deps.txt.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"android/soong/sizediff"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s report --deps <deps.json> --output <contents.json>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s why --deps <deps.json or contents.json> <module>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s diff [--error_on_growth <bytes>] <old contents.json> <new contents.json>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s explain_allowed_deps --allowed_deps <file> --new_allowed_deps <file> <deps.json>...\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "report":
+		err = report(os.Args[2:])
+	case "why":
+		err = why(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	case "explain_allowed_deps":
+		err = explain(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func report(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	depsFile := flags.String("deps", "", "dependency graph of the APEX")
+	output := flags.String("output", "", "contents file to write")
+	flags.Parse(args)
+
+	if *depsFile == "" || *output == "" {
+		usage()
+	}
+
+	var g depsGraph
+	if err := readJSON(*depsFile, &g); err != nil {
+		return fmt.Errorf("%s: %w", *depsFile, err)
+	}
+	c, err := createContents(&g, func(path string) (int64, error) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	})
+	if err != nil {
+		return err
+	}
+	return writeJSON(*output, c)
+}
+
+func why(args []string) error {
+	flags := flag.NewFlagSet("why", flag.ExitOnError)
+	depsFile := flags.String("deps", "", "dependency graph or contents of the APEX")
+	flags.Parse(args)
+
+	if *depsFile == "" || flags.NArg() == 0 {
+		usage()
+	}
+
+	var g depsGraph
+	if err := readJSON(*depsFile, &g); err != nil {
+		return fmt.Errorf("%s: %w", *depsFile, err)
+	}
+	chains := g.chains()
+	var missing []string
+	for _, m := range flags.Args() {
+		chain, ok := chains[m]
+		if !ok {
+			missing = append(missing, m)
+			continue
+		}
+		fmt.Println(strings.Join(chain, " -> "))
+		for _, f := range g.Files {
+			if f.Module == m {
+				fmt.Printf("  %s\n", f.Path)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s doesn't depend on %s", g.Apex, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func diff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	errorOnGrowth := sizediff.ErrorOnGrowthFlag(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		usage()
+	}
+
+	var oldC, newC contents
+	if err := readJSON(flags.Arg(0), &oldC); err != nil {
+		return fmt.Errorf("%s: %w", flags.Arg(0), err)
+	}
+	if err := readJSON(flags.Arg(1), &newC); err != nil {
+		return fmt.Errorf("%s: %w", flags.Arg(1), err)
+	}
+
+	d := diffContents(&oldC, &newC)
+	d.print(os.Stdout)
+
+	return sizediff.CheckGrowth(d.oldSize, d.newSize, *errorOnGrowth)
+}
+
+func explain(args []string) error {
+	flags := flag.NewFlagSet("explain_allowed_deps", flag.ExitOnError)
+	allowedDepsFile := flags.String("allowed_deps", "", "checked in allowed_deps.txt")
+	newAllowedDepsFile := flags.String("new_allowed_deps", "", "allowed_deps.txt generated from the build")
+	flags.Parse(args)
+
+	if *allowedDepsFile == "" || *newAllowedDepsFile == "" {
+		usage()
+	}
+
+	readLines := func(file string) (map[string]bool, error) {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return readAllowedDeps(f)
+	}
+	allowedDeps, err := readLines(*allowedDepsFile)
+	if err != nil {
+		return err
+	}
+	newAllowedDeps, err := readLines(*newAllowedDepsFile)
+	if err != nil {
+		return err
+	}
+
+	var graphs []*depsGraph
+	for _, file := range flags.Args() {
+		g := &depsGraph{}
+		if err := readJSON(file, g); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		graphs = append(graphs, g)
+	}
+
+	explainAllowedDeps(os.Stdout, allowedDeps, newAllowedDeps, graphs)
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// depsGraph is the dependency graph of the payload of an APEX, written by the apex module.
+type depsGraph struct {
+	Apex  string      `json:"apex"`
+	Files []apexEntry `json:"files"`
+	Deps  []dep       `json:"deps"`
+}
+
+// apexEntry is a file in the payload of an APEX.
+type apexEntry struct {
+	// Path of the file in the APEX
+	Path string `json:"path"`
+
+	// Module that built the file
+	Module string `json:"module"`
+
+	// Path of the built file. Only set in the dependency graph.
+	BuiltFile string `json:"built_file,omitempty"`
+
+	// Size of the file. Only set in the contents.
+	Size int64 `json:"size"`
+
+	// Shortest dependency chain from the APEX to the module. Only set in the contents.
+	Chain []string `json:"chain,omitempty"`
+}
+
+// dep is a module in the dependency graph of the payload of an APEX.
+type dep struct {
+	Module string `json:"module"`
+
+	// Modules that depend on this module
+	From []string `json:"from"`
+
+	// Whether the module is outside of the APEX, e.g. a stub library
+	External bool `json:"external,omitempty"`
+
+	// Line in allowed_deps.txt for the module, if it's tracked there
+	AllowedDeps string `json:"allowed_deps,omitempty"`
+}
+
+// contents is the listing of the contents of an APEX with the sizes of the files and the
+// dependency chains that pulled them in.
+type contents struct {
+	Apex  string      `json:"apex"`
+	Size  int64       `json:"size"`
+	Files []apexEntry `json:"files"`
+	Deps  []dep       `json:"deps"`
+}
+
+func readJSON(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0666)
+}
+
+// chains returns the shortest dependency chain from the APEX to each module of the graph.
+func (g *depsGraph) chains() map[string][]string {
+	children := make(map[string][]string)
+	for _, d := range g.Deps {
+		for _, from := range d.From {
+			children[from] = append(children[from], d.Module)
+		}
+	}
+	for _, c := range children {
+		sort.Strings(c)
+	}
+
+	ret := map[string][]string{g.Apex: {g.Apex}}
+	queue := []string{g.Apex}
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		for _, c := range children[m] {
+			if _, ok := ret[c]; ok {
+				continue
+			}
+			ret[c] = append(append([]string(nil), ret[m]...), c)
+			queue = append(queue, c)
+		}
+	}
+	return ret
+}
+
+// createContents returns the contents of an APEX from its dependency graph, using size to get
+// the sizes of the built files.
+func createContents(g *depsGraph, size func(string) (int64, error)) (*contents, error) {
+	chains := g.chains()
+	c := &contents{Apex: g.Apex, Files: []apexEntry{}, Deps: g.Deps}
+	if c.Deps == nil {
+		c.Deps = []dep{}
+	}
+	for _, f := range g.Files {
+		s, err := size(f.BuiltFile)
+		if err != nil {
+			return nil, err
+		}
+		c.Files = append(c.Files, apexEntry{
+			Path:   f.Path,
+			Module: f.Module,
+			Size:   s,
+			Chain:  chains[f.Module],
+		})
+		c.Size += s
+	}
+	sort.Slice(c.Files, func(i, j int) bool { return c.Files[i].Path < c.Files[j].Path })
+	return c, nil
+}
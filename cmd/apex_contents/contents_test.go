@@ -0,0 +1,112 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testGraph = &depsGraph{
+	Apex: "com.android.foo",
+	Files: []apexEntry{
+		{Path: "lib64/libfoo.so", Module: "libfoo", BuiltFile: "out/libfoo.so"},
+		{Path: "lib64/libbase.so", Module: "libbase", BuiltFile: "out/libbase.so"},
+		{Path: "bin/food", Module: "food", BuiltFile: "out/food"},
+	},
+	Deps: []dep{
+		{Module: "food", From: []string{"com.android.foo"}},
+		{Module: "libfoo", From: []string{"food"}, AllowedDeps: "libfoo(minSdkVersion:30)"},
+		{Module: "libutils_static", From: []string{"libfoo"}, AllowedDeps: "libutils_static(minSdkVersion:29)"},
+		{Module: "libbase", From: []string{"libutils_static", "food"}, AllowedDeps: "libbase(minSdkVersion:29)"},
+		{Module: "libc", From: []string{"libbase"}, External: true},
+	},
+}
+
+func TestChains(t *testing.T) {
+	chains := testGraph.chains()
+	want := map[string][]string{
+		"com.android.foo": {"com.android.foo"},
+		"food":            {"com.android.foo", "food"},
+		"libfoo":          {"com.android.foo", "food", "libfoo"},
+		"libutils_static": {"com.android.foo", "food", "libfoo", "libutils_static"},
+		"libbase":         {"com.android.foo", "food", "libbase"},
+		"libc":            {"com.android.foo", "food", "libbase", "libc"},
+	}
+	if !reflect.DeepEqual(chains, want) {
+		t.Errorf("want %q, got %q", want, chains)
+	}
+}
+
+func TestCreateContents(t *testing.T) {
+	sizes := map[string]int64{"out/libfoo.so": 100, "out/libbase.so": 50, "out/food": 10}
+	c, err := createContents(testGraph, func(path string) (int64, error) {
+		s, ok := sizes[path]
+		if !ok {
+			return 0, fmt.Errorf("%s doesn't exist", path)
+		}
+		return s, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Size != 160 {
+		t.Errorf("want size 160, got %d", c.Size)
+	}
+	want := []apexEntry{
+		{Path: "bin/food", Module: "food", Size: 10, Chain: []string{"com.android.foo", "food"}},
+		{Path: "lib64/libbase.so", Module: "libbase", Size: 50, Chain: []string{"com.android.foo", "food", "libbase"}},
+		{Path: "lib64/libfoo.so", Module: "libfoo", Size: 100, Chain: []string{"com.android.foo", "food", "libfoo"}},
+	}
+	if !reflect.DeepEqual(c.Files, want) {
+		t.Errorf("want %+v, got %+v", want, c.Files)
+	}
+}
+
+func TestExplainAllowedDeps(t *testing.T) {
+	allowedDeps, err := readAllowedDeps(strings.NewReader(
+		"# comment\nlibbase(minSdkVersion:29)\nlibold(minSdkVersion:29)\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newAllowedDeps := map[string]bool{
+		"libbase(minSdkVersion:29)":         true,
+		"libfoo(minSdkVersion:30)":          true,
+		"libutils_static(minSdkVersion:29)": true,
+		"libapp(minSdkVersion:31)":          true,
+	}
+
+	buf := &bytes.Buffer{}
+	explainAllowedDeps(buf, allowedDeps, newAllowedDeps, []*depsGraph{testGraph})
+
+	want := `New dependencies that are not in allowed_deps.txt:
+  libapp(minSdkVersion:31)
+    not in the dependency graph of any APEX, it may be a dependency of an updatable app
+  libfoo(minSdkVersion:30)
+    com.android.foo -> food -> libfoo
+  libutils_static(minSdkVersion:29)
+    com.android.foo -> food -> libfoo -> libutils_static
+
+Dependencies in allowed_deps.txt that are no longer needed:
+  libold(minSdkVersion:29)
+`
+	if buf.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"android/soong/sizediff"
+)
+
+// contentsDiff is the difference between the contents of two builds of an APEX.
+type contentsDiff struct {
+	sizediff.Diff[apexEntry]
+
+	oldSize, newSize int64
+
+	// Modules that the new build depends on but the old doesn't, and vice versa
+	addedDeps, removedDeps []dep
+}
+
+// entryChanges returns what differs between two entries for the same path.
+func entryChanges(o, n apexEntry) []string {
+	var what []string
+	if o.Size != n.Size {
+		what = append(what, "size")
+	}
+	if o.Module != n.Module {
+		what = append(what, "module")
+	}
+	return what
+}
+
+func diffContents(oldC, newC *contents) *contentsDiff {
+	d := &contentsDiff{
+		Diff: sizediff.DiffEntries(oldC.Files, newC.Files,
+			func(e apexEntry) string { return e.Path }, entryChanges),
+		oldSize: oldC.Size,
+		newSize: newC.Size,
+	}
+
+	oldDeps := make(map[string]bool)
+	for _, dep := range oldC.Deps {
+		oldDeps[dep.Module] = true
+	}
+	newDeps := make(map[string]bool)
+	for _, dep := range newC.Deps {
+		newDeps[dep.Module] = true
+		if !oldDeps[dep.Module] {
+			d.addedDeps = append(d.addedDeps, dep)
+		}
+	}
+	for _, dep := range oldC.Deps {
+		if !newDeps[dep.Module] {
+			d.removedDeps = append(d.removedDeps, dep)
+		}
+	}
+
+	sort.Slice(d.addedDeps, func(i, j int) bool { return d.addedDeps[i].Module < d.addedDeps[j].Module })
+	sort.Slice(d.removedDeps, func(i, j int) bool { return d.removedDeps[i].Module < d.removedDeps[j].Module })
+	return d
+}
+
+func chainString(chain []string) string {
+	if len(chain) == 0 {
+		return "<unknown>"
+	}
+	return strings.Join(chain, " -> ")
+}
+
+func (d *contentsDiff) print(w io.Writer) {
+	sizediff.PrintTotalSize(w, d.oldSize, d.newSize)
+
+	sizediff.PrintSection(w, "Added", d.Added, func(w io.Writer, e apexEntry) {
+		fmt.Fprintf(w, "  %s (%d bytes, %s)\n", e.Path, e.Size, chainString(e.Chain))
+	})
+
+	sizediff.PrintSection(w, "Removed", d.Removed, func(w io.Writer, e apexEntry) {
+		fmt.Fprintf(w, "  %s (%d bytes, %s)\n", e.Path, e.Size, e.Module)
+	})
+
+	sizediff.PrintSection(w, "Changed", d.Changed, func(w io.Writer, c sizediff.Change[apexEntry]) {
+		fmt.Fprintf(w, "  %s\n", c.New.Path)
+		for _, what := range c.What {
+			switch what {
+			case "size":
+				fmt.Fprintf(w, "    size: %d -> %d (%+d)\n", c.Old.Size, c.New.Size, c.New.Size-c.Old.Size)
+			case "module":
+				fmt.Fprintf(w, "    module: %s -> %s\n", c.Old.Module, c.New.Module)
+			}
+		}
+	})
+
+	sizediff.PrintSection(w, "New dependencies", d.addedDeps, func(w io.Writer, dep dep) {
+		fmt.Fprintf(w, "  %s <- %s\n", dep.Module, strings.Join(dep.From, ", "))
+	})
+
+	sizediff.PrintSection(w, "Removed dependencies", d.removedDeps, func(w io.Writer, dep dep) {
+		fmt.Fprintf(w, "  %s\n", dep.Module)
+	})
+}
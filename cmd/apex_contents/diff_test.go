@@ -0,0 +1,68 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDiffContents(t *testing.T) {
+	oldC := &contents{
+		Apex: "com.android.foo",
+		Size: 160,
+		Files: []apexEntry{
+			{Path: "bin/food", Module: "food", Size: 10},
+			{Path: "lib64/libbase.so", Module: "libbase", Size: 50},
+			{Path: "lib64/libfoo.so", Module: "libfoo", Size: 100},
+		},
+		Deps: []dep{{Module: "food"}, {Module: "libbase"}, {Module: "libfoo"}},
+	}
+	newC := &contents{
+		Apex: "com.android.foo",
+		Size: 190,
+		Files: []apexEntry{
+			{Path: "bin/food", Module: "food", Size: 10},
+			{Path: "lib64/libbase.so", Module: "libbase", Size: 80},
+			{Path: "lib64/libbar.so", Module: "libbar", Size: 100, Chain: []string{"com.android.foo", "food", "libbar"}},
+		},
+		Deps: []dep{{Module: "food"}, {Module: "libbase"}, {Module: "libbar", From: []string{"food"}}},
+	}
+
+	buf := &bytes.Buffer{}
+	diffContents(oldC, newC).print(buf)
+
+	want := `Total size: 160 -> 190 (+30)
+
+Added:
+  lib64/libbar.so (100 bytes, com.android.foo -> food -> libbar)
+
+Removed:
+  lib64/libfoo.so (100 bytes, libfoo)
+
+Changed:
+  lib64/libbase.so
+    size: 50 -> 80 (+30)
+
+New dependencies:
+  libbar <- food
+
+Removed dependencies:
+  libfoo
+`
+	if buf.String() != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Categories of the entries of an APK.
+const (
+	categoryDex           = "dex"
+	categoryResources     = "resources"
+	categoryResourceTable = "resource_table"
+	categoryNativeLibs    = "native_libs"
+	categoryAssets        = "assets"
+	categoryManifest      = "manifest"
+	categorySignature     = "signature"
+	categoryOther         = "other"
+)
+
+// sizes are the compressed and uncompressed sizes of one or more entries of an APK.
+type sizes struct {
+	Files            int    `json:"files"`
+	CompressedSize   uint64 `json:"compressed_size"`
+	UncompressedSize uint64 `json:"uncompressed_size"`
+}
+
+func (s *sizes) add(f *zip.File) {
+	s.Files++
+	s.CompressedSize += f.CompressedSize64
+	s.UncompressedSize += f.UncompressedSize64
+}
+
+// resourceGroup are the sizes of the resources of a type and configuration, e.g. drawable and
+// hdpi-v4 for res/drawable-hdpi-v4/.
+type resourceGroup struct {
+	Type   string `json:"type"`
+	Config string `json:"config,omitempty"`
+	sizes
+}
+
+// file is a single entry of an APK.
+type file struct {
+	Path             string `json:"path"`
+	CompressedSize   uint64 `json:"compressed_size"`
+	UncompressedSize uint64 `json:"uncompressed_size"`
+}
+
+func newFile(f *zip.File) file {
+	return file{Path: f.Name, CompressedSize: f.CompressedSize64, UncompressedSize: f.UncompressedSize64}
+}
+
+// abiLibs are the native libraries of an ABI, from lib/<abi>/.
+type abiLibs struct {
+	Abi string `json:"abi"`
+	sizes
+	Libs []file `json:"libs"`
+}
+
+// breakdown is the report of the contents of an APK.
+type breakdown struct {
+	Apk      string `json:"apk"`
+	FileSize uint64 `json:"file_size"`
+	sizes
+
+	// Maximum size of the APK file. 0 means no budget.
+	SizeBudget uint64 `json:"size_budget,omitempty"`
+
+	Categories map[string]*sizes `json:"categories"`
+
+	Dex         dexCounts       `json:"dex"`
+	DexPackages []dexPackage    `json:"dex_packages"`
+	Resources   []resourceGroup `json:"resources"`
+	NativeLibs  []abiLibs       `json:"native_libs"`
+	Assets      []file          `json:"assets"`
+}
+
+// category returns the category of the APK entry with the given name.
+func category(name string) string {
+	switch {
+	case path.Dir(name) == "." && strings.HasPrefix(name, "classes") && strings.HasSuffix(name, ".dex"):
+		return categoryDex
+	case name == "resources.arsc":
+		return categoryResourceTable
+	case name == "AndroidManifest.xml":
+		return categoryManifest
+	case strings.HasPrefix(name, "res/"):
+		return categoryResources
+	case strings.HasPrefix(name, "lib/") && strings.HasSuffix(name, ".so"):
+		return categoryNativeLibs
+	case strings.HasPrefix(name, "assets/"):
+		return categoryAssets
+	case strings.HasPrefix(name, "META-INF/"):
+		return categorySignature
+	}
+	return categoryOther
+}
+
+// resourceTypeAndConfig returns the type and configuration of a resource from the name of its
+// directory, e.g. drawable and hdpi-v4 for res/drawable-hdpi-v4/icon.png. Resources whose paths
+// were shortened by aapt2 don't have a directory per type and are attributed to type "unknown".
+func resourceTypeAndConfig(name string) (string, string) {
+	dir := strings.TrimPrefix(path.Dir(name), "res/")
+	if dir == "res" || strings.Contains(dir, "/") {
+		return "unknown", ""
+	}
+	typ, config, _ := strings.Cut(dir, "-")
+	return typ, config
+}
+
+// readApk returns the breakdown of the contents of an APK.
+func readApk(r io.ReaderAt, size int64) (*breakdown, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &breakdown{
+		FileSize:    uint64(size),
+		Categories:  make(map[string]*sizes),
+		DexPackages: []dexPackage{},
+		Resources:   []resourceGroup{},
+		NativeLibs:  []abiLibs{},
+		Assets:      []file{},
+	}
+	packages := make(map[string]*dexCounts)
+	resources := make(map[[2]string]*resourceGroup)
+	libs := make(map[string]*abiLibs)
+
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		b.sizes.add(f)
+		c := category(f.Name)
+		if b.Categories[c] == nil {
+			b.Categories[c] = &sizes{}
+		}
+		b.Categories[c].add(f)
+
+		switch c {
+		case categoryDex:
+			data, err := readEntry(f)
+			if err != nil {
+				return nil, err
+			}
+			if err := countDex(data, packages); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+		case categoryResources:
+			typ, config := resourceTypeAndConfig(f.Name)
+			key := [2]string{typ, config}
+			if resources[key] == nil {
+				resources[key] = &resourceGroup{Type: typ, Config: config}
+			}
+			resources[key].add(f)
+		case categoryNativeLibs:
+			abi := strings.Split(f.Name, "/")[1]
+			if libs[abi] == nil {
+				libs[abi] = &abiLibs{Abi: abi, Libs: []file{}}
+			}
+			libs[abi].add(f)
+			libs[abi].Libs = append(libs[abi].Libs, newFile(f))
+		case categoryAssets:
+			b.Assets = append(b.Assets, newFile(f))
+		}
+	}
+
+	for pkg, counts := range packages {
+		b.Dex.Classes += counts.Classes
+		b.Dex.Methods += counts.Methods
+		b.DexPackages = append(b.DexPackages, dexPackage{Package: pkg, dexCounts: *counts})
+	}
+	sort.Slice(b.DexPackages, func(i, j int) bool { return b.DexPackages[i].Package < b.DexPackages[j].Package })
+
+	for _, r := range resources {
+		b.Resources = append(b.Resources, *r)
+	}
+	sort.Slice(b.Resources, func(i, j int) bool {
+		if b.Resources[i].Type != b.Resources[j].Type {
+			return b.Resources[i].Type < b.Resources[j].Type
+		}
+		return b.Resources[i].Config < b.Resources[j].Config
+	})
+
+	for _, l := range libs {
+		sort.Slice(l.Libs, func(i, j int) bool { return l.Libs[i].Path < l.Libs[j].Path })
+		b.NativeLibs = append(b.NativeLibs, *l)
+	}
+	sort.Slice(b.NativeLibs, func(i, j int) bool { return b.NativeLibs[i].Abi < b.NativeLibs[j].Abi })
+
+	sort.Slice(b.Assets, func(i, j int) bool { return b.Assets[i].Path < b.Assets[j].Path })
+
+	return b, nil
+}
+
+func readEntry(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return data, nil
+}
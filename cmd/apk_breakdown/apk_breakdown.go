@@ -0,0 +1,101 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apk_breakdown writes a JSON report of what is inside an APK: the number of classes and methods
+// in the dex files per Java package, the resources by type and configuration, the native
+// libraries per ABI and the assets, with their compressed and uncompressed sizes. It fails if
+// the APK is larger than its size budget.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func main() {
+	output := flag.String("output", "", "file to write the report to")
+	sizeBudget := flag.Uint64("size_budget", 0, "maximum size of the APK in bytes")
+	flag.Parse()
+
+	if *output == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *output, *sizeBudget); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// checkBudget returns an error describing where the size of the APK goes if it's larger than its
+// budget.
+func checkBudget(b *breakdown) error {
+	if b.SizeBudget == 0 || b.FileSize <= b.SizeBudget {
+		return nil
+	}
+
+	var categories []string
+	for c := range b.Categories {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return b.Categories[categories[i]].CompressedSize > b.Categories[categories[j]].CompressedSize
+	})
+	var lines []string
+	for _, c := range categories {
+		s := b.Categories[c]
+		lines = append(lines, fmt.Sprintf("  %s: %d bytes in %d files (%d bytes uncompressed)",
+			c, s.CompressedSize, s.Files, s.UncompressedSize))
+	}
+
+	return fmt.Errorf("%s is %d bytes, which is %d bytes over its size budget of %d bytes:\n%s",
+		b.Apk, b.FileSize, b.FileSize-b.SizeBudget, b.SizeBudget, strings.Join(lines, "\n"))
+}
+
+func run(apk, output string, sizeBudget uint64) error {
+	f, err := os.Open(apk)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	b, err := readApk(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("%s: %w", apk, err)
+	}
+	b.Apk = filepath.Base(apk)
+	b.SizeBudget = sizeBudget
+
+	// The report is only written when the APK is within its budget, so that the build fails again
+	// until the APK is fixed.
+	if err := checkBudget(b); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append(data, '\n'), 0666)
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func buildApk(t *testing.T, entries map[string][]byte, stored ...string) *bytes.Reader {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, name := range []string{"AndroidManifest.xml", "classes.dex", "classes2.dex", "resources.arsc",
+		"res/drawable-hdpi-v4/icon.png", "res/drawable-xhdpi-v4/icon.png", "res/layout/main.xml", "res/a1.xml",
+		"lib/arm64-v8a/libfoo.so", "lib/arm64-v8a/libbar.so", "lib/armeabi-v7a/libfoo.so",
+		"assets/data.bin", "META-INF/CERT.RSA"} {
+		data, ok := entries[name]
+		if !ok {
+			continue
+		}
+		method := zip.Deflate
+		for _, s := range stored {
+			if s == name {
+				method = zip.Store
+			}
+		}
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestReadApk(t *testing.T) {
+	types := []string{"Lcom/example/Foo;", "Ljava/lang/Object;"}
+	apk := buildApk(t, map[string][]byte{
+		"AndroidManifest.xml":            []byte("manifest"),
+		"classes.dex":                    buildDex(types, []uint16{0, 1}, []uint32{0}),
+		"classes2.dex":                   buildDex(types, []uint16{1}, nil),
+		"resources.arsc":                 []byte("table"),
+		"res/drawable-hdpi-v4/icon.png":  []byte("hdpi"),
+		"res/drawable-xhdpi-v4/icon.png": []byte("xhdpi"),
+		"res/layout/main.xml":            []byte("layout"),
+		"res/a1.xml":                     []byte("shortened"),
+		"lib/arm64-v8a/libfoo.so":        []byte(strings.Repeat("a", 100)),
+		"lib/arm64-v8a/libbar.so":        []byte("bar"),
+		"lib/armeabi-v7a/libfoo.so":      []byte("foo"),
+		"assets/data.bin":                []byte("data"),
+		"META-INF/CERT.RSA":              []byte("cert"),
+	}, "lib/arm64-v8a/libfoo.so")
+
+	b, err := readApk(apk, apk.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Files != 13 {
+		t.Errorf("want 13 files, got %d", b.Files)
+	}
+	if want := (dexCounts{Classes: 1, Methods: 3}); b.Dex != want {
+		t.Errorf("want dex counts %v, got %v", want, b.Dex)
+	}
+	wantPackages := []dexPackage{
+		{Package: "com.example", dexCounts: dexCounts{Classes: 1, Methods: 1}},
+		{Package: "java.lang", dexCounts: dexCounts{Methods: 2}},
+	}
+	if !reflect.DeepEqual(b.DexPackages, wantPackages) {
+		t.Errorf("want dex packages %v, got %v", wantPackages, b.DexPackages)
+	}
+
+	var resources []string
+	for _, r := range b.Resources {
+		resources = append(resources, r.Type+":"+r.Config)
+	}
+	wantResources := []string{"drawable:hdpi-v4", "drawable:xhdpi-v4", "layout:", "unknown:"}
+	if !reflect.DeepEqual(resources, wantResources) {
+		t.Errorf("want resources %q, got %q", wantResources, resources)
+	}
+
+	if len(b.NativeLibs) != 2 || b.NativeLibs[0].Abi != "arm64-v8a" || b.NativeLibs[0].Files != 2 ||
+		b.NativeLibs[0].Libs[1].Path != "lib/arm64-v8a/libfoo.so" {
+		t.Errorf("unexpected native libs %+v", b.NativeLibs)
+	}
+	if lib := b.NativeLibs[0].Libs[1]; lib.CompressedSize != 100 || lib.UncompressedSize != 100 {
+		t.Errorf("want stored library to be 100 bytes compressed and uncompressed, got %+v", lib)
+	}
+
+	for c, files := range map[string]int{categoryDex: 2, categoryResources: 4, categoryResourceTable: 1,
+		categoryNativeLibs: 3, categoryAssets: 1, categoryManifest: 1, categorySignature: 1} {
+		if got := b.Categories[c]; got == nil || got.Files != files {
+			t.Errorf("want %d files in category %s, got %+v", files, c, got)
+		}
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	b := &breakdown{
+		Apk:        "Foo.apk",
+		FileSize:   1500,
+		SizeBudget: 2000,
+		Categories: map[string]*sizes{
+			categoryDex:        {Files: 1, CompressedSize: 400, UncompressedSize: 1000},
+			categoryNativeLibs: {Files: 2, CompressedSize: 1000, UncompressedSize: 1000},
+		},
+	}
+	if err := checkBudget(b); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	b.SizeBudget = 1000
+	want := "Foo.apk is 1500 bytes, which is 500 bytes over its size budget of 1000 bytes:\n" +
+		"  native_libs: 1000 bytes in 2 files (1000 bytes uncompressed)\n" +
+		"  dex: 400 bytes in 1 files (1000 bytes uncompressed)"
+	if err := checkBudget(b); err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %v", want, err)
+	}
+}
+
+func TestRunOverBudget(t *testing.T) {
+	dir := t.TempDir()
+	apk := filepath.Join(dir, "Foo.apk")
+	data, err := io.ReadAll(buildApk(t, map[string][]byte{"assets/data.bin": bytes.Repeat([]byte{1}, 100)},
+		"assets/data.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(apk, data, 0666); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "Foo.apk.json")
+
+	if err := run(apk, output, 10); err == nil {
+		t.Errorf("expected an error for an APK over its budget")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected no report for an APK over its budget, got %v", err)
+	}
+
+	if err := run(apk, output, uint64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Errorf("expected a report for an APK within its budget: %s", err)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Offsets and sizes of the parts of the dex format that are read, see
+// https://source.android.com/docs/core/runtime/dex-format.
+const (
+	dexMagicPrefix  = "dex\n"
+	dexHeaderSize   = 0x70
+	dexEndianTagOff = 0x28
+	dexEndianTag    = 0x12345678
+
+	// Offsets of the size and offset pairs of the sections in the header
+	dexStringIdsOff = 0x38
+	dexTypeIdsOff   = 0x40
+	dexMethodIdsOff = 0x58
+	dexClassDefsOff = 0x60
+
+	dexStringIdSize = 4
+	dexTypeIdSize   = 4
+	dexMethodIdSize = 8
+	dexClassDefSize = 32
+)
+
+// Package that classes without a package are attributed to.
+const defaultPackage = "(default)"
+
+// dexCounts are the number of classes defined and methods referenced by one or more dex files.
+type dexCounts struct {
+	Classes int `json:"classes"`
+
+	// Number of method references, which is what the 64K limit of a dex file applies to
+	Methods int `json:"methods"`
+}
+
+// dexPackage are the counts of the classes in a Java package.
+type dexPackage struct {
+	Package string `json:"package"`
+	dexCounts
+}
+
+// dexFile reads the parts of a dex file that are needed to count classes and methods.
+type dexFile struct {
+	data []byte
+}
+
+func (d *dexFile) uint32At(off uint32) (uint32, error) {
+	if uint64(off)+4 > uint64(len(d.data)) {
+		return 0, fmt.Errorf("offset %#x out of bounds", off)
+	}
+	return binary.LittleEndian.Uint32(d.data[off:]), nil
+}
+
+func (d *dexFile) uint16At(off uint32) (uint16, error) {
+	if uint64(off)+2 > uint64(len(d.data)) {
+		return 0, fmt.Errorf("offset %#x out of bounds", off)
+	}
+	return binary.LittleEndian.Uint16(d.data[off:]), nil
+}
+
+// section returns the size and offset of the section whose header is at off.
+func (d *dexFile) section(off uint32) (size, offset uint32, err error) {
+	if size, err = d.uint32At(off); err != nil {
+		return 0, 0, err
+	}
+	offset, err = d.uint32At(off + 4)
+	return size, offset, err
+}
+
+// str returns the string with index idx, which is MUTF-8 encoded after its ULEB128 encoded
+// length in UTF-16 code units.
+func (d *dexFile) str(idx uint32) (string, error) {
+	size, off, err := d.section(dexStringIdsOff)
+	if err != nil {
+		return "", err
+	}
+	if idx >= size {
+		return "", fmt.Errorf("string index %d out of bounds", idx)
+	}
+	dataOff, err := d.uint32At(off + idx*dexStringIdSize)
+	if err != nil {
+		return "", err
+	}
+	for dataOff < uint32(len(d.data)) && d.data[dataOff]&0x80 != 0 {
+		dataOff++
+	}
+	dataOff++
+	if dataOff > uint32(len(d.data)) {
+		return "", fmt.Errorf("string %d out of bounds", idx)
+	}
+	end := bytes.IndexByte(d.data[dataOff:], 0)
+	if end < 0 {
+		return "", fmt.Errorf("string %d isn't terminated", idx)
+	}
+	return string(d.data[dataOff : dataOff+uint32(end)]), nil
+}
+
+// typeDescriptor returns the descriptor of the type with index idx, e.g. Ljava/lang/Object;.
+func (d *dexFile) typeDescriptor(idx uint32) (string, error) {
+	size, off, err := d.section(dexTypeIdsOff)
+	if err != nil {
+		return "", err
+	}
+	if idx >= size {
+		return "", fmt.Errorf("type index %d out of bounds", idx)
+	}
+	strIdx, err := d.uint32At(off + idx*dexTypeIdSize)
+	if err != nil {
+		return "", err
+	}
+	return d.str(strIdx)
+}
+
+// packageOf returns the Java package of a type descriptor, e.g. java.lang for
+// Ljava/lang/Object;. Array types are attributed to the package of their element type.
+func packageOf(descriptor string) string {
+	descriptor = strings.TrimLeft(descriptor, "[")
+	if !strings.HasPrefix(descriptor, "L") {
+		return defaultPackage
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(descriptor, "L"), ";")
+	i := strings.LastIndexByte(name, '/')
+	if i < 0 {
+		return defaultPackage
+	}
+	return strings.ReplaceAll(name[:i], "/", ".")
+}
+
+// countDex adds the classes defined and the methods referenced by a dex file to packages.
+func countDex(data []byte, packages map[string]*dexCounts) error {
+	if len(data) < dexHeaderSize || !bytes.HasPrefix(data, []byte(dexMagicPrefix)) {
+		return fmt.Errorf("not a dex file")
+	}
+	d := &dexFile{data: data}
+	if tag, _ := d.uint32At(dexEndianTagOff); tag != dexEndianTag {
+		return fmt.Errorf("unsupported endianness")
+	}
+
+	counts := func(typeIdx uint32) (*dexCounts, error) {
+		descriptor, err := d.typeDescriptor(typeIdx)
+		if err != nil {
+			return nil, err
+		}
+		pkg := packageOf(descriptor)
+		if packages[pkg] == nil {
+			packages[pkg] = &dexCounts{}
+		}
+		return packages[pkg], nil
+	}
+
+	size, off, err := d.section(dexMethodIdsOff)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		classIdx, err := d.uint16At(off + i*dexMethodIdSize)
+		if err != nil {
+			return err
+		}
+		c, err := counts(uint32(classIdx))
+		if err != nil {
+			return err
+		}
+		c.Methods++
+	}
+
+	size, off, err = d.section(dexClassDefsOff)
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		classIdx, err := d.uint32At(off + i*dexClassDefSize)
+		if err != nil {
+			return err
+		}
+		c, err := counts(classIdx)
+		if err != nil {
+			return err
+		}
+		c.Classes++
+	}
+	return nil
+}
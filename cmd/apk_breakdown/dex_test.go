@@ -0,0 +1,105 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// buildDex returns a dex file with the given type descriptors, method references to classes
+// and class definitions, both as indices into types. Only the parts of the format that countDex
+// reads are filled in.
+func buildDex(types []string, methods []uint16, classes []uint32) []byte {
+	le := binary.LittleEndian
+	data := make([]byte, dexHeaderSize)
+	copy(data, dexMagicPrefix+"035\x00")
+	le.PutUint32(data[dexEndianTagOff:], dexEndianTag)
+
+	section := func(headerOff int, count int, itemSize int) int {
+		off := len(data)
+		le.PutUint32(data[headerOff:], uint32(count))
+		le.PutUint32(data[headerOff+4:], uint32(off))
+		data = append(data, make([]byte, count*itemSize)...)
+		return off
+	}
+	stringIds := section(dexStringIdsOff, len(types), dexStringIdSize)
+	typeIds := section(dexTypeIdsOff, len(types), dexTypeIdSize)
+	methodIds := section(dexMethodIdsOff, len(methods), dexMethodIdSize)
+	classDefs := section(dexClassDefsOff, len(classes), dexClassDefSize)
+
+	for i, t := range types {
+		le.PutUint32(data[stringIds+i*dexStringIdSize:], uint32(len(data)))
+		le.PutUint32(data[typeIds+i*dexTypeIdSize:], uint32(i))
+		data = append(data, byte(len(t)))
+		data = append(data, t...)
+		data = append(data, 0)
+	}
+	for i, m := range methods {
+		le.PutUint16(data[methodIds+i*dexMethodIdSize:], m)
+	}
+	for i, c := range classes {
+		le.PutUint32(data[classDefs+i*dexClassDefSize:], c)
+	}
+	return data
+}
+
+func TestCountDex(t *testing.T) {
+	types := []string{"Lcom/example/Foo;", "Lcom/example/Bar;", "Ljava/lang/Object;", "[Ljava/lang/String;", "LMain;", "I"}
+	dex := buildDex(types, []uint16{0, 0, 1, 2, 3, 4}, []uint32{0, 1, 4})
+
+	packages := make(map[string]*dexCounts)
+	if err := countDex(dex, packages); err != nil {
+		t.Fatal(err)
+	}
+	// A second dex file adds to the counts.
+	if err := countDex(buildDex(types, []uint16{2}, []uint32{}), packages); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*dexCounts{
+		"com.example":  {Classes: 2, Methods: 3},
+		"java.lang":    {Methods: 3},
+		defaultPackage: {Classes: 1, Methods: 1},
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("want %v, got %v", want, packages)
+	}
+}
+
+func TestCountDexInvalid(t *testing.T) {
+	if err := countDex([]byte("PK\x03\x04"), map[string]*dexCounts{}); err == nil {
+		t.Error("expected an error for a file that isn't a dex file")
+	}
+
+	dex := buildDex([]string{"LFoo;"}, []uint16{1}, nil)
+	if err := countDex(dex, map[string]*dexCounts{}); err == nil {
+		t.Error("expected an error for a method of a type that doesn't exist")
+	}
+}
+
+func TestPackageOf(t *testing.T) {
+	for descriptor, want := range map[string]string{
+		"Landroid/app/Activity;": "android.app",
+		"[[Ljava/lang/String;":   "java.lang",
+		"LMain;":                 defaultPackage,
+		"[I":                     defaultPackage,
+	} {
+		if got := packageOf(descriptor); got != want {
+			t.Errorf("packageOf(%q) = %q, want %q", descriptor, got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// bpf_manifest inspects compiled BPF objects and checks that bpfloader can load them: programs are
+// in sections named after a program type bpfloader knows and the objects have a license. It writes a
+// JSON manifest listing the programs and maps of each object. With --check_shared_maps it instead
+// reads the manifests of all of the bpf modules and checks that maps are defined consistently by
+// all of their objects.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// manifest is the JSON manifest that is installed next to the BPF objects.
+type manifest struct {
+	Objects []*bpfObject `json:"objects"`
+}
+
+func main() {
+	output := flag.String("output", "", "file to write the manifest to")
+	sharedMaps := flag.Bool("check_shared_maps", false,
+		"check the maps of the objects in the manifests given as arguments instead of writing a manifest")
+	flag.Parse()
+
+	if (*output != "") == *sharedMaps || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	if *sharedMaps {
+		err = checkManifests(flag.Args())
+	} else {
+		err = run(flag.Args(), *output)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// checkSharedMaps returns errors for maps that are defined by more than one object with
+// definitions that don't create the same map.
+func checkSharedMaps(objects []*bpfObject) []string {
+	type definition struct {
+		object string
+		m      bpfMap
+	}
+	first := make(map[string]definition)
+	var errs []string
+	for _, o := range objects {
+		for _, m := range o.Maps {
+			d, ok := first[m.Name]
+			if !ok {
+				first[m.Name] = definition{o.Object, m}
+				continue
+			}
+			if !d.m.compatible(m) {
+				errs = append(errs, fmt.Sprintf("map %s is defined differently by %s (%s) and %s (%s)",
+					m.Name, d.object, d.m, o.Object, m))
+			}
+		}
+	}
+	return errs
+}
+
+func run(paths []string, output string) error {
+	var m manifest
+	var errs []string
+	for _, path := range paths {
+		o, err := readBpfObject(path)
+		if err != nil {
+			return err
+		}
+		m.Objects = append(m.Objects, o)
+		errs = append(errs, o.errs...)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d problems with BPF objects:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, append(data, '\n'), 0666)
+}
+
+// checkManifests checks the shared maps of the objects in the manifests of all of the bpf modules,
+// as objects in different modules create the same map if they use the same name for it.
+func checkManifests(paths []string) error {
+	var objects []*bpfObject
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var m manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, o := range m.Objects {
+			o.Object = path + ":" + o.Object
+			objects = append(objects, o)
+		}
+	}
+
+	if errs := checkSharedMaps(objects); len(errs) > 0 {
+		return fmt.Errorf("found %d problems with BPF maps:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckSharedMaps(t *testing.T) {
+	hash := bpfMap{Name: "stats_map", Type: "hash", KeySize: 4, ValueSize: 8, MaxEntries: 64, Mode: 0660}
+	otherOwner := hash
+	otherOwner.Gid = 1000
+	bigger := hash
+	bigger.MaxEntries = 128
+	array := bpfMap{Name: "config_map", Type: "array", KeySize: 4, ValueSize: 4, MaxEntries: 1}
+
+	objects := []*bpfObject{
+		{Object: "a.o", Maps: []bpfMap{array, hash}},
+		{Object: "b.o", Maps: []bpfMap{otherOwner}},
+		{Object: "c.o", Maps: []bpfMap{array, bigger}},
+	}
+
+	want := []string{
+		"map stats_map is defined differently by a.o (type hash, key_size 4, value_size 8, max_entries 64, map_flags 0x0) " +
+			"and c.o (type hash, key_size 4, value_size 8, max_entries 128, map_flags 0x0)",
+	}
+	if got := checkSharedMaps(objects); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestCheckManifests(t *testing.T) {
+	dir := t.TempDir()
+	writeManifest := func(name string, objects ...*bpfObject) string {
+		data, err := json.Marshal(manifest{Objects: objects})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	hash := bpfMap{Name: "stats_map", Type: "hash", KeySize: 4, ValueSize: 8, MaxEntries: 64}
+	bigger := hash
+	bigger.MaxEntries = 128
+	a := writeManifest("a.manifest.json", &bpfObject{Object: "a.o", Maps: []bpfMap{hash}})
+	b := writeManifest("b.manifest.json", &bpfObject{Object: "b.o", Maps: []bpfMap{hash}})
+	c := writeManifest("c.manifest.json", &bpfObject{Object: "c.o", Maps: []bpfMap{bigger}})
+
+	if err := checkManifests([]string{a, b}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	err := checkManifests([]string{a, b, c})
+	if err == nil {
+		t.Fatal("expected an error for the maps of a.o and c.o")
+	}
+	if want := "map stats_map is defined differently by " + a + ":a.o"; !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, got %q", want, err)
+	}
+	if want := c + ":c.o"; !strings.Contains(err.Error(), want) {
+		t.Errorf("want error containing %q, got %q", want, err)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prefixes of the program section names that bpfloader understands, and the program types it
+// loads them as.
+var programTypes = map[string]string{
+	"bind4/":             "cgroup_sock_addr",
+	"bind6/":             "cgroup_sock_addr",
+	"cgroupskb/":         "cgroup_skb",
+	"cgroupsock/":        "cgroup_sock",
+	"cgroupsockcreate/":  "cgroup_sock",
+	"cgroupsockrelease/": "cgroup_sock",
+	"connect4/":          "cgroup_sock_addr",
+	"connect6/":          "cgroup_sock_addr",
+	"egress/":            "cgroup_skb",
+	"getsockopt/":        "cgroup_sockopt",
+	"ingress/":           "cgroup_skb",
+	"kprobe/":            "kprobe",
+	"kretprobe/":         "kprobe",
+	"lwt_in/":            "lwt_in",
+	"lwt_out/":           "lwt_out",
+	"lwt_seg6local/":     "lwt_seg6local",
+	"lwt_xmit/":          "lwt_xmit",
+	"perf_event/":        "perf_event",
+	"postbind4/":         "cgroup_sock",
+	"postbind6/":         "cgroup_sock",
+	"recvmsg4/":          "cgroup_sock_addr",
+	"recvmsg6/":          "cgroup_sock_addr",
+	"schedact/":          "sched_act",
+	"schedcls/":          "sched_cls",
+	"sendmsg4/":          "cgroup_sock_addr",
+	"sendmsg6/":          "cgroup_sock_addr",
+	"setsockopt/":        "cgroup_sockopt",
+	"skfilter/":          "socket_filter",
+	"sockops/":           "sock_ops",
+	"sysctl/":            "cgroup_sysctl",
+	"tracepoint/":        "tracepoint",
+	"uprobe/":            "kprobe",
+	"uretprobe/":         "kprobe",
+	"xdp/":               "xdp",
+}
+
+// Names of the map types, indexed by enum bpf_map_type.
+var mapTypes = []string{
+	"unspec",
+	"hash",
+	"array",
+	"prog_array",
+	"perf_event_array",
+	"percpu_hash",
+	"percpu_array",
+	"stack_trace",
+	"cgroup_array",
+	"lru_hash",
+	"lru_percpu_hash",
+	"lpm_trie",
+	"array_of_maps",
+	"hash_of_maps",
+	"devmap",
+	"sockmap",
+	"cpumap",
+	"xskmap",
+	"sockhash",
+	"cgroup_storage",
+	"reuseport_sockarray",
+	"percpu_cgroup_storage",
+	"queue",
+	"stack",
+	"sk_storage",
+	"devmap_hash",
+	"struct_ops",
+	"ringbuf",
+}
+
+// Sections that bpfloader reads in addition to the programs.
+const (
+	licenseSection    = "license"
+	criticalSection   = "critical"
+	mapsSection       = "maps"
+	mapDefSizeSection = "size_of_bpf_map_def"
+)
+
+// Size of the fields of struct bpf_map_def that every version of bpfloader understands.
+const minMapDefSize = 5 * 4
+
+// bpfProgram is a program of a BPF object, from a section named <type prefix>/<name>[$<suffix>].
+type bpfProgram struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+}
+
+// bpfMap is a map definition from the maps section of a BPF object, i.e. the leading fields of
+// struct bpf_map_def.
+type bpfMap struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	KeySize    uint32 `json:"key_size"`
+	ValueSize  uint32 `json:"value_size"`
+	MaxEntries uint32 `json:"max_entries"`
+	Flags      uint32 `json:"map_flags"`
+	Uid        uint32 `json:"uid"`
+	Gid        uint32 `json:"gid"`
+	Mode       uint32 `json:"mode"`
+}
+
+// compatible returns whether two definitions of a map create the same map.
+func (m bpfMap) compatible(other bpfMap) bool {
+	return m.Type == other.Type && m.KeySize == other.KeySize && m.ValueSize == other.ValueSize &&
+		m.MaxEntries == other.MaxEntries && m.Flags == other.Flags
+}
+
+func (m bpfMap) String() string {
+	return fmt.Sprintf("type %s, key_size %d, value_size %d, max_entries %d, map_flags %#x",
+		m.Type, m.KeySize, m.ValueSize, m.MaxEntries, m.Flags)
+}
+
+// bpfObject is the information of a BPF object that ends up in the manifest.
+type bpfObject struct {
+	Object   string       `json:"object"`
+	License  string       `json:"license"`
+	Critical string       `json:"critical,omitempty"`
+	Programs []bpfProgram `json:"programs"`
+	Maps     []bpfMap     `json:"maps"`
+
+	// Problems that would make bpfloader reject the object
+	errs []string
+}
+
+// programType returns the program type of a section with the given name, and the name of the
+// program, or false if it isn't a section name bpfloader loads programs from.
+func programType(section string) (typ, name string, ok bool) {
+	for prefix, t := range programTypes {
+		if rest, found := strings.CutPrefix(section, prefix); found {
+			name, _, _ = strings.Cut(rest, "$")
+			if name == "" {
+				return "", "", false
+			}
+			return t, name, true
+		}
+	}
+	return "", "", false
+}
+
+func mapTypeName(t uint32) string {
+	if int(t) < len(mapTypes) {
+		return mapTypes[t]
+	}
+	return fmt.Sprintf("unknown(%d)", t)
+}
+
+// readBpfObject reads the programs and maps of a BPF object and checks that bpfloader can load it.
+func readBpfObject(path string) (*bpfObject, error) {
+	ef, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ef.Close()
+
+	o := &bpfObject{
+		Object:   filepath.Base(path),
+		Programs: []bpfProgram{},
+		Maps:     []bpfMap{},
+	}
+	errorf := func(format string, args ...interface{}) {
+		o.errs = append(o.errs, fmt.Sprintf("%s: ", path)+fmt.Sprintf(format, args...))
+	}
+
+	if ef.Machine != elf.EM_BPF {
+		return nil, fmt.Errorf("%s: not a BPF object, machine is %s", path, ef.Machine)
+	}
+
+	stringSection := func(name string) (string, bool, error) {
+		s := ef.Section(name)
+		if s == nil {
+			return "", false, nil
+		}
+		data, err := s.Data()
+		if err != nil {
+			return "", false, fmt.Errorf("%s: section %s: %w", path, name, err)
+		}
+		return strings.TrimRight(string(data), "\x00"), true, nil
+	}
+
+	license, ok, err := stringSection(licenseSection)
+	if err != nil {
+		return nil, err
+	} else if !ok || license == "" {
+		errorf("missing %q section, bpfloader won't load programs without a license", licenseSection)
+	}
+	o.License = license
+
+	if o.Critical, _, err = stringSection(criticalSection); err != nil {
+		return nil, err
+	}
+
+	for _, s := range ef.Sections {
+		if s.Type != elf.SHT_PROGBITS || s.Flags&elf.SHF_EXECINSTR == 0 {
+			continue
+		}
+		if s.Name == ".text" {
+			if s.Size > 0 {
+				errorf("code in the .text section isn't loaded by bpfloader, mark functions " +
+					"static inline or put them in a program section")
+			}
+			continue
+		}
+		typ, name, ok := programType(s.Name)
+		if !ok {
+			errorf("section %q isn't a program section bpfloader knows about", s.Name)
+			continue
+		}
+		o.Programs = append(o.Programs, bpfProgram{Section: s.Name, Name: name, Type: typ})
+	}
+	sort.Slice(o.Programs, func(i, j int) bool { return o.Programs[i].Section < o.Programs[j].Section })
+
+	maps := ef.Section(mapsSection)
+	if maps == nil {
+		return o, nil
+	}
+	data, err := maps.Data()
+	if err != nil {
+		return nil, fmt.Errorf("%s: section %s: %w", path, mapsSection, err)
+	}
+
+	mapDefSize := uint64(0)
+	if s := ef.Section(mapDefSizeSection); s != nil {
+		sizeData, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("%s: section %s: %w", path, mapDefSizeSection, err)
+		}
+		if len(sizeData) >= 4 {
+			mapDefSize = uint64(ef.ByteOrder.Uint32(sizeData))
+		}
+	}
+
+	syms, err := ef.Symbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, sym := range syms {
+		if sym.Name == "" || int(sym.Section) >= len(ef.Sections) || ef.Sections[sym.Section] != maps ||
+			elf.ST_TYPE(sym.Info) == elf.STT_SECTION {
+			continue
+		}
+		size := sym.Size
+		if mapDefSize != 0 {
+			size = mapDefSize
+		}
+		if size < minMapDefSize || sym.Value+size > uint64(len(data)) {
+			errorf("map %s has an invalid definition of %d bytes", sym.Name, size)
+			continue
+		}
+		m := parseMapDef(sym.Name, data[sym.Value:sym.Value+size], ef.ByteOrder)
+		if m.Type == mapTypes[0] || strings.HasPrefix(m.Type, "unknown") {
+			errorf("map %s has invalid type %s", m.Name, m.Type)
+		}
+		if m.MaxEntries == 0 {
+			errorf("map %s has max_entries 0", m.Name)
+		}
+		o.Maps = append(o.Maps, m)
+	}
+	sort.Slice(o.Maps, func(i, j int) bool { return o.Maps[i].Name < o.Maps[j].Name })
+
+	return o, nil
+}
+
+// parseMapDef parses the fields of struct bpf_map_def that describe the map and its owner. The
+// owner fields are left zero if the definition is too short to contain them.
+func parseMapDef(name string, def []byte, order binary.ByteOrder) bpfMap {
+	field := func(i int) uint32 {
+		if (i+1)*4 > len(def) {
+			return 0
+		}
+		return order.Uint32(def[i*4:])
+	}
+	return bpfMap{
+		Name:       name,
+		Type:       mapTypeName(field(0)),
+		KeySize:    field(1),
+		ValueSize:  field(2),
+		MaxEntries: field(3),
+		Flags:      field(4),
+		Uid:        field(6),
+		Gid:        field(7),
+		Mode:       field(8),
+	}
+}
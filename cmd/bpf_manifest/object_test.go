@@ -0,0 +1,69 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestProgramType(t *testing.T) {
+	tests := []struct {
+		section  string
+		wantType string
+		wantName string
+		wantOk   bool
+	}{
+		{"schedcls/ingress", "sched_cls", "ingress", true},
+		{"skfilter/allowlist$4_14", "socket_filter", "allowlist", true},
+		{"cgroupsockcreate/inet_create", "cgroup_sock", "inet_create", true},
+		{"kretprobe/do_exit", "kprobe", "do_exit", true},
+		{"tracepoint/sched/sched_switch", "tracepoint", "sched/sched_switch", true},
+		{"schedcls/", "", "", false},
+		{"classifier", "", "", false},
+		{"socket/filter", "", "", false},
+	}
+	for _, test := range tests {
+		typ, name, ok := programType(test.section)
+		if typ != test.wantType || name != test.wantName || ok != test.wantOk {
+			t.Errorf("programType(%q) = %q, %q, %v, want %q, %q, %v", test.section,
+				typ, name, ok, test.wantType, test.wantName, test.wantOk)
+		}
+	}
+}
+
+func TestParseMapDef(t *testing.T) {
+	def := func(fields ...uint32) []byte {
+		var ret []byte
+		for _, f := range fields {
+			ret = binary.LittleEndian.AppendUint32(ret, f)
+		}
+		return ret
+	}
+
+	got := parseMapDef("cookie_tag_map", def(1, 8, 16, 10000, 1, 0, 0, 1000, 0660, 0, 0), binary.LittleEndian)
+	want := bpfMap{Name: "cookie_tag_map", Type: "hash", KeySize: 8, ValueSize: 16, MaxEntries: 10000,
+		Flags: 1, Uid: 0, Gid: 1000, Mode: 0660}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	got = parseMapDef("short", def(99, 4, 4, 1, 0), binary.LittleEndian)
+	want = bpfMap{Name: "short", Type: "unknown(99)", KeySize: 4, ValueSize: 4, MaxEntries: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
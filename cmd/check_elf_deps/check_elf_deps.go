@@ -0,0 +1,145 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// check_elf_deps verifies that the DT_NEEDED entries of the ELF files installed in a set of
+// partitions and APEXes resolve to libraries that are visible under the linker namespace
+// configuration, and that the executables don't have undefined symbols. It can also check that
+// the linker configuration itself is consistent with the installed files, and render the namespace
+// graph in the DOT language.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// config is the input of this tool.
+type config struct {
+	Entries    []entry           `json:"entries"`
+	Namespaces []namespaceConfig `json:"namespaces"`
+}
+
+// linkerConfigFlags collects --linker_config <namespace>=<linker.config.json> flags.
+type linkerConfigFlags map[string][]string
+
+func (l linkerConfigFlags) String() string {
+	return ""
+}
+
+func (l linkerConfigFlags) Set(s string) error {
+	ns, file, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected <namespace>=<linker.config.json>, got %q", s)
+	}
+	l[ns] = append(l[ns], file)
+	return nil
+}
+
+func main() {
+	configFile := flag.String("config", "", "JSON file listing the installed files and the namespaces")
+	output := flag.String("output", "", "file to write the report to")
+	checkLinkerConfig := flag.Bool("check_linker_config", false,
+		"also check that provideLibs are installed by their namespace and that requireLibs are provided by another namespace")
+	dot := flag.String("dot", "", "file to write the namespace graph to, in the DOT language")
+	linkerConfigs := make(linkerConfigFlags)
+	flag.Var(linkerConfigs, "linker_config", "<namespace>=<linker.config.json> with provideLibs and requireLibs of the namespace")
+	flag.Parse()
+
+	if *configFile == "" || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*configFile, linkerConfigs, *checkLinkerConfig, *dot, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func readJson(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+func run(configFile string, linkerConfigs linkerConfigFlags, checkLinkerConfig bool, dot string, output string) error {
+	var c config
+	if err := readJson(configFile, &c); err != nil {
+		return err
+	}
+
+	a := newAnalysis()
+	for _, ns := range c.Namespaces {
+		a.addConfig(ns)
+	}
+	for ns, files := range linkerConfigs {
+		for _, file := range files {
+			lc := namespaceConfig{Name: ns}
+			if err := readJson(file, &lc); err != nil {
+				return err
+			}
+			lc.Name = ns
+			a.addConfig(lc)
+		}
+	}
+
+	for _, e := range c.Entries {
+		var info *elfInfo
+		if e.File != "" {
+			var err error
+			if info, err = readElf(e.File); err != nil {
+				return err
+			}
+		}
+		a.addEntry(e, info)
+	}
+
+	if dot != "" {
+		f, err := os.Create(dot)
+		if err != nil {
+			return err
+		}
+		if err := a.writeDot(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	errs := a.check()
+	if checkLinkerConfig {
+		errs = append(errs, a.checkLinkerConfig()...)
+	}
+	report := strings.Join(errs, "\n")
+	if len(errs) > 0 {
+		report += "\n"
+	}
+	if err := os.WriteFile(output, []byte(report), 0666); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d problems with native dependencies:\n%s", len(errs), report)
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Maximum number of undefined symbols that are reported per object.
+const maxReportedSymbols = 10
+
+// entry is a file installed in a partition or an APEX.
+type entry struct {
+	// Partition the file is installed in, e.g. "system", "vendor" or "apex"
+	Partition string `json:"partition"`
+
+	// Path relative to the partition. For the "apex" partition, the first component is the name
+	// of the APEX.
+	Path string `json:"path"`
+
+	// Path to the built file. Empty for symlinks.
+	File string `json:"file,omitempty"`
+
+	// Name of the module that installed the file
+	Module string `json:"module,omitempty"`
+}
+
+// namespaceConfig is the linker configuration of a namespace, i.e. a partition or an APEX.
+type namespaceConfig struct {
+	Name string `json:"name"`
+
+	// Libraries that this namespace provides to other namespaces
+	ProvideLibs []string `json:"provideLibs"`
+
+	// Libraries that this namespace requires from other namespaces
+	RequireLibs []string `json:"requireLibs"`
+}
+
+// object is a file installed in a namespace.
+type object struct {
+	entry
+	namespace *namespace
+
+	// Path relative to the root of the namespace
+	nsPath string
+
+	// Nil if this isn't an ELF file, or if this is a symlink.
+	elf *elfInfo
+}
+
+func (o *object) String() string {
+	if o.namespace.apex {
+		return "/apex/" + o.namespace.name + "/" + o.nsPath
+	}
+	return "/" + o.namespace.name + "/" + o.nsPath
+}
+
+// libDir returns "lib64" or "lib" according to the bitness of the object.
+func (o *object) libDir() string {
+	if o.elf != nil && o.elf.is64 {
+		return "lib64"
+	}
+	return "lib"
+}
+
+type namespace struct {
+	name string
+	apex bool
+
+	// Whether files installed in this namespace are analyzed
+	installed bool
+
+	provideLibs map[string]bool
+	requireLibs map[string]bool
+
+	// Libraries directly under lib/ and lib64/, keyed by the directory and then the file name
+	libs map[string]map[string]*object
+}
+
+type analysis struct {
+	namespaces map[string]*namespace
+	objects    []*object
+}
+
+func newAnalysis() *analysis {
+	return &analysis{namespaces: make(map[string]*namespace)}
+}
+
+func (a *analysis) namespace(name string, apex bool) *namespace {
+	ns, ok := a.namespaces[name]
+	if !ok {
+		ns = &namespace{
+			name:        name,
+			apex:        apex,
+			provideLibs: make(map[string]bool),
+			requireLibs: make(map[string]bool),
+			libs:        map[string]map[string]*object{"lib": {}, "lib64": {}},
+		}
+		a.namespaces[name] = ns
+	}
+	return ns
+}
+
+func (a *analysis) addConfig(c namespaceConfig) {
+	ns := a.namespace(c.Name, false)
+	for _, lib := range c.ProvideLibs {
+		ns.provideLibs[lib] = true
+	}
+	for _, lib := range c.RequireLibs {
+		ns.requireLibs[lib] = true
+	}
+}
+
+// addEntry adds an installed file. elf is nil if the file is not an ELF file.
+func (a *analysis) addEntry(e entry, elf *elfInfo) {
+	nsName, nsPath, apex := e.Partition, e.Path, false
+	if e.Partition == "apex" {
+		var ok bool
+		if nsName, nsPath, ok = strings.Cut(e.Path, "/"); !ok {
+			return
+		}
+		apex = true
+	}
+	ns := a.namespace(nsName, apex)
+	ns.apex = apex
+	ns.installed = true
+	o := &object{entry: e, namespace: ns, nsPath: nsPath, elf: elf}
+	a.objects = append(a.objects, o)
+
+	dir, file := path.Split(nsPath)
+	dir = strings.TrimSuffix(dir, "/")
+	if libs, ok := ns.libs[dir]; ok {
+		libs[file] = o
+	}
+}
+
+// resolution is the result of resolving a DT_NEEDED entry.
+type resolution struct {
+	// Nil if the library is declared to be provided from outside the analyzed files.
+	lib *object
+	err string
+}
+
+// resolve finds the library that satisfies the DT_NEEDED entry `needed` of o, following the
+// linker namespace configuration.
+func (a *analysis) resolve(o *object, needed string) resolution {
+	name := path.Base(needed)
+	dir := o.libDir()
+	ns := o.namespace
+
+	// Libraries in the same namespace are always visible.
+	if lib, ok := ns.libs[dir][name]; ok {
+		return resolution{lib: lib}
+	}
+
+	var providers, others []*object
+	for _, other := range a.sortedNamespaces() {
+		if other == ns {
+			continue
+		}
+		lib, ok := other.libs[dir][name]
+		if !ok {
+			continue
+		}
+		if other.provideLibs[name] || ns.requireLibs[name] {
+			providers = append(providers, lib)
+		} else {
+			others = append(others, lib)
+		}
+	}
+
+	if len(providers) > 0 {
+		return resolution{lib: providers[0]}
+	}
+	if ns.requireLibs[name] {
+		// Declared to be provided by a namespace that is not in the analyzed files.
+		return resolution{}
+	}
+	if len(others) > 0 {
+		var where []string
+		for _, lib := range others {
+			where = append(where, lib.String())
+		}
+		return resolution{err: fmt.Sprintf("%s needs %s, which is only in other namespaces (%s) that don't provide it",
+			o, name, strings.Join(where, ", "))}
+	}
+	return resolution{err: fmt.Sprintf("%s needs %s, which can't be found", o, name)}
+}
+
+func (a *analysis) sortedNamespaces() []*namespace {
+	var ret []*namespace
+	for _, ns := range a.namespaces {
+		ret = append(ret, ns)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].name < ret[j].name })
+	return ret
+}
+
+// check returns the list of problems found: DT_NEEDED entries that can't be resolved or that are
+// resolved across namespaces without being provided, and undefined symbols of executables that
+// aren't defined in the libraries they load.
+func (a *analysis) check() []string {
+	var errs []string
+	resolved := make(map[*object][]resolution)
+	for _, o := range a.objects {
+		if o.elf == nil {
+			continue
+		}
+		for _, needed := range o.elf.needed {
+			r := a.resolve(o, needed)
+			if r.err != "" {
+				errs = append(errs, r.err)
+			}
+			resolved[o] = append(resolved[o], r)
+		}
+	}
+
+	for _, o := range a.objects {
+		if o.elf == nil || !o.elf.executable {
+			continue
+		}
+		errs = append(errs, checkSymbols(o, resolved)...)
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+// checkSymbols checks that all undefined symbols of the executable and the libraries that it
+// loads are defined by one of them. The check is skipped when some of the libraries are not
+// known, e.g. when they are provided from outside the analyzed files or are symlinks.
+func checkSymbols(exe *object, resolved map[*object][]resolution) []string {
+	closure := []*object{exe}
+	seen := map[*object]bool{exe: true}
+	for i := 0; i < len(closure); i++ {
+		for _, r := range resolved[closure[i]] {
+			if r.err != "" || r.lib == nil || r.lib.elf == nil {
+				return nil
+			}
+			if !seen[r.lib] {
+				seen[r.lib] = true
+				closure = append(closure, r.lib)
+			}
+		}
+	}
+
+	defined := make(map[string]bool)
+	for _, o := range closure {
+		for sym := range o.elf.defined {
+			defined[sym] = true
+		}
+	}
+
+	var errs []string
+	for _, o := range closure {
+		var missing []string
+		for _, sym := range o.elf.undefined {
+			if !defined[sym] {
+				missing = append(missing, sym)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		sort.Strings(missing)
+		missing = dedup(missing)
+		more := ""
+		if len(missing) > maxReportedSymbols {
+			more = fmt.Sprintf(" and %d more", len(missing)-maxReportedSymbols)
+			missing = missing[:maxReportedSymbols]
+		}
+		if o == exe {
+			errs = append(errs, fmt.Sprintf("%s has undefined symbols: %s%s",
+				exe, strings.Join(missing, ", "), more))
+		} else {
+			errs = append(errs, fmt.Sprintf("%s loads %s, which has undefined symbols: %s%s",
+				exe, o, strings.Join(missing, ", "), more))
+		}
+	}
+	return errs
+}
+
+func dedup(sorted []string) []string {
+	var ret []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func lib(needed []string, defined []string, undefined []string) *elfInfo {
+	info := &elfInfo{is64: true, needed: needed, defined: make(map[string]bool), undefined: undefined}
+	for _, s := range defined {
+		info.defined[s] = true
+	}
+	return info
+}
+
+func exe(needed []string, undefined []string) *elfInfo {
+	info := lib(needed, nil, undefined)
+	info.executable = true
+	return info
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []namespaceConfig
+		entries    map[entry]*elfInfo
+		want       []string
+	}{
+		{
+			name: "resolved in same namespace",
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}:         exe([]string{"libbar.so"}, []string{"bar"}),
+				{Partition: "system", Path: "lib64/libbar.so"}: lib(nil, []string{"bar"}, nil),
+			},
+		},
+		{
+			name: "missing library",
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}: exe([]string{"libbar.so"}, nil),
+			},
+			want: []string{"/system/bin/foo needs libbar.so, which can't be found"},
+		},
+		{
+			name: "wrong bitness",
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}:       exe([]string{"libbar.so"}, nil),
+				{Partition: "system", Path: "lib/libbar.so"}: lib(nil, nil, nil),
+			},
+			want: []string{"/system/bin/foo needs libbar.so, which can't be found"},
+		},
+		{
+			name: "cross namespace",
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}:         exe([]string{"libbar.so"}, nil),
+				{Partition: "vendor", Path: "lib64/libbar.so"}: lib(nil, nil, nil),
+			},
+			want: []string{"/system/bin/foo needs libbar.so, which is only in other namespaces " +
+				"(/vendor/lib64/libbar.so) that don't provide it"},
+		},
+		{
+			name: "apex uses library provided by system",
+			namespaces: []namespaceConfig{
+				{Name: "system", ProvideLibs: []string{"libc.so"}},
+			},
+			entries: map[entry]*elfInfo{
+				{Partition: "apex", Path: "com.android.foo/bin/foo"}: exe([]string{"libc.so"}, []string{"malloc"}),
+				{Partition: "system", Path: "lib64/libc.so"}:         lib(nil, []string{"malloc"}, nil),
+			},
+		},
+		{
+			name: "apex uses library not provided by system",
+			entries: map[entry]*elfInfo{
+				{Partition: "apex", Path: "com.android.foo/bin/foo"}: exe([]string{"libbar.so"}, nil),
+				{Partition: "system", Path: "lib64/libbar.so"}:       lib(nil, nil, nil),
+			},
+			want: []string{"/apex/com.android.foo/bin/foo needs libbar.so, which is only in other " +
+				"namespaces (/system/lib64/libbar.so) that don't provide it"},
+		},
+		{
+			name: "system uses library provided by apex",
+			namespaces: []namespaceConfig{
+				{Name: "com.android.foo", ProvideLibs: []string{"libfoo.so"}},
+			},
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}:                       exe([]string{"libfoo.so"}, []string{"foo"}),
+				{Partition: "apex", Path: "com.android.foo/lib64/libfoo.so"}: lib(nil, []string{"foo"}, nil),
+			},
+		},
+		{
+			name: "apex uses missing library",
+			namespaces: []namespaceConfig{
+				{Name: "com.android.foo", ProvideLibs: []string{"libfoo.so"}},
+			},
+			entries: map[entry]*elfInfo{
+				{Partition: "apex", Path: "com.android.foo/bin/foo"}:         exe([]string{"libfoo.so", "libbar.so"}, nil),
+				{Partition: "apex", Path: "com.android.foo/lib64/libfoo.so"}: lib(nil, nil, nil),
+			},
+			want: []string{"/apex/com.android.foo/bin/foo needs libbar.so, which can't be found"},
+		},
+		{
+			name: "required library from outside",
+			namespaces: []namespaceConfig{
+				{Name: "system", RequireLibs: []string{"libicu.so"}},
+			},
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}: exe([]string{"libicu.so"}, []string{"u_foo"}),
+			},
+		},
+		{
+			name: "undefined symbols",
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}:         exe([]string{"libbar.so"}, []string{"bar", "baz"}),
+				{Partition: "system", Path: "lib64/libbar.so"}: lib([]string{"libbaz.so"}, []string{"bar"}, []string{"qux"}),
+				{Partition: "system", Path: "lib64/libbaz.so"}: lib(nil, nil, nil),
+			},
+			want: []string{
+				"/system/bin/foo has undefined symbols: baz",
+				"/system/bin/foo loads /system/lib64/libbar.so, which has undefined symbols: qux",
+			},
+		},
+		{
+			name: "symbols of symlinked library are not checked",
+			entries: map[entry]*elfInfo{
+				{Partition: "system", Path: "bin/foo"}:         exe([]string{"libbar.so"}, []string{"bar"}),
+				{Partition: "system", Path: "lib64/libbar.so"}: nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := newAnalysis()
+			for _, ns := range test.namespaces {
+				a.addConfig(ns)
+			}
+			for e, info := range test.entries {
+				a.addEntry(e, info)
+			}
+			got := a.check()
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+		})
+	}
+}
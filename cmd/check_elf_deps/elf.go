@@ -0,0 +1,107 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// elfInfo is the information from the dynamic section and the dynamic symbol table of an ELF file
+// that is needed to check its dependencies.
+type elfInfo struct {
+	// Whether this is a 64-bit ELF file
+	is64 bool
+
+	// Whether this is an executable, i.e. it has a PT_INTERP program header
+	executable bool
+
+	// DT_NEEDED entries
+	needed []string
+
+	// Symbols that are defined and exported by this file
+	defined map[string]bool
+
+	// Symbols that are undefined and non-weak, i.e. must be provided by a dependency
+	undefined []string
+}
+
+// readElf reads the ELF file at path. It returns nil without an error if the file is not an ELF
+// file.
+func readElf(path string) (*elfInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(f, magic); err != nil || !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+		return nil, nil
+	}
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer ef.Close()
+
+	info := &elfInfo{
+		is64:    ef.Class == elf.ELFCLASS64,
+		defined: make(map[string]bool),
+	}
+	for _, p := range ef.Progs {
+		if p.Type == elf.PT_INTERP {
+			info.executable = true
+		}
+	}
+
+	if ef.Section(".dynamic") == nil {
+		// Statically linked
+		return info, nil
+	}
+
+	if info.needed, err = ef.DynString(elf.DT_NEEDED); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	syms, err := ef.DynamicSymbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, s := range syms {
+		if s.Name == "" {
+			continue
+		}
+		bind := elf.ST_BIND(s.Info)
+		if s.Section == elf.SHN_UNDEF {
+			if bind != elf.STB_WEAK {
+				info.undefined = append(info.undefined, s.Name)
+			}
+			continue
+		}
+		vis := elf.ST_VISIBILITY(s.Other)
+		// STB_LOOS is STB_GNU_UNIQUE
+		if (bind == elf.STB_GLOBAL || bind == elf.STB_WEAK || bind == elf.STB_LOOS) &&
+			(vis == elf.STV_DEFAULT || vis == elf.STV_PROTECTED) {
+			info.defined[s.Name] = true
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// installs returns whether the library is installed directly under lib/ or lib64/ of the
+// namespace.
+func (ns *namespace) installs(lib string) bool {
+	_, ok32 := ns.libs["lib"][lib]
+	_, ok64 := ns.libs["lib64"][lib]
+	return ok32 || ok64
+}
+
+// providers returns the namespaces other than ns that make lib available to it. A library is
+// available when it's in provideLibs of a namespace, or when it's installed by an APEX, whose
+// exported libraries are declared in its apex_manifest rather than in the linker configuration.
+func (a *analysis) providers(ns *namespace, lib string) []*namespace {
+	var ret []*namespace
+	for _, other := range a.sortedNamespaces() {
+		if other == ns {
+			continue
+		}
+		if other.provideLibs[lib] || (other.apex && other.installs(lib)) {
+			ret = append(ret, other)
+		}
+	}
+	return ret
+}
+
+// checkLinkerConfig returns the inconsistencies of the linker configuration: libraries in
+// provideLibs that the namespace doesn't install, and libraries in requireLibs that no other
+// namespace provides. provideLibs is only checked for namespaces whose files are analyzed.
+func (a *analysis) checkLinkerConfig() []string {
+	var errs []string
+	for _, ns := range a.sortedNamespaces() {
+		if ns.installed {
+			for _, lib := range sortedKeys(ns.provideLibs) {
+				if !ns.installs(lib) {
+					errs = append(errs, fmt.Sprintf("namespace %s provides %s, which it doesn't install",
+						ns.name, lib))
+				}
+			}
+		}
+		for _, lib := range sortedKeys(ns.requireLibs) {
+			if len(a.providers(ns, lib)) == 0 {
+				errs = append(errs, fmt.Sprintf("namespace %s requires %s, which no other namespace provides",
+					ns.name, lib))
+			}
+		}
+	}
+	return errs
+}
+
+// writeDot renders the namespaces as a graph in the DOT language. There is an edge from each
+// namespace to the namespaces that provide its requireLibs, labeled with the libraries. Libraries
+// that aren't provided by any namespace point to a separate "unresolved" node.
+func (a *analysis) writeDot(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph linker_namespaces {\n")
+	b.WriteString("  rankdir=LR;\n")
+
+	namespaces := a.sortedNamespaces()
+	for _, ns := range namespaces {
+		shape := "ellipse"
+		if ns.apex {
+			shape = "box"
+		}
+		fmt.Fprintf(&b, "  %q [shape=%s, label=%q];\n", ns.name, shape,
+			fmt.Sprintf("%s\n%d provided", ns.name, len(ns.provideLibs)))
+	}
+
+	unresolved := false
+	for _, ns := range namespaces {
+		edges := make(map[string][]string)
+		for _, lib := range sortedKeys(ns.requireLibs) {
+			providers := a.providers(ns, lib)
+			if len(providers) == 0 {
+				edges[""] = append(edges[""], lib)
+			}
+			for _, p := range providers {
+				edges[p.name] = append(edges[p.name], lib)
+			}
+		}
+		for _, to := range sortedKeys(edges) {
+			label := strings.Join(edges[to], "\n")
+			if to == "" {
+				unresolved = true
+				fmt.Fprintf(&b, "  %q -> \"unresolved\" [color=red, label=%q];\n", ns.name, label)
+			} else {
+				fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", ns.name, to, label)
+			}
+		}
+	}
+	if unresolved {
+		b.WriteString("  \"unresolved\" [shape=octagon, color=red];\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedKeys returns the sorted keys of a map with string keys.
+func sortedKeys[V any](m map[string]V) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
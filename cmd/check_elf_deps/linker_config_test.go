@@ -0,0 +1,73 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckLinkerConfig(t *testing.T) {
+	a := newAnalysis()
+	a.addConfig(namespaceConfig{
+		Name:        "system",
+		ProvideLibs: []string{"libc.so", "libgone.so"},
+		RequireLibs: []string{"libart.so", "libmissing.so", "libvendor.so"},
+	})
+	a.addConfig(namespaceConfig{Name: "vendor", ProvideLibs: []string{"libvendor.so"}})
+	a.addEntry(entry{Partition: "system", Path: "lib64/libc.so"}, nil)
+	a.addEntry(entry{Partition: "apex", Path: "com.android.art/lib64/libart.so"}, nil)
+
+	want := []string{
+		"namespace system provides libgone.so, which it doesn't install",
+		"namespace system requires libmissing.so, which no other namespace provides",
+	}
+	if got := a.checkLinkerConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	var dot strings.Builder
+	if err := a.writeDot(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`"com.android.art" [shape=box, label="com.android.art\n0 provided"];`,
+		`"system" -> "com.android.art" [label="libart.so"];`,
+		`"system" -> "unresolved" [color=red, label="libmissing.so"];`,
+		`"system" -> "vendor" [label="libvendor.so"];`,
+	} {
+		if !strings.Contains(dot.String(), line) {
+			t.Errorf("expected %q in:\n%s", line, dot.String())
+		}
+	}
+}
+
+func TestCheckLinkerConfigOfApex(t *testing.T) {
+	a := newAnalysis()
+	a.addConfig(namespaceConfig{
+		Name:        "com.android.foo",
+		ProvideLibs: []string{"libfoo.so", "libgone.so"},
+		RequireLibs: []string{"libc.so"},
+	})
+	a.addConfig(namespaceConfig{Name: "system", ProvideLibs: []string{"libc.so"}})
+	a.addEntry(entry{Partition: "apex", Path: "com.android.foo/lib64/libfoo.so"}, nil)
+	a.addEntry(entry{Partition: "system", Path: "lib64/libc.so"}, nil)
+
+	want := []string{"namespace com.android.foo provides libgone.so, which it doesn't install"}
+	if got := a.checkLinkerConfig(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
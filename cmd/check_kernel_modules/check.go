@@ -0,0 +1,216 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Maximum number of unresolved symbols that are reported per module.
+const maxReportedSymbols = 10
+
+type checkOptions struct {
+	// Kernel version that vermagic of the modules must match. Not checked if empty.
+	kernelVersion string
+
+	// Whether the modules must be signed
+	requireSignature bool
+
+	// Symbols exported by the kernel image. Unresolved symbols are not checked if nil.
+	kernelSymbols map[string]bool
+
+	// Names of the modules that must not be loaded
+	blocklist []string
+
+	// Whether problems fail the check. They are only printed as warnings otherwise.
+	enforce bool
+}
+
+type checkResult struct {
+	// File names of the modules to load, in an order where dependencies are loaded first
+	load []string
+
+	// Problems found
+	errs []string
+}
+
+// check verifies that the modules can be loaded: their dependencies and symbols are provided by
+// the other modules or the kernel, and they're built for the kernel. Modules in deps are
+// dependencies that are loaded separately, e.g. from another partition. Returns the load order of
+// modules.
+func check(modules, deps []*kernelModule, opts checkOptions) checkResult {
+	var res checkResult
+
+	byName := make(map[string]*kernelModule)
+	for _, m := range deps {
+		byName[m.name] = m
+	}
+	for _, m := range modules {
+		if other, ok := byName[m.name]; ok {
+			res.errs = append(res.errs, fmt.Sprintf("%s: module %s is also defined by %s", m.path, m.name, other.path))
+		}
+		byName[m.name] = m
+	}
+
+	exporters := make(map[string]*kernelModule)
+	for _, m := range append(append([]*kernelModule(nil), deps...), modules...) {
+		for _, sym := range m.exported {
+			exporters[sym] = m
+		}
+	}
+
+	blocked := make(map[string]bool)
+	for _, name := range opts.blocklist {
+		blocked[moduleName(name)] = true
+	}
+
+	// Direct dependencies of each module, from both the depends modinfo and the symbols.
+	edges := make(map[*kernelModule][]*kernelModule)
+	for _, m := range modules {
+		if opts.kernelVersion != "" && !matchesKernelVersion(m.vermagic, opts.kernelVersion) {
+			res.errs = append(res.errs, fmt.Sprintf("%s: vermagic %q doesn't match kernel version %s",
+				m.path, m.vermagic, opts.kernelVersion))
+		}
+		if opts.requireSignature && !m.signed {
+			res.errs = append(res.errs, fmt.Sprintf("%s: module is not signed", m.path))
+		}
+
+		for _, dep := range m.depends {
+			d, ok := byName[dep]
+			if !ok {
+				res.errs = append(res.errs, fmt.Sprintf("%s: depends on %s, which isn't available", m.path, dep))
+				continue
+			}
+			edges[m] = append(edges[m], d)
+		}
+
+		var unresolved []string
+		for _, sym := range m.undefined {
+			if d, ok := exporters[sym]; ok {
+				if d != m {
+					edges[m] = append(edges[m], d)
+				}
+			} else if opts.kernelSymbols != nil && !opts.kernelSymbols[sym] {
+				unresolved = append(unresolved, sym)
+			}
+		}
+		if len(unresolved) > 0 {
+			sort.Strings(unresolved)
+			more := ""
+			if len(unresolved) > maxReportedSymbols {
+				more = fmt.Sprintf(" and %d more", len(unresolved)-maxReportedSymbols)
+				unresolved = unresolved[:maxReportedSymbols]
+			}
+			res.errs = append(res.errs, fmt.Sprintf("%s: unresolved symbols: %s%s",
+				m.path, strings.Join(unresolved, ", "), more))
+		}
+
+		for _, d := range edges[m] {
+			if blocked[d.name] && !blocked[m.name] {
+				res.errs = append(res.errs, fmt.Sprintf("%s: depends on %s, which is blocklisted", m.path, d.name))
+			}
+		}
+	}
+
+	// Order the modules so that dependencies are loaded first, keeping the order of the input
+	// otherwise.
+	inSet := make(map[*kernelModule]bool)
+	for _, m := range modules {
+		inSet[m] = true
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*kernelModule]int)
+	var visit func(m *kernelModule, path []string)
+	visit = func(m *kernelModule, path []string) {
+		path = append(path, m.name)
+		switch state[m] {
+		case visiting:
+			res.errs = append(res.errs, "circular dependency: "+strings.Join(path, " -> "))
+			return
+		case visited:
+			return
+		}
+		state[m] = visiting
+		for _, d := range edges[m] {
+			if inSet[d] {
+				visit(d, path)
+			}
+		}
+		state[m] = visited
+		// modules.load lists the installed files, whose names may differ from the module names,
+		// e.g. with dashes.
+		if !blocked[m.name] {
+			res.load = append(res.load, filepath.Base(m.path))
+		}
+	}
+	for _, m := range modules {
+		visit(m, nil)
+	}
+
+	res.errs = dedup(res.errs)
+	return res
+}
+
+// matchesKernelVersion returns whether the release in vermagic, i.e. the first word, is the
+// kernel version or a more specific version of it, e.g. 5.10.43-android12-9 for 5.10.
+func matchesKernelVersion(vermagic, kernelVersion string) bool {
+	release, _, _ := strings.Cut(vermagic, " ")
+	if release == kernelVersion {
+		return true
+	}
+	if rest, ok := strings.CutPrefix(release, kernelVersion); ok {
+		return strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "-")
+	}
+	return false
+}
+
+// readKernelSymbols reads a list of symbols exported by the kernel. Both Module.symvers and
+// symbol lists with one symbol per line are supported.
+func readKernelSymbols(r io.Reader, symbols map[string]bool) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if fields := strings.Split(line, "\t"); len(fields) >= 2 {
+			// Module.symvers: <crc> <symbol> <module> <export type> [<namespace>]
+			symbols[fields[1]] = true
+		} else {
+			symbols[line] = true
+		}
+	}
+	return s.Err()
+}
+
+func dedup(errs []string) []string {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, e := range errs {
+		if !seen[e] {
+			seen[e] = true
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
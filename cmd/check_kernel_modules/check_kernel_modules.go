@@ -0,0 +1,137 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// check_kernel_modules verifies that a set of kernel modules can be loaded: the modules they
+// depend on are available, their undefined symbols are exported by the kernel or another module,
+// and they're built and signed for the kernel. The problems fail the check with --enforce, and are
+// printed as warnings otherwise. It writes modules.load with the modules ordered so that
+// dependencies are loaded first, and modules.blocklist.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func main() {
+	var opts checkOptions
+	var depFiles, symbolFiles listFlag
+	flag.StringVar(&opts.kernelVersion, "kernel_version", "", "kernel version that the vermagic of the modules must match")
+	flag.BoolVar(&opts.requireSignature, "require_signature", false, "require the modules to be signed")
+	flag.Var(&depFiles, "dep", "kernel module that the modules may depend on, but that is loaded separately")
+	flag.Var(&symbolFiles, "kernel_symbols", "Module.symvers or symbol list of the kernel image")
+	flag.Var((*listFlag)(&opts.blocklist), "blocklist", "name of a module that must not be loaded")
+	flag.BoolVar(&opts.enforce, "enforce", false, "fail if the modules can't be loaded, instead of printing warnings")
+	modulesLoad := flag.String("modules_load", "", "file to write modules.load to")
+	modulesBlocklist := flag.String("modules_blocklist", "", "file to write modules.blocklist to")
+	output := flag.String("output", "", "file to write the report to")
+	flag.Parse()
+
+	if *modulesLoad == "" || *output == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Args(), depFiles, symbolFiles, opts, *modulesLoad, *modulesBlocklist, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func readKernelModules(paths []string) ([]*kernelModule, error) {
+	var ret []*kernelModule
+	for _, path := range paths {
+		m, err := readKernelModule(path)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, m)
+	}
+	return ret, nil
+}
+
+func writeLines(file string, lines []string) error {
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	return os.WriteFile(file, []byte(content), 0666)
+}
+
+func run(moduleFiles, depFiles, symbolFiles []string, opts checkOptions,
+	modulesLoad, modulesBlocklist, output string) error {
+
+	modules, err := readKernelModules(moduleFiles)
+	if err != nil {
+		return err
+	}
+	deps, err := readKernelModules(depFiles)
+	if err != nil {
+		return err
+	}
+	if len(symbolFiles) > 0 {
+		opts.kernelSymbols = make(map[string]bool)
+		for _, file := range symbolFiles {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			err = readKernelSymbols(f, opts.kernelSymbols)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+	}
+
+	res := check(modules, deps, opts)
+
+	if err := writeLines(modulesLoad, res.load); err != nil {
+		return err
+	}
+	if modulesBlocklist != "" {
+		var lines []string
+		for _, name := range opts.blocklist {
+			lines = append(lines, "blocklist "+moduleName(name))
+		}
+		if err := writeLines(modulesBlocklist, lines); err != nil {
+			return err
+		}
+	}
+	if err := writeLines(output, res.errs); err != nil {
+		return err
+	}
+	if len(res.errs) > 0 {
+		if opts.enforce {
+			return fmt.Errorf("found %d problems with kernel modules:\n%s", len(res.errs), strings.Join(res.errs, "\n"))
+		}
+		for _, e := range res.errs {
+			fmt.Fprintln(os.Stderr, "warning:", e)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	mod := func(name string, depends []string, exported []string, undefined []string) *kernelModule {
+		return &kernelModule{
+			path:      name + ".ko",
+			name:      name,
+			depends:   depends,
+			vermagic:  "5.10.43-android12 SMP preempt mod_unload modversions aarch64",
+			signed:    true,
+			exported:  exported,
+			undefined: undefined,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modules  []*kernelModule
+		deps     []*kernelModule
+		opts     checkOptions
+		wantLoad []string
+		wantErrs []string
+	}{
+		{
+			name: "dependencies are loaded first",
+			modules: []*kernelModule{
+				mod("a", []string{"b"}, nil, []string{"b_fn", "c_fn", "printk"}),
+				mod("b", nil, []string{"b_fn"}, nil),
+				mod("c", nil, []string{"c_fn"}, nil),
+			},
+			opts:     checkOptions{kernelVersion: "5.10", kernelSymbols: map[string]bool{"printk": true}},
+			wantLoad: []string{"b.ko", "c.ko", "a.ko"},
+		},
+		{
+			name: "dependency from another set",
+			modules: []*kernelModule{
+				mod("a", []string{"gki"}, nil, []string{"gki_fn"}),
+			},
+			deps:     []*kernelModule{mod("gki", nil, []string{"gki_fn"}, nil)},
+			wantLoad: []string{"a.ko"},
+		},
+		{
+			name: "missing dependency and symbols",
+			modules: []*kernelModule{
+				mod("a", []string{"b"}, nil, []string{"missing_fn", "printk"}),
+			},
+			opts:     checkOptions{kernelSymbols: map[string]bool{"printk": true}},
+			wantLoad: []string{"a.ko"},
+			wantErrs: []string{
+				"a.ko: depends on b, which isn't available",
+				"a.ko: unresolved symbols: missing_fn",
+			},
+		},
+		{
+			name: "wrong kernel version and unsigned",
+			modules: []*kernelModule{
+				{path: "a.ko", name: "a", vermagic: "5.4.86 SMP preempt"},
+			},
+			opts:     checkOptions{kernelVersion: "5.10", requireSignature: true},
+			wantLoad: []string{"a.ko"},
+			wantErrs: []string{
+				`a.ko: vermagic "5.4.86 SMP preempt" doesn't match kernel version 5.10`,
+				"a.ko: module is not signed",
+			},
+		},
+		{
+			name: "circular dependency",
+			modules: []*kernelModule{
+				mod("a", []string{"b"}, nil, nil),
+				mod("b", []string{"a"}, nil, nil),
+			},
+			wantLoad: []string{"b.ko", "a.ko"},
+			wantErrs: []string{"circular dependency: a -> b -> a"},
+		},
+		{
+			name: "blocklist",
+			modules: []*kernelModule{
+				mod("a", []string{"b"}, nil, nil),
+				mod("b", nil, nil, nil),
+				mod("c", nil, nil, nil),
+			},
+			opts:     checkOptions{blocklist: []string{"b", "c.ko"}},
+			wantLoad: []string{"a.ko"},
+			wantErrs: []string{"a.ko: depends on b, which is blocklisted"},
+		},
+		{
+			name: "file names with dashes",
+			modules: []*kernelModule{
+				{path: "vendor/my-drv.ko", name: "my_drv", depends: []string{"my_core"}},
+				{path: "vendor/my-core.ko", name: "my_core"},
+			},
+			wantLoad: []string{"my-core.ko", "my-drv.ko"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := check(test.modules, test.deps, test.opts)
+			if !reflect.DeepEqual(res.load, test.wantLoad) {
+				t.Errorf("want load order %q, got %q", test.wantLoad, res.load)
+			}
+			if !reflect.DeepEqual(res.errs, test.wantErrs) {
+				t.Errorf("want errors %q, got %q", test.wantErrs, res.errs)
+			}
+		})
+	}
+}
+
+func TestMatchesKernelVersion(t *testing.T) {
+	for vermagic, want := range map[string]bool{
+		"5.10 SMP":                true,
+		"5.10.43-android12-9 SMP": true,
+		"5.10-android12 SMP":      true,
+		"5.100.1 SMP":             false,
+		"5.4.86 SMP preempt":      false,
+		"":                        false,
+	} {
+		if got := matchesKernelVersion(vermagic, "5.10"); got != want {
+			t.Errorf("matchesKernelVersion(%q, 5.10) = %v, want %v", vermagic, got, want)
+		}
+	}
+}
+
+func TestReadKernelSymbols(t *testing.T) {
+	input := "[abi_symbol_list]\n  printk\n\n0x12345678\tkmalloc\tvmlinux\tEXPORT_SYMBOL\t\n"
+	symbols := make(map[string]bool)
+	if err := readKernelSymbols(strings.NewReader(input), symbols); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"printk": true, "kmalloc": true}
+	if !reflect.DeepEqual(symbols, want) {
+		t.Errorf("want %v, got %v", want, symbols)
+	}
+}
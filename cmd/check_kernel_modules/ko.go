@@ -0,0 +1,131 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Marker that is appended to signed kernel modules, after the signature.
+const moduleSignatureMarker = "~Module signature appended~\n"
+
+// Prefix of the symbols that describe the exported symbols of a kernel module.
+const ksymtabPrefix = "__ksymtab_"
+
+// kernelModule is the information of a .ko file that is needed to check that it can be loaded.
+type kernelModule struct {
+	// Path to the .ko file
+	path string
+
+	// Name of the module, from the modinfo or the file name
+	name string
+
+	// Names of the modules listed in the "depends" modinfo
+	depends []string
+
+	// The "vermagic" modinfo
+	vermagic string
+
+	// Whether a signature is appended to the file
+	signed bool
+
+	// Symbols exported with EXPORT_SYMBOL*
+	exported []string
+
+	// Symbols that are undefined and non-weak, i.e. must be provided by the kernel or another
+	// module
+	undefined []string
+}
+
+// moduleName normalizes the name of a module like the kernel does, i.e. without the .ko extension
+// and with dashes replaced with underscores.
+func moduleName(s string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(filepath.Base(s), ".ko"), "-", "_")
+}
+
+func readKernelModule(path string) (*kernelModule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ef, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer ef.Close()
+
+	m := &kernelModule{
+		path:   path,
+		name:   moduleName(path),
+		signed: bytes.HasSuffix(data, []byte(moduleSignatureMarker)),
+	}
+
+	if s := ef.Section(".modinfo"); s != nil {
+		modinfo, err := s.Data()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		m.parseModinfo(modinfo)
+	}
+
+	syms, err := ef.Symbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, s := range syms {
+		if s.Name == "" || elf.ST_TYPE(s.Info) == elf.STT_SECTION || elf.ST_TYPE(s.Info) == elf.STT_FILE {
+			continue
+		}
+		if s.Section == elf.SHN_UNDEF {
+			if elf.ST_BIND(s.Info) != elf.STB_WEAK {
+				m.undefined = append(m.undefined, s.Name)
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(s.Name, ksymtabPrefix); ok {
+			m.exported = append(m.exported, name)
+		}
+	}
+	return m, nil
+}
+
+// parseModinfo parses the .modinfo section, which is a list of NUL-terminated key=value pairs.
+func (m *kernelModule) parseModinfo(modinfo []byte) {
+	for _, entry := range strings.Split(string(modinfo), "\x00") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "name":
+			m.name = moduleName(value)
+		case "vermagic":
+			m.vermagic = value
+		case "depends":
+			for _, dep := range strings.Split(value, ",") {
+				if dep != "" {
+					m.depends = append(m.depends, moduleName(dep))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// config is the JSON file written by the classpath_report singleton in java/classpath_report.go.
+type config struct {
+	Classpaths []*classpath
+}
+
+// classpath is an ordered classpath, e.g. BOOTCLASSPATH.
+type classpath struct {
+	Name string
+
+	// The classpaths whose classes are visible to the jars of this classpath, in the order in which
+	// the class loaders delegate to them, e.g. BOOTCLASSPATH for SYSTEMSERVERCLASSPATH.
+	Parents []string
+
+	// Whether each jar is loaded by a separate class loader, so that the jars do not see each other.
+	Standalone bool
+
+	Jars []*jar
+}
+
+// jar is a jar on a classpath.
+type jar struct {
+	// The module name, e.g. core-oj.
+	Name string
+
+	// The APEX that provides the jar, or "platform".
+	Apex string
+
+	// The path on device.
+	Path string
+
+	// The bootclasspath_fragment or systemserverclasspath_fragment that generates the classpaths.proto
+	// config that adds the jar to the classpath on device.
+	Fragment string
+
+	// The product variable that configures the jar, e.g. PRODUCT_APEX_BOOT_JARS.
+	Variable string
+
+	// The path to the dex jar in the build, or empty if it is unknown.
+	DexJar string
+
+	// The classes defined in the dex jar, read by readClasses.
+	classes []string
+}
+
+func (j *jar) String() string {
+	return j.Name + " (" + j.Apex + ")"
+}
+
+func (j *jar) isPlatform() bool {
+	return j.Apex == "platform" || j.Apex == "system_ext"
+}
+
+// readClasses reads the classes of the jars that have a dex jar.
+func (c *config) readClasses() error {
+	for _, cp := range c.Classpaths {
+		for _, j := range cp.Jars {
+			if j.DexJar == "" {
+				continue
+			}
+			classes, err := readJarClasses(j.DexJar)
+			if err != nil {
+				return err
+			}
+			j.classes = classes
+		}
+	}
+	return nil
+}
+
+func (c *config) classpath(name string) *classpath {
+	for _, cp := range c.Classpaths {
+		if cp.Name == name {
+			return cp
+		}
+	}
+	return nil
+}
+
+// conflict is a package whose classes are in more than one jar that a class loader can see.
+type conflict struct {
+	Classpath string
+
+	// One of "duplicate classes" or "split package".
+	Kind string
+
+	Package string
+
+	// The jars, in the order in which they are searched for a class.
+	Jars []*jar
+
+	// The number of duplicate classes.
+	Classes int
+
+	// Whether the package is in the allowlist.
+	Allowlisted bool
+}
+
+func (c *conflict) String() string {
+	var jars []string
+	for _, j := range c.Jars {
+		jars = append(jars, j.String())
+	}
+	var s string
+	if c.Kind == "duplicate classes" {
+		s = fmt.Sprintf("%d classes in package %s are in %s, the ones in %s win",
+			c.Classes, c.Package, strings.Join(jars, ", "), c.Jars[0].Name)
+	} else {
+		s = fmt.Sprintf("package %s is split between platform and APEX jars %s", c.Package, strings.Join(jars, ", "))
+	}
+	if c.Allowlisted {
+		s += " (allowlisted)"
+	}
+	return s
+}
+
+// findConflicts returns the classes that are in more than one jar that a class loader can see, and
+// the packages that are split between platform and APEX jars. Only the conflicts that involve a
+// jar of the classpath itself are reported for a classpath, the others are reported for its
+// parents.
+func (c *config) findConflicts(allowlist map[string]bool) ([]*conflict, error) {
+	var conflicts []*conflict
+	for _, cp := range c.Classpaths {
+		var parentJars []*jar
+		for _, name := range cp.Parents {
+			parent := c.classpath(name)
+			if parent == nil {
+				return nil, fmt.Errorf("unknown parent %q of %s", name, cp.Name)
+			}
+			parentJars = append(parentJars, parent.Jars...)
+		}
+
+		var jarSets [][]*jar
+		if cp.Standalone {
+			for _, j := range cp.Jars {
+				jarSets = append(jarSets, append(append([]*jar(nil), parentJars...), j))
+			}
+		} else {
+			jarSets = append(jarSets, append(append([]*jar(nil), parentJars...), cp.Jars...))
+		}
+
+		own := map[*jar]bool{}
+		for _, j := range cp.Jars {
+			own[j] = true
+		}
+		for _, jars := range jarSets {
+			conflicts = append(conflicts, findConflictsInJars(cp.Name, jars, own, allowlist)...)
+		}
+	}
+	return conflicts, nil
+}
+
+func findConflictsInJars(classpath string, jars []*jar, own map[*jar]bool, allowlist map[string]bool) []*conflict {
+	jarsByClass := map[string][]*jar{}
+	jarsByPackage := map[string][]*jar{}
+	for _, j := range jars {
+		packages := map[string]bool{}
+		for _, class := range j.classes {
+			jarsByClass[class] = append(jarsByClass[class], j)
+			pkg := packageOf(class)
+			if !packages[pkg] {
+				packages[pkg] = true
+				jarsByPackage[pkg] = append(jarsByPackage[pkg], j)
+			}
+		}
+	}
+
+	involvesOwnJar := func(jars []*jar) bool {
+		for _, j := range jars {
+			if own[j] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Group the duplicate classes by package and jars.
+	duplicates := map[string]*conflict{}
+	for class, classJars := range jarsByClass {
+		if len(classJars) < 2 || !involvesOwnJar(classJars) {
+			continue
+		}
+		pkg := packageOf(class)
+		key := pkg
+		for _, j := range classJars {
+			key += " " + j.Path
+		}
+		if d, ok := duplicates[key]; ok {
+			d.Classes++
+		} else {
+			duplicates[key] = &conflict{
+				Classpath:   classpath,
+				Kind:        "duplicate classes",
+				Package:     pkg,
+				Jars:        classJars,
+				Classes:     1,
+				Allowlisted: allowlist[pkg],
+			}
+		}
+	}
+
+	var conflicts []*conflict
+	for _, key := range sortedKeys(duplicates) {
+		conflicts = append(conflicts, duplicates[key])
+	}
+
+	for _, pkg := range sortedKeys(jarsByPackage) {
+		pkgJars := jarsByPackage[pkg]
+		if len(pkgJars) < 2 || !involvesOwnJar(pkgJars) {
+			continue
+		}
+		platform, apex := false, false
+		for _, j := range pkgJars {
+			if j.isPlatform() {
+				platform = true
+			} else {
+				apex = true
+			}
+		}
+		if platform && apex {
+			conflicts = append(conflicts, &conflict{
+				Classpath:   classpath,
+				Kind:        "split package",
+				Package:     pkg,
+				Jars:        pkgJars,
+				Allowlisted: allowlist[pkg],
+			})
+		}
+	}
+	return conflicts
+}
+
+// printReport writes the ordered classpaths, followed by the conflicts.
+func printReport(w io.Writer, c *config, conflicts []*conflict) {
+	for i, cp := range c.Classpaths {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, cp.Name)
+		if len(cp.Parents) > 0 {
+			fmt.Fprintf(w, " (after %s)", strings.Join(cp.Parents, ", "))
+		}
+		if cp.Standalone {
+			fmt.Fprint(w, " (standalone class loaders)")
+		}
+		fmt.Fprintln(w, ":")
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  #\tJar\tAPEX\tFragment\tProduct variable")
+		for i, j := range cp.Jars {
+			fmt.Fprintf(tw, "  %d\t%s\t%s\t%s\t%s\n", i+1, j.Path, j.Apex, j.Fragment, j.Variable)
+		}
+		tw.Flush()
+	}
+
+	fmt.Fprintln(w)
+	if len(conflicts) == 0 {
+		fmt.Fprintln(w, "No conflicts")
+		return
+	}
+	fmt.Fprintln(w, "Conflicts:")
+	for _, c := range conflicts {
+		fmt.Fprintf(w, "  %s: %s\n", c.Classpath, c)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// classpath_report writes the ordered BOOTCLASSPATH, SYSTEMSERVERCLASSPATH and standalone
+// system_server jars of a product, with the APEX, the classpath fragment and the product variable
+// that contribute each jar, as described by the JSON file written by the classpath_report
+// singleton. It also reads the classes of the dex jars and reports the classes that are in more
+// than one jar that a class loader can see, in which case only the first one is used, and the
+// packages that are split between platform and APEX jars.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	configFile := flag.String("config", "", "JSON file describing the classpaths")
+	output := flag.String("output", "", "file to write the report to instead of stdout")
+	allowlistFile := flag.String("allowlist", "", "file listing the packages whose conflicts are allowed, one per line")
+	errorOnConflicts := flag.Bool("error_on_conflicts", false, "exit with an error if there are conflicts that are not allowlisted")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s --config <file> [--allowlist <file>] [--error_on_conflicts] [--output <file>]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *configFile == "" || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*configFile, *allowlistFile, *output, *errorOnConflicts); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configFile, allowlistFile, output string, errorOnConflicts bool) error {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+	c := &config{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("%s: %w", configFile, err)
+	}
+	if err := c.readClasses(); err != nil {
+		return err
+	}
+
+	allowlist := map[string]bool{}
+	if allowlistFile != "" {
+		data, err := os.ReadFile(allowlistFile)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				allowlist[line] = true
+			}
+		}
+	}
+
+	conflicts, err := c.findConflicts(allowlist)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	printReport(w, c, conflicts)
+
+	if errorOnConflicts {
+		msg := &strings.Builder{}
+		count := 0
+		for _, c := range conflicts {
+			if !c.Allowlisted {
+				fmt.Fprintf(msg, "\n  %s: %s", c.Classpath, c)
+				count++
+			}
+		}
+		if count > 0 {
+			fix := "Fix the order or the contents of the jars"
+			if allowlistFile != "" {
+				fix += ", or add the packages to " + allowlistFile
+			}
+			return fmt.Errorf("%d classpath conflicts:%s\n%s", count, msg, fix)
+		}
+	}
+	return nil
+}
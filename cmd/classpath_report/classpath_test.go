@@ -0,0 +1,110 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func testConfig() *config {
+	return &config{
+		Classpaths: []*classpath{
+			{
+				Name: "BOOTCLASSPATH",
+				Jars: []*jar{
+					{Name: "core-oj", Apex: "com.android.art", Path: "/apex/com.android.art/javalib/core-oj.jar",
+						Fragment: "art-bootclasspath-fragment", Variable: "ART_APEX_JARS",
+						classes: []string{"java.lang.Object", "java.lang.String"}},
+					{Name: "framework", Apex: "platform", Path: "/system/framework/framework.jar",
+						Fragment: "platform-bootclasspath", Variable: "PRODUCT_BOOT_JARS",
+						classes: []string{"android.net.Uri", "java.lang.String"}},
+					{Name: "framework-connectivity", Apex: "com.android.tethering", Path: "/apex/com.android.tethering/javalib/framework-connectivity.jar",
+						Fragment: "com.android.tethering-bootclasspath-fragment", Variable: "PRODUCT_APEX_BOOT_JARS",
+						classes: []string{"android.net.ConnectivityManager"}},
+				},
+			},
+			{
+				Name:    "SYSTEMSERVERCLASSPATH",
+				Parents: []string{"BOOTCLASSPATH"},
+				Jars: []*jar{
+					{Name: "services", Apex: "platform", Path: "/system/framework/services.jar",
+						Fragment: "platform-systemserverclasspath", Variable: "PRODUCT_SYSTEM_SERVER_JARS",
+						classes: []string{"com.android.server.Foo", "com.android.server.Bar"}},
+					{Name: "service-foo", Apex: "com.android.foo", Path: "/apex/com.android.foo/javalib/service-foo.jar",
+						Fragment: "com.android.foo-systemserverclasspath-fragment", Variable: "PRODUCT_APEX_SYSTEM_SERVER_JARS",
+						classes: []string{"com.android.server.Bar", "com.android.server.foo.Foo"}},
+				},
+			},
+			{
+				Name:       "STANDALONE_SYSTEMSERVER_JARS",
+				Parents:    []string{"BOOTCLASSPATH", "SYSTEMSERVERCLASSPATH"},
+				Standalone: true,
+				Jars: []*jar{
+					{Name: "service-bar", Apex: "com.android.bar", Path: "/apex/com.android.bar/javalib/service-bar.jar",
+						Fragment: "com.android.bar-systemserverclasspath-fragment", Variable: "PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS",
+						classes: []string{"com.android.server.bar.Bar"}},
+					{Name: "service-baz", Apex: "com.android.baz", Path: "/apex/com.android.baz/javalib/service-baz.jar",
+						Fragment: "com.android.baz-systemserverclasspath-fragment", Variable: "PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS",
+						classes: []string{"com.android.server.bar.Bar"}},
+				},
+			},
+		},
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	c := testConfig()
+	conflicts, err := c.findConflicts(map[string]bool{"android.net": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &strings.Builder{}
+	printReport(buf, c, conflicts)
+	want := `BOOTCLASSPATH:
+  #  Jar                                                             APEX                   Fragment                                      Product variable
+  1  /apex/com.android.art/javalib/core-oj.jar                       com.android.art        art-bootclasspath-fragment                    ART_APEX_JARS
+  2  /system/framework/framework.jar                                 platform               platform-bootclasspath                        PRODUCT_BOOT_JARS
+  3  /apex/com.android.tethering/javalib/framework-connectivity.jar  com.android.tethering  com.android.tethering-bootclasspath-fragment  PRODUCT_APEX_BOOT_JARS
+
+SYSTEMSERVERCLASSPATH (after BOOTCLASSPATH):
+  #  Jar                                            APEX             Fragment                                        Product variable
+  1  /system/framework/services.jar                 platform         platform-systemserverclasspath                  PRODUCT_SYSTEM_SERVER_JARS
+  2  /apex/com.android.foo/javalib/service-foo.jar  com.android.foo  com.android.foo-systemserverclasspath-fragment  PRODUCT_APEX_SYSTEM_SERVER_JARS
+
+STANDALONE_SYSTEMSERVER_JARS (after BOOTCLASSPATH, SYSTEMSERVERCLASSPATH) (standalone class loaders):
+  #  Jar                                            APEX             Fragment                                        Product variable
+  1  /apex/com.android.bar/javalib/service-bar.jar  com.android.bar  com.android.bar-systemserverclasspath-fragment  PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS
+  2  /apex/com.android.baz/javalib/service-baz.jar  com.android.baz  com.android.baz-systemserverclasspath-fragment  PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS
+
+Conflicts:
+  BOOTCLASSPATH: 1 classes in package java.lang are in core-oj (com.android.art), framework (platform), the ones in core-oj win
+  BOOTCLASSPATH: package android.net is split between platform and APEX jars framework (platform), framework-connectivity (com.android.tethering) (allowlisted)
+  BOOTCLASSPATH: package java.lang is split between platform and APEX jars core-oj (com.android.art), framework (platform)
+  SYSTEMSERVERCLASSPATH: 1 classes in package com.android.server are in services (platform), service-foo (com.android.foo), the ones in services win
+  SYSTEMSERVERCLASSPATH: package com.android.server is split between platform and APEX jars services (platform), service-foo (com.android.foo)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestFindConflictsUnknownParent(t *testing.T) {
+	c := &config{Classpaths: []*classpath{{Name: "SYSTEMSERVERCLASSPATH", Parents: []string{"BOOTCLASSPATH"}}}}
+	if _, err := c.findConflicts(nil); err == nil || !strings.Contains(err.Error(), `unknown parent "BOOTCLASSPATH"`) {
+		t.Errorf("expected an unknown parent error, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The dex files in a jar, e.g. classes.dex, classes2.dex.
+var dexFileRegexp = regexp.MustCompile(`^classes[0-9]*\.dex$`)
+
+// readJarClasses returns the sorted names of the classes defined by the dex files in a jar, e.g.
+// "java.lang.Object".
+func readJarClasses(jar string) ([]string, error) {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var classes []string
+	for _, f := range r.File {
+		if !dexFileRegexp.MatchString(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		dexClasses, err := readDexClasses(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s!%s: %w", jar, f.Name, err)
+		}
+		classes = append(classes, dexClasses...)
+	}
+	sort.Strings(classes)
+	return classes, nil
+}
+
+// readDexClasses returns the names of the classes defined by the class_defs of a dex file.
+func readDexClasses(data []byte) ([]string, error) {
+	if len(data) < 0x70 || string(data[:4]) != "dex\n" {
+		return nil, fmt.Errorf("not a dex file")
+	}
+	u32 := func(off uint32) (uint32, error) {
+		if uint64(off)+4 > uint64(len(data)) {
+			return 0, fmt.Errorf("offset 0x%x is out of bounds", off)
+		}
+		return binary.LittleEndian.Uint32(data[off:]), nil
+	}
+
+	stringIdsSize, _ := u32(0x38)
+	stringIdsOff, _ := u32(0x3c)
+	typeIdsSize, _ := u32(0x40)
+	typeIdsOff, _ := u32(0x44)
+	classDefsSize, _ := u32(0x60)
+	classDefsOff, _ := u32(0x64)
+
+	str := func(idx uint32) (string, error) {
+		if idx >= stringIdsSize {
+			return "", fmt.Errorf("string index %d is out of bounds", idx)
+		}
+		off, err := u32(stringIdsOff + idx*4)
+		if err != nil {
+			return "", err
+		}
+		// Skip the ULEB128 encoded UTF-16 size of the string.
+		for ; off < uint32(len(data)) && data[off]&0x80 != 0; off++ {
+		}
+		off++
+		if off > uint32(len(data)) {
+			return "", fmt.Errorf("string %d is out of bounds", idx)
+		}
+		end := off
+		for ; end < uint32(len(data)) && data[end] != 0; end++ {
+		}
+		return string(data[off:end]), nil
+	}
+
+	classes := make([]string, 0, classDefsSize)
+	for i := uint32(0); i < classDefsSize; i++ {
+		// class_def_item is 32 bytes and starts with the type index of the class.
+		typeIdx, err := u32(classDefsOff + i*32)
+		if err != nil {
+			return nil, err
+		}
+		if typeIdx >= typeIdsSize {
+			return nil, fmt.Errorf("type index %d is out of bounds", typeIdx)
+		}
+		descriptorIdx, err := u32(typeIdsOff + typeIdx*4)
+		if err != nil {
+			return nil, err
+		}
+		descriptor, err := str(descriptorIdx)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(descriptor, "L") || !strings.HasSuffix(descriptor, ";") {
+			return nil, fmt.Errorf("invalid class descriptor %q", descriptor)
+		}
+		classes = append(classes, strings.ReplaceAll(descriptor[1:len(descriptor)-1], "/", "."))
+	}
+	return classes, nil
+}
+
+// packageOf returns the package of a class, or an empty string for the default package.
+func packageOf(class string) string {
+	if i := strings.LastIndex(class, "."); i >= 0 {
+		return class[:i]
+	}
+	return ""
+}
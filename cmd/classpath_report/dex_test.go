@@ -0,0 +1,130 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeDex returns a dex file with just the string_ids, type_ids and class_defs needed to define
+// the classes with the given descriptors. Each descriptor is also referenced as the superclass of
+// the classes, to check that only the defined classes are returned.
+func fakeDex(descriptors ...string) []byte {
+	descriptors = append(descriptors, "Ljava/lang/Object;")
+	n := uint32(len(descriptors))
+
+	const headerSize = 0x70
+	stringIdsOff := uint32(headerSize)
+	typeIdsOff := stringIdsOff + 4*n
+	classDefsOff := typeIdsOff + 4*n
+	dataOff := classDefsOff + 32*(n-1)
+
+	data := make([]byte, dataOff)
+	copy(data, "dex\n035\x00")
+	put := func(off, v uint32) { binary.LittleEndian.PutUint32(data[off:], v) }
+	put(0x38, n)
+	put(0x3c, stringIdsOff)
+	put(0x40, n)
+	put(0x44, typeIdsOff)
+	put(0x60, n-1)
+	put(0x64, classDefsOff)
+
+	for i, d := range descriptors {
+		put(stringIdsOff+uint32(i)*4, uint32(len(data)))
+		put(typeIdsOff+uint32(i)*4, uint32(i))
+		data = append(data, byte(len(d)))
+		data = append(data, d...)
+		data = append(data, 0)
+	}
+	for i := uint32(0); i < n-1; i++ {
+		put(classDefsOff+i*32, i)
+		put(classDefsOff+i*32+8, n-1)
+	}
+	return data
+}
+
+// writeFakeJar writes a jar with a dex file per list of descriptors.
+func writeFakeJar(t *testing.T, file string, dexes ...[]string) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for i, descriptors := range dexes {
+		name := "classes.dex"
+		if i > 0 {
+			name = "classes" + string(rune('1'+i)) + ".dex"
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(fakeDex(descriptors...))
+	}
+	w, err := zw.Create("META-INF/MANIFEST.MF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("Manifest-Version: 1.0\n"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadJarClasses(t *testing.T) {
+	jar := filepath.Join(t.TempDir(), "foo.jar")
+	writeFakeJar(t, jar,
+		[]string{"Lfoo/bar/Baz;", "Lfoo/Foo$Inner;"},
+		[]string{"LDefault;"})
+
+	classes, err := readJarClasses(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Default", "foo.Foo$Inner", "foo.bar.Baz"}
+	if !reflect.DeepEqual(classes, want) {
+		t.Errorf("expected %q, got %q", want, classes)
+	}
+}
+
+func TestReadDexClassesErrors(t *testing.T) {
+	if _, err := readDexClasses([]byte("not a dex file")); err == nil {
+		t.Error("expected an error for a file that is not a dex file")
+	}
+
+	truncated := fakeDex("Lfoo/Foo;")[:0x80]
+	if _, err := readDexClasses(truncated); err == nil {
+		t.Error("expected an error for a truncated dex file")
+	}
+}
+
+func TestPackageOf(t *testing.T) {
+	for class, want := range map[string]string{
+		"java.lang.Object":    "java.lang",
+		"foo.Foo$Inner":       "foo",
+		"DefaultPackageClass": "",
+	} {
+		if got := packageOf(class); got != want {
+			t.Errorf("packageOf(%q): expected %q, got %q", class, want, got)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// budgetConfig describes the size budgets of the partitions of a super image. It is written by
+// the logical_partition module.
+type budgetConfig struct {
+	// Files are rounded up to this size when projecting the size of a partition.
+	BlockSize int64 `json:"block_size"`
+
+	// Number of largest modules to report for each partition and group.
+	TopModules int `json:"top_modules"`
+
+	Partitions []budgetItem `json:"partitions"`
+	Groups     []budgetItem `json:"groups"`
+
+	// Budget of the entire super image. 0 means no budget.
+	DeviceBudget int64 `json:"device_budget"`
+}
+
+type budgetItem struct {
+	Name string `json:"name"`
+
+	// Name of the group the partition belongs to. Unused for groups.
+	Group string `json:"group,omitempty"`
+
+	// Budget in bytes. 0 means no budget.
+	Budget int64 `json:"budget"`
+}
+
+// budgetEntry is a file that will be installed in a partition.
+type budgetEntry struct {
+	partition string
+	module    string
+	size      int64
+}
+
+type moduleSize struct {
+	Module string `json:"module"`
+	Size   int64  `json:"size"`
+}
+
+type sizeReport struct {
+	Name          string `json:"name"`
+	ProjectedSize int64  `json:"projected_size"`
+	Budget        int64  `json:"budget,omitempty"`
+
+	// Budget minus projected size. Only set when there is a budget, and kept when it is 0.
+	Headroom *int64 `json:"headroom,omitempty"`
+
+	TopModules []moduleSize `json:"top_modules"`
+}
+
+func (r sizeReport) overBudget() bool {
+	return r.Budget > 0 && r.ProjectedSize > r.Budget
+}
+
+type budgetReport struct {
+	Partitions []sizeReport `json:"partitions"`
+	Groups     []sizeReport `json:"groups"`
+	Device     sizeReport   `json:"device"`
+}
+
+// parseBudgetEntries parses a file with lines in the form of "<partition>\t<module>\t<file>".
+// statSize is called to get the size of each file.
+func parseBudgetEntries(r io.Reader, statSize func(string) (int64, error)) ([]budgetEntry, error) {
+	var ret []budgetEntry
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected <partition>\\t<module>\\t<file>, got %q", lineNum, line)
+		}
+		size, err := statSize(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, budgetEntry{partition: fields[0], module: fields[1], size: size})
+	}
+	return ret, scanner.Err()
+}
+
+func roundUp(size, blockSize int64) int64 {
+	if blockSize <= 0 {
+		return size
+	}
+	return (size + blockSize - 1) / blockSize * blockSize
+}
+
+// accumulator sums up the sizes of the entries in a partition, group or device.
+type accumulator struct {
+	total   int64
+	modules map[string]int64
+}
+
+func (a *accumulator) add(module string, size int64) {
+	if a.modules == nil {
+		a.modules = make(map[string]int64)
+	}
+	a.total += size
+	a.modules[module] += size
+}
+
+func (a *accumulator) report(name string, budget int64, top int) sizeReport {
+	r := sizeReport{Name: name, ProjectedSize: a.total, Budget: budget}
+	if budget > 0 {
+		headroom := budget - a.total
+		r.Headroom = &headroom
+	}
+	for m, s := range a.modules {
+		r.TopModules = append(r.TopModules, moduleSize{Module: m, Size: s})
+	}
+	sort.Slice(r.TopModules, func(i, j int) bool {
+		if r.TopModules[i].Size != r.TopModules[j].Size {
+			return r.TopModules[i].Size > r.TopModules[j].Size
+		}
+		return r.TopModules[i].Module < r.TopModules[j].Module
+	})
+	if top > 0 && len(r.TopModules) > top {
+		r.TopModules = r.TopModules[:top]
+	}
+	return r
+}
+
+// checkBudgets projects the size of each partition, group and the whole device from the given
+// entries and compares them against the budgets in config.
+func checkBudgets(config budgetConfig, entries []budgetEntry) budgetReport {
+	partitions := make(map[string]*accumulator)
+	groups := make(map[string]*accumulator)
+	device := &accumulator{}
+	groupOf := make(map[string]string)
+	for _, p := range config.Partitions {
+		partitions[p.Name] = &accumulator{}
+		groupOf[p.Name] = p.Group
+	}
+	for _, g := range config.Groups {
+		groups[g.Name] = &accumulator{}
+	}
+
+	for _, e := range entries {
+		size := roundUp(e.size, config.BlockSize)
+		device.add(e.module, size)
+		if p, ok := partitions[e.partition]; ok {
+			p.add(e.module, size)
+		}
+		if g, ok := groups[groupOf[e.partition]]; ok {
+			g.add(e.module, size)
+		}
+	}
+
+	var report budgetReport
+	for _, p := range config.Partitions {
+		report.Partitions = append(report.Partitions, partitions[p.Name].report(p.Name, p.Budget, config.TopModules))
+	}
+	for _, g := range config.Groups {
+		report.Groups = append(report.Groups, groups[g.Name].report(g.Name, g.Budget, config.TopModules))
+	}
+	report.Device = device.report("device", config.DeviceBudget, config.TopModules)
+	return report
+}
+
+// overBudget returns a human readable description of each partition, group or device that is
+// over its budget, with the modules contributing the most to it.
+func (r budgetReport) overBudget() []string {
+	var ret []string
+	describe := func(kind string, s sizeReport) {
+		if !s.overBudget() {
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s %q is projected to be %d bytes, %d bytes over its budget of %d bytes. Largest modules:",
+			kind, s.Name, s.ProjectedSize, s.ProjectedSize-s.Budget, s.Budget)
+		for _, m := range s.TopModules {
+			fmt.Fprintf(&b, "\n  %12d  %s", m.Size, moduleOrUnknown(m.Module))
+		}
+		ret = append(ret, b.String())
+	}
+	for _, p := range r.Partitions {
+		describe("partition", p)
+	}
+	for _, g := range r.Groups {
+		describe("group", g)
+	}
+	describe("super image", r.Device)
+	return ret
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBudgetEntries(t *testing.T) {
+	sizes := map[string]int64{"out/foo": 10, "out/libbar.so": 5000}
+	input := "system\tfoo\tout/foo\nvendor\tlibbar\tout/libbar.so\n"
+	got, err := parseBudgetEntries(strings.NewReader(input), func(path string) (int64, error) {
+		return sizes[path], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []budgetEntry{
+		{partition: "system", module: "foo", size: 10},
+		{partition: "vendor", module: "libbar", size: 5000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if _, err := parseBudgetEntries(strings.NewReader("system\tfoo\n"), nil); err == nil {
+		t.Errorf("expected error for malformed line")
+	}
+}
+
+func TestCheckBudgets(t *testing.T) {
+	config := budgetConfig{
+		BlockSize:  4096,
+		TopModules: 1,
+		Partitions: []budgetItem{
+			{Name: "system", Group: "group_a", Budget: 3 * 4096},
+			{Name: "vendor", Group: "group_a", Budget: 4096},
+			{Name: "odm", Group: "default"},
+		},
+		Groups: []budgetItem{
+			{Name: "group_a", Budget: 10 * 4096},
+		},
+		DeviceBudget: 100 * 4096,
+	}
+	entries := []budgetEntry{
+		{partition: "system", module: "foo", size: 10},
+		{partition: "system", module: "libbar", size: 4097},
+		{partition: "system", module: "libbar", size: 1},
+		{partition: "vendor", module: "libbaz", size: 4096},
+		{partition: "odm", module: "libodm", size: 1},
+	}
+
+	report := checkBudgets(config, entries)
+
+	wantPartitions := []sizeReport{
+		{Name: "system", ProjectedSize: 4 * 4096, Budget: 3 * 4096, Headroom: int64Ptr(-4096),
+			TopModules: []moduleSize{{Module: "libbar", Size: 3 * 4096}}},
+		{Name: "vendor", ProjectedSize: 4096, Budget: 4096, Headroom: int64Ptr(0),
+			TopModules: []moduleSize{{Module: "libbaz", Size: 4096}}},
+		{Name: "odm", ProjectedSize: 4096, TopModules: []moduleSize{{Module: "libodm", Size: 4096}}},
+	}
+	if !reflect.DeepEqual(report.Partitions, wantPartitions) {
+		t.Errorf("want partitions %v, got %v", wantPartitions, report.Partitions)
+	}
+
+	wantGroups := []sizeReport{
+		{Name: "group_a", ProjectedSize: 5 * 4096, Budget: 10 * 4096, Headroom: int64Ptr(5 * 4096),
+			TopModules: []moduleSize{{Module: "libbar", Size: 3 * 4096}}},
+	}
+	if !reflect.DeepEqual(report.Groups, wantGroups) {
+		t.Errorf("want groups %v, got %v", wantGroups, report.Groups)
+	}
+
+	if report.Device.ProjectedSize != 6*4096 || report.Device.Headroom == nil ||
+		*report.Device.Headroom != 94*4096 {
+		t.Errorf("unexpected device report %v", report.Device)
+	}
+
+	// The headroom of a partition exactly at its budget is reported.
+	data, err := json.Marshal(report.Partitions[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"headroom":0`) {
+		t.Errorf("expected headroom 0 for vendor, got %s", data)
+	}
+	data, err = json.Marshal(report.Partitions[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"headroom"`) {
+		t.Errorf("expected no headroom without a budget, got %s", data)
+	}
+
+	over := report.overBudget()
+	if len(over) != 1 {
+		t.Fatalf("expected only system to be over budget, got %v", over)
+	}
+	if !strings.Contains(over[0], `partition "system" is projected to be 16384 bytes, 4096 bytes over its budget`) ||
+		!strings.Contains(over[0], "12288  libbar") {
+		t.Errorf("unexpected over budget message %q", over[0])
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
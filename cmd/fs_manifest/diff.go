@@ -0,0 +1,155 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"android/soong/sizediff"
+)
+
+// manifestDiff is the difference between two manifests.
+type manifestDiff struct {
+	sizediff.Diff[Entry]
+
+	oldSize, newSize int64
+
+	// Size difference per module, sorted by descending growth.
+	modules []moduleSizeDelta
+}
+
+type moduleSizeDelta struct {
+	module           string
+	oldSize, newSize int64
+}
+
+func (d moduleSizeDelta) delta() int64 {
+	return d.newSize - d.oldSize
+}
+
+func manifestTotalSize(m *Manifest) int64 {
+	var total int64
+	for _, e := range m.Entries {
+		total += e.Size
+	}
+	return total
+}
+
+// entryChanges returns what differs between two entries for the same path.
+func entryChanges(o, n Entry) []string {
+	var what []string
+	if o.Type != n.Type {
+		what = append(what, "type")
+	}
+	if o.Sha256 != n.Sha256 || o.Size != n.Size {
+		what = append(what, "content")
+	}
+	if o.SymlinkTarget != n.SymlinkTarget {
+		what = append(what, "symlink_target")
+	}
+	if o.Mode != n.Mode || o.Uid != n.Uid || o.Gid != n.Gid || o.Capabilities != n.Capabilities {
+		what = append(what, "fs_config")
+	}
+	if o.SeLabel != n.SeLabel {
+		what = append(what, "selabel")
+	}
+	if o.Module != n.Module {
+		what = append(what, "module")
+	}
+	return what
+}
+
+func diffManifests(oldM, newM *Manifest) *manifestDiff {
+	d := &manifestDiff{
+		Diff: sizediff.DiffEntries(oldM.Entries, newM.Entries,
+			func(e Entry) string { return e.Path }, entryChanges),
+		oldSize: manifestTotalSize(oldM),
+		newSize: manifestTotalSize(newM),
+	}
+
+	modules := make(map[string]*moduleSizeDelta)
+	module := func(name string) *moduleSizeDelta {
+		if modules[name] == nil {
+			modules[name] = &moduleSizeDelta{module: name}
+		}
+		return modules[name]
+	}
+	for _, o := range oldM.Entries {
+		module(o.Module).oldSize += o.Size
+	}
+	for _, n := range newM.Entries {
+		module(n.Module).newSize += n.Size
+	}
+
+	for _, m := range modules {
+		if m.delta() != 0 {
+			d.modules = append(d.modules, *m)
+		}
+	}
+	sort.Slice(d.modules, func(i, j int) bool {
+		if d.modules[i].delta() != d.modules[j].delta() {
+			return d.modules[i].delta() > d.modules[j].delta()
+		}
+		return d.modules[i].module < d.modules[j].module
+	})
+	return d
+}
+
+func moduleOrUnknown(m string) string {
+	if m == "" {
+		return "<unknown>"
+	}
+	return m
+}
+
+func printEntry(w io.Writer, e Entry) {
+	fmt.Fprintf(w, "  %s (%s, %d bytes, %s)\n", e.Path, e.Type, e.Size, moduleOrUnknown(e.Module))
+}
+
+func (d *manifestDiff) print(w io.Writer) {
+	sizediff.PrintTotalSize(w, d.oldSize, d.newSize)
+
+	sizediff.PrintSection(w, "Size change by module", d.modules, func(w io.Writer, m moduleSizeDelta) {
+		fmt.Fprintf(w, "  %+12d  %s (%d -> %d)\n", m.delta(), moduleOrUnknown(m.module), m.oldSize, m.newSize)
+	})
+
+	sizediff.PrintSection(w, "Added", d.Added, printEntry)
+	sizediff.PrintSection(w, "Removed", d.Removed, printEntry)
+
+	sizediff.PrintSection(w, "Changed", d.Changed, func(w io.Writer, c sizediff.Change[Entry]) {
+		fmt.Fprintf(w, "  %s [%s]\n", c.New.Path, strings.Join(c.What, ", "))
+		for _, what := range c.What {
+			switch what {
+			case "type":
+				fmt.Fprintf(w, "    type: %s -> %s\n", c.Old.Type, c.New.Type)
+			case "content":
+				fmt.Fprintf(w, "    size: %d -> %d (%+d)\n", c.Old.Size, c.New.Size, c.New.Size-c.Old.Size)
+			case "symlink_target":
+				fmt.Fprintf(w, "    symlink_target: %s -> %s\n", c.Old.SymlinkTarget, c.New.SymlinkTarget)
+			case "fs_config":
+				fmt.Fprintf(w, "    fs_config: %d %d %s %s -> %d %d %s %s\n",
+					c.Old.Uid, c.Old.Gid, c.Old.Mode, c.Old.Capabilities,
+					c.New.Uid, c.New.Gid, c.New.Mode, c.New.Capabilities)
+			case "selabel":
+				fmt.Fprintf(w, "    selabel: %s -> %s\n", c.Old.SeLabel, c.New.SeLabel)
+			case "module":
+				fmt.Fprintf(w, "    module: %s -> %s\n", moduleOrUnknown(c.Old.Module), moduleOrUnknown(c.New.Module))
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	oldM := &Manifest{Entries: []Entry{
+		{Path: "system/bin/foo", Type: "file", Mode: "755", Size: 100, Sha256: "a", Module: "foo"},
+		{Path: "system/lib64/libbar.so", Type: "file", Mode: "644", Size: 200, Sha256: "b", Module: "libbar"},
+		{Path: "system/etc/removed", Type: "file", Mode: "644", Size: 10, Sha256: "c", Module: "removed"},
+	}}
+	newM := &Manifest{Entries: []Entry{
+		{Path: "system/bin/foo", Type: "file", Mode: "755", Size: 150, Sha256: "a2", Module: "foo"},
+		{Path: "system/lib64/libbar.so", Type: "file", Mode: "644", Size: 200, Sha256: "b",
+			SeLabel: "u:object_r:system_lib_file:s0", Module: "libbar"},
+		{Path: "system/lib64/libbaz.so", Type: "file", Mode: "644", Size: 1000, Sha256: "d", Module: "libbaz"},
+	}}
+
+	d := diffManifests(oldM, newM)
+
+	if d.oldSize != 310 || d.newSize != 1350 {
+		t.Errorf("unexpected total sizes %d -> %d", d.oldSize, d.newSize)
+	}
+
+	wantModules := []moduleSizeDelta{
+		{module: "libbaz", oldSize: 0, newSize: 1000},
+		{module: "foo", oldSize: 100, newSize: 150},
+		{module: "removed", oldSize: 10, newSize: 0},
+	}
+	if !reflect.DeepEqual(d.modules, wantModules) {
+		t.Errorf("want modules %v, got %v", wantModules, d.modules)
+	}
+
+	if len(d.Added) != 1 || d.Added[0].Path != "system/lib64/libbaz.so" {
+		t.Errorf("unexpected added entries %v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Path != "system/etc/removed" {
+		t.Errorf("unexpected removed entries %v", d.Removed)
+	}
+	if len(d.Changed) != 2 {
+		t.Fatalf("unexpected changed entries %v", d.Changed)
+	}
+	if got := d.Changed[0].What; !reflect.DeepEqual(got, []string{"content"}) {
+		t.Errorf("expected content change for foo, got %v", got)
+	}
+	if got := d.Changed[1].What; !reflect.DeepEqual(got, []string{"selabel"}) {
+		t.Errorf("expected selabel change for libbar.so, got %v", got)
+	}
+
+	buf := &bytes.Buffer{}
+	d.print(buf)
+	for _, want := range []string{
+		"Total size: 310 -> 1350 (+1040)",
+		"+1000  libbaz (0 -> 1000)",
+		"system/bin/foo [content]",
+		"selabel:  -> u:object_r:system_lib_file:s0",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestDiffManifestsIdentical(t *testing.T) {
+	m := &Manifest{Entries: []Entry{
+		{Path: "system/bin/foo", Type: "file", Mode: "755", Size: 100, Sha256: "a", Module: "foo"},
+	}}
+	if d := diffManifests(m, m); !d.Empty() || len(d.modules) != 0 {
+		t.Errorf("expected empty diff, got %v", d)
+	}
+}
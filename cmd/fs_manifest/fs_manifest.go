@@ -0,0 +1,175 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// fs_manifest creates a machine-readable listing of the contents of a filesystem image, and
+// compares two such listings attributing the size difference to the modules that installed the
+// files. It also checks the projected sizes of the partitions of a super image against their
+// budgets.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"android/soong/sizediff"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s create --root <dir> --fs_config <file> [--owners <file>] [--default_owner <module>] --output <file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s diff [--error_on_growth <bytes>] <old manifest> <new manifest>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s budget --config <file> --entries <file> --output <file>\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = create(os.Args[2:])
+	case "diff":
+		err = diff(os.Args[2:])
+	case "budget":
+		err = budget(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func create(args []string) error {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	root := flags.String("root", "", "staged root directory of the image")
+	fsConfigFile := flags.String("fs_config", "", "output of fs_config for the entries of the image")
+	ownersFile := flags.String("owners", "", "file with <path>\\t<module> lines")
+	defaultOwner := flags.String("default_owner", "", "module to attribute entries that are not in --owners to")
+	output := flags.String("output", "", "manifest file to write")
+	flags.Parse(args)
+
+	if *root == "" || *fsConfigFile == "" || *output == "" {
+		usage()
+	}
+
+	f, err := os.Open(*fsConfigFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fsConfig, err := parseFsConfig(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *fsConfigFile, err)
+	}
+
+	owners := make(map[string]string)
+	if *ownersFile != "" {
+		o, err := os.Open(*ownersFile)
+		if err != nil {
+			return err
+		}
+		defer o.Close()
+		if owners, err = parseOwners(o); err != nil {
+			return fmt.Errorf("%s: %w", *ownersFile, err)
+		}
+	}
+
+	m, err := createManifest(*root, fsConfig, owners, *defaultOwner)
+	if err != nil {
+		return err
+	}
+	return writeManifest(*output, m)
+}
+
+func diff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	errorOnGrowth := sizediff.ErrorOnGrowthFlag(flags)
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		usage()
+	}
+
+	oldM, err := readManifest(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newM, err := readManifest(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d := diffManifests(oldM, newM)
+	d.print(os.Stdout)
+
+	return sizediff.CheckGrowth(d.oldSize, d.newSize, *errorOnGrowth)
+}
+
+func budget(args []string) error {
+	flags := flag.NewFlagSet("budget", flag.ExitOnError)
+	configFile := flags.String("config", "", "budgets of the partitions, groups and device")
+	entriesFile := flags.String("entries", "", "file with <partition>\\t<module>\\t<file> lines")
+	output := flags.String("output", "", "report file to write")
+	flags.Parse(args)
+
+	if *configFile == "" || *entriesFile == "" || *output == "" {
+		usage()
+	}
+
+	data, err := os.ReadFile(*configFile)
+	if err != nil {
+		return err
+	}
+	var config budgetConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", *configFile, err)
+	}
+
+	f, err := os.Open(*entriesFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := parseBudgetEntries(f, func(path string) (int64, error) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", *entriesFile, err)
+	}
+
+	report := checkBudgets(config, entries)
+	data, err = json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, append(data, '\n'), 0666); err != nil {
+		return err
+	}
+
+	if over := report.overBudget(); len(over) > 0 {
+		return fmt.Errorf("size budget exceeded\n%s", strings.Join(over, "\n"))
+	}
+	return nil
+}
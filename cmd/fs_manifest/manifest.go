@@ -0,0 +1,205 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Manifest describes every entry of a filesystem image.
+type Manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Entry describes a single file, directory or symlink in a filesystem image.
+type Entry struct {
+	// Path relative to the root of the image
+	Path string `json:"path"`
+
+	// One of "file", "dir" or "symlink"
+	Type string `json:"type"`
+
+	// Octal file mode, uid and gid as reported by fs_config
+	Mode string `json:"mode"`
+	Uid  int    `json:"uid"`
+	Gid  int    `json:"gid"`
+
+	// SELinux label from file_contexts. Empty when no file_contexts was given.
+	SeLabel string `json:"selabel,omitempty"`
+
+	// Capabilities as reported by fs_config. Empty when there are none.
+	Capabilities string `json:"capabilities,omitempty"`
+
+	// Size in bytes and sha256 of the content. Only set for regular files.
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256,omitempty"`
+
+	// Target of the symlink. Only set for symlinks.
+	SymlinkTarget string `json:"symlink_target,omitempty"`
+
+	// Name of the module that installed this entry
+	Module string `json:"module,omitempty"`
+}
+
+// fsConfigEntry is a parsed line of the output of fs_config.
+type fsConfigEntry struct {
+	path         string
+	uid, gid     int
+	mode         string
+	seLabel      string
+	capabilities string
+}
+
+// parseFsConfig parses the output of `fs_config [-C] [-S file_contexts]`, which has lines in the
+// form of "<path> <uid> <gid> <mode> [selabel=<label>] [capabilities=<caps>]".
+func parseFsConfig(r io.Reader) ([]fsConfigEntry, error) {
+	var ret []fsConfigEntry
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("line %d: expected at least 4 fields, got %q", lineNum, scanner.Text())
+		}
+		e := fsConfigEntry{path: fields[0], mode: fields[3]}
+		var err error
+		if e.uid, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid uid %q", lineNum, fields[1])
+		}
+		if e.gid, err = strconv.Atoi(fields[2]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid gid %q", lineNum, fields[2])
+		}
+		for _, f := range fields[4:] {
+			if v, ok := strings.CutPrefix(f, "selabel="); ok {
+				e.seLabel = v
+			} else if v, ok := strings.CutPrefix(f, "capabilities="); ok {
+				if v != "0x0" {
+					e.capabilities = v
+				}
+			} else {
+				return nil, fmt.Errorf("line %d: unknown field %q", lineNum, f)
+			}
+		}
+		ret = append(ret, e)
+	}
+	return ret, scanner.Err()
+}
+
+// parseOwners parses a file with lines in the form of "<path>\t<module>".
+func parseOwners(r io.Reader) (map[string]string, error) {
+	ret := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		path, module, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected <path>\\t<module>, got %q", lineNum, line)
+		}
+		ret[filepath.Clean(path)] = module
+	}
+	return ret, scanner.Err()
+}
+
+// createManifest builds a Manifest of the staged image root directory. fsConfig provides the list
+// of entries and their metadata, owners maps paths to the modules that installed them. Entries
+// that are not in owners are attributed to defaultOwner.
+func createManifest(root string, fsConfig []fsConfigEntry, owners map[string]string, defaultOwner string) (*Manifest, error) {
+	m := &Manifest{}
+	for _, c := range fsConfig {
+		e := Entry{
+			Path:         c.path,
+			Mode:         c.mode,
+			Uid:          c.uid,
+			Gid:          c.gid,
+			SeLabel:      c.seLabel,
+			Capabilities: c.capabilities,
+			Module:       defaultOwner,
+		}
+		if owner, ok := owners[filepath.Clean(c.path)]; ok {
+			e.Module = owner
+		}
+
+		hostPath := filepath.Join(root, c.path)
+		fi, err := os.Lstat(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			e.Type = "symlink"
+			if e.SymlinkTarget, err = os.Readlink(hostPath); err != nil {
+				return nil, err
+			}
+		case fi.IsDir():
+			e.Type = "dir"
+		default:
+			e.Type = "file"
+			e.Size = fi.Size()
+			if e.Sha256, err = sha256File(hostPath); err != nil {
+				return nil, err
+			}
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].Path < m.Entries[j].Path })
+	return m, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func writeManifest(path string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0666)
+}
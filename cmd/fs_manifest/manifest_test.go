@@ -0,0 +1,85 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFsConfig(t *testing.T) {
+	input := `system 0 0 755 selabel=u:object_r:system_file:s0 capabilities=0x0
+system/bin/foo 0 2000 750 selabel=u:object_r:system_file:s0 capabilities=0x1000
+system/etc/bar 0 0 644
+`
+	got, err := parseFsConfig(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fsConfigEntry{
+		{path: "system", uid: 0, gid: 0, mode: "755", seLabel: "u:object_r:system_file:s0"},
+		{path: "system/bin/foo", uid: 0, gid: 2000, mode: "750", seLabel: "u:object_r:system_file:s0", capabilities: "0x1000"},
+		{path: "system/etc/bar", uid: 0, gid: 0, mode: "644"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{"system 0 0", "system x 0 755", "system 0 0 755 foo=bar"} {
+		if _, err := parseFsConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestCreateManifest(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "system", "bin"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "system", "bin", "foo"), []byte("foo"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("foo", filepath.Join(root, "system", "bin", "bar")); err != nil {
+		t.Fatal(err)
+	}
+
+	fsConfig := []fsConfigEntry{
+		{path: "system/bin", mode: "755"},
+		{path: "system/bin/foo", gid: 2000, mode: "755", seLabel: "u:object_r:system_file:s0"},
+		{path: "system/bin/bar", mode: "644"},
+	}
+	owners := map[string]string{
+		"system/bin/foo": "foo",
+		"system/bin/bar": "foo",
+	}
+
+	got, err := createManifest(root, fsConfig, owners, "myfilesystem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Manifest{Entries: []Entry{
+		{Path: "system/bin", Type: "dir", Mode: "755", Module: "myfilesystem"},
+		{Path: "system/bin/bar", Type: "symlink", Mode: "644", SymlinkTarget: "foo", Module: "foo"},
+		{Path: "system/bin/foo", Type: "file", Mode: "755", Gid: 2000, SeLabel: "u:object_r:system_file:s0",
+			Size: 3, Sha256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae", Module: "foo"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v, got %v", want, got)
+	}
+}
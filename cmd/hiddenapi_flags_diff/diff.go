@@ -0,0 +1,239 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The name of the monolithic flags of the whole bootclasspath in hiddenapi-flags-by-fragment.zip.
+// Members do not move in and out of it, unlike between the bootclasspath_fragments.
+const platformFragment = "platform"
+
+// change is a change to the flags of a member of a bootclasspath_fragment.
+type change struct {
+	Fragment  string
+	Surface   string
+	Signature string
+
+	// One of added, removed, moved, loosened, tightened or changed.
+	Kind string
+
+	// The fragment that the member was in before, for moved members.
+	From string
+
+	Old flags
+	New flags
+
+	// Whether the member is now in a less restrictive API list, e.g. it moved from blocked to
+	// unsupported, or it was added with a greylist flag.
+	Loosened bool
+
+	// Whether the member is in the loosened flags allowlist.
+	Allowlisted bool
+}
+
+func (c *change) String() string {
+	var s string
+	switch c.Kind {
+	case "added":
+		s = "added as " + c.New.String()
+	case "removed":
+		s = "removed, was " + c.Old.String()
+	case "moved":
+		s = "moved from " + c.From
+		if !c.Old.equal(c.New) {
+			s += ", " + c.Old.String() + " -> " + c.New.String()
+		}
+	default:
+		s = c.Old.String() + " -> " + c.New.String()
+	}
+	if c.Allowlisted {
+		s += " (loosened, allowlisted)"
+	} else if c.Loosened {
+		s += " (LOOSENED)"
+	}
+	return s
+}
+
+// diffFlags compares the flags of each fragment in two builds. A member that is only in a fragment
+// in one build and only in another fragment in the other build is reported as moved, in the
+// fragment that it moved to.
+func diffFlags(oldFlags, newFlags map[string]flagsFile, allowlist map[string]bool) []*change {
+	owners := func(files map[string]flagsFile) map[string]string {
+		ret := map[string]string{}
+		for _, fragment := range sortedKeys(files) {
+			if fragment == platformFragment {
+				continue
+			}
+			for signature := range files[fragment] {
+				ret[signature] = fragment
+			}
+		}
+		return ret
+	}
+	oldOwners := owners(oldFlags)
+	newOwners := owners(newFlags)
+
+	fragments := map[string]bool{}
+	for fragment := range oldFlags {
+		fragments[fragment] = true
+	}
+	for fragment := range newFlags {
+		fragments[fragment] = true
+	}
+
+	var changes []*change
+	for _, fragment := range sortedKeys(fragments) {
+		oldF, newF := oldFlags[fragment], newFlags[fragment]
+		var fragmentChanges []*change
+
+		for signature, n := range newF {
+			c := &change{Fragment: fragment, Signature: signature, New: n}
+			if o, ok := oldF[signature]; ok {
+				c.Old = o
+				c.Kind = compareRestrictions(o, n)
+			} else if from := oldOwners[signature]; fragment != platformFragment && from != "" && newOwners[signature] == fragment {
+				c.Old = oldFlags[from][signature]
+				c.Kind = "moved"
+				c.From = from
+			} else {
+				c.Kind = "added"
+			}
+			if c.Kind == "" {
+				continue
+			}
+			if c.Old != nil {
+				c.Loosened = n.restrictionRank() > c.Old.restrictionRank()
+			} else {
+				c.Loosened = n.restrictionRank() > 0 && n.restriction() != "sdk"
+			}
+			c.Allowlisted = c.Loosened && allowlist[signature]
+			fragmentChanges = append(fragmentChanges, c)
+		}
+
+		for signature, o := range oldF {
+			if _, ok := newF[signature]; ok {
+				continue
+			}
+			if to := newOwners[signature]; fragment != platformFragment && to != "" && oldOwners[signature] == fragment {
+				// Reported as moved in the fragment that the member moved to.
+				continue
+			}
+			fragmentChanges = append(fragmentChanges, &change{
+				Fragment:  fragment,
+				Signature: signature,
+				Kind:      "removed",
+				Old:       o,
+			})
+		}
+
+		for _, c := range fragmentChanges {
+			if c.New != nil {
+				c.Surface = c.New.surface()
+			} else {
+				c.Surface = c.Old.surface()
+			}
+		}
+		sort.Slice(fragmentChanges, func(i, j int) bool {
+			a, b := fragmentChanges[i], fragmentChanges[j]
+			if a.Surface != b.Surface {
+				return surfaceRank(a.Surface) < surfaceRank(b.Surface)
+			}
+			return a.Signature < b.Signature
+		})
+		changes = append(changes, fragmentChanges...)
+	}
+	return changes
+}
+
+// compareRestrictions returns the kind of change between the flags of a member, or an empty string
+// if they are the same.
+func compareRestrictions(o, n flags) string {
+	switch {
+	case n.restrictionRank() > o.restrictionRank():
+		return "loosened"
+	case n.restrictionRank() < o.restrictionRank():
+		return "tightened"
+	case !n.equal(o):
+		return "changed"
+	}
+	return ""
+}
+
+func surfaceRank(surface string) int {
+	for i, s := range apiSurfaces {
+		if s == surface {
+			return i
+		}
+	}
+	return len(apiSurfaces)
+}
+
+// notAllowlisted returns the changes that loosen the flags of members that are not allowlisted.
+func notAllowlisted(changes []*change) []*change {
+	var ret []*change
+	for _, c := range changes {
+		if c.Loosened && !c.Allowlisted {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// printChanges writes the changes grouped by fragment and by API surface, followed by a summary.
+func printChanges(w io.Writer, changes []*change) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+
+	fragment, surface := "", ""
+	fragments, loosened, allowlisted := 0, 0, 0
+	for _, c := range changes {
+		if c.Fragment != fragment {
+			if fragment != "" {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprintf(w, "%s:\n", c.Fragment)
+			fragment, surface = c.Fragment, ""
+			fragments++
+		}
+		if c.Surface != surface {
+			fmt.Fprintf(w, "  %s:\n", c.Surface)
+			surface = c.Surface
+		}
+		fmt.Fprintf(w, "    %s: %s\n", c.Signature, c)
+		if c.Loosened {
+			loosened++
+		}
+		if c.Allowlisted {
+			allowlisted++
+		}
+	}
+	fmt.Fprintf(w, "\n%d changes in %d fragments, %d members loosened (%d allowlisted)\n",
+		len(changes), fragments, loosened, allowlisted)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
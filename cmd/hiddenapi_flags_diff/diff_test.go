@@ -0,0 +1,140 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustParseFlags(t *testing.T, s string) flagsFile {
+	t.Helper()
+	f, err := parseFlags(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestDiffFlags(t *testing.T) {
+	oldFlags := map[string]flagsFile{
+		"art-bootclasspath-fragment": mustParseFlags(t, `
+Ljava/lang/Object;-><init>()V,public-api,sdk,system-api,test-api
+Ljava/lang/Object;->shadow$_klass_:Ljava/lang/Class;,blocked
+Ljava/lang/Thread;->stop0(Ljava/lang/Object;)V,blocked
+Ldalvik/system/VMRuntime;->getRuntime()Ldalvik/system/VMRuntime;,core-platform-api,unsupported
+Llibcore/util/Moved;->foo()V,max-target-o
+`),
+		"com.android.foo-bootclasspath-fragment": mustParseFlags(t, `
+Lcom/android/foo/Foo;->bar()V,lo-prio,max-target-r
+Lcom/android/foo/Foo;->baz()V,public-api,sdk
+`),
+	}
+	newFlags := map[string]flagsFile{
+		"art-bootclasspath-fragment": mustParseFlags(t, `
+Ljava/lang/Object;-><init>()V,public-api,sdk,system-api,test-api
+Ljava/lang/Object;->shadow$_klass_:Ljava/lang/Class;,unsupported
+Ljava/lang/Thread;->stop0(Ljava/lang/Object;)V,max-target-s
+Ldalvik/system/VMRuntime;->getRuntime()Ldalvik/system/VMRuntime;,blocked,core-platform-api
+Ljava/lang/Object;->newMethod()V,blocked
+`),
+		"com.android.foo-bootclasspath-fragment": mustParseFlags(t, `
+Lcom/android/foo/Foo;->bar()V,max-target-r
+Llibcore/util/Moved;->foo()V,unsupported
+Lcom/android/foo/Foo;->qux()V,max-target-s
+`),
+	}
+	allowlist := map[string]bool{
+		"Ljava/lang/Thread;->stop0(Ljava/lang/Object;)V": true,
+	}
+
+	changes := diffFlags(oldFlags, newFlags, allowlist)
+	buf := &strings.Builder{}
+	printChanges(buf, changes)
+
+	want := `art-bootclasspath-fragment:
+  core-platform-api:
+    Ldalvik/system/VMRuntime;->getRuntime()Ldalvik/system/VMRuntime;: core-platform-api,unsupported -> blocked,core-platform-api
+  hidden:
+    Ljava/lang/Object;->newMethod()V: added as blocked
+    Ljava/lang/Object;->shadow$_klass_:Ljava/lang/Class;: blocked -> unsupported (LOOSENED)
+    Ljava/lang/Thread;->stop0(Ljava/lang/Object;)V: blocked -> max-target-s (loosened, allowlisted)
+
+com.android.foo-bootclasspath-fragment:
+  public-api:
+    Lcom/android/foo/Foo;->baz()V: removed, was public-api,sdk
+  hidden:
+    Lcom/android/foo/Foo;->bar()V: lo-prio,max-target-r -> max-target-r
+    Lcom/android/foo/Foo;->qux()V: added as max-target-s (LOOSENED)
+    Llibcore/util/Moved;->foo()V: moved from art-bootclasspath-fragment, max-target-o -> unsupported (LOOSENED)
+
+8 changes in 2 fragments, 4 members loosened (1 allowlisted)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+
+	var kinds []string
+	for _, c := range changes {
+		kinds = append(kinds, c.Kind)
+	}
+	wantKinds := "tightened added loosened loosened removed changed added moved"
+	if got := strings.Join(kinds, " "); got != wantKinds {
+		t.Errorf("expected kinds %q, got %q", wantKinds, got)
+	}
+
+	if got := len(notAllowlisted(changes)); got != 3 {
+		t.Errorf("expected 3 changes that are not allowlisted, got %d", got)
+	}
+}
+
+func TestDiffFlagsPlatform(t *testing.T) {
+	// Members do not move in and out of the platform flags.
+	oldFlags := map[string]flagsFile{
+		"platform": mustParseFlags(t, "La;->a()V,blocked\n"),
+		"foo":      mustParseFlags(t, "Lb;->b()V,blocked\n"),
+	}
+	newFlags := map[string]flagsFile{
+		"platform": mustParseFlags(t, "Lb;->b()V,blocked\n"),
+		"foo":      mustParseFlags(t, "La;->a()V,blocked\n"),
+	}
+
+	buf := &strings.Builder{}
+	printChanges(buf, diffFlags(oldFlags, newFlags, nil))
+	want := `foo:
+  hidden:
+    La;->a()V: added as blocked
+    Lb;->b()V: removed, was blocked
+
+platform:
+  hidden:
+    La;->a()V: removed, was blocked
+    Lb;->b()V: added as blocked
+
+4 changes in 2 fragments, 0 members loosened (0 allowlisted)
+`
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestPrintNoChanges(t *testing.T) {
+	f := map[string]flagsFile{"foo": mustParseFlags(t, "La;->a()V,blocked\n")}
+	buf := &strings.Builder{}
+	printChanges(buf, diffFlags(f, f, nil))
+	if got := buf.String(); got != "No changes\n" {
+		t.Errorf("expected no changes, got %q", got)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// The API list flags from scripts/hiddenapi/generate_hiddenapi_lists.py, from the most restrictive
+// to the least restrictive one.
+var restrictions = []string{
+	"blocked",
+	"max-target-o",
+	"max-target-p",
+	"max-target-q",
+	"max-target-r",
+	"max-target-s",
+	"unsupported",
+	"sdk",
+}
+
+// The API surface flags, from the most public to the least public one.
+var apiSurfaces = []string{
+	"public-api",
+	"system-api",
+	"test-api",
+	"core-platform-api",
+}
+
+// The API surface of the members that are not in any API.
+const hiddenSurface = "hidden"
+
+// flags are the hidden API flags of a member, e.g. "blocked" or "lo-prio,max-target-r".
+type flags []string
+
+func (f flags) String() string {
+	return strings.Join(f, ",")
+}
+
+// restriction returns the API list of the member, or an empty string if it has none.
+func (f flags) restriction() string {
+	for _, flag := range f {
+		for _, r := range restrictions {
+			if flag == r {
+				return flag
+			}
+		}
+	}
+	return ""
+}
+
+// restrictionRank returns the position of the API list of the member in restrictions. Members
+// without an API list are treated as blocked, as that is how the runtime treats them.
+func (f flags) restrictionRank() int {
+	r := f.restriction()
+	for i := range restrictions {
+		if restrictions[i] == r {
+			return i
+		}
+	}
+	return 0
+}
+
+// surface returns the most public API surface of the member, or hiddenSurface.
+func (f flags) surface() string {
+	for _, s := range apiSurfaces {
+		for _, flag := range f {
+			if flag == s {
+				return s
+			}
+		}
+	}
+	return hiddenSurface
+}
+
+func (f flags) equal(other flags) bool {
+	return f.String() == other.String()
+}
+
+// flagsFile maps the signature of each member to its flags.
+type flagsFile map[string]flags
+
+// parseFlags parses a hidden API flags file, e.g. all-flags.csv or hiddenapi-flags.csv, in which
+// each line is a dex signature followed by the comma separated flags.
+func parseFlags(r io.Reader) (flagsFile, error) {
+	ret := flagsFile{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	for lineNo := 1; s.Scan(); lineNo++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if fields[0] == "" {
+			return nil, fmt.Errorf("line %d: missing signature", lineNo)
+		}
+		ret[fields[0]] = flags(fields[1:])
+	}
+	return ret, s.Err()
+}
+
+// readFlags reads the flags files of a build, keyed by bootclasspath_fragment. The path is either
+// a single flags file of the named fragment, or a zip file like hiddenapi-flags-by-fragment.zip
+// with a <fragment>/<flags file> entry per fragment.
+func readFlags(file, fragment string) (map[string]flagsFile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		f, err := parseFlags(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		return map[string]flagsFile{fragment: f}, nil
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	ret := map[string]flagsFile{}
+	for _, entry := range zr.File {
+		if entry.FileInfo().IsDir() || path.Ext(entry.Name) != ".csv" {
+			continue
+		}
+		name := path.Dir(entry.Name)
+		if name == "." {
+			return nil, fmt.Errorf("%s: %s is not in a fragment directory", file, entry.Name)
+		}
+		if _, exists := ret[name]; exists {
+			return nil, fmt.Errorf("%s: more than one flags file for %s", file, name)
+		}
+		r, err := entry.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		f, err := parseFlags(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", file, entry.Name, err)
+		}
+		ret[name] = f
+	}
+	return ret, nil
+}
+
+// readAllowlist reads a file listing the signatures of the members whose flags are allowed to be
+// loosened, one per line. Empty lines and lines starting with # are ignored.
+func readAllowlist(file string, allowlist map[string]bool) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allowlist[line] = true
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	testCases := []struct {
+		flags       flags
+		restriction string
+		rank        int
+		surface     string
+	}{
+		{flags{"blocked"}, "blocked", 0, "hidden"},
+		{flags{"lo-prio", "max-target-r"}, "max-target-r", 4, "hidden"},
+		{flags{"core-platform-api", "unsupported"}, "unsupported", 6, "core-platform-api"},
+		{flags{"public-api", "sdk", "system-api", "test-api"}, "sdk", 7, "public-api"},
+		{flags{"system-api", "test-api", "sdk"}, "sdk", 7, "system-api"},
+		{flags{}, "", 0, "hidden"},
+	}
+	for _, tc := range testCases {
+		if got := tc.flags.restriction(); got != tc.restriction {
+			t.Errorf("%v: expected restriction %q, got %q", tc.flags, tc.restriction, got)
+		}
+		if got := tc.flags.restrictionRank(); got != tc.rank {
+			t.Errorf("%v: expected rank %d, got %d", tc.flags, tc.rank, got)
+		}
+		if got := tc.flags.surface(); got != tc.surface {
+			t.Errorf("%v: expected surface %q, got %q", tc.flags, tc.surface, got)
+		}
+	}
+}
+
+func TestReadFlags(t *testing.T) {
+	dir := t.TempDir()
+
+	csv := filepath.Join(dir, "all-flags.csv")
+	if err := os.WriteFile(csv, []byte("La;->a()V,blocked\nLb;->b()V,public-api,sdk\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readFlags(csv, "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]flagsFile{
+		"foo": {
+			"La;->a()V": flags{"blocked"},
+			"Lb;->b()V": flags{"public-api", "sdk"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	zipFile := filepath.Join(dir, "hiddenapi-flags-by-fragment.zip")
+	f, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, contents := range map[string]string{
+		"foo/all-flags.csv":            "La;->a()V,blocked\n",
+		"platform/hiddenapi-flags.csv": "La;->a()V,blocked\nLc;->c()V,unsupported\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(contents))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err = readFlags(zipFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]flagsFile{
+		"foo": {
+			"La;->a()V": flags{"blocked"},
+		},
+		"platform": {
+			"La;->a()V": flags{"blocked"},
+			"Lc;->c()V": flags{"unsupported"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
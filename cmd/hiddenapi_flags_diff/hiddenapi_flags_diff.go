@@ -0,0 +1,105 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// hiddenapi_flags_diff compares the hidden API flags generated by two builds, or by a build and a
+// checked-in baseline, and reports the changes grouped by bootclasspath_fragment and by API
+// surface. Each side is either the all-flags.csv file of a single bootclasspath_fragment or the
+// hiddenapi-flags-by-fragment.zip file generated by the platform_bootclasspath module.
+//
+// A member whose flags are loosened, e.g. one that moves from blocked to unsupported or that is
+// added with a max-target-* flag, makes the tool fail when --error_on_loosening is specified,
+// unless the member is listed in an --allowlist file.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type multiString []string
+
+func (ms *multiString) String() string     { return strings.Join(*ms, ", ") }
+func (ms *multiString) Set(s string) error { *ms = append(*ms, s); return nil }
+
+func main() {
+	var allowlists multiString
+	fragment := flag.String("fragment", "", "name of the bootclasspath_fragment when comparing flags files instead of zip files")
+	output := flag.String("output", "", "file to write the report to instead of stdout")
+	errorOnLoosening := flag.Bool("error_on_loosening", false, "exit with an error if the flags of a member that is not allowlisted are loosened")
+	flag.Var(&allowlists, "allowlist", "file listing the signatures of the members whose flags may be loosened, may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--fragment <name>] [--allowlist <file>] [--error_on_loosening] [--output <file>] <old flags> <new flags>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Arg(1), *fragment, allowlists, *output, *errorOnLoosening); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(oldFile, newFile, fragment string, allowlists []string, output string, errorOnLoosening bool) error {
+	if fragment == "" {
+		fragment = strings.TrimSuffix(filepath.Base(newFile), filepath.Ext(newFile))
+	}
+	oldFlags, err := readFlags(oldFile, fragment)
+	if err != nil {
+		return err
+	}
+	newFlags, err := readFlags(newFile, fragment)
+	if err != nil {
+		return err
+	}
+
+	allowlist := map[string]bool{}
+	for _, file := range allowlists {
+		if err := readAllowlist(file, allowlist); err != nil {
+			return err
+		}
+	}
+
+	changes := diffFlags(oldFlags, newFlags, allowlist)
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	printChanges(w, changes)
+
+	if loosened := notAllowlisted(changes); errorOnLoosening && len(loosened) > 0 {
+		msg := &strings.Builder{}
+		fmt.Fprintf(msg, "the flags of %d members were loosened:\n", len(loosened))
+		for _, c := range loosened {
+			fmt.Fprintf(msg, "  %s: %s: %s\n", c.Fragment, c.Signature, c)
+		}
+		fmt.Fprintf(msg, "If this is intended then add the signatures to the loosened flags allowlist, or update the baseline with:\n  cp %s %s", newFile, oldFile)
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Report is the include-what-you-use analysis of a translation unit.
+type Report struct {
+	// Source is the source file of the translation unit.
+	Source string `json:"source"`
+	// Files are the analyzed files, the source file and its associated header.
+	Files []FileReport `json:"files"`
+}
+
+// FileReport lists the includes and forward declarations that a file is missing and those it
+// does not use.
+type FileReport struct {
+	File    string    `json:"file"`
+	Add     []Include `json:"add,omitempty"`
+	Remove  []Include `json:"remove,omitempty"`
+	Correct bool      `json:"correct,omitempty"`
+}
+
+// Include is an #include line or a forward declaration.
+type Include struct {
+	Line string `json:"line"`
+	// Comment is what the include is needed for, for missing includes.
+	Comment string `json:"comment,omitempty"`
+	// FirstLine and LastLine are the line numbers of unused includes.
+	FirstLine int `json:"first_line,omitempty"`
+	LastLine  int `json:"last_line,omitempty"`
+}
+
+var (
+	addRe     = regexp.MustCompile(`^(.+) should add these lines:$`)
+	removeRe  = regexp.MustCompile(`^(.+) should remove these lines:$`)
+	fullRe    = regexp.MustCompile(`^The full include-list for (.+):$`)
+	correctRe = regexp.MustCompile(`^\((.+) has correct #includes/fwd-decls\)$`)
+	linesRe   = regexp.MustCompile(`^lines (\d+)-(\d+)$`)
+)
+
+// parseIwyuOutput parses the output of include-what-you-use in its default format. Lines that are
+// not part of the analysis, such as compiler warnings, are ignored. It returns false if the output
+// has no analysis, e.g. because the source file did not compile.
+func parseIwyuOutput(source, output string) (Report, bool) {
+	report := Report{Source: source, Files: []FileReport{}}
+	found := false
+
+	fileReport := func(file string) *FileReport {
+		found = true
+		for i := range report.Files {
+			if report.Files[i].File == file {
+				return &report.Files[i]
+			}
+		}
+		report.Files = append(report.Files, FileReport{File: file})
+		return &report.Files[len(report.Files)-1]
+	}
+
+	const (
+		none = iota
+		add
+		remove
+		full
+	)
+	section := none
+	var current *FileReport
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, " \r")
+
+		if m := addRe.FindStringSubmatch(line); m != nil {
+			section, current = add, fileReport(m[1])
+			continue
+		}
+		if m := removeRe.FindStringSubmatch(line); m != nil {
+			section, current = remove, fileReport(m[1])
+			continue
+		}
+		if m := fullRe.FindStringSubmatch(line); m != nil {
+			section, current = full, fileReport(m[1])
+			continue
+		}
+		if m := correctRe.FindStringSubmatch(line); m != nil {
+			fileReport(m[1]).Correct = true
+			section = none
+			continue
+		}
+		if line == "" || line == "---" {
+			section = none
+			continue
+		}
+
+		switch section {
+		case add:
+			current.Add = append(current.Add, parseInclude(line))
+		case remove:
+			include := parseInclude(strings.TrimPrefix(line, "- "))
+			if m := linesRe.FindStringSubmatch(include.Comment); m != nil {
+				include.FirstLine, _ = strconv.Atoi(m[1])
+				include.LastLine, _ = strconv.Atoi(m[2])
+				include.Comment = ""
+			}
+			current.Remove = append(current.Remove, include)
+		}
+	}
+	return report, found
+}
+
+// parseInclude splits an include line into the include and its trailing comment.
+func parseInclude(line string) Include {
+	if i := strings.Index(line, "//"); i >= 0 {
+		return Include{
+			Line:    strings.TrimSpace(line[:i]),
+			Comment: strings.TrimSpace(line[i+2:]),
+		}
+	}
+	return Include{Line: strings.TrimSpace(line)}
+}
+
+// Summary is the merged report of all the translation units.
+type Summary struct {
+	TranslationUnits int      `json:"translation_units"`
+	MissingIncludes  int      `json:"missing_includes"`
+	UnusedIncludes   int      `json:"unused_includes"`
+	Reports          []Report `json:"reports"`
+}
+
+// summarize counts the missing and unused includes of the reports. A header that is analyzed in
+// several translation units is only counted once.
+func summarize(reports []Report) Summary {
+	summary := Summary{TranslationUnits: len(reports), Reports: reports}
+	counted := make(map[string]bool)
+	for _, report := range reports {
+		for _, file := range report.Files {
+			if counted[file.File] {
+				continue
+			}
+			counted[file.File] = true
+			summary.MissingIncludes += len(file.Add)
+			summary.UnusedIncludes += len(file.Remove)
+		}
+	}
+	return summary
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// iwyu_report runs include-what-you-use over a translation unit and writes its analysis as JSON,
+// and merges the analyses of many translation units into a single report.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s run --source <file> --output <file> -- include-what-you-use <args>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge --output <file> [--list <file>] [<report.json>...]\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runMain(os.Args[2:])
+	case "merge":
+		err = mergeMain(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func runMain(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	source := flags.String("source", "", "source file of the translation unit")
+	output := flags.String("output", "", "file to write the JSON report to")
+	flags.Parse(args)
+
+	if *source == "" || *output == "" || flags.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	// include-what-you-use exits with an error status when it suggests changes, so its status
+	// is only checked when it printed no analysis.
+	cmd := exec.Command(flags.Arg(0), flags.Args()[1:]...)
+	out, runErr := cmd.CombinedOutput()
+	report, ok := parseIwyuOutput(*source, string(out))
+	if !ok {
+		os.Stderr.Write(out)
+		if runErr != nil {
+			return fmt.Errorf("%s: %s", flags.Arg(0), runErr)
+		}
+		return fmt.Errorf("%s printed no analysis of %s", flags.Arg(0), *source)
+	}
+
+	return writeJSON(*output, report)
+}
+
+func mergeMain(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("output", "", "file to write the merged report to")
+	list := flags.String("list", "", "file listing the reports to merge, one per line")
+	flags.Parse(args)
+
+	if *output == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	files := flags.Args()
+	if *list != "" {
+		data, err := os.ReadFile(*list)
+		if err != nil {
+			return err
+		}
+		files = append(files, strings.Fields(string(data))...)
+	}
+
+	reports := make([]Report, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		reports = append(reports, report)
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Source < reports[j].Source
+	})
+
+	return writeJSON(*output, summarize(reports))
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0666)
+}
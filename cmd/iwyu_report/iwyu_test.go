@@ -0,0 +1,97 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const iwyuOutput = `foo/foo.cpp:3:10: warning: unused variable 'x' [-Wunused-variable]
+
+foo/foo.h should add these lines:
+#include <stddef.h>                     // for size_t
+class Bar;
+
+foo/foo.h should remove these lines:
+- #include <map>  // lines 4-4
+- class Baz;  // lines 6-7
+
+The full include-list for foo/foo.h:
+#include <stddef.h>                     // for size_t
+#include <string>                       // for string
+class Bar;
+---
+
+(foo/foo.cpp has correct #includes/fwd-decls)
+`
+
+func TestParseIwyuOutput(t *testing.T) {
+	report, ok := parseIwyuOutput("foo/foo.cpp", iwyuOutput)
+	if !ok {
+		t.Fatal("want an analysis")
+	}
+	want := Report{
+		Source: "foo/foo.cpp",
+		Files: []FileReport{
+			{
+				File: "foo/foo.h",
+				Add: []Include{
+					{Line: "#include <stddef.h>", Comment: "for size_t"},
+					{Line: "class Bar;"},
+				},
+				Remove: []Include{
+					{Line: "#include <map>", FirstLine: 4, LastLine: 4},
+					{Line: "class Baz;", FirstLine: 6, LastLine: 7},
+				},
+			},
+			{
+				File:    "foo/foo.cpp",
+				Correct: true,
+			},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("want %#v\ngot  %#v", want, report)
+	}
+}
+
+func TestParseIwyuOutputNoAnalysis(t *testing.T) {
+	if _, ok := parseIwyuOutput("foo.cpp", "foo.cpp:1:10: fatal error: 'bar.h' file not found\n"); ok {
+		t.Errorf("want no analysis for a compilation error")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	a, _ := parseIwyuOutput("foo/foo.cpp", iwyuOutput)
+	b, _ := parseIwyuOutput("foo/bar.cpp", `foo/bar.cpp should remove these lines:
+- #include "foo/foo.h"  // lines 1-1
+
+foo/foo.h should add these lines:
+#include <stddef.h>                     // for size_t
+class Bar;
+`)
+	summary := summarize([]Report{a, b})
+	if summary.TranslationUnits != 2 {
+		t.Errorf("want 2 translation units, got %d", summary.TranslationUnits)
+	}
+	// foo/foo.h is counted once.
+	if summary.MissingIncludes != 2 {
+		t.Errorf("want 2 missing includes, got %d", summary.MissingIncludes)
+	}
+	if summary.UnusedIncludes != 3 {
+		t.Errorf("want 3 unused includes, got %d", summary.UnusedIncludes)
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// link_report reports on the links of cc modules from the files written by the linker, and on
+// the coverage of the profiles they are built with.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s unused-libs --config <file> --output <file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge-unused-libs --output <file> [--list <file>] [<report.json>...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s sizes --config <file> --output <file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge-sizes --output <file> [--list <file>] [<sizes.json>...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s lto-stats --config <file> --output <file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge-lto-stats --output <file> [--list <file>] [<lto_stats.json>...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s profile-coverage --config <file> --output <file>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge-profile-coverage --output <file> [--list <file>] [<report.json>...]\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "unused-libs":
+		err = unusedLibsMain(os.Args[2:])
+	case "merge-unused-libs":
+		err = mergeUnusedLibsMain(os.Args[2:])
+	case "sizes":
+		err = sizesMain(os.Args[2:])
+	case "merge-sizes":
+		err = mergeSizesMain(os.Args[2:])
+	case "lto-stats":
+		err = ltoStatsMain(os.Args[2:])
+	case "merge-lto-stats":
+		err = mergeLtoStatsMain(os.Args[2:])
+	case "profile-coverage":
+		err = profileCoverageMain(os.Args[2:])
+	case "merge-profile-coverage":
+		err = mergeProfileCoverageMain(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func unusedLibsMain(args []string) error {
+	flags := flag.NewFlagSet("unused-libs", flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON file describing the link")
+	output := flags.String("output", "", "file to write the report to")
+	flags.Parse(args)
+
+	if *configFile == "" || *output == "" || flags.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var config unusedLibsConfig
+	if err := readJSON(*configFile, &config); err != nil {
+		return err
+	}
+	inputs, err := readLinkInputs(config)
+	if err != nil {
+		return err
+	}
+	report, err := checkUnusedLibs(config, inputs, readTocFile)
+	if err != nil {
+		return err
+	}
+	return writeJSON(*output, report)
+}
+
+func mergeUnusedLibsMain(args []string) error {
+	flags := flag.NewFlagSet("merge-unused-libs", flag.ExitOnError)
+	output := flags.String("output", "", "file to write the merged report to")
+	list := flags.String("list", "", "file listing the reports to merge, one per line")
+	flags.Parse(args)
+
+	if *output == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	files, err := reportFiles(flags.Args(), *list)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]variantReport, 0, len(files))
+	for _, file := range files {
+		var report variantReport
+		if err := readJSON(file, &report); err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	return writeJSON(*output, mergeUnusedLibs(reports))
+}
+
+func sizesMain(args []string) error {
+	flags := flag.NewFlagSet("sizes", flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON file describing the link")
+	output := flags.String("output", "", "file to write the size attribution to")
+	flags.Parse(args)
+
+	if *configFile == "" || *output == "" || flags.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var config sizesConfig
+	if err := readJSON(*configFile, &config); err != nil {
+		return err
+	}
+	f, err := os.Open(config.Map)
+	if err != nil {
+		return err
+	}
+	sections, err := parseLinkerMap(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.Map, err)
+	}
+	return writeJSON(*output, attributeSizes(config, sections))
+}
+
+func mergeSizesMain(args []string) error {
+	flags := flag.NewFlagSet("merge-sizes", flag.ExitOnError)
+	output := flags.String("output", "", "file to write the sizes rolled up by partition to")
+	list := flags.String("list", "", "file listing the size attributions to merge, one per line")
+	flags.Parse(args)
+
+	if *output == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	files, err := reportFiles(flags.Args(), *list)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]binarySizes, 0, len(files))
+	for _, file := range files {
+		var report binarySizes
+		if err := readJSON(file, &report); err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	return writeJSON(*output, mergeSizes(reports))
+}
+
+func ltoStatsMain(args []string) error {
+	flags := flag.NewFlagSet("lto-stats", flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON file describing the link")
+	output := flags.String("output", "", "file to write the LTO diagnostics to")
+	flags.Parse(args)
+
+	if *configFile == "" || *output == "" || flags.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var config ltoStatsConfig
+	if err := readJSON(*configFile, &config); err != nil {
+		return err
+	}
+	f, err := os.Open(config.TimeTrace)
+	if err != nil {
+		return err
+	}
+	events, err := parseTimeTrace(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.TimeTrace, err)
+	}
+
+	var imports *ltoImports
+	if config.Stats != "" {
+		f, err := os.Open(config.Stats)
+		if err != nil {
+			return err
+		}
+		imports, err = parseLtoStats(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", config.Stats, err)
+		}
+	}
+	return writeJSON(*output, ltoStats(config, events, imports))
+}
+
+func mergeLtoStatsMain(args []string) error {
+	flags := flag.NewFlagSet("merge-lto-stats", flag.ExitOnError)
+	output := flags.String("output", "", "file to write the merged LTO diagnostics to")
+	list := flags.String("list", "", "file listing the LTO diagnostics to merge, one per line")
+	flags.Parse(args)
+
+	if *output == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	files, err := reportFiles(flags.Args(), *list)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]ltoLinkStats, 0, len(files))
+	for _, file := range files {
+		var report ltoLinkStats
+		if err := readJSON(file, &report); err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	return writeJSON(*output, mergeLtoStats(reports))
+}
+
+func profileCoverageMain(args []string) error {
+	flags := flag.NewFlagSet("profile-coverage", flag.ExitOnError)
+	configFile := flags.String("config", "", "JSON file describing the profiles of the module")
+	output := flags.String("output", "", "file to write the coverage of the profiles to")
+	flags.Parse(args)
+
+	if *configFile == "" || *output == "" || flags.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var config profileCoverageConfig
+	if err := readJSON(*configFile, &config); err != nil {
+		return err
+	}
+	f, err := os.Open(config.Symbols)
+	if err != nil {
+		return err
+	}
+	symbols, err := parseSymbols(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.Symbols, err)
+	}
+
+	report := profileCoverage{
+		Module:  config.Module,
+		Variant: config.Variant,
+	}
+	if config.AfdoText != "" {
+		f, err := os.Open(config.AfdoText)
+		if err != nil {
+			return err
+		}
+		functions, err := parseSampleProfile(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", config.AfdoText, err)
+		}
+		report.Afdo = afdoCoverage(config.AfdoProfile, functions, symbols, config.Threshold)
+		report.Stale = report.Stale || report.Afdo.Stale
+	}
+	if config.Orderfile != "" {
+		f, err := os.Open(config.Orderfile)
+		if err != nil {
+			return err
+		}
+		orderfileSymbols, err := parseOrderfile(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", config.Orderfile, err)
+		}
+		report.Orderfile = orderfileCoverage(config.Orderfile, orderfileSymbols, symbols, config.Threshold)
+		report.Stale = report.Stale || report.Orderfile.Stale
+	}
+	return writeJSON(*output, report)
+}
+
+func mergeProfileCoverageMain(args []string) error {
+	flags := flag.NewFlagSet("merge-profile-coverage", flag.ExitOnError)
+	output := flags.String("output", "", "file to write the merged coverage of the profiles to")
+	list := flags.String("list", "", "file listing the reports to merge, one per line")
+	flags.Parse(args)
+
+	if *output == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	files, err := reportFiles(flags.Args(), *list)
+	if err != nil {
+		return err
+	}
+
+	reports := make([]profileCoverage, 0, len(files))
+	for _, file := range files {
+		var report profileCoverage
+		if err := readJSON(file, &report); err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	summary := mergeProfileCoverage(reports)
+	for _, stale := range summary.Stale {
+		covered := "symbols"
+		if stale.Kind == kindAfdo {
+			covered = "hot functions"
+		}
+		fmt.Fprintf(os.Stderr, "warning: %s profile %s of %s (%s) is stale: only %.0f%% of its %s are defined\n",
+			stale.Kind, stale.Profile, stale.Module, stale.Variant, stale.Coverage*100, covered)
+	}
+	return writeJSON(*output, summary)
+}
+
+// reportFiles returns the files given as arguments and the ones listed in the list file.
+func reportFiles(args []string, list string) ([]string, error) {
+	files := args
+	if list != "" {
+		data, err := os.ReadFile(list)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, strings.Fields(string(data))...)
+	}
+	return files, nil
+}
+
+func readJSON(file string, v interface{}) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+func writeJSON(file string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0666)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// inputSection is an input section in a linker map written by lld.
+type inputSection struct {
+	// OutputSection is the name of the output section the input section was placed in.
+	OutputSection string
+	// File is the input file, an object file or an archive.
+	File string
+	// Member is the archive member, if File is an archive.
+	Member string
+	// Name is the name of the input section.
+	Name string
+	Size uint64
+}
+
+// lld writes the VMA, LMA, size and alignment of each line in fixed width columns, followed by
+// the output section, input section or symbol, each indented by 8 more spaces than the previous.
+var mapLineRe = regexp.MustCompile(`^\s*[0-9a-f]+\s+[0-9a-f]+\s+([0-9a-f]+)\s+\d+ ( *)(\S.*)$`)
+
+// parseLinkerMap returns the input sections of a linker map written by lld with -Map.
+func parseLinkerMap(r io.Reader) ([]inputSection, error) {
+	var sections []inputSection
+	outputSection := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		m := mapLineRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			// The header, or a line that is not part of a section.
+			continue
+		}
+		size, err := strconv.ParseUint(m[1], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid size %q", lineNum, m[1])
+		}
+		switch len(m[2]) {
+		case 0:
+			outputSection = m[3]
+		case 8:
+			section, ok := parseInputSection(m[3])
+			if !ok {
+				// Assignments in linker scripts are also indented like input sections.
+				continue
+			}
+			section.OutputSection = outputSection
+			section.Size = size
+			sections = append(sections, section)
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// parseInputSection parses an input section, "file:(section)" or "archive(member):(section)".
+func parseInputSection(s string) (inputSection, bool) {
+	i := strings.LastIndex(s, ":(")
+	if i < 0 || !strings.HasSuffix(s, ")") {
+		return inputSection{}, false
+	}
+	section := inputSection{File: s[:i], Name: s[i+2 : len(s)-1]}
+	if strings.HasSuffix(section.File, ")") {
+		// Member names may contain parentheses, but archive names do not.
+		dir := strings.LastIndex(section.File, "/") + 1
+		if j := strings.Index(section.File[dir:], "("); j > 0 {
+			section.Member = section.File[dir+j+1 : len(section.File)-1]
+			section.File = section.File[:dir+j]
+		}
+	}
+	return section, true
+}
+
+// allocated returns true if the output section is loaded at run time, and so contains the code or
+// data of the input sections. Debug information, notes and the symbol table are not.
+func allocated(outputSection string) bool {
+	for _, prefix := range []string{".debug", ".comment", ".note", ".symtab", ".strtab", ".shstrtab",
+		".gnu_debuglink", ".llvm", ".ARM.attributes", ".riscv.attributes"} {
+		if strings.HasPrefix(outputSection, prefix) {
+			return false
+		}
+	}
+	return true
+}
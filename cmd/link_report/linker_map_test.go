@@ -0,0 +1,69 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const linkerMap = `             VMA              LMA     Size Align Out     In      Symbol
+             2a8              2a8       15     1 .interp
+             2a8              2a8       15     1         <internal>:(.interp)
+            1000             1000       64    16 .text
+            1000             1000       20    16         out/obj/main.o:(.text.main)
+            1000             1000        0     1                 main
+            1020             1020       44    16         out/libfoo.a(foo.o):(.text._Z3foov)
+            1020             1020        0     1                 foo()
+            1064             1064        0     1         . = ALIGN ( 16 )
+            2000             2000        8     8 .data
+            2000             2000        8     8         out/libbar.a(bar (1).o):(.data)
+               0                0       20     1 .comment
+               0                0       20     1         out/libbaz.a(baz.o):(.comment)
+`
+
+func TestParseLinkerMap(t *testing.T) {
+	sections, err := parseLinkerMap(strings.NewReader(linkerMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inputSection{
+		{OutputSection: ".interp", File: "<internal>", Name: ".interp", Size: 0x15},
+		{OutputSection: ".text", File: "out/obj/main.o", Name: ".text.main", Size: 0x20},
+		{OutputSection: ".text", File: "out/libfoo.a", Member: "foo.o", Name: ".text._Z3foov", Size: 0x44},
+		{OutputSection: ".data", File: "out/libbar.a", Member: "bar (1).o", Name: ".data", Size: 8},
+		{OutputSection: ".comment", File: "out/libbaz.a", Member: "baz.o", Name: ".comment", Size: 0x20},
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Errorf("want %#v\ngot  %#v", want, sections)
+	}
+}
+
+func TestAllocated(t *testing.T) {
+	for section, want := range map[string]bool{
+		".text":          true,
+		".data.rel.ro":   true,
+		".bss":           true,
+		".comment":       false,
+		".debug_info":    false,
+		".llvm_addrsig":  false,
+		".note.gnu.prop": false,
+	} {
+		if got := allocated(section); got != want {
+			t.Errorf("allocated(%q): want %v, got %v", section, want, got)
+		}
+	}
+}
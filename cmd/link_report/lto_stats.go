@@ -0,0 +1,250 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ltoStatsConfig describes the link of a module variant whose LTO diagnostics are reported,
+// written by Soong.
+type ltoStatsConfig struct {
+	Module  string `json:"module"`
+	Variant string `json:"variant"`
+	// Cache is true if the link used the shared ThinLTO cache.
+	Cache bool `json:"cache"`
+
+	// TimeTrace is the trace written by lld with --time-trace.
+	TimeTrace string `json:"time_trace"`
+	// Stats is the file of LLVM statistics written by lld with --plugin-opt=stats-file, if the
+	// link has bitcode inputs.
+	Stats string `json:"stats"`
+}
+
+// Names of the time trace events of lld for the whole link and for LTO.
+const (
+	linkEvent = "ExecuteLinker"
+	ltoEvent  = "LTO"
+)
+
+// ltoEventTime is the total time spent in the events of a name.
+type ltoEventTime struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count"`
+	Ms    float64 `json:"ms"`
+}
+
+// ltoImports are the counts of the function import statistics of LLVM. The statistics are
+// compiled out of LLVM unless it is built with assertions or LLVM_FORCE_ENABLE_STATS, so they are
+// missing with most release toolchains.
+type ltoImports struct {
+	Functions  uint64 `json:"functions"`
+	GlobalVars uint64 `json:"global_vars"`
+	Modules    uint64 `json:"modules"`
+}
+
+func (i *ltoImports) add(other ltoImports) {
+	i.Functions += other.Functions
+	i.GlobalVars += other.GlobalVars
+	i.Modules += other.Modules
+}
+
+// ltoLinkStats are the LTO diagnostics of a link.
+type ltoLinkStats struct {
+	Module  string  `json:"module"`
+	Variant string  `json:"variant"`
+	Cache   bool    `json:"cache"`
+	LinkMs  float64 `json:"link_ms"`
+	LtoMs   float64 `json:"lto_ms"`
+	// Imports is nil if the statistics are not available, see ltoImports.
+	Imports *ltoImports `json:"imports"`
+	// Events are the total times of all the events of the time trace, by decreasing time.
+	Events []ltoEventTime `json:"events"`
+}
+
+// ltoLinkTotal is the summary of a link in the merged report.
+type ltoLinkTotal struct {
+	Module  string      `json:"module"`
+	Variant string      `json:"variant"`
+	Cache   bool        `json:"cache"`
+	LinkMs  float64     `json:"link_ms"`
+	LtoMs   float64     `json:"lto_ms"`
+	Imports *ltoImports `json:"imports"`
+}
+
+// ltoSummary is the merged report of the LTO diagnostics of all links.
+type ltoSummary struct {
+	LinkCount       int     `json:"link_count"`
+	CachedLinkCount int     `json:"cached_link_count"`
+	LinkMs          float64 `json:"link_ms"`
+	LtoMs           float64 `json:"lto_ms"`
+	// Imports are the imports of the links that have statistics, and
+	// LinksWithoutImportStats the count of the links that don't.
+	Imports                 ltoImports `json:"imports"`
+	LinksWithoutImportStats int        `json:"links_without_import_stats"`
+	// Events are the total times of the events of all the links, by decreasing time.
+	Events []ltoEventTime `json:"events"`
+	// Links are the links by decreasing LTO time.
+	Links []ltoLinkTotal `json:"links"`
+}
+
+// timeTrace is the Chrome trace event format written by the LLVM time trace profiler.
+type timeTrace struct {
+	TraceEvents []struct {
+		Name string `json:"name"`
+		Ph   string `json:"ph"`
+		Dur  int64  `json:"dur"`
+		Args struct {
+			Count int `json:"count"`
+		} `json:"args"`
+	} `json:"traceEvents"`
+}
+
+// parseTimeTrace returns the total times of the events of a time trace. The LLVM time trace
+// profiler adds a "Total <name>" event for each event name with the count of the events and
+// their total duration, in microseconds. The durations of the events of concurrent threads, like
+// the ThinLTO backends, add up.
+func parseTimeTrace(r io.Reader) ([]ltoEventTime, error) {
+	var trace timeTrace
+	if err := json.NewDecoder(r).Decode(&trace); err != nil {
+		return nil, err
+	}
+	var events []ltoEventTime
+	for _, event := range trace.TraceEvents {
+		if event.Ph != "X" || !strings.HasPrefix(event.Name, "Total ") {
+			continue
+		}
+		events = append(events, ltoEventTime{
+			Name:  strings.TrimPrefix(event.Name, "Total "),
+			Count: event.Args.Count,
+			Ms:    float64(event.Dur) / 1000,
+		})
+	}
+	sortEventTimes(events)
+	return events, nil
+}
+
+func sortEventTimes(events []ltoEventTime) {
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Ms != events[j].Ms {
+			return events[i].Ms > events[j].Ms
+		}
+		return events[i].Name < events[j].Name
+	})
+}
+
+// parseLtoStats returns the function import statistics from a JSON file of LLVM statistics, or
+// nil if LLVM was built without statistics, in which case the file only has the timers of the
+// passes. A missing import counter of an LLVM with statistics means that nothing was imported.
+func parseLtoStats(r io.Reader) (*ltoImports, error) {
+	var stats map[string]float64
+	if err := json.NewDecoder(r).Decode(&stats); err != nil {
+		return nil, err
+	}
+	hasStatistics := false
+	for name := range stats {
+		if !strings.HasPrefix(name, "time.") {
+			hasStatistics = true
+			break
+		}
+	}
+	if !hasStatistics {
+		return nil, nil
+	}
+	return &ltoImports{
+		Functions:  uint64(stats["function-import.NumImportedFunctions"]),
+		GlobalVars: uint64(stats["function-import.NumImportedGlobalVars"]),
+		Modules:    uint64(stats["function-import.NumImportedModules"]),
+	}, nil
+}
+
+// ltoStats returns the LTO diagnostics of a link from the total times of its events and its
+// import statistics.
+func ltoStats(config ltoStatsConfig, events []ltoEventTime, imports *ltoImports) ltoLinkStats {
+	stats := ltoLinkStats{
+		Module:  config.Module,
+		Variant: config.Variant,
+		Cache:   config.Cache,
+		Imports: imports,
+		Events:  events,
+	}
+	for _, event := range events {
+		switch event.Name {
+		case linkEvent:
+			stats.LinkMs = event.Ms
+		case ltoEvent:
+			stats.LtoMs = event.Ms
+		}
+	}
+	return stats
+}
+
+// mergeLtoStats sums the LTO diagnostics of links.
+func mergeLtoStats(reports []ltoLinkStats) ltoSummary {
+	summary := ltoSummary{
+		Events: []ltoEventTime{},
+		Links:  []ltoLinkTotal{},
+	}
+	events := make(map[string]*ltoEventTime)
+	for _, report := range reports {
+		summary.LinkCount++
+		if report.Cache {
+			summary.CachedLinkCount++
+		}
+		summary.LinkMs += report.LinkMs
+		summary.LtoMs += report.LtoMs
+		if report.Imports != nil {
+			summary.Imports.add(*report.Imports)
+		} else {
+			summary.LinksWithoutImportStats++
+		}
+		summary.Links = append(summary.Links, ltoLinkTotal{
+			Module:  report.Module,
+			Variant: report.Variant,
+			Cache:   report.Cache,
+			LinkMs:  report.LinkMs,
+			LtoMs:   report.LtoMs,
+			Imports: report.Imports,
+		})
+		for _, event := range report.Events {
+			total, ok := events[event.Name]
+			if !ok {
+				total = &ltoEventTime{Name: event.Name}
+				events[event.Name] = total
+			}
+			total.Count += event.Count
+			total.Ms += event.Ms
+		}
+	}
+
+	for _, event := range events {
+		summary.Events = append(summary.Events, *event)
+	}
+	sortEventTimes(summary.Events)
+	sort.Slice(summary.Links, func(i, j int) bool {
+		a, b := summary.Links[i], summary.Links[j]
+		if a.LtoMs != b.LtoMs {
+			return a.LtoMs > b.LtoMs
+		}
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		return a.Variant < b.Variant
+	})
+	return summary
+}
@@ -0,0 +1,152 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testTimeTrace = `{
+  "traceEvents": [
+    {"pid": 1, "tid": 1, "ph": "X", "ts": 10, "dur": 1500, "name": "ExecuteLinker"},
+    {"pid": 1, "tid": 1, "ph": "X", "ts": 20, "dur": 1000, "name": "LTO"},
+    {"pid": 1, "tid": 2, "ph": "X", "ts": 0, "dur": 2500, "name": "Total ExecuteLinker", "args": {"count": 1, "avg ms": 2}},
+    {"pid": 1, "tid": 3, "ph": "X", "ts": 0, "dur": 1000, "name": "Total LTO", "args": {"count": 1, "avg ms": 1}},
+    {"pid": 1, "tid": 4, "ph": "X", "ts": 0, "dur": 1200, "name": "Total OptModule", "args": {"count": 3, "avg ms": 0}},
+    {"pid": 1, "tid": 0, "ph": "M", "ts": 0, "cat": "", "name": "process_name", "args": {"name": "ld.lld"}}
+  ],
+  "beginningOfTime": 1700000000000000
+}`
+
+func TestParseTimeTrace(t *testing.T) {
+	events, err := parseTimeTrace(strings.NewReader(testTimeTrace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ltoEventTime{
+		{Name: "ExecuteLinker", Count: 1, Ms: 2.5},
+		{Name: "OptModule", Count: 3, Ms: 1.2},
+		{Name: "LTO", Count: 1, Ms: 1},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("want %#v\ngot  %#v", want, events)
+	}
+}
+
+func TestParseLtoStats(t *testing.T) {
+	stats := `{
+	"function-import.NumImportedFunctions": 42,
+	"function-import.NumImportedFunctionsThinLink": 50,
+	"function-import.NumImportedGlobalVars": 3,
+	"function-import.NumImportedModules": 7,
+	"time.pass.Function Importing.wall": 0.25
+}`
+	imports, err := parseLtoStats(strings.NewReader(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ltoImports{Functions: 42, GlobalVars: 3, Modules: 7}
+	if imports == nil || *imports != want {
+		t.Errorf("want %#v\ngot  %#v", want, imports)
+	}
+
+	// The statistics of an LLVM built without them only have the timers of the passes.
+	imports, err = parseLtoStats(strings.NewReader(`{"time.pass.Function Importing.wall": 0.25}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imports != nil {
+		t.Errorf("want no imports without statistics, got %#v", imports)
+	}
+}
+
+func TestLtoStats(t *testing.T) {
+	config := ltoStatsConfig{Module: "foo", Variant: "android_arm64_armv8-a", Cache: true}
+	events := []ltoEventTime{
+		{Name: "ExecuteLinker", Count: 1, Ms: 2.5},
+		{Name: "LTO", Count: 1, Ms: 1},
+	}
+	imports := &ltoImports{Functions: 42}
+	want := ltoLinkStats{
+		Module:  "foo",
+		Variant: "android_arm64_armv8-a",
+		Cache:   true,
+		LinkMs:  2.5,
+		LtoMs:   1,
+		Imports: imports,
+		Events:  events,
+	}
+	if got := ltoStats(config, events, imports); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
+
+func TestMergeLtoStats(t *testing.T) {
+	reports := []ltoLinkStats{
+		{
+			Module:  "foo",
+			Variant: "arm64",
+			Cache:   true,
+			LinkMs:  3,
+			LtoMs:   2,
+			Imports: &ltoImports{Functions: 10, Modules: 2},
+			Events: []ltoEventTime{
+				{Name: "ExecuteLinker", Count: 1, Ms: 3},
+				{Name: "LTO", Count: 1, Ms: 2},
+			},
+		},
+		{
+			Module:  "bar",
+			Variant: "arm64",
+			LinkMs:  8,
+			LtoMs:   6,
+			Imports: &ltoImports{Functions: 5, GlobalVars: 1, Modules: 1},
+			Events: []ltoEventTime{
+				{Name: "ExecuteLinker", Count: 1, Ms: 8},
+				{Name: "LTO", Count: 1, Ms: 6},
+				{Name: "OptModule", Count: 4, Ms: 5},
+			},
+		},
+		{
+			Module:  "baz",
+			Variant: "arm64",
+			LinkMs:  1,
+			LtoMs:   0.5,
+		},
+	}
+	want := ltoSummary{
+		LinkCount:               3,
+		CachedLinkCount:         1,
+		LinkMs:                  12,
+		LtoMs:                   8.5,
+		Imports:                 ltoImports{Functions: 15, GlobalVars: 1, Modules: 3},
+		LinksWithoutImportStats: 1,
+		Events: []ltoEventTime{
+			{Name: "ExecuteLinker", Count: 2, Ms: 11},
+			{Name: "LTO", Count: 2, Ms: 8},
+			{Name: "OptModule", Count: 4, Ms: 5},
+		},
+		Links: []ltoLinkTotal{
+			{Module: "bar", Variant: "arm64", LinkMs: 8, LtoMs: 6, Imports: &ltoImports{Functions: 5, GlobalVars: 1, Modules: 1}},
+			{Module: "foo", Variant: "arm64", Cache: true, LinkMs: 3, LtoMs: 2, Imports: &ltoImports{Functions: 10, Modules: 2}},
+			{Module: "baz", Variant: "arm64", LinkMs: 1, LtoMs: 0.5},
+		},
+	}
+	if got := mergeLtoStats(reports); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
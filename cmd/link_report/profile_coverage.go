@@ -0,0 +1,320 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// profileCoverageConfig describes the profiles a module variant is built with, written by Soong.
+type profileCoverageConfig struct {
+	Module  string `json:"module"`
+	Variant string `json:"variant"`
+
+	// Symbols are the symbols of the unstripped output of the link, listed by
+	// llvm-nm -P --defined-only.
+	Symbols string `json:"symbols"`
+
+	// AfdoProfile is the AFDO profile of the module, and AfdoText the same profile converted to
+	// the text format by llvm-profdata.
+	AfdoProfile string `json:"afdo_profile,omitempty"`
+	AfdoText    string `json:"afdo_text,omitempty"`
+
+	// Orderfile is the orderfile of the module.
+	Orderfile string `json:"orderfile,omitempty"`
+
+	// Threshold is the coverage below which a profile is reported as stale.
+	Threshold float64 `json:"threshold"`
+}
+
+// The kinds of profiles.
+const (
+	kindAfdo      = "afdo"
+	kindOrderfile = "orderfile"
+)
+
+// hotSamplesFraction is the fraction of the samples of an AFDO profile taken by its hot
+// functions.
+const hotSamplesFraction = 0.9
+
+// maxMissing is the maximum number of missing functions or symbols listed in a report.
+const maxMissing = 10
+
+// profileFunction is a top-level function of a sample profile.
+type profileFunction struct {
+	Name    string
+	Samples uint64
+}
+
+// hotCoverage is the fraction of the hot functions of an AFDO profile, which take 90% of its
+// samples, that are defined by the output of the link.
+type hotCoverage struct {
+	Total    int     `json:"total"`
+	Found    int     `json:"found"`
+	Coverage float64 `json:"coverage"`
+}
+
+// coverage is the fraction of the functions or symbols of a profile that are defined by the
+// output of the link.
+type coverage struct {
+	Profile  string  `json:"profile"`
+	Total    int     `json:"total"`
+	Found    int     `json:"found"`
+	Coverage float64 `json:"coverage"`
+
+	// Hot is the coverage of the hot functions of an AFDO profile.
+	Hot *hotCoverage `json:"hot,omitempty"`
+
+	// Unchecked is the number of functions of an AFDO profile with MD5 names, which cannot be
+	// matched with the symbols.
+	Unchecked int `json:"unchecked,omitempty"`
+
+	// Missing are the first missing symbols of an orderfile, or the hottest missing functions
+	// of an AFDO profile.
+	Missing []string `json:"missing,omitempty"`
+
+	Stale bool `json:"stale"`
+}
+
+// profileCoverage is the coverage of the profiles of a module variant.
+type profileCoverage struct {
+	Module    string    `json:"module"`
+	Variant   string    `json:"variant"`
+	Afdo      *coverage `json:"afdo,omitempty"`
+	Orderfile *coverage `json:"orderfile,omitempty"`
+	Stale     bool      `json:"stale"`
+}
+
+// staleProfile is a stale profile in the merged report.
+type staleProfile struct {
+	Module   string  `json:"module"`
+	Variant  string  `json:"variant"`
+	Kind     string  `json:"kind"`
+	Profile  string  `json:"profile"`
+	Coverage float64 `json:"coverage"`
+}
+
+// profileCoverageSummary is the merged report of the coverage of the profiles of all modules.
+type profileCoverageSummary struct {
+	ModuleCount int `json:"module_count"`
+	StaleCount  int `json:"stale_count"`
+	// Stale are the stale profiles by increasing coverage.
+	Stale   []staleProfile    `json:"stale"`
+	Modules []profileCoverage `json:"modules"`
+}
+
+// symbolName returns the name of a symbol or profile function without the suffixes added by
+// the compiler to internal or cloned functions, like .__uniq.<hash>, .llvm.<hash> or .cold.
+func symbolName(name string) string {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// parseSymbols returns the defined functions listed by llvm-nm -P.
+func parseSymbols(r io.Reader) (map[string]bool, error) {
+	symbols := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Skip the names of the files and archive members, which end with a colon.
+		if len(fields) < 2 || len(fields[1]) != 1 {
+			continue
+		}
+		switch fields[1][0] {
+		case 'T', 't', 'W', 'w', 'i':
+			symbols[symbolName(fields[0])] = true
+		}
+	}
+	return symbols, scanner.Err()
+}
+
+// parseSampleProfile returns the top-level functions of a sample profile in the text format,
+// whose lines are "<name>:<total samples>:<head samples>". The lines of the bodies of the
+// functions are indented.
+func parseSampleProfile(r io.Reader) ([]profileFunction, error) {
+	var functions []profileFunction
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || text[0] == ' ' || text[0] == '\t' {
+			continue
+		}
+		fields := strings.Split(text, ":")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: invalid function %q", line, text)
+		}
+		samples, err := strconv.ParseUint(fields[len(fields)-2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid samples in %q", line, text)
+		}
+		functions = append(functions, profileFunction{
+			Name:    strings.Join(fields[:len(fields)-2], ":"),
+			Samples: samples,
+		})
+	}
+	return functions, scanner.Err()
+}
+
+// parseOrderfile returns the symbols of an orderfile, one per line.
+func parseOrderfile(r io.Reader) ([]string, error) {
+	var symbols []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		symbol := strings.TrimSpace(scanner.Text())
+		if symbol == "" || strings.HasPrefix(symbol, "#") {
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, scanner.Err()
+}
+
+func fraction(found, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(found) / float64(total)
+}
+
+// isMD5Name returns whether the name of a profile function is the MD5 hash of its name.
+func isMD5Name(name string) bool {
+	_, err := strconv.ParseUint(name, 10, 64)
+	return err == nil
+}
+
+// afdoCoverage returns the coverage of the functions of an AFDO profile. It is stale if the
+// coverage of its hot functions is below the threshold.
+func afdoCoverage(profile string, functions []profileFunction, symbols map[string]bool, threshold float64) *coverage {
+	c := &coverage{Profile: profile}
+
+	var checked []profileFunction
+	var samples uint64
+	for _, function := range functions {
+		if isMD5Name(function.Name) {
+			c.Unchecked++
+			continue
+		}
+		checked = append(checked, function)
+		samples += function.Samples
+		c.Total++
+		if symbols[symbolName(function.Name)] {
+			c.Found++
+		}
+	}
+	c.Coverage = fraction(c.Found, c.Total)
+
+	sort.SliceStable(checked, func(i, j int) bool {
+		return checked[i].Samples > checked[j].Samples
+	})
+	hot := &hotCoverage{}
+	var hotSamples uint64
+	for _, function := range checked {
+		if samples == 0 || float64(hotSamples) >= hotSamplesFraction*float64(samples) {
+			break
+		}
+		hotSamples += function.Samples
+		hot.Total++
+		if symbols[symbolName(function.Name)] {
+			hot.Found++
+		} else if len(c.Missing) < maxMissing {
+			c.Missing = append(c.Missing, function.Name)
+		}
+	}
+	hot.Coverage = fraction(hot.Found, hot.Total)
+	c.Hot = hot
+	c.Stale = hot.Coverage < threshold
+	return c
+}
+
+// orderfileCoverage returns the coverage of the symbols of an orderfile. It is stale if the
+// coverage is below the threshold.
+func orderfileCoverage(orderfile string, orderfileSymbols []string, symbols map[string]bool, threshold float64) *coverage {
+	c := &coverage{Profile: orderfile}
+	for _, symbol := range orderfileSymbols {
+		c.Total++
+		if symbols[symbolName(symbol)] {
+			c.Found++
+		} else if len(c.Missing) < maxMissing {
+			c.Missing = append(c.Missing, symbol)
+		}
+	}
+	c.Coverage = fraction(c.Found, c.Total)
+	c.Stale = c.Coverage < threshold
+	return c
+}
+
+// mergeProfileCoverage lists the stale profiles of all modules.
+func mergeProfileCoverage(reports []profileCoverage) profileCoverageSummary {
+	summary := profileCoverageSummary{
+		Stale:   []staleProfile{},
+		Modules: append([]profileCoverage{}, reports...),
+	}
+	for _, report := range summary.Modules {
+		summary.ModuleCount++
+		if report.Stale {
+			summary.StaleCount++
+		}
+		if c := report.Afdo; c != nil && c.Stale {
+			summary.Stale = append(summary.Stale, staleProfile{
+				Module:   report.Module,
+				Variant:  report.Variant,
+				Kind:     kindAfdo,
+				Profile:  c.Profile,
+				Coverage: c.Hot.Coverage,
+			})
+		}
+		if c := report.Orderfile; c != nil && c.Stale {
+			summary.Stale = append(summary.Stale, staleProfile{
+				Module:   report.Module,
+				Variant:  report.Variant,
+				Kind:     kindOrderfile,
+				Profile:  c.Profile,
+				Coverage: c.Coverage,
+			})
+		}
+	}
+
+	sort.SliceStable(summary.Modules, func(i, j int) bool {
+		a, b := summary.Modules[i], summary.Modules[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		return a.Variant < b.Variant
+	})
+	sort.SliceStable(summary.Stale, func(i, j int) bool {
+		a, b := summary.Stale[i], summary.Stale[j]
+		if a.Coverage != b.Coverage {
+			return a.Coverage < b.Coverage
+		}
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.Variant != b.Variant {
+			return a.Variant < b.Variant
+		}
+		return a.Kind < b.Kind
+	})
+	return summary
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSymbols = `out/foo/main.o:
+main T 0 10
+_ZL6helperv.__uniq.1234 t 10 8
+counter D 0 4
+out/libbar/libbar.a[bar.o]:
+_Z3barv T 0 20
+_Z3bazv U
+_Z4weakv W 20 4
+`
+
+func TestParseSymbols(t *testing.T) {
+	symbols, err := parseSymbols(strings.NewReader(testSymbols))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"main":        true,
+		"_ZL6helperv": true,
+		"_Z3barv":     true,
+		"_Z4weakv":    true,
+	}
+	if !reflect.DeepEqual(symbols, want) {
+		t.Errorf("want %v\ngot  %v", want, symbols)
+	}
+}
+
+func TestParseSampleProfile(t *testing.T) {
+	profile := `main:1000:10
+ 1: 10
+ 2: 20 _Z3barv:15
+ 3: _Z3bazv:40
+  1: 40
+_Z3barv:500:5
+ 1: 5
+1234567890:20:0
+`
+	functions, err := parseSampleProfile(strings.NewReader(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []profileFunction{
+		{Name: "main", Samples: 1000},
+		{Name: "_Z3barv", Samples: 500},
+		{Name: "1234567890", Samples: 20},
+	}
+	if !reflect.DeepEqual(functions, want) {
+		t.Errorf("want %#v\ngot  %#v", want, functions)
+	}
+
+	if _, err := parseSampleProfile(strings.NewReader("main:many:10\n")); err == nil {
+		t.Errorf("want error for invalid samples")
+	}
+}
+
+func TestAfdoCoverage(t *testing.T) {
+	symbols := map[string]bool{"main": true, "_ZL6helperv": true}
+	functions := []profileFunction{
+		{Name: "_Z3oldv", Samples: 100},
+		{Name: "main", Samples: 800},
+		{Name: "_ZL6helperv.__uniq.5678", Samples: 50},
+		{Name: "_Z4coldv", Samples: 50},
+		{Name: "1234567890", Samples: 1000},
+	}
+
+	want := &coverage{
+		Profile:   "foo.afdo",
+		Total:     4,
+		Found:     2,
+		Coverage:  0.5,
+		Hot:       &hotCoverage{Total: 2, Found: 1, Coverage: 0.5},
+		Unchecked: 1,
+		Missing:   []string{"_Z3oldv"},
+		Stale:     true,
+	}
+	if got := afdoCoverage("foo.afdo", functions, symbols, 0.8); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+
+	want.Stale = false
+	if got := afdoCoverage("foo.afdo", functions, symbols, 0.5); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
+
+func TestOrderfileCoverage(t *testing.T) {
+	orderfile, err := parseOrderfile(strings.NewReader("# comment\nmain\n\n_Z3barv\n_Z3oldv\n_Z4weakv\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := map[string]bool{"main": true, "_Z3barv": true, "_Z4weakv": true}
+
+	want := &coverage{
+		Profile:  "foo.orderfile",
+		Total:    4,
+		Found:    3,
+		Coverage: 0.75,
+		Missing:  []string{"_Z3oldv"},
+		Stale:    true,
+	}
+	if got := orderfileCoverage("foo.orderfile", orderfile, symbols, 0.8); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
+
+func TestMergeProfileCoverage(t *testing.T) {
+	reports := []profileCoverage{
+		{
+			Module:  "foo",
+			Variant: "arm64",
+			Afdo: &coverage{
+				Profile:  "foo.afdo",
+				Coverage: 0.5,
+				Hot:      &hotCoverage{Coverage: 0.6},
+				Stale:    true,
+			},
+			Orderfile: &coverage{Profile: "foo.orderfile", Coverage: 0.9},
+			Stale:     true,
+		},
+		{
+			Module:    "bar",
+			Variant:   "arm64",
+			Orderfile: &coverage{Profile: "bar.orderfile", Coverage: 0.3, Stale: true},
+			Stale:     true,
+		},
+		{
+			Module:  "baz",
+			Variant: "arm64",
+			Afdo:    &coverage{Profile: "baz.afdo", Coverage: 1, Hot: &hotCoverage{Coverage: 1}},
+		},
+	}
+
+	got := mergeProfileCoverage(reports)
+	wantStale := []staleProfile{
+		{Module: "bar", Variant: "arm64", Kind: kindOrderfile, Profile: "bar.orderfile", Coverage: 0.3},
+		{Module: "foo", Variant: "arm64", Kind: kindAfdo, Profile: "foo.afdo", Coverage: 0.6},
+	}
+	if !reflect.DeepEqual(got.Stale, wantStale) {
+		t.Errorf("want stale %#v\ngot  %#v", wantStale, got.Stale)
+	}
+	if got.ModuleCount != 3 || got.StaleCount != 2 {
+		t.Errorf("want 3 modules and 2 stale, got %d modules and %d stale", got.ModuleCount, got.StaleCount)
+	}
+	var modules []string
+	for _, m := range got.Modules {
+		modules = append(modules, m.Module)
+	}
+	if want := []string{"bar", "baz", "foo"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("want modules %q, got %q", want, modules)
+	}
+}
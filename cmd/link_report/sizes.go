@@ -0,0 +1,288 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sizesConfig describes the link of a module variant whose size is attributed, written by Soong.
+type sizesConfig struct {
+	Module    string `json:"module"`
+	Variant   string `json:"variant"`
+	Partition string `json:"partition"`
+
+	// Map is the linker map written with -Map.
+	Map string `json:"map"`
+	// Lto is true if the objects were LTO bitcode, so the linker map attributes their code to
+	// the LTO output instead.
+	Lto bool `json:"lto"`
+
+	// Objects are the object files of the module itself.
+	Objects []string `json:"objects"`
+	// WholeStaticLibs are the whole static libraries whose object files the linker got instead
+	// of the archive. Other static libraries are attributed by the name of their archive.
+	WholeStaticLibs []staticLib `json:"whole_static_libs"`
+}
+
+// The kinds of contributors to the size of a binary.
+const (
+	kindModule    = "module"
+	kindStaticLib = "static_lib"
+	kindLinker    = "linker"
+	kindLto       = "lto"
+	kindOther     = "other"
+)
+
+// sectionSizes are the sizes of the code, data and zero-initialized data of a contributor.
+type sectionSizes struct {
+	Text uint64 `json:"text"`
+	Data uint64 `json:"data"`
+	Bss  uint64 `json:"bss"`
+}
+
+func (s sectionSizes) total() uint64 {
+	return s.Text + s.Data + s.Bss
+}
+
+func (s *sectionSizes) add(other sectionSizes) {
+	s.Text += other.Text
+	s.Data += other.Data
+	s.Bss += other.Bss
+}
+
+// addSection adds the size of an input section according to its output section.
+func (s *sectionSizes) addSection(outputSection string, size uint64) {
+	switch {
+	case strings.HasPrefix(outputSection, ".text"), outputSection == ".init", outputSection == ".fini",
+		outputSection == ".plt", outputSection == ".iplt":
+		s.Text += size
+	case strings.HasPrefix(outputSection, ".bss"), strings.HasPrefix(outputSection, ".tbss"):
+		s.Bss += size
+	default:
+		s.Data += size
+	}
+}
+
+// objectSize is the size contributed by an object file.
+type objectSize struct {
+	Name string `json:"name"`
+	sectionSizes
+}
+
+// contributorSize is the size contributed by the module itself, a static library, or the linker.
+type contributorSize struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	sectionSizes
+	Objects []objectSize `json:"objects,omitempty"`
+}
+
+// binarySizes is the size attribution of a module variant.
+type binarySizes struct {
+	Module    string `json:"module"`
+	Variant   string `json:"variant"`
+	Partition string `json:"partition"`
+	Lto       bool   `json:"lto"`
+	sectionSizes
+	Contributors []contributorSize `json:"contributors"`
+}
+
+// attributeSizes attributes the sizes of the allocated input sections of a linker map to the
+// module, the static libraries and the object files they come from.
+func attributeSizes(config sizesConfig, sections []inputSection) binarySizes {
+	type contributorKey struct{ name, kind string }
+	owners := make(map[string]contributorKey)
+	for _, obj := range config.Objects {
+		owners[obj] = contributorKey{config.Module, kindModule}
+	}
+	for _, lib := range config.WholeStaticLibs {
+		owners[lib.Archive] = contributorKey{lib.Name, kindStaticLib}
+		for _, obj := range lib.Objects {
+			owners[obj] = contributorKey{lib.Name, kindStaticLib}
+		}
+	}
+
+	contributors := make(map[contributorKey]*contributorSize)
+	objects := make(map[contributorKey]map[string]*sectionSizes)
+	var keys []contributorKey
+
+	sizes := binarySizes{
+		Module:       config.Module,
+		Variant:      config.Variant,
+		Partition:    config.Partition,
+		Lto:          config.Lto,
+		Contributors: []contributorSize{},
+	}
+	for _, s := range sections {
+		if s.Size == 0 || !allocated(s.OutputSection) {
+			continue
+		}
+		key, ok := owners[s.File]
+		object := filepath.Base(s.File)
+		if s.Member != "" {
+			object = s.Member
+		}
+		switch {
+		case ok:
+		case s.Member != "" || strings.HasSuffix(s.File, ".a"):
+			key = contributorKey{strings.TrimSuffix(filepath.Base(s.File), ".a"), kindStaticLib}
+		case strings.HasPrefix(s.File, "<"):
+			// Sections created by the linker, such as "<internal>".
+			key = contributorKey{s.File, kindLinker}
+			object = ""
+		case config.Lto && strings.HasPrefix(filepath.Base(s.File), "lto."):
+			// The output of the LTO backends, e.g. "lto.tmp".
+			key = contributorKey{s.File, kindLto}
+			object = ""
+		default:
+			// Objects such as crtbegin.o that come from neither the module nor a library.
+			key = contributorKey{s.File, kindOther}
+			object = ""
+		}
+
+		c := contributors[key]
+		if c == nil {
+			c = &contributorSize{Name: key.name, Kind: key.kind}
+			contributors[key] = c
+			objects[key] = make(map[string]*sectionSizes)
+			keys = append(keys, key)
+		}
+		c.addSection(s.OutputSection, s.Size)
+		sizes.addSection(s.OutputSection, s.Size)
+		if object != "" {
+			o := objects[key][object]
+			if o == nil {
+				o = &sectionSizes{}
+				objects[key][object] = o
+			}
+			o.addSection(s.OutputSection, s.Size)
+		}
+	}
+
+	for _, key := range keys {
+		c := contributors[key]
+		for name, o := range objects[key] {
+			c.Objects = append(c.Objects, objectSize{Name: name, sectionSizes: *o})
+		}
+		sort.Slice(c.Objects, func(i, j int) bool {
+			return bySize(c.Objects[i].total(), c.Objects[j].total(), c.Objects[i].Name, c.Objects[j].Name)
+		})
+		sizes.Contributors = append(sizes.Contributors, *c)
+	}
+	sort.Slice(sizes.Contributors, func(i, j int) bool {
+		a, b := sizes.Contributors[i], sizes.Contributors[j]
+		return bySize(a.total(), b.total(), a.Kind+":"+a.Name, b.Kind+":"+b.Name)
+	})
+	return sizes
+}
+
+// bySize orders the largest first, then by name.
+func bySize(a, b uint64, aName, bName string) bool {
+	if a != b {
+		return a > b
+	}
+	return aName < bName
+}
+
+// binaryTotal is the size of a module variant in a partition.
+type binaryTotal struct {
+	Module  string `json:"module"`
+	Variant string `json:"variant"`
+	sectionSizes
+}
+
+// contributorTotal is the size a contributor adds to all the binaries of a partition.
+type contributorTotal struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	sectionSizes
+	// Binaries is the number of binaries the contributor adds size to.
+	Binaries int `json:"binaries"`
+}
+
+// partitionSizes is the size attribution rolled up across a partition.
+type partitionSizes struct {
+	Partition string `json:"partition"`
+	sectionSizes
+	Binaries     []binaryTotal      `json:"binaries"`
+	Contributors []contributorTotal `json:"contributors"`
+}
+
+type sizesSummary struct {
+	Partitions []partitionSizes `json:"partitions"`
+}
+
+// mergeSizes rolls up the size attributions of module variants by partition. The module
+// contributors are omitted, as the size of each module is already in the binaries.
+func mergeSizes(reports []binarySizes) sizesSummary {
+	type contributorKey struct{ name, kind string }
+	partitions := make(map[string]*partitionSizes)
+	contributors := make(map[string]map[contributorKey]*contributorTotal)
+	var names []string
+	for _, report := range reports {
+		p := partitions[report.Partition]
+		if p == nil {
+			p = &partitionSizes{
+				Partition:    report.Partition,
+				Binaries:     []binaryTotal{},
+				Contributors: []contributorTotal{},
+			}
+			partitions[report.Partition] = p
+			contributors[report.Partition] = make(map[contributorKey]*contributorTotal)
+			names = append(names, report.Partition)
+		}
+		p.add(report.sectionSizes)
+		p.Binaries = append(p.Binaries, binaryTotal{
+			Module:       report.Module,
+			Variant:      report.Variant,
+			sectionSizes: report.sectionSizes,
+		})
+		for _, c := range report.Contributors {
+			if c.Kind == kindModule || c.Kind == kindLto || c.Kind == kindOther {
+				continue
+			}
+			key := contributorKey{c.Name, c.Kind}
+			total := contributors[report.Partition][key]
+			if total == nil {
+				total = &contributorTotal{Name: c.Name, Kind: c.Kind}
+				contributors[report.Partition][key] = total
+			}
+			total.add(c.sectionSizes)
+			total.Binaries++
+		}
+	}
+
+	sort.Strings(names)
+	summary := sizesSummary{Partitions: []partitionSizes{}}
+	for _, name := range names {
+		p := partitions[name]
+		sort.Slice(p.Binaries, func(i, j int) bool {
+			a, b := p.Binaries[i], p.Binaries[j]
+			return bySize(a.total(), b.total(), a.Module+":"+a.Variant, b.Module+":"+b.Variant)
+		})
+		for _, c := range contributors[name] {
+			p.Contributors = append(p.Contributors, *c)
+		}
+		sort.Slice(p.Contributors, func(i, j int) bool {
+			a, b := p.Contributors[i], p.Contributors[j]
+			return bySize(a.total(), b.total(), a.Kind+":"+a.Name, b.Kind+":"+b.Name)
+		})
+		summary.Partitions = append(summary.Partitions, *p)
+	}
+	return summary
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttributeSizes(t *testing.T) {
+	config := sizesConfig{
+		Module:    "foo",
+		Variant:   "android_arm64_armv8-a",
+		Partition: "system",
+		Objects:   []string{"out/foo/main.o"},
+		WholeStaticLibs: []staticLib{
+			{Name: "libwhole", Archive: "out/libwhole/libwhole.a", Objects: []string{"out/libwhole/whole.o"}},
+		},
+	}
+	sections := []inputSection{
+		{OutputSection: ".text", File: "out/foo/main.o", Name: ".text.main", Size: 0x10},
+		{OutputSection: ".bss", File: "out/foo/main.o", Name: ".bss", Size: 0x8},
+		{OutputSection: ".text", File: "out/libbar/libbar.a", Member: "bar.o", Name: ".text.bar", Size: 0x20},
+		{OutputSection: ".rodata", File: "out/libbar/libbar.a", Member: "baz.o", Name: ".rodata", Size: 0x4},
+		{OutputSection: ".data", File: "out/libwhole/whole.o", Name: ".data", Size: 0x2},
+		{OutputSection: ".dynsym", File: "<internal>", Name: ".dynsym", Size: 0x30},
+		{OutputSection: ".init_array", File: "out/crtbegin_dynamic.o", Name: ".init_array", Size: 0x1},
+		{OutputSection: ".debug_info", File: "out/foo/main.o", Name: ".debug_info", Size: 0x100},
+		{OutputSection: ".text", File: "out/foo/main.o", Name: ".text.empty", Size: 0},
+	}
+
+	want := binarySizes{
+		Module:       "foo",
+		Variant:      "android_arm64_armv8-a",
+		Partition:    "system",
+		sectionSizes: sectionSizes{Text: 0x30, Data: 0x37, Bss: 0x8},
+		Contributors: []contributorSize{
+			{
+				Name:         "<internal>",
+				Kind:         kindLinker,
+				sectionSizes: sectionSizes{Data: 0x30},
+			},
+			{
+				Name:         "libbar",
+				Kind:         kindStaticLib,
+				sectionSizes: sectionSizes{Text: 0x20, Data: 0x4},
+				Objects: []objectSize{
+					{Name: "bar.o", sectionSizes: sectionSizes{Text: 0x20}},
+					{Name: "baz.o", sectionSizes: sectionSizes{Data: 0x4}},
+				},
+			},
+			{
+				Name:         "foo",
+				Kind:         kindModule,
+				sectionSizes: sectionSizes{Text: 0x10, Bss: 0x8},
+				Objects:      []objectSize{{Name: "main.o", sectionSizes: sectionSizes{Text: 0x10, Bss: 0x8}}},
+			},
+			{
+				Name:         "libwhole",
+				Kind:         kindStaticLib,
+				sectionSizes: sectionSizes{Data: 0x2},
+				Objects:      []objectSize{{Name: "whole.o", sectionSizes: sectionSizes{Data: 0x2}}},
+			},
+			{
+				Name:         "out/crtbegin_dynamic.o",
+				Kind:         kindOther,
+				sectionSizes: sectionSizes{Data: 0x1},
+			},
+		},
+	}
+	if got := attributeSizes(config, sections); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
+
+func TestMergeSizes(t *testing.T) {
+	reports := []binarySizes{
+		{
+			Module:       "foo",
+			Variant:      "arm64",
+			Partition:    "system",
+			sectionSizes: sectionSizes{Text: 10, Data: 2},
+			Contributors: []contributorSize{
+				{Name: "foo", Kind: kindModule, sectionSizes: sectionSizes{Text: 4}},
+				{Name: "libbar", Kind: kindStaticLib, sectionSizes: sectionSizes{Text: 6, Data: 2}},
+			},
+		},
+		{
+			Module:       "baz",
+			Variant:      "arm64",
+			Partition:    "system",
+			sectionSizes: sectionSizes{Text: 20},
+			Contributors: []contributorSize{
+				{Name: "libbar", Kind: kindStaticLib, sectionSizes: sectionSizes{Text: 6}},
+				{Name: "baz", Kind: kindModule, sectionSizes: sectionSizes{Text: 14}},
+			},
+		},
+		{
+			Module:       "qux",
+			Variant:      "arm64",
+			Partition:    "vendor",
+			sectionSizes: sectionSizes{Bss: 1},
+			Contributors: []contributorSize{
+				{Name: "qux", Kind: kindModule, sectionSizes: sectionSizes{Bss: 1}},
+			},
+		},
+	}
+	want := sizesSummary{
+		Partitions: []partitionSizes{
+			{
+				Partition:    "system",
+				sectionSizes: sectionSizes{Text: 30, Data: 2},
+				Binaries: []binaryTotal{
+					{Module: "baz", Variant: "arm64", sectionSizes: sectionSizes{Text: 20}},
+					{Module: "foo", Variant: "arm64", sectionSizes: sectionSizes{Text: 10, Data: 2}},
+				},
+				Contributors: []contributorTotal{
+					{Name: "libbar", Kind: kindStaticLib, sectionSizes: sectionSizes{Text: 12, Data: 2}, Binaries: 2},
+				},
+			},
+			{
+				Partition:    "vendor",
+				sectionSizes: sectionSizes{Bss: 1},
+				Binaries: []binaryTotal{
+					{Module: "qux", Variant: "arm64", sectionSizes: sectionSizes{Bss: 1}},
+				},
+				Contributors: []contributorTotal{},
+			},
+		},
+	}
+	if got := mergeSizes(reports); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
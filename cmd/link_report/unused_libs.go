@@ -0,0 +1,340 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// unusedLibsConfig describes the link of a module variant, written by Soong.
+type unusedLibsConfig struct {
+	Module    string `json:"module"`
+	Variant   string `json:"variant"`
+	Blueprint string `json:"blueprint"`
+
+	// Output is the file written by the linker.
+	Output string `json:"output"`
+	// Map is the linker map written with -Map.
+	Map string `json:"map"`
+	// WhyExtract is the file written with --why-extract.
+	WhyExtract string `json:"why_extract"`
+	// Lto is true if the objects were LTO bitcode, so the linker map attributes their code to
+	// the LTO output instead.
+	Lto bool `json:"lto"`
+
+	SharedLibs      []sharedLib `json:"shared_libs"`
+	StaticLibs      []staticLib `json:"static_libs"`
+	WholeStaticLibs []staticLib `json:"whole_static_libs"`
+}
+
+type sharedLib struct {
+	Name string `json:"name"`
+	// Toc is the table of contents file that lists the dynamic symbols of the library.
+	Toc string `json:"toc"`
+}
+
+type staticLib struct {
+	Name    string `json:"name"`
+	Archive string `json:"archive"`
+	// Objects are the object files of a whole static library that the linker got instead of the
+	// archive.
+	Objects []string `json:"objects,omitempty"`
+}
+
+const (
+	libUsed    = "used"
+	libUnused  = "unused"
+	libUnknown = "unknown"
+)
+
+// libUsage is whether a library listed in a property is used by the link.
+type libUsage struct {
+	Name     string `json:"name"`
+	Property string `json:"property"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// variantReport is the usage of the libraries of a module variant.
+type variantReport struct {
+	Module    string     `json:"module"`
+	Variant   string     `json:"variant"`
+	Blueprint string     `json:"blueprint"`
+	Libs      []libUsage `json:"libs"`
+}
+
+// linkInputs are what the linker used from its inputs.
+type linkInputs struct {
+	// undefinedSymbols are the symbols that the output needs from shared libraries.
+	undefinedSymbols map[string]bool
+	// extractedArchives are the archives that the linker extracted members from.
+	extractedArchives map[string]bool
+	// usedFiles are the input files and archives that have sections in the output.
+	usedFiles map[string]bool
+}
+
+// checkUnusedLibs returns the usage of each library in the config.
+func checkUnusedLibs(config unusedLibsConfig, inputs linkInputs, readToc func(string) (map[string]bool, error)) (variantReport, error) {
+	report := variantReport{
+		Module:    config.Module,
+		Variant:   config.Variant,
+		Blueprint: config.Blueprint,
+		Libs:      []libUsage{},
+	}
+	status := func(used bool) string {
+		if used {
+			return libUsed
+		}
+		return libUnused
+	}
+
+	for _, lib := range config.SharedLibs {
+		symbols, err := readToc(lib.Toc)
+		if err != nil {
+			return variantReport{}, err
+		}
+		used := false
+		for symbol := range symbols {
+			if inputs.undefinedSymbols[symbol] {
+				used = true
+				break
+			}
+		}
+		report.Libs = append(report.Libs, libUsage{Name: lib.Name, Property: "shared_libs", Status: status(used)})
+	}
+
+	for _, lib := range config.StaticLibs {
+		used := inputs.extractedArchives[lib.Archive]
+		report.Libs = append(report.Libs, libUsage{Name: lib.Name, Property: "static_libs", Status: status(used)})
+	}
+
+	for _, lib := range config.WholeStaticLibs {
+		usage := libUsage{Name: lib.Name, Property: "whole_static_libs"}
+		if config.Lto {
+			usage.Status = libUnknown
+			usage.Reason = "the linker map does not show which LTO objects are used"
+		} else {
+			used := inputs.usedFiles[lib.Archive]
+			for _, obj := range lib.Objects {
+				used = used || inputs.usedFiles[obj]
+			}
+			usage.Status = status(used)
+		}
+		report.Libs = append(report.Libs, usage)
+	}
+	return report, nil
+}
+
+// readLinkInputs reads what the linker used from the output, the linker map and the
+// --why-extract file.
+func readLinkInputs(config unusedLibsConfig) (linkInputs, error) {
+	inputs := linkInputs{
+		undefinedSymbols:  make(map[string]bool),
+		extractedArchives: make(map[string]bool),
+		usedFiles:         make(map[string]bool),
+	}
+
+	if len(config.SharedLibs) > 0 {
+		symbols, err := undefinedDynamicSymbols(config.Output)
+		if err != nil {
+			return linkInputs{}, err
+		}
+		for _, s := range symbols {
+			inputs.undefinedSymbols[s] = true
+		}
+	}
+
+	if len(config.StaticLibs) > 0 {
+		f, err := os.Open(config.WhyExtract)
+		if err != nil {
+			return linkInputs{}, err
+		}
+		archives, err := parseWhyExtract(f)
+		f.Close()
+		if err != nil {
+			return linkInputs{}, err
+		}
+		inputs.extractedArchives = archives
+	}
+
+	if len(config.WholeStaticLibs) > 0 && !config.Lto {
+		f, err := os.Open(config.Map)
+		if err != nil {
+			return linkInputs{}, err
+		}
+		sections, err := parseLinkerMap(f)
+		f.Close()
+		if err != nil {
+			return linkInputs{}, err
+		}
+		for _, s := range sections {
+			if s.Size > 0 && allocated(s.OutputSection) {
+				inputs.usedFiles[s.File] = true
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// undefinedDynamicSymbols returns the undefined dynamic symbols of an ELF file, which are the
+// symbols it needs from shared libraries.
+func undefinedDynamicSymbols(file string) ([]string, error) {
+	f, err := elf.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	syms, err := f.DynamicSymbols()
+	if err == elf.ErrNoSymbols {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var undefined []string
+	for _, sym := range syms {
+		if sym.Section == elf.SHN_UNDEF && sym.Name != "" {
+			undefined = append(undefined, sym.Name)
+		}
+	}
+	return undefined, nil
+}
+
+// parseWhyExtract returns the archives that members were extracted from, from the file written by
+// lld --why-extract. Each line after the header is the reference that caused the extraction, the
+// extracted archive member and the symbol.
+func parseWhyExtract(r io.Reader) (map[string]bool, error) {
+	archives := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 3 || fields[0] == "reference" {
+			continue
+		}
+		if section, ok := parseInputSection(fields[1] + ":()"); ok && section.Member != "" {
+			archives[section.File] = true
+		}
+	}
+	return archives, scanner.Err()
+}
+
+// parseToc returns the symbols defined by a shared library, from its table of contents written by
+// toc.sh. For ELF files, it has the output of llvm-readelf --dyn-syms without the value and size
+// columns.
+func parseToc(r io.Reader) (map[string]bool, error) {
+	symbols := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		// e.g. "1: FUNC GLOBAL DEFAULT 12 foo@@LIBFOO"
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || !strings.HasSuffix(fields[0], ":") || fields[0] == "Num:" {
+			continue
+		}
+		if fields[4] == "UND" || fields[2] == "LOCAL" {
+			continue
+		}
+		name := fields[5]
+		if i := strings.Index(name, "@"); i >= 0 {
+			name = name[:i]
+		}
+		symbols[name] = true
+	}
+	return symbols, scanner.Err()
+}
+
+func readTocFile(file string) (map[string]bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseToc(f)
+}
+
+// unusedLib is a library that a module does not use in any of its variants.
+type unusedLib struct {
+	Name     string `json:"name"`
+	Property string `json:"property"`
+}
+
+// moduleReport lists the unused libraries of a module.
+type moduleReport struct {
+	Module    string      `json:"module"`
+	Blueprint string      `json:"blueprint"`
+	Unused    []unusedLib `json:"unused"`
+}
+
+// unusedLibsSummary is the merged report of all modules, the input of bpfix --unused_libs.
+type unusedLibsSummary struct {
+	Modules []moduleReport `json:"modules"`
+}
+
+// mergeUnusedLibs merges the reports of the variants of each module. A library is unused by a
+// module if it is unused by all the variants that link it.
+func mergeUnusedLibs(reports []variantReport) unusedLibsSummary {
+	type moduleKey struct{ module, blueprint string }
+	statuses := make(map[moduleKey]map[unusedLib]string)
+	var modules []moduleKey
+	for _, report := range reports {
+		key := moduleKey{report.Module, report.Blueprint}
+		if statuses[key] == nil {
+			statuses[key] = make(map[unusedLib]string)
+			modules = append(modules, key)
+		}
+		for _, lib := range report.Libs {
+			libKey := unusedLib{lib.Name, lib.Property}
+			if prev, ok := statuses[key][libKey]; !ok || prev == libUnused {
+				statuses[key][libKey] = lib.Status
+			}
+		}
+	}
+
+	sort.Slice(modules, func(i, j int) bool {
+		if modules[i].blueprint != modules[j].blueprint {
+			return modules[i].blueprint < modules[j].blueprint
+		}
+		return modules[i].module < modules[j].module
+	})
+	summary := unusedLibsSummary{Modules: []moduleReport{}}
+	for _, key := range modules {
+		var unused []unusedLib
+		for lib, status := range statuses[key] {
+			if status == libUnused {
+				unused = append(unused, lib)
+			}
+		}
+		if len(unused) == 0 {
+			continue
+		}
+		sort.Slice(unused, func(i, j int) bool {
+			if unused[i].Property != unused[j].Property {
+				return unused[i].Property < unused[j].Property
+			}
+			return unused[i].Name < unused[j].Name
+		})
+		summary.Modules = append(summary.Modules, moduleReport{
+			Module:    key.module,
+			Blueprint: key.blueprint,
+			Unused:    unused,
+		})
+	}
+	return summary
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseToc(t *testing.T) {
+	toc := `Library soname: [libfoo.so]
+Symbol table '.dynsym' contains 4 entries:
+Num:   Type Bind Vis Ndx Name
+0:   NOTYPE LOCAL DEFAULT UND
+1:   FUNC GLOBAL DEFAULT UND __cxa_finalize@LIBC (2)
+2:   FUNC GLOBAL DEFAULT 12 foo@@LIBFOO
+3:   OBJECT WEAK DEFAULT 20 bar
+`
+	symbols, err := parseToc(strings.NewReader(toc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]bool{"foo": true, "bar": true}; !reflect.DeepEqual(symbols, want) {
+		t.Errorf("want %v, got %v", want, symbols)
+	}
+}
+
+func TestParseWhyExtract(t *testing.T) {
+	whyExtract := "reference\textracted\tsymbol\n" +
+		"out/obj/main.o\tout/libfoo.a(foo.o)\tfoo()\n" +
+		"out/libfoo.a(foo.o)\tout/libbar.a(bar.o)\tbar\n" +
+		"--undefined\tout/libqux.a(qux.o)\tqux\n"
+	archives, err := parseWhyExtract(strings.NewReader(whyExtract))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"out/libfoo.a": true, "out/libbar.a": true, "out/libqux.a": true}
+	if !reflect.DeepEqual(archives, want) {
+		t.Errorf("want %v, got %v", want, archives)
+	}
+}
+
+func TestCheckUnusedLibs(t *testing.T) {
+	config := unusedLibsConfig{
+		Module:    "foo",
+		Variant:   "android_arm64_armv8-a",
+		Blueprint: "foo/Android.bp",
+		SharedLibs: []sharedLib{
+			{Name: "libused", Toc: "libused.so.toc"},
+			{Name: "libunused", Toc: "libunused.so.toc"},
+		},
+		StaticLibs: []staticLib{
+			{Name: "libstatic", Archive: "libstatic.a"},
+			{Name: "libstatic_unused", Archive: "libstatic_unused.a"},
+		},
+		WholeStaticLibs: []staticLib{
+			{Name: "libwhole", Archive: "libwhole.a", Objects: []string{"whole1.o", "whole2.o"}},
+			{Name: "libwhole_unused", Archive: "libwhole_unused.a", Objects: []string{"whole3.o"}},
+			{Name: "libwhole_prebuilt", Archive: "libwhole_prebuilt.a"},
+		},
+	}
+	inputs := linkInputs{
+		undefinedSymbols:  map[string]bool{"used": true},
+		extractedArchives: map[string]bool{"libstatic.a": true},
+		usedFiles:         map[string]bool{"whole2.o": true, "libwhole_prebuilt.a": true},
+	}
+	tocs := map[string]map[string]bool{
+		"libused.so.toc":   {"other": true, "used": true},
+		"libunused.so.toc": {"other": true},
+	}
+	readToc := func(file string) (map[string]bool, error) {
+		if toc, ok := tocs[file]; ok {
+			return toc, nil
+		}
+		return nil, fmt.Errorf("no such file %s", file)
+	}
+
+	report, err := checkUnusedLibs(config, inputs, readToc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []libUsage{
+		{Name: "libused", Property: "shared_libs", Status: libUsed},
+		{Name: "libunused", Property: "shared_libs", Status: libUnused},
+		{Name: "libstatic", Property: "static_libs", Status: libUsed},
+		{Name: "libstatic_unused", Property: "static_libs", Status: libUnused},
+		{Name: "libwhole", Property: "whole_static_libs", Status: libUsed},
+		{Name: "libwhole_unused", Property: "whole_static_libs", Status: libUnused},
+		{Name: "libwhole_prebuilt", Property: "whole_static_libs", Status: libUsed},
+	}
+	if !reflect.DeepEqual(report.Libs, want) {
+		t.Errorf("want %#v\ngot  %#v", want, report.Libs)
+	}
+
+	config.Lto = true
+	report, err = checkUnusedLibs(config, inputs, readToc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lib := range report.Libs {
+		if lib.Property == "whole_static_libs" && lib.Status != libUnknown {
+			t.Errorf("with LTO, want %s to be unknown, got %s", lib.Name, lib.Status)
+		}
+	}
+}
+
+func TestMergeUnusedLibs(t *testing.T) {
+	reports := []variantReport{
+		{
+			Module:    "foo",
+			Variant:   "arm64",
+			Blueprint: "foo/Android.bp",
+			Libs: []libUsage{
+				{Name: "libbar", Property: "shared_libs", Status: libUnused},
+				{Name: "libbaz", Property: "shared_libs", Status: libUnused},
+				{Name: "libqux", Property: "whole_static_libs", Status: libUnknown},
+			},
+		},
+		{
+			Module:    "foo",
+			Variant:   "arm",
+			Blueprint: "foo/Android.bp",
+			Libs: []libUsage{
+				{Name: "libbar", Property: "shared_libs", Status: libUsed},
+				{Name: "libbaz", Property: "shared_libs", Status: libUnused},
+				{Name: "libqux", Property: "whole_static_libs", Status: libUnused},
+				{Name: "libarm", Property: "static_libs", Status: libUnused},
+			},
+		},
+		{
+			Module:    "all_used",
+			Variant:   "arm64",
+			Blueprint: "foo/Android.bp",
+			Libs:      []libUsage{{Name: "libbar", Property: "shared_libs", Status: libUsed}},
+		},
+	}
+	want := unusedLibsSummary{
+		Modules: []moduleReport{
+			{
+				Module:    "foo",
+				Blueprint: "foo/Android.bp",
+				Unused: []unusedLib{
+					{Name: "libbaz", Property: "shared_libs"},
+					{Name: "libarm", Property: "static_libs"},
+				},
+			},
+		},
+	}
+	if got := mergeUnusedLibs(reports); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %#v\ngot  %#v", want, got)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"errors"
+	"strings"
+)
+
+// exportedSymbols returns the symbols defined and exported by a native library.
+func exportedSymbols(data []byte) (map[string]bool, error) {
+	ef, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer ef.Close()
+
+	syms, err := ef.DynamicSymbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, err
+	}
+	ret := make(map[string]bool)
+	for _, s := range syms {
+		if s.Name == "" || s.Section == elf.SHN_UNDEF {
+			continue
+		}
+		bind := elf.ST_BIND(s.Info)
+		vis := elf.ST_VISIBILITY(s.Other)
+		// STB_LOOS is STB_GNU_UNIQUE
+		if (bind == elf.STB_GLOBAL || bind == elf.STB_WEAK || bind == elf.STB_LOOS) &&
+			(vis == elf.STV_DEFAULT || vis == elf.STV_PROTECTED) {
+			ret[s.Name] = true
+		}
+	}
+	return ret, nil
+}
+
+// apiSignature is the contents of an API signature file generated by metalava.
+type apiSignature struct {
+	// Declarations of the classes, keyed by the qualified name of the class
+	classes map[string]string
+
+	// Members of the classes, as "<qualified class name>: <member>"
+	members map[string]bool
+}
+
+// readApiSignature parses an API signature file. Each line of a signature file is either a
+// package, a class declaration, a member of the class, or the closing brace of one of these.
+func readApiSignature(data []byte) *apiSignature {
+	api := &apiSignature{classes: make(map[string]string), members: make(map[string]bool)}
+	var pkg, class string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		// Constant values are followed by a comment with their hex value.
+		if i := strings.Index(line, "; //"); i >= 0 {
+			line = line[:i+1]
+		}
+		switch {
+		case line == "" || strings.HasPrefix(line, "//"):
+		case strings.HasPrefix(line, "package ") && strings.HasSuffix(line, "{"):
+			pkg = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "package "), "{"))
+		case line == "}":
+			if class != "" {
+				class = ""
+			} else {
+				pkg = ""
+			}
+		case strings.HasSuffix(line, "{"):
+			decl := strings.TrimSpace(strings.TrimSuffix(line, "{"))
+			class = pkg + "." + className(decl)
+			api.classes[class] = decl
+		default:
+			api.members[class+": "+strings.TrimSuffix(line, ";")] = true
+		}
+	}
+	return api
+}
+
+// className returns the name of the class declared by a class declaration of a signature file,
+// e.g. Foo.Bar for "public static class Foo.Bar<T> extends Baz".
+func className(decl string) string {
+	fields := strings.Fields(decl)
+	for i, f := range fields {
+		if (f == "class" || f == "interface" || f == "enum" || f == "@interface") && i+1 < len(fields) {
+			name, _, _ := strings.Cut(fields[i+1], "<")
+			return name
+		}
+	}
+	return decl
+}
@@ -0,0 +1,78 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadApiSignature(t *testing.T) {
+	api := readApiSignature([]byte(`// Signature format: 2.0
+package android.foo {
+
+  public class Foo {
+    ctor public Foo();
+    method public void bar(int);
+    field public static final int X = 1; // 0x1
+  }
+
+  public static interface Foo.Listener<T> {
+    method public void onFoo(T);
+  }
+
+}
+
+package android.foo.bar {
+
+  public final class Bar extends android.foo.Foo {
+  }
+
+}
+`))
+
+	wantClasses := map[string]string{
+		"android.foo.Foo":          "public class Foo",
+		"android.foo.Foo.Listener": "public static interface Foo.Listener<T>",
+		"android.foo.bar.Bar":      "public final class Bar extends android.foo.Foo",
+	}
+	wantMembers := map[string]bool{
+		"android.foo.Foo: ctor public Foo()":                    true,
+		"android.foo.Foo: method public void bar(int)":          true,
+		"android.foo.Foo: field public static final int X = 1":  true,
+		"android.foo.Foo.Listener: method public void onFoo(T)": true,
+	}
+	if !reflect.DeepEqual(api.classes, wantClasses) {
+		t.Errorf("expected classes %q, got %q", wantClasses, api.classes)
+	}
+	if !reflect.DeepEqual(api.members, wantMembers) {
+		t.Errorf("expected members %v, got %v", wantMembers, api.members)
+	}
+}
+
+func TestClassName(t *testing.T) {
+	testCases := map[string]string{
+		"public class Foo":                           "Foo",
+		"public static class Foo.Bar<T> extends Baz": "Foo.Bar",
+		"public enum E":                              "E",
+		"public @interface A":                        "A",
+		"public abstract interface I implements J":   "I",
+	}
+	for decl, want := range testCases {
+		if got := className(decl); got != want {
+			t.Errorf("className(%q): expected %q, got %q", decl, want, got)
+		}
+	}
+}
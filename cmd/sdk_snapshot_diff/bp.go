@@ -0,0 +1,90 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/blueprint/parser"
+)
+
+// parseBp returns the modules with a name in the Android.bp file of a snapshot, sorted by name.
+// Modules without a name, like the package module, are not members of the snapshot.
+func parseBp(filename string, data []byte) ([]*member, error) {
+	file, errs := parser.ParseAndEval(filename, bytes.NewReader(data), parser.NewScope(nil))
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var members []*member
+	for _, def := range file.Defs {
+		module, ok := def.(*parser.Module)
+		if !ok {
+			continue
+		}
+		props, err := mapValue(&module.Map)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", module.Type, err)
+		}
+		name, _ := props["name"].(string)
+		if name == "" {
+			continue
+		}
+		delete(props, "name")
+		members = append(members, &member{Type: module.Type, Name: name, Props: props})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
+
+func mapValue(m *parser.Map) (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+	for _, prop := range m.Properties {
+		v, err := value(prop.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", prop.Name, err)
+		}
+		ret[prop.Name] = v
+	}
+	return ret, nil
+}
+
+func value(e parser.Expression) (interface{}, error) {
+	switch v := e.Eval().(type) {
+	case *parser.String:
+		return v.Value, nil
+	case *parser.Bool:
+		return v.Value, nil
+	case *parser.Int64:
+		return v.Value, nil
+	case *parser.List:
+		ret := make([]interface{}, 0, len(v.Values))
+		for _, e := range v.Values {
+			ev, err := value(e)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, ev)
+		}
+		return ret, nil
+	case *parser.Map:
+		return mapValue(v)
+	default:
+		return nil, fmt.Errorf("unsupported value %s", e)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBp(t *testing.T) {
+	members, err := parseBp("Android.bp", []byte(`
+// This is auto-generated. DO NOT EDIT.
+
+package {
+    default_applicable_licenses: ["Android-Apache-2.0"],
+}
+
+java_import {
+    name: "myjavalib",
+    prefer: false,
+    jars: ["java/myjavalib.jar"],
+}
+
+cc_prebuilt_library_shared {
+    name: "mynativelib",
+    prefer: false,
+    stl: "none",
+    compile_multilib: "both",
+    stubs: {
+        versions: ["1"],
+    },
+    arch: {
+        arm64: {
+            srcs: ["arm64/lib/mynativelib.so"],
+        },
+    },
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*member{
+		{Type: "java_import", Name: "myjavalib", Props: map[string]interface{}{
+			"prefer": false,
+			"jars":   []interface{}{"java/myjavalib.jar"},
+		}},
+		{Type: "cc_prebuilt_library_shared", Name: "mynativelib", Props: map[string]interface{}{
+			"prefer":           false,
+			"stl":              "none",
+			"compile_multilib": "both",
+			"stubs": map[string]interface{}{
+				"versions": []interface{}{"1"},
+			},
+			"arch": map[string]interface{}{
+				"arm64": map[string]interface{}{
+					"srcs": []interface{}{"arm64/lib/mynativelib.so"},
+				},
+			},
+		}},
+	}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("expected %v, got %v", want, members)
+	}
+}
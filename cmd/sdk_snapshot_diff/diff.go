@@ -0,0 +1,396 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// change is a difference between two snapshots.
+type change struct {
+	Member string `json:"member"`
+
+	// One of "member", "property", "abi", "api" or "unchecked"
+	Kind string `json:"kind"`
+
+	Description string `json:"description"`
+
+	// Whether users of the old snapshot may fail to build or run against the new snapshot
+	Breaking bool `json:"breaking"`
+}
+
+// diffSnapshots returns the changes between two snapshots, grouped by member.
+func diffSnapshots(oldS, newS *snapshot) ([]change, error) {
+	oldMembers := make(map[string]*member)
+	for _, m := range oldS.members {
+		oldMembers[m.Name] = m
+	}
+	newMembers := make(map[string]*member)
+	for _, m := range newS.members {
+		newMembers[m.Name] = m
+	}
+
+	var names []string
+	for name := range oldMembers {
+		names = append(names, name)
+	}
+	for name := range newMembers {
+		if _, ok := oldMembers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []change
+	for _, name := range names {
+		o, n := oldMembers[name], newMembers[name]
+		switch {
+		case n == nil:
+			changes = append(changes, change{name, "member", fmt.Sprintf("%s removed", o.Type), true})
+		case o == nil:
+			changes = append(changes, change{name, "member", fmt.Sprintf("%s added", n.Type), false})
+		default:
+			if o.Type != n.Type {
+				changes = append(changes, change{name, "member",
+					fmt.Sprintf("module type changed from %s to %s", o.Type, n.Type), true})
+			}
+			changes = append(changes, diffProperties(name, o.Props, n.Props)...)
+
+			oldLibs, oldApis, oldJars := o.files()
+			newLibs, newApis, newJars := n.files()
+			abi, err := diffFiles(name, "abi", oldLibs, newLibs, oldS.files, newS.files, diffSymbols)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, abi...)
+
+			switch {
+			case len(oldApis) > 0 || len(newApis) > 0:
+				api, err := diffFiles(name, "api", oldApis, newApis, oldS.files, newS.files, diffApis)
+				if err != nil {
+					return nil, err
+				}
+				changes = append(changes, api...)
+			case len(oldJars) > 0 || len(newJars) > 0:
+				// Members without an API signature file, e.g. java_header_libs, are compared by
+				// the classes in their jars.
+				api, err := diffFiles(name, "api", oldJars, newJars, oldS.files, newS.files, diffJars)
+				if err != nil {
+					return nil, err
+				}
+				changes = append(changes, api...)
+			case strings.HasPrefix(n.Type, "java_"):
+				// e.g. java_boot_libs and java_systemserver_libs, whose jars aren't in the
+				// snapshot.
+				changes = append(changes, change{name, "unchecked",
+					"API not compared, the snapshot has neither an API signature file nor a jar", false})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// flattenProperties returns the leaf properties of a property map, keyed by their dotted name,
+// e.g. "arch.arm64.srcs".
+func flattenProperties(prefix string, props map[string]interface{}, ret map[string]interface{}) {
+	for k, v := range props {
+		if m, ok := v.(map[string]interface{}); ok {
+			flattenProperties(prefix+k+".", m, ret)
+		} else {
+			ret[prefix+k] = v
+		}
+	}
+}
+
+func valueString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		var s []string
+		for _, e := range v {
+			s = append(s, valueString(e))
+		}
+		return "[" + strings.Join(s, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// listDiff returns the values of the old list that aren't in the new list and vice versa.
+func listDiff(oldL, newL []interface{}) (removed, added []string) {
+	oldSet := make(map[string]bool)
+	for _, v := range oldL {
+		oldSet[valueString(v)] = true
+	}
+	newSet := make(map[string]bool)
+	for _, v := range newL {
+		newSet[valueString(v)] = true
+		if !oldSet[valueString(v)] {
+			added = append(added, valueString(v))
+		}
+	}
+	for _, v := range oldL {
+		if !newSet[valueString(v)] {
+			removed = append(removed, valueString(v))
+		}
+	}
+	return removed, added
+}
+
+// propertyBreaking returns true if changing the property from the old value to the new value
+// may break the users of the member. A nil value means that the property isn't set.
+//
+//   - Removing values from apex_available, visibility or stubs.versions makes the member
+//     unavailable to some of its users.
+//   - Increasing min_sdk_version makes the member unavailable to users that support older
+//     releases.
+//   - Setting enabled to false, e.g. for a target, makes the member unavailable on it.
+//
+// Other properties are considered compatible, the changes to what the member exports are
+// reported separately from the symbols and the API signature files.
+func propertyBreaking(name string, oldV, newV interface{}) bool {
+	leaf := name[strings.LastIndex(name, ".")+1:]
+	switch leaf {
+	case "apex_available", "visibility", "versions":
+		oldL, _ := oldV.([]interface{})
+		newL, _ := newV.([]interface{})
+		removed, _ := listDiff(oldL, newL)
+		return len(removed) > 0
+	case "min_sdk_version":
+		if newV == nil {
+			return false
+		}
+		if oldV == nil {
+			return true
+		}
+		o, oErr := strconv.Atoi(fmt.Sprint(oldV))
+		n, nErr := strconv.Atoi(fmt.Sprint(newV))
+		if oErr != nil || nErr != nil {
+			// Codenames can't be ordered, assume the worst.
+			return fmt.Sprint(oldV) != fmt.Sprint(newV)
+		}
+		return n > o
+	case "enabled":
+		return newV == false && oldV != false
+	}
+	return false
+}
+
+// diffProperties returns the changes to the properties of a member.
+func diffProperties(name string, oldProps, newProps map[string]interface{}) []change {
+	oldFlat := make(map[string]interface{})
+	flattenProperties("", oldProps, oldFlat)
+	newFlat := make(map[string]interface{})
+	flattenProperties("", newProps, newFlat)
+
+	var keys []string
+	for k := range oldFlat {
+		keys = append(keys, k)
+	}
+	for k := range newFlat {
+		if _, ok := oldFlat[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []change
+	for _, k := range keys {
+		o, oOk := oldFlat[k]
+		n, nOk := newFlat[k]
+		var desc string
+		switch {
+		case !nOk:
+			desc = fmt.Sprintf("%s removed, was %s", k, valueString(o))
+		case !oOk:
+			desc = fmt.Sprintf("%s added: %s", k, valueString(n))
+		case valueString(o) == valueString(n):
+			continue
+		default:
+			oldL, oIsList := o.([]interface{})
+			newL, nIsList := n.([]interface{})
+			if oIsList && nIsList {
+				removed, added := listDiff(oldL, newL)
+				var parts []string
+				if len(removed) > 0 {
+					parts = append(parts, "removed "+strings.Join(removed, ", "))
+				}
+				if len(added) > 0 {
+					parts = append(parts, "added "+strings.Join(added, ", "))
+				}
+				if len(parts) == 0 {
+					parts = append(parts, "reordered")
+				}
+				desc = fmt.Sprintf("%s: %s", k, strings.Join(parts, "; "))
+			} else {
+				desc = fmt.Sprintf("%s changed from %s to %s", k, valueString(o), valueString(n))
+			}
+		}
+		changes = append(changes, change{name, "property", desc, propertyBreaking(k, o, n)})
+	}
+	return changes
+}
+
+// diffFiles compares the files of a member in the old and new snapshots with diffFn. Files that
+// are only in the old snapshot are breaking changes.
+func diffFiles(name, kind string, oldPaths, newPaths []string, oldFiles, newFiles map[string][]byte,
+	diffFn func(name, path string, oldData, newData []byte) ([]change, error)) ([]change, error) {
+
+	var changes []change
+	for _, path := range oldPaths {
+		if !contains(newPaths, path) {
+			changes = append(changes, change{name, kind, path + " removed", true})
+			continue
+		}
+		c, err := diffFn(name, path, oldFiles[path], newFiles[path])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		changes = append(changes, c...)
+	}
+	for _, path := range newPaths {
+		if !contains(oldPaths, path) {
+			changes = append(changes, change{name, kind, path + " added", false})
+		}
+	}
+	return changes, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// diffSymbols compares the exported symbols of two versions of a native library. Removing a
+// symbol is a breaking change.
+func diffSymbols(name, path string, oldData, newData []byte) ([]change, error) {
+	oldSyms, err := exportedSymbols(oldData)
+	if err != nil {
+		return nil, err
+	}
+	newSyms, err := exportedSymbols(newData)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	for _, sym := range sortedKeys(oldSyms) {
+		if !newSyms[sym] {
+			changes = append(changes, change{name, "abi", fmt.Sprintf("%s: symbol %s removed", path, sym), true})
+		}
+	}
+	for _, sym := range sortedKeys(newSyms) {
+		if !oldSyms[sym] {
+			changes = append(changes, change{name, "abi", fmt.Sprintf("%s: symbol %s added", path, sym), false})
+		}
+	}
+	return changes, nil
+}
+
+// diffApis compares two versions of an API signature file. Removing a class or a member, or
+// changing the declaration of a class, is a breaking change.
+func diffApis(name, path string, oldData, newData []byte) ([]change, error) {
+	oldApi := readApiSignature(oldData)
+	newApi := readApiSignature(newData)
+
+	var changes []change
+	for _, class := range sortedKeys(oldApi.classes) {
+		oldDecl := oldApi.classes[class]
+		newDecl, ok := newApi.classes[class]
+		if !ok {
+			changes = append(changes, change{name, "api", fmt.Sprintf("%s: class %s removed", path, class), true})
+		} else if oldDecl != newDecl {
+			changes = append(changes, change{name, "api",
+				fmt.Sprintf("%s: class %s changed from %q to %q", path, class, oldDecl, newDecl), true})
+		}
+	}
+	for _, class := range sortedKeys(newApi.classes) {
+		if _, ok := oldApi.classes[class]; !ok {
+			changes = append(changes, change{name, "api", fmt.Sprintf("%s: class %s added", path, class), false})
+		}
+	}
+
+	for _, m := range sortedKeys(oldApi.members) {
+		if !newApi.members[m] {
+			class, _, _ := strings.Cut(m, ": ")
+			// The removal of the class has already been reported.
+			if _, ok := newApi.classes[class]; ok {
+				changes = append(changes, change{name, "api", fmt.Sprintf("%s: removed %s", path, m), true})
+			}
+		}
+	}
+	for _, m := range sortedKeys(newApi.members) {
+		if !oldApi.members[m] {
+			class, _, _ := strings.Cut(m, ": ")
+			// The members of new classes are part of the addition of the class.
+			if _, ok := oldApi.classes[class]; ok {
+				changes = append(changes, change{name, "api", fmt.Sprintf("%s: added %s", path, m), false})
+			}
+		}
+	}
+	return changes, nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// printChanges writes the breaking changes followed by the compatible changes, and the members
+// whose API could not be compared.
+func printChanges(w io.Writer, changes []change) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes")
+		return
+	}
+	sections := 0
+	for _, section := range []struct {
+		title string
+		match func(c change) bool
+	}{
+		{"Breaking changes:", func(c change) bool { return c.Breaking }},
+		{"Compatible changes:", func(c change) bool { return !c.Breaking && c.Kind != "unchecked" }},
+		{"Not checked:", func(c change) bool { return c.Kind == "unchecked" }},
+	} {
+		printed := false
+		for _, c := range changes {
+			if !section.match(c) {
+				continue
+			}
+			if !printed {
+				if sections > 0 {
+					fmt.Fprintln(w)
+				}
+				fmt.Fprintln(w, section.title)
+				printed = true
+				sections++
+			}
+			fmt.Fprintf(w, "  %s: %s\n", c.Member, c.Description)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func list(values ...interface{}) []interface{} {
+	return values
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	oldS := &snapshot{
+		members: []*member{
+			{Type: "java_sdk_library_import", Name: "myjavalib", Props: map[string]interface{}{
+				"apex_available": list("com.android.foo", "com.android.bar"),
+				"public": map[string]interface{}{
+					"jars":        list("sdk_library/public/myjavalib-stubs.jar"),
+					"current_api": "sdk_library/public/myjavalib.txt",
+				},
+			}},
+			{Type: "java_import", Name: "oldlib", Props: map[string]interface{}{}},
+			{Type: "java_import", Name: "bootlib", Props: map[string]interface{}{}},
+			{Type: "cc_prebuilt_library_shared", Name: "stubslib", Props: map[string]interface{}{
+				"min_sdk_version": "29",
+				"stubs": map[string]interface{}{
+					"versions": list("1", "2", "current"),
+				},
+			}},
+		},
+		files: map[string][]byte{
+			"sdk_library/public/myjavalib.txt": []byte(`package android.foo {
+  public class Foo {
+    method public void bar();
+    method public void baz();
+  }
+  public class Gone {
+    method public void gone();
+  }
+}
+`),
+		},
+	}
+	newS := &snapshot{
+		members: []*member{
+			{Type: "java_sdk_library_import", Name: "myjavalib", Props: map[string]interface{}{
+				"apex_available": list("com.android.foo"),
+				"public": map[string]interface{}{
+					"jars":        list("sdk_library/public/myjavalib-stubs.jar"),
+					"current_api": "sdk_library/public/myjavalib.txt",
+				},
+			}},
+			{Type: "java_import", Name: "newlib", Props: map[string]interface{}{}},
+			{Type: "java_import", Name: "bootlib", Props: map[string]interface{}{}},
+			{Type: "cc_prebuilt_library_shared", Name: "stubslib", Props: map[string]interface{}{
+				"min_sdk_version": "30",
+				"stubs": map[string]interface{}{
+					"versions": list("1", "2", "3", "current"),
+				},
+			}},
+		},
+		files: map[string][]byte{
+			"sdk_library/public/myjavalib.txt": []byte(`package android.foo {
+  public class Foo {
+    method public void bar();
+    method public void qux();
+  }
+  public class New {
+    method public void added();
+  }
+}
+`),
+		},
+	}
+
+	changes, err := diffSnapshots(oldS, newS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []change{
+		{"bootlib", "unchecked", "API not compared, the snapshot has neither an API signature file nor a jar", false},
+		{"myjavalib", "property", `apex_available: removed "com.android.bar"`, true},
+		{"myjavalib", "api", "sdk_library/public/myjavalib.txt: class android.foo.Gone removed", true},
+		{"myjavalib", "api", "sdk_library/public/myjavalib.txt: class android.foo.New added", false},
+		{"myjavalib", "api", "sdk_library/public/myjavalib.txt: removed android.foo.Foo: method public void baz()", true},
+		{"myjavalib", "api", "sdk_library/public/myjavalib.txt: added android.foo.Foo: method public void qux()", false},
+		{"newlib", "member", "java_import added", false},
+		{"oldlib", "member", "java_import removed", true},
+		{"stubslib", "property", `min_sdk_version changed from "29" to "30"`, true},
+		{"stubslib", "property", `stubs.versions: added "3"`, false},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes:\n%v\ngot:\n%v", want, changes)
+	}
+}
+
+func TestPropertyBreaking(t *testing.T) {
+	testCases := []struct {
+		name       string
+		oldV, newV interface{}
+		want       bool
+	}{
+		{"apex_available", list("a", "b"), list("a"), true},
+		{"apex_available", list("a"), list("a", "b"), false},
+		{"visibility", list("//visibility:public"), nil, true},
+		{"stubs.versions", list("1", "2"), list("2"), true},
+		{"min_sdk_version", "29", "30", true},
+		{"min_sdk_version", "30", "29", false},
+		{"min_sdk_version", "29", nil, false},
+		{"min_sdk_version", nil, "29", true},
+		{"min_sdk_version", "UpsideDownCake", "UpsideDownCake", false},
+		{"min_sdk_version", "33", "UpsideDownCake", true},
+		{"target.host.enabled", nil, false, true},
+		{"target.host.enabled", false, true, false},
+		{"arch.arm64.srcs", list("a.so"), nil, false},
+		{"export_include_dirs", list("include"), list("include2"), false},
+	}
+	for _, tc := range testCases {
+		if got := propertyBreaking(tc.name, tc.oldV, tc.newV); got != tc.want {
+			t.Errorf("propertyBreaking(%q, %v, %v): expected %t, got %t", tc.name, tc.oldV, tc.newV, tc.want, got)
+		}
+	}
+}
+
+func TestDiffFiles(t *testing.T) {
+	changes, err := diffFiles("mylib", "abi", []string{"arm/lib/mylib.so", "arm64/lib/mylib.so"},
+		[]string{"arm64/lib/mylib.so", "x86_64/lib/mylib.so"}, nil, nil,
+		func(name, path string, oldData, newData []byte) ([]change, error) {
+			return []change{{name, "abi", path + " compared", false}}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []change{
+		{"mylib", "abi", "arm/lib/mylib.so removed", true},
+		{"mylib", "abi", "arm64/lib/mylib.so compared", false},
+		{"mylib", "abi", "x86_64/lib/mylib.so added", false},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes:\n%v\ngot:\n%v", want, changes)
+	}
+}
+
+func TestMemberFiles(t *testing.T) {
+	m := &member{Props: map[string]interface{}{
+		"arch": map[string]interface{}{
+			"arm64": map[string]interface{}{"srcs": list("arm64/lib/mylib.so")},
+			"arm":   map[string]interface{}{"srcs": list("arm/lib/mylib.so")},
+		},
+		"export_include_dirs": list("include/mylib"),
+		"public": map[string]interface{}{
+			"jars":        list("sdk_library/public/myjavalib-stubs.jar"),
+			"current_api": "sdk_library/public/myjavalib.txt",
+			"removed_api": "sdk_library/public/myjavalib-removed.txt",
+		},
+	}}
+	libs, apis, jars := m.files()
+	if want := []string{"arm/lib/mylib.so", "arm64/lib/mylib.so"}; !reflect.DeepEqual(libs, want) {
+		t.Errorf("expected libs %q, got %q", want, libs)
+	}
+	if want := []string{"sdk_library/public/myjavalib.txt"}; !reflect.DeepEqual(apis, want) {
+		t.Errorf("expected apis %q, got %q", want, apis)
+	}
+	if want := []string{"sdk_library/public/myjavalib-stubs.jar"}; !reflect.DeepEqual(jars, want) {
+		t.Errorf("expected jars %q, got %q", want, jars)
+	}
+}
+
+func TestPrintChanges(t *testing.T) {
+	buf := &bytes.Buffer{}
+	printChanges(buf, []change{
+		{"a", "member", "java_import added", false},
+		{"b", "member", "java_import removed", true},
+		{"c", "unchecked", "API not compared", false},
+	})
+	want := `Breaking changes:
+  b: java_import removed
+
+Compatible changes:
+  a: java_import added
+
+Not checked:
+  c: API not compared
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	printChanges(buf, []change{{"a", "member", "java_import added", false}})
+	if want := "Compatible changes:\n  a: java_import added\n"; buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
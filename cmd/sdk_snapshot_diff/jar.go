@@ -0,0 +1,260 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Access flags of classes, fields and methods in class files.
+const (
+	accPublic    = 0x0001
+	accProtected = 0x0004
+	accSynthetic = 0x1000
+	accModule    = 0x8000
+)
+
+// Tags of the constant pool entries in class files.
+const (
+	constantUtf8               = 1
+	constantInteger            = 3
+	constantFloat              = 4
+	constantLong               = 5
+	constantDouble             = 6
+	constantClass              = 7
+	constantString             = 8
+	constantFieldref           = 9
+	constantMethodref          = 10
+	constantInterfaceMethodref = 11
+	constantNameAndType        = 12
+	constantMethodHandle       = 15
+	constantMethodType         = 16
+	constantDynamic            = 17
+	constantInvokeDynamic      = 18
+	constantModule             = 19
+	constantPackage            = 20
+)
+
+// jarApi is the API of the classes of a jar, for java libraries that don't have an API signature
+// file in the snapshot.
+type jarApi struct {
+	// The public classes, keyed by their binary name, e.g. "android.foo.Foo$Bar"
+	classes map[string]bool
+
+	// The public and protected fields and methods of the public classes, as
+	// "<class>: field <name>:<descriptor>" or "<class>: method <name><descriptor>"
+	members map[string]bool
+}
+
+// classReader reads the big-endian values of a class file, and remembers the first error.
+type classReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (c *classReader) read(v interface{}) {
+	if c.err == nil {
+		c.err = binary.Read(c.r, binary.BigEndian, v)
+	}
+}
+
+func (c *classReader) u2() uint16 {
+	var v uint16
+	c.read(&v)
+	return v
+}
+
+func (c *classReader) u4() uint32 {
+	var v uint32
+	c.read(&v)
+	return v
+}
+
+func (c *classReader) skip(n int64) {
+	if c.err == nil {
+		_, c.err = c.r.Seek(n, io.SeekCurrent)
+	}
+}
+
+// skipAttributes skips the attributes of a class, a field or a method.
+func (c *classReader) skipAttributes() {
+	count := c.u2()
+	for i := 0; i < int(count) && c.err == nil; i++ {
+		c.u2()
+		c.skip(int64(c.u4()))
+	}
+}
+
+// parseClass returns the binary name of the class and its public and protected fields and
+// methods. public is false for classes that aren't public, and for modules.
+func parseClass(data []byte) (name string, public bool, members []string, err error) {
+	c := &classReader{r: bytes.NewReader(data)}
+	if c.u4() != 0xCAFEBABE {
+		return "", false, nil, errors.New("not a class file")
+	}
+	c.skip(4) // minor_version and major_version
+
+	// The constant pool is indexed from 1. Only the strings and the class names are needed.
+	count := int(c.u2())
+	utf8 := make(map[int]string)
+	classes := make(map[int]int)
+	for i := 1; i < count && c.err == nil; i++ {
+		var tag uint8
+		c.read(&tag)
+		switch tag {
+		case constantUtf8:
+			b := make([]byte, c.u2())
+			c.read(b)
+			utf8[i] = string(b)
+		case constantClass:
+			classes[i] = int(c.u2())
+		case constantString, constantMethodType, constantModule, constantPackage:
+			c.skip(2)
+		case constantMethodHandle:
+			c.skip(3)
+		case constantInteger, constantFloat, constantFieldref, constantMethodref,
+			constantInterfaceMethodref, constantNameAndType, constantDynamic, constantInvokeDynamic:
+			c.skip(4)
+		case constantLong, constantDouble:
+			// 8 byte constants take two entries.
+			c.skip(8)
+			i++
+		default:
+			if c.err == nil {
+				return "", false, nil, fmt.Errorf("unknown constant pool tag %d", tag)
+			}
+		}
+	}
+
+	access := c.u2()
+	thisClass := int(c.u2())
+	c.skip(2) // super_class
+	c.skip(2 * int64(c.u2()))
+	if c.err != nil {
+		return "", false, nil, c.err
+	}
+	internalName, ok := utf8[classes[thisClass]]
+	if !ok {
+		return "", false, nil, errors.New("invalid this_class")
+	}
+	name = strings.ReplaceAll(internalName, "/", ".")
+	if access&accPublic == 0 || access&(accSynthetic|accModule) != 0 {
+		return name, false, nil, nil
+	}
+
+	for _, kind := range []string{"field", "method"} {
+		count := c.u2()
+		for i := 0; i < int(count) && c.err == nil; i++ {
+			access := c.u2()
+			memberName := utf8[int(c.u2())]
+			descriptor := utf8[int(c.u2())]
+			c.skipAttributes()
+			if access&(accPublic|accProtected) == 0 || access&accSynthetic != 0 {
+				continue
+			}
+			if kind == "field" {
+				members = append(members, fmt.Sprintf("field %s:%s", memberName, descriptor))
+			} else {
+				members = append(members, fmt.Sprintf("method %s%s", memberName, descriptor))
+			}
+		}
+	}
+	if c.err != nil {
+		return "", false, nil, c.err
+	}
+	return name, true, members, nil
+}
+
+// readJarApi returns the public classes of a jar, with their public and protected members.
+func readJarApi(data []byte) (*jarApi, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	api := &jarApi{classes: make(map[string]bool), members: make(map[string]bool)}
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".class") || strings.HasPrefix(f.Name, "META-INF/") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		classData, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		name, public, members, err := parseClass(classData)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if !public {
+			continue
+		}
+		api.classes[name] = true
+		for _, m := range members {
+			api.members[name+": "+m] = true
+		}
+	}
+	return api, nil
+}
+
+// diffJars compares the classes of two versions of a jar. Removing a public class or a public or
+// protected member is a breaking change.
+func diffJars(name, path string, oldData, newData []byte) ([]change, error) {
+	oldApi, err := readJarApi(oldData)
+	if err != nil {
+		return nil, err
+	}
+	newApi, err := readJarApi(newData)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []change
+	for _, class := range sortedKeys(oldApi.classes) {
+		if !newApi.classes[class] {
+			changes = append(changes, change{name, "api", fmt.Sprintf("%s: class %s removed", path, class), true})
+		}
+	}
+	for _, class := range sortedKeys(newApi.classes) {
+		if !oldApi.classes[class] {
+			changes = append(changes, change{name, "api", fmt.Sprintf("%s: class %s added", path, class), false})
+		}
+	}
+
+	for _, m := range sortedKeys(oldApi.members) {
+		class, _, _ := strings.Cut(m, ": ")
+		// The removal of the class has already been reported.
+		if !newApi.members[m] && newApi.classes[class] {
+			changes = append(changes, change{name, "api", fmt.Sprintf("%s: removed %s", path, m), true})
+		}
+	}
+	for _, m := range sortedKeys(newApi.members) {
+		class, _, _ := strings.Cut(m, ": ")
+		// The members of new classes are part of the addition of the class.
+		if !oldApi.members[m] && oldApi.classes[class] {
+			changes = append(changes, change{name, "api", fmt.Sprintf("%s: added %s", path, m), false})
+		}
+	}
+	return changes, nil
+}
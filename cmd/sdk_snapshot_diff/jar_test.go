@@ -0,0 +1,168 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+type testMember struct {
+	access     uint16
+	name       string
+	descriptor string
+}
+
+// buildClass returns a class file declaring a class with its fields and methods. The constant
+// pool starts with a long constant, which takes two entries.
+func buildClass(name string, access uint16, fields, methods []testMember) []byte {
+	buf := &bytes.Buffer{}
+	w := func(v interface{}) { binary.Write(buf, binary.BigEndian, v) }
+
+	var pool bytes.Buffer
+	next := uint16(1)
+	pool.WriteByte(constantLong)
+	binary.Write(&pool, binary.BigEndian, uint64(42))
+	next += 2
+	utf8 := func(s string) uint16 {
+		pool.WriteByte(constantUtf8)
+		binary.Write(&pool, binary.BigEndian, uint16(len(s)))
+		pool.WriteString(s)
+		next++
+		return next - 1
+	}
+	nameIndex := utf8(name)
+	pool.WriteByte(constantClass)
+	binary.Write(&pool, binary.BigEndian, nameIndex)
+	thisClass := next
+	next++
+
+	type member struct {
+		access           uint16
+		name, descriptor uint16
+	}
+	var fieldEntries, methodEntries []member
+	for _, f := range fields {
+		fieldEntries = append(fieldEntries, member{f.access, utf8(f.name), utf8(f.descriptor)})
+	}
+	for _, m := range methods {
+		methodEntries = append(methodEntries, member{m.access, utf8(m.name), utf8(m.descriptor)})
+	}
+	code := utf8("Code")
+
+	w(uint32(0xCAFEBABE))
+	w(uint16(0))
+	w(uint16(52))
+	w(next)
+	buf.Write(pool.Bytes())
+	w(access)
+	w(thisClass)
+	w(uint16(0)) // super_class
+	w(uint16(0)) // interfaces_count
+	for _, entries := range [][]member{fieldEntries, methodEntries} {
+		w(uint16(len(entries)))
+		for _, e := range entries {
+			w(e.access)
+			w(e.name)
+			w(e.descriptor)
+			// One attribute, which is skipped.
+			w(uint16(1))
+			w(code)
+			w(uint32(3))
+			buf.Write([]byte{1, 2, 3})
+		}
+	}
+	w(uint16(0)) // attributes_count
+	return buf.Bytes()
+}
+
+func buildJar(t *testing.T, classes map[string][]byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, name := range sortedKeys(classes) {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(classes[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseClass(t *testing.T) {
+	data := buildClass("android/foo/Foo$Bar", accPublic,
+		[]testMember{{accPublic, "count", "I"}, {0, "hidden", "I"}},
+		[]testMember{
+			{accPublic, "bar", "()V"},
+			{accProtected, "baz", "(Ljava/lang/String;)I"},
+			{accPublic | accSynthetic, "access$000", "()V"},
+		})
+	name, public, members, err := parseClass(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "android.foo.Foo$Bar" || !public {
+		t.Errorf("expected public class android.foo.Foo$Bar, got %q (public: %t)", name, public)
+	}
+	want := []string{"field count:I", "method bar()V", "method baz(Ljava/lang/String;)I"}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("expected members %q, got %q", want, members)
+	}
+
+	if _, public, _, err := parseClass(buildClass("android/foo/Hidden", 0, nil, nil)); err != nil || public {
+		t.Errorf("expected a class that isn't public, got public: %t, error: %v", public, err)
+	}
+	if _, _, _, err := parseClass([]byte("not a class")); err == nil {
+		t.Errorf("expected an error for an invalid class file")
+	}
+}
+
+func TestDiffJars(t *testing.T) {
+	oldJar := buildJar(t, map[string][]byte{
+		"android/foo/Foo.class": buildClass("android/foo/Foo", accPublic, nil,
+			[]testMember{{accPublic, "bar", "()V"}, {accPublic, "baz", "()V"}}),
+		"android/foo/Gone.class": buildClass("android/foo/Gone", accPublic, nil,
+			[]testMember{{accPublic, "gone", "()V"}}),
+		"android/foo/Internal.class": buildClass("android/foo/Internal", 0, nil, nil),
+	})
+	newJar := buildJar(t, map[string][]byte{
+		"android/foo/Foo.class": buildClass("android/foo/Foo", accPublic, nil,
+			[]testMember{{accPublic, "bar", "()V"}, {accPublic, "qux", "()V"}}),
+		"android/foo/New.class": buildClass("android/foo/New", accPublic, nil,
+			[]testMember{{accPublic, "added", "()V"}}),
+		"META-INF/versions/9/module-info.class": []byte("skipped"),
+	})
+
+	changes, err := diffJars("mylib", "java/mylib.jar", oldJar, newJar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []change{
+		{"mylib", "api", "java/mylib.jar: class android.foo.Gone removed", true},
+		{"mylib", "api", "java/mylib.jar: class android.foo.New added", false},
+		{"mylib", "api", "java/mylib.jar: removed android.foo.Foo: method baz()V", true},
+		{"mylib", "api", "java/mylib.jar: added android.foo.Foo: method qux()V", false},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes:\n%v\ngot:\n%v", want, changes)
+	}
+}
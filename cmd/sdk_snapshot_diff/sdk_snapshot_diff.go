@@ -0,0 +1,85 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// sdk_snapshot_diff compares two snapshots of an sdk or module_exports module, as generated by
+// sdk/update.go, and reports the added and removed members, the changed member properties, the
+// changes to the symbols exported by the native libraries and the changes to the API signature
+// files of the java libraries, or to the classes in their jars when they have no API signature
+// file. Each change is classified as compatible or breaking, so that it can be decided whether a
+// snapshot can safely replace a previously distributed one. The java libraries that have neither
+// are reported as not checked.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	output := flag.String("output", "", "file to write the changes to as JSON, in addition to the report on stdout")
+	errorOnBreaking := flag.Bool("error_on_breaking", false, "exit with an error if there are breaking changes")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--output <file>] [--error_on_breaking] <old snapshot zip> <new snapshot zip>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), flag.Arg(1), *output, *errorOnBreaking); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(oldZip, newZip, output string, errorOnBreaking bool) error {
+	oldS, err := readSnapshot(oldZip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", oldZip, err)
+	}
+	newS, err := readSnapshot(newZip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", newZip, err)
+	}
+
+	changes, err := diffSnapshots(oldS, newS)
+	if err != nil {
+		return err
+	}
+	printChanges(os.Stdout, changes)
+
+	if output != "" {
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, append(data, '\n'), 0666); err != nil {
+			return err
+		}
+	}
+
+	if errorOnBreaking {
+		for _, c := range changes {
+			if c.Breaking {
+				return fmt.Errorf("%s is not compatible with %s", newZip, oldZip)
+			}
+		}
+	}
+	return nil
+}
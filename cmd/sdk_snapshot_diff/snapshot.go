@@ -0,0 +1,143 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// snapshot is the contents of an sdk snapshot zip.
+type snapshot struct {
+	// The prebuilt modules in the Android.bp file of the snapshot, sorted by name
+	members []*member
+
+	// Contents of the native libraries, the API signature files and the jars in the snapshot,
+	// keyed by their path in the snapshot
+	files map[string][]byte
+}
+
+// member is a prebuilt module in the Android.bp file of a snapshot.
+type member struct {
+	Type string
+	Name string
+
+	// Properties of the module other than the name. The values are string, bool, int64,
+	// []interface{} or map[string]interface{}.
+	Props map[string]interface{}
+}
+
+// isApiProperty returns true if the property points to an API signature file.
+func isApiProperty(name string) bool {
+	return name == "current_api"
+}
+
+// isNativeLibrary returns true if the path is a native library whose symbols are compared.
+func isNativeLibrary(path string) bool {
+	return strings.HasSuffix(path, ".so")
+}
+
+// isJar returns true if the path is a jar whose classes are compared when the member has no API
+// signature file.
+func isJar(path string) bool {
+	return strings.HasSuffix(path, ".jar")
+}
+
+// files returns the paths of the native libraries, of the API signature files and of the jars
+// referenced by the properties of the member.
+func (m *member) files() (libs, apis, jars []string) {
+	var walk func(name string, v interface{})
+	walk = func(name string, v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if isApiProperty(name) {
+				apis = append(apis, v)
+			} else if isNativeLibrary(v) {
+				libs = append(libs, v)
+			} else if isJar(v) {
+				jars = append(jars, v)
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(name, e)
+			}
+		case map[string]interface{}:
+			for k, e := range v {
+				walk(k, e)
+			}
+		}
+	}
+	walk("", m.Props)
+	sort.Strings(libs)
+	sort.Strings(apis)
+	sort.Strings(jars)
+	return libs, apis, jars
+}
+
+// readSnapshot reads the Android.bp file of a snapshot zip, and the native libraries, API
+// signature files and jars referenced by its members.
+func readSnapshot(file string) (*snapshot, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	entries := make(map[string]*zip.File)
+	for _, f := range r.File {
+		entries[f.Name] = f
+	}
+
+	readEntry := func(f *zip.File) ([]byte, error) {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+
+	bp, ok := entries["Android.bp"]
+	if !ok {
+		return nil, fmt.Errorf("missing Android.bp")
+	}
+	data, err := readEntry(bp)
+	if err != nil {
+		return nil, err
+	}
+	members, err := parseBp(bp.Name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &snapshot{members: members, files: make(map[string][]byte)}
+	for _, m := range members {
+		libs, apis, jars := m.files()
+		paths := append(append(libs, apis...), jars...)
+		for _, path := range paths {
+			f, ok := entries[path]
+			if !ok {
+				return nil, fmt.Errorf("%s of %s is missing", path, m.Name)
+			}
+			if s.files[path], err = readEntry(f); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	return s, nil
+}
@@ -90,6 +90,12 @@ var commands = []command{
 		config:      buildActionConfig,
 		stdio:       stdio,
 		run:         runMake,
+	}, {
+		flag:        "--ide-workspace-mode",
+		description: "generate IDE project files limited to the given modules and directories",
+		config:      ideWorkspaceConfig,
+		stdio:       stdio,
+		run:         runMake,
 	},
 }
 
@@ -227,6 +233,8 @@ func main() {
 		criticalPath.WriteToMetrics(met)
 		met.Dump(soongMetricsFile)
 		if !config.SkipMetricsUpload() {
+			// Headroom of the partitions with a budget, see filesystem/partition_budget.go.
+			metricsFiles = append(metricsFiles, build.PartitionBudgetReports(config, buildStarted)...)
 			build.UploadMetrics(buildCtx, config, c.simpleOutput, buildStarted, metricsFiles...)
 		}
 	}()
@@ -526,6 +534,58 @@ func buildActionConfig(ctx build.Context, args ...string) build.Config {
 	return build.NewBuildActionConfig(buildAction, *dir, ctx, args...)
 }
 
+// ideWorkspaceConfig builds the ide-workspace phony target with the environment variables that
+// select the modules of the IDE workspace, see android/ide_workspace.go in Soong.
+func ideWorkspaceConfig(ctx build.Context, args ...string) build.Config {
+	flags := flag.NewFlagSet("ide-workspace-mode", flag.ExitOnError)
+	flags.SetOutput(ctx.Writer)
+
+	flags.Usage = func() {
+		fmt.Fprintf(ctx.Writer, "usage: %s --ide-workspace-mode [--module=<name>] [--dir=<path>] [<build arg 1> ...]\n\n", os.Args[0])
+		fmt.Fprintln(ctx.Writer, "In IDE workspace mode, generate compile_commands.json, rust-project.json,")
+		fmt.Fprintln(ctx.Writer, "IntelliJ module files and VS Code/clangd configuration limited to the given")
+		fmt.Fprintln(ctx.Writer, "modules, the modules defined in the given directories, and their transitive")
+		fmt.Fprintln(ctx.Writer, "dependencies. The files are written to $OUT_DIR/soong/development/ide/workspace.")
+		fmt.Fprintln(ctx.Writer, "Both flags may be repeated or given a comma separated list.")
+		fmt.Fprintln(ctx.Writer, "")
+		flags.PrintDefaults()
+	}
+
+	var modules, dirs []string
+	flags.Func("module", "name of a module to include in the workspace", func(value string) error {
+		modules = append(modules, strings.Split(value, ",")...)
+		return nil
+	})
+	flags.Func("dir", "directory, relative to the top of the source tree, whose modules to include in the workspace", func(value string) error {
+		for _, dir := range strings.Split(value, ",") {
+			if filepath.IsAbs(dir) {
+				top, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+				if dir, err = filepath.Rel(top, dir); err != nil || strings.HasPrefix(dir, "..") {
+					return fmt.Errorf("%q is not in the source tree", value)
+				}
+			}
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+		return nil
+	})
+	flags.Parse(args)
+
+	if len(modules) == 0 && len(dirs) == 0 {
+		flags.Usage()
+		ctx.Fatalln("At least one of --module or --dir is required.")
+	}
+
+	configArgs := []string{
+		"SOONG_IDE_WORKSPACE_MODULES=" + strings.Join(modules, ","),
+		"SOONG_IDE_WORKSPACE_DIRS=" + strings.Join(dirs, ","),
+		"ide-workspace",
+	}
+	return build.NewConfig(ctx, append(configArgs, flags.Args()...)...)
+}
+
 func runMake(ctx build.Context, config build.Config, _ []string) {
 	logsDir := config.LogsDir()
 	if config.IsVerbose() {
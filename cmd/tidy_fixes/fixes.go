@@ -0,0 +1,330 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Replacement replaces Length bytes at Offset in FilePath with ReplacementText.
+type Replacement struct {
+	FilePath        string
+	Offset          int
+	Length          int
+	ReplacementText string
+}
+
+// end returns the offset of the first byte after the replaced range.
+func (r Replacement) end() int {
+	return r.Offset + r.Length
+}
+
+// overlaps returns true if r and other can not both be applied, i.e. if their ranges intersect or
+// if they insert different text at the same offset.
+func (r Replacement) overlaps(other Replacement) bool {
+	if r.FilePath != other.FilePath {
+		return false
+	}
+	if r.Offset == other.Offset {
+		return true
+	}
+	return r.Offset < other.end() && other.Offset < r.end()
+}
+
+// Diagnostic is a clang-tidy diagnostic along with the replacements that fix it.
+type Diagnostic struct {
+	Name           string
+	Message        string
+	FilePath       string
+	FileOffset     int
+	Level          string
+	BuildDirectory string
+	Replacements   []Replacement
+}
+
+// key identifies a diagnostic, so that the diagnostics of a header that is included by several
+// translation units are only kept once.
+func (d Diagnostic) key() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s\x00%s\x00%d", d.Name, d.Message, d.FilePath, d.FileOffset)
+	for _, r := range d.Replacements {
+		fmt.Fprintf(&b, "\x00%s\x00%d\x00%d\x00%s", r.FilePath, r.Offset, r.Length, r.ReplacementText)
+	}
+	return b.String()
+}
+
+// parseFixes parses a file written by clang-tidy --export-fixes, or by writeFixes. The relative
+// paths are resolved against the build directory of each diagnostic. An empty file, which is what
+// the build leaves when clang-tidy found nothing to fix, has no diagnostics.
+func parseFixes(data string) ([]Diagnostic, error) {
+	root, err := parseYaml(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var diags []Diagnostic
+	for i, node := range root.get("Diagnostics").items() {
+		message := node.get("DiagnosticMessage")
+		d := Diagnostic{
+			Name:           node.get("DiagnosticName").str(),
+			Message:        node.get("Message").str(),
+			FilePath:       node.get("FilePath").str(),
+			Level:          node.get("Level").str(),
+			BuildDirectory: node.get("BuildDirectory").str(),
+		}
+		offset := node.get("FileOffset")
+		replacements := node.get("Replacements")
+		// Since LLVM 10 the message, its location and its replacements are in a DiagnosticMessage
+		// mapping, before that they were fields of the diagnostic.
+		if message != nil && message.mapping != nil {
+			d.Message = message.get("Message").str()
+			d.FilePath = message.get("FilePath").str()
+			offset = message.get("FileOffset")
+			replacements = message.get("Replacements")
+		}
+		if offset != nil {
+			if d.FileOffset, err = offset.int(); err != nil {
+				return nil, fmt.Errorf("diagnostic %d: invalid FileOffset: %s", i, err)
+			}
+		}
+		d.FilePath = resolvePath(d.BuildDirectory, d.FilePath)
+
+		for j, r := range replacements.items() {
+			replacement := Replacement{
+				FilePath:        resolvePath(d.BuildDirectory, r.get("FilePath").str()),
+				ReplacementText: r.get("ReplacementText").str(),
+			}
+			if replacement.Offset, err = r.get("Offset").int(); err != nil {
+				return nil, fmt.Errorf("diagnostic %d, replacement %d: invalid Offset: %s", i, j, err)
+			}
+			if replacement.Length, err = r.get("Length").int(); err != nil {
+				return nil, fmt.Errorf("diagnostic %d, replacement %d: invalid Length: %s", i, j, err)
+			}
+			d.Replacements = append(d.Replacements, replacement)
+		}
+		diags = append(diags, d)
+	}
+	return diags, nil
+}
+
+func resolvePath(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	if !filepath.IsAbs(path) && dir != "" {
+		path = filepath.Join(dir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// mergeFixes returns the diagnostics of all the translation units without duplicates, sorted by
+// location.
+func mergeFixes(units ...[]Diagnostic) []Diagnostic {
+	seen := make(map[string]bool)
+	var merged []Diagnostic
+	for _, diags := range units {
+		for _, d := range diags {
+			if key := d.key(); !seen[key] {
+				seen[key] = true
+				merged = append(merged, d)
+			}
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		if a.FileOffset != b.FileOffset {
+			return a.FileOffset < b.FileOffset
+		}
+		return a.Name < b.Name
+	})
+	return merged
+}
+
+// writeFixes writes the diagnostics in the format of clang-tidy --export-fixes, so that the merged
+// fixes can also be applied with clang-apply-replacements.
+func writeFixes(w io.Writer, diags []Diagnostic) error {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("MainSourceFile:  ''\n")
+	if len(diags) == 0 {
+		b.WriteString("Diagnostics:     []\n")
+	} else {
+		b.WriteString("Diagnostics:\n")
+	}
+	for _, d := range diags {
+		fmt.Fprintf(&b, "  - DiagnosticName:  %s\n", quoteYaml(d.Name))
+		b.WriteString("    DiagnosticMessage:\n")
+		fmt.Fprintf(&b, "      Message:         %s\n", quoteYaml(d.Message))
+		fmt.Fprintf(&b, "      FilePath:        %s\n", quoteYaml(d.FilePath))
+		fmt.Fprintf(&b, "      FileOffset:      %d\n", d.FileOffset)
+		if len(d.Replacements) == 0 {
+			b.WriteString("      Replacements:    []\n")
+		} else {
+			b.WriteString("      Replacements:\n")
+		}
+		for _, r := range d.Replacements {
+			fmt.Fprintf(&b, "        - FilePath:        %s\n", quoteYaml(r.FilePath))
+			fmt.Fprintf(&b, "          Offset:          %d\n", r.Offset)
+			fmt.Fprintf(&b, "          Length:          %d\n", r.Length)
+			fmt.Fprintf(&b, "          ReplacementText: %s\n", quoteYaml(r.ReplacementText))
+		}
+		if d.Level != "" {
+			fmt.Fprintf(&b, "    Level:           %s\n", d.Level)
+		}
+		if d.BuildDirectory != "" {
+			fmt.Fprintf(&b, "    BuildDirectory:  %s\n", quoteYaml(d.BuildDirectory))
+		}
+	}
+	b.WriteString("...\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// conflict is a diagnostic whose fix was not applied because it overlaps the fix of another one.
+type conflict struct {
+	diag  Diagnostic
+	other Diagnostic
+}
+
+func (c conflict) String() string {
+	return fmt.Sprintf("%s:%d: %s: fix overlaps the fix of %s at offset %d", c.diag.FilePath,
+		c.diag.FileOffset, c.diag.Name, c.other.Name, c.other.FileOffset)
+}
+
+// planFixes chooses the fixes to apply. Each diagnostic's replacements are applied either all or
+// not at all, and a diagnostic whose replacements overlap those of an earlier one is reported as a
+// conflict. It returns the replacements to apply to each file, sorted by offset.
+func planFixes(diags []Diagnostic) (map[string][]Replacement, []conflict) {
+	type accepted struct {
+		replacement Replacement
+		diag        Diagnostic
+	}
+	files := make(map[string][]accepted)
+	var conflicts []conflict
+
+diags:
+	for _, d := range diags {
+		var toAdd []Replacement
+		for _, r := range d.Replacements {
+			duplicate := false
+			for _, a := range files[r.FilePath] {
+				if a.replacement == r {
+					// The same fix is reported by several diagnostics, e.g. for a macro expanded in
+					// several places.
+					duplicate = true
+					break
+				}
+				if a.replacement.overlaps(r) {
+					conflicts = append(conflicts, conflict{diag: d, other: a.diag})
+					continue diags
+				}
+			}
+			for _, other := range toAdd {
+				if other != r && other.overlaps(r) {
+					conflicts = append(conflicts, conflict{diag: d, other: d})
+					continue diags
+				}
+			}
+			if !duplicate {
+				toAdd = append(toAdd, r)
+			}
+		}
+		for _, r := range toAdd {
+			files[r.FilePath] = append(files[r.FilePath], accepted{r, d})
+		}
+	}
+
+	plan := make(map[string][]Replacement)
+	for file, replacements := range files {
+		for _, a := range replacements {
+			plan[file] = append(plan[file], a.replacement)
+		}
+		sort.Slice(plan[file], func(i, j int) bool {
+			return plan[file][i].Offset < plan[file][j].Offset
+		})
+	}
+	return plan, conflicts
+}
+
+// applyReplacements returns content with the replacements, which must be sorted by offset and must
+// not overlap, applied.
+func applyReplacements(content string, replacements []Replacement) (string, error) {
+	var b strings.Builder
+	pos := 0
+	for _, r := range replacements {
+		if r.Offset < pos || r.end() > len(content) {
+			return "", fmt.Errorf("replacement at offset %d of length %d is out of range", r.Offset, r.Length)
+		}
+		b.WriteString(content[pos:r.Offset])
+		b.WriteString(r.ReplacementText)
+		pos = r.end()
+	}
+	b.WriteString(content[pos:])
+	return b.String(), nil
+}
+
+// applyFixes applies the fixes to the files, or only reports what would be changed if dryRun is
+// set. It reports the changed files and the conflicts to w.
+func applyFixes(w io.Writer, diags []Diagnostic, dryRun bool) error {
+	plan, conflicts := planFixes(diags)
+	for _, c := range conflicts {
+		fmt.Fprintf(w, "warning: %s\n", c)
+	}
+
+	files := make([]string, 0, len(plan))
+	for file := range plan {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		content, err := applyReplacements(string(data), plan[file])
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		if dryRun {
+			fmt.Fprintf(w, "%s: would apply %s\n", file, pluralReplacements(len(plan[file])))
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, []byte(content), info.Mode()); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s: applied %s\n", file, pluralReplacements(len(plan[file])))
+	}
+	return nil
+}
+
+func pluralReplacements(n int) string {
+	if n == 1 {
+		return "1 replacement"
+	}
+	return strconv.Itoa(n) + " replacements"
+}
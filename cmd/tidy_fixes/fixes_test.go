@@ -0,0 +1,215 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixesFor returns the fixes clang-tidy would export for a translation unit that includes a.h.
+func fixesFor(source string) string {
+	return `---
+MainSourceFile:  '/src/` + source + `'
+Diagnostics:
+  - DiagnosticName:  readability-braces-around-statements
+    DiagnosticMessage:
+      Message:         'statement should be inside braces'
+      FilePath:        'a.h'
+      FileOffset:      4
+      Replacements:
+        - FilePath:        'a.h'
+          Offset:          4
+          Length:          0
+          ReplacementText: ' {'
+    Level:           Warning
+    BuildDirectory:  '/src'
+  - DiagnosticName:  modernize-use-nullptr
+    DiagnosticMessage:
+      Message:         'use nullptr'
+      FilePath:        '` + source + `'
+      FileOffset:      2
+      Replacements:
+        - FilePath:        '` + source + `'
+          Offset:          2
+          Length:          1
+          ReplacementText: nullptr
+    Level:           Warning
+    BuildDirectory:  '/src'
+...
+`
+}
+
+func TestParseFixes(t *testing.T) {
+	diags, err := parseFixes(fixesFor("a.cpp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Diagnostic{
+		{
+			Name:           "readability-braces-around-statements",
+			Message:        "statement should be inside braces",
+			FilePath:       "/src/a.h",
+			FileOffset:     4,
+			Level:          "Warning",
+			BuildDirectory: "/src",
+			Replacements:   []Replacement{{FilePath: "/src/a.h", Offset: 4, ReplacementText: " {"}},
+		},
+		{
+			Name:           "modernize-use-nullptr",
+			Message:        "use nullptr",
+			FilePath:       "/src/a.cpp",
+			FileOffset:     2,
+			Level:          "Warning",
+			BuildDirectory: "/src",
+			Replacements:   []Replacement{{FilePath: "/src/a.cpp", Offset: 2, Length: 1, ReplacementText: "nullptr"}},
+		},
+	}
+	if !reflect.DeepEqual(diags, want) {
+		t.Errorf("want %#v\ngot  %#v", want, diags)
+	}
+
+	diags, err = parseFixes("")
+	if err != nil || len(diags) != 0 {
+		t.Errorf("empty file: want no diagnostics, got %v, %v", diags, err)
+	}
+}
+
+func TestParseFixesOldFormat(t *testing.T) {
+	diags, err := parseFixes(`---
+MainSourceFile:  /src/a.cpp
+Diagnostics:
+  - DiagnosticName:  misc-unused
+    Message:         unused
+    FileOffset:      7
+    FilePath:        /src/a.cpp
+    Replacements:
+      - FilePath:        /src/a.cpp
+        Offset:          7
+        Length:          3
+        ReplacementText: ''
+...
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Diagnostic{{
+		Name:         "misc-unused",
+		Message:      "unused",
+		FilePath:     "/src/a.cpp",
+		FileOffset:   7,
+		Replacements: []Replacement{{FilePath: "/src/a.cpp", Offset: 7, Length: 3}},
+	}}
+	if !reflect.DeepEqual(diags, want) {
+		t.Errorf("want %#v\ngot  %#v", want, diags)
+	}
+}
+
+func TestMergeFixes(t *testing.T) {
+	a, err := parseFixes(fixesFor("a.cpp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parseFixes(fixesFor("b.cpp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := mergeFixes(b, a)
+	var files []string
+	for _, d := range merged {
+		files = append(files, d.FilePath)
+	}
+	if want := []string{"/src/a.cpp", "/src/a.h", "/src/b.cpp"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("want diagnostics in %q, got %q", want, files)
+	}
+
+	var out strings.Builder
+	if err := writeFixes(&out, merged); err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := parseFixes(out.String())
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out.String())
+	}
+	if !reflect.DeepEqual(reparsed, merged) {
+		t.Errorf("round trip: want %#v\ngot  %#v", merged, reparsed)
+	}
+}
+
+func TestPlanFixes(t *testing.T) {
+	diag := func(name string, replacements ...Replacement) Diagnostic {
+		return Diagnostic{Name: name, FilePath: "f", Replacements: replacements}
+	}
+	r := func(offset, length int, text string) Replacement {
+		return Replacement{FilePath: "f", Offset: offset, Length: length, ReplacementText: text}
+	}
+
+	plan, conflicts := planFixes([]Diagnostic{
+		diag("first", r(10, 2, "x")),
+		diag("same", r(10, 2, "x")),
+		diag("overlapping", r(11, 2, "y"), r(0, 1, "z")),
+		diag("insert", r(12, 0, "w")),
+		diag("other", r(0, 1, "z")),
+	})
+
+	if want := []Replacement{r(0, 1, "z"), r(10, 2, "x"), r(12, 0, "w")}; !reflect.DeepEqual(plan["f"], want) {
+		t.Errorf("want replacements %v, got %v", want, plan["f"])
+	}
+	if len(conflicts) != 1 || conflicts[0].diag.Name != "overlapping" || conflicts[0].other.Name != "first" {
+		t.Errorf("want the overlapping diagnostic to conflict with the first one, got %v", conflicts)
+	}
+}
+
+func TestApplyFixes(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.cpp")
+	if err := os.WriteFile(file, []byte("int *p = 0;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diags := []Diagnostic{{
+		Name:         "modernize-use-nullptr",
+		FilePath:     file,
+		FileOffset:   9,
+		Replacements: []Replacement{{FilePath: file, Offset: 9, Length: 1, ReplacementText: "nullptr"}},
+	}}
+
+	var out strings.Builder
+	if err := applyFixes(&out, diags, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "int *p = 0;\n" {
+		t.Errorf("dry run changed the file to %q", data)
+	}
+	if !strings.Contains(out.String(), "would apply 1 replacement") {
+		t.Errorf("dry run: unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	if err := applyFixes(&out, diags, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "int *p = nullptr;\n" {
+		t.Errorf("want the fix applied, got %q", data)
+	}
+
+	diags[0].Replacements[0].Offset = 100
+	if err := applyFixes(&out, diags, false); err == nil {
+		t.Errorf("want an error for a replacement out of range")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// tidy_fixes merges the fixes exported by clang-tidy for each translation unit into a single file,
+// and applies merged fixes to the source tree.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s merge --output <file> [--list <file>] [<fixes.yaml>...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s apply [--dry_run] <fixes.yaml>...\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "merge":
+		err = mergeMain(os.Args[2:])
+	case "apply":
+		err = applyMain(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func mergeMain(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("output", "", "file to write the merged fixes to")
+	list := flags.String("list", "", "file listing the fixes files to merge, one per line")
+	flags.Parse(args)
+
+	if *output == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	files := flags.Args()
+	if *list != "" {
+		data, err := os.ReadFile(*list)
+		if err != nil {
+			return err
+		}
+		files = append(files, strings.Fields(string(data))...)
+	}
+
+	units, err := readFixesFiles(files)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeFixes(f, mergeFixes(units...)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func applyMain(args []string) error {
+	flags := flag.NewFlagSet("apply", flag.ExitOnError)
+	dryRun := flags.Bool("dry_run", false, "only print the files that would be changed")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	units, err := readFixesFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+	return applyFixes(os.Stdout, mergeFixes(units...), *dryRun)
+}
+
+func readFixesFiles(files []string) ([][]Diagnostic, error) {
+	var units [][]Diagnostic
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		diags, err := parseFixes(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		units = append(units, diags)
+	}
+	return units, nil
+}
@@ -0,0 +1,408 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file reads and writes the subset of YAML that clang-tidy uses for its exported fixes:
+// block mappings and sequences of plain, single quoted and double quoted scalars, where quoted
+// scalars may span several lines. Flow collections, anchors, tags and block scalars are not
+// supported, except for empty flow collections.
+
+// yamlNode is a parsed YAML node. Exactly one of the fields is set, except for empty nodes.
+type yamlNode struct {
+	scalar   *string
+	mapping  []yamlField
+	sequence []*yamlNode
+}
+
+type yamlField struct {
+	key   string
+	value *yamlNode
+}
+
+// get returns the value of the key of a mapping node, or nil.
+func (n *yamlNode) get(key string) *yamlNode {
+	if n == nil {
+		return nil
+	}
+	for _, f := range n.mapping {
+		if f.key == key {
+			return f.value
+		}
+	}
+	return nil
+}
+
+// str returns the value of a scalar node, or "" for other nodes.
+func (n *yamlNode) str() string {
+	if n == nil || n.scalar == nil {
+		return ""
+	}
+	return *n.scalar
+}
+
+// items returns the entries of a sequence node, or nil for other nodes.
+func (n *yamlNode) items() []*yamlNode {
+	if n == nil {
+		return nil
+	}
+	return n.sequence
+}
+
+// int returns the value of a scalar node as an int.
+func (n *yamlNode) int() (int, error) {
+	return strconv.Atoi(n.str())
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // The line without its indentation.
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYaml parses the first document of data.
+func parseYaml(data string) (*yamlNode, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(line) - len(trimmed), text: trimmed})
+	}
+
+	// Skip to the start of the first document.
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].indent == 0 && p.lines[p.pos].text == "---" {
+		p.pos++
+	}
+	p.skipBlank()
+	if p.atEnd() {
+		return &yamlNode{}, nil
+	}
+	node, err := p.parseBlock(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if !p.atEnd() {
+		return nil, p.errorf("unexpected content %q", p.lines[p.pos].text)
+	}
+	return node, nil
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	num := len(p.lines)
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	}
+	return fmt.Errorf("line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		text := strings.TrimSpace(p.lines[p.pos].text)
+		if text != "" && !strings.HasPrefix(text, "#") {
+			return
+		}
+		p.pos++
+	}
+}
+
+// atEnd returns true at the end of the input or of the current document.
+func (p *yamlParser) atEnd() bool {
+	if p.pos >= len(p.lines) {
+		return true
+	}
+	line := p.lines[p.pos]
+	return line.indent == 0 && (line.text == "..." || line.text == "---")
+}
+
+// parseBlock parses the mapping or sequence whose entries start at indent.
+func (p *yamlParser) parseBlock(indent int) (*yamlNode, error) {
+	if isSequenceEntry(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func isSequenceEntry(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSequence(indent int) (*yamlNode, error) {
+	node := &yamlNode{sequence: []*yamlNode{}}
+	for {
+		p.skipBlank()
+		if p.atEnd() || p.lines[p.pos].indent != indent || !isSequenceEntry(p.lines[p.pos].text) {
+			return node, nil
+		}
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			p.pos++
+			item, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			node.sequence = append(node.sequence, item)
+			continue
+		}
+		// The entry continues on the same line as the dash, so it is parsed as if the dash was
+		// indentation.
+		entryIndent := indent + len(line.text) - len(rest)
+		p.lines[p.pos] = yamlLine{num: line.num, indent: entryIndent, text: rest}
+		var item *yamlNode
+		var err error
+		if _, _, ok := splitMappingKey(rest); ok || isSequenceEntry(rest) {
+			item, err = p.parseBlock(entryIndent)
+		} else {
+			item, err = p.parseScalar(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.sequence = append(node.sequence, item)
+	}
+}
+
+func (p *yamlParser) parseMapping(indent int) (*yamlNode, error) {
+	node := &yamlNode{mapping: []yamlField{}}
+	for {
+		p.skipBlank()
+		if p.atEnd() || p.lines[p.pos].indent < indent {
+			return node, nil
+		}
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		if isSequenceEntry(line.text) {
+			return node, nil
+		}
+		key, rest, ok := splitMappingKey(line.text)
+		if !ok {
+			return nil, p.errorf("expected a mapping key in %q", line.text)
+		}
+		var value *yamlNode
+		var err error
+		if rest == "" {
+			p.pos++
+			value, err = p.parseNested(indent)
+		} else {
+			value, err = p.parseScalar(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		node.mapping = append(node.mapping, yamlField{key: key, value: value})
+	}
+}
+
+// parseNested parses the value of a mapping key or sequence entry that starts on the next line.
+// A sequence may be at the same indentation as its mapping key.
+func (p *yamlParser) parseNested(parentIndent int) (*yamlNode, error) {
+	p.skipBlank()
+	if p.atEnd() {
+		return &yamlNode{}, nil
+	}
+	line := p.lines[p.pos]
+	if line.indent > parentIndent || (line.indent == parentIndent && isSequenceEntry(line.text)) {
+		return p.parseBlock(line.indent)
+	}
+	return &yamlNode{}, nil
+}
+
+// splitMappingKey splits "key: value" into its key and value.
+func splitMappingKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, "'") || strings.HasPrefix(text, "\"") {
+		return "", "", false
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if strings.HasSuffix(text, ":") {
+			return strings.TrimSpace(strings.TrimSuffix(text, ":")), "", true
+		}
+		return "", "", false
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:]), true
+}
+
+// parseScalar parses the scalar that starts with text on the current line, consuming the following
+// lines of quoted scalars that span several lines.
+func (p *yamlParser) parseScalar(text string) (*yamlNode, error) {
+	quote := text[0]
+	if quote != '\'' && quote != '"' {
+		p.pos++
+		value := strings.TrimSpace(text)
+		switch value {
+		case "~", "null":
+			return &yamlNode{}, nil
+		case "[]":
+			return &yamlNode{sequence: []*yamlNode{}}, nil
+		case "{}":
+			return &yamlNode{mapping: []yamlField{}}, nil
+		}
+		return &yamlNode{scalar: &value}, nil
+	}
+
+	// Collect the lines of the quoted scalar until the closing quote.
+	var lines []string
+	content := text[1:]
+	for {
+		if end, ok := closingQuote(content, quote); ok {
+			if strings.TrimSpace(content[end+1:]) != "" {
+				return nil, p.errorf("unexpected content after quoted scalar: %q", content[end+1:])
+			}
+			lines = append(lines, content[:end])
+			p.pos++
+			break
+		}
+		lines = append(lines, content)
+		p.pos++
+		if p.pos >= len(p.lines) {
+			return nil, p.errorf("unterminated quoted scalar")
+		}
+		content = p.lines[p.pos].text
+	}
+
+	value, err := unquote(foldLines(lines), quote)
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	return &yamlNode{scalar: &value}, nil
+}
+
+// closingQuote returns the index of the quote that ends a quoted scalar in s.
+func closingQuote(s string, quote byte) (int, bool) {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote:
+			if quote == '\'' && i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// foldLines joins the lines of a quoted scalar: a line break between two lines becomes a space,
+// and each empty line becomes a newline.
+func foldLines(lines []string) string {
+	var b strings.Builder
+	empty := 0
+	for i, line := range lines {
+		if i > 0 {
+			line = strings.TrimLeft(line, " \t")
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimRight(line, " \t")
+		}
+		if i > 0 && i < len(lines)-1 && line == "" {
+			empty++
+			continue
+		}
+		if i > 0 {
+			if empty > 0 {
+				b.WriteString(strings.Repeat("\n", empty))
+			} else {
+				b.WriteByte(' ')
+			}
+			empty = 0
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func unquote(s string, quote byte) (string, error) {
+	if quote == '\'' {
+		return strings.ReplaceAll(s, "''", "'"), nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("invalid escape at the end of %q", s)
+		}
+		switch c := s[i]; c {
+		case '0':
+			b.WriteByte(0)
+		case 'a':
+			b.WriteByte('\a')
+		case 'b':
+			b.WriteByte('\b')
+		case 't', '\t':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'v':
+			b.WriteByte('\v')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case 'e':
+			b.WriteByte(0x1b)
+		case ' ', '"', '/', '\\':
+			b.WriteByte(c)
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+			if i+1+n > len(s) {
+				return "", fmt.Errorf("invalid escape in %q", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in %q", s)
+			}
+			if c == 'x' {
+				b.WriteByte(byte(r))
+			} else {
+				b.WriteRune(rune(r))
+			}
+			i += n
+		default:
+			return "", fmt.Errorf("invalid escape \\%c in %q", c, s)
+		}
+	}
+	return b.String(), nil
+}
+
+// quoteYaml returns s as a YAML scalar, single quoted unless it contains characters that need to
+// be escaped.
+func quoteYaml(s string) string {
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || !utf8.ValidString(s) {
+			return strconv.Quote(s)
+		}
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestParseYaml(t *testing.T) {
+	data := `---
+# A comment.
+Name:  plain value
+Single: 'it''s
+  folded
+
+  with a newline'
+Double: "tab\there\n\u00e9"
+Empty: []
+List:
+- a
+- key: b
+  other: 'c'
+  nested:
+    - d
+Last:
+...
+Ignored: true
+`
+	root, err := parseYaml(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkString := func(name, got, want string) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%s: want %q, got %q", name, want, got)
+		}
+	}
+	checkString("Name", root.get("Name").str(), "plain value")
+	checkString("Single", root.get("Single").str(), "it's folded\nwith a newline")
+	checkString("Double", root.get("Double").str(), "tab\there\n\u00e9")
+	if empty := root.get("Empty"); empty == nil || empty.sequence == nil || len(empty.sequence) != 0 {
+		t.Errorf("Empty: want an empty sequence, got %#v", empty)
+	}
+
+	list := root.get("List").sequence
+	if len(list) != 2 {
+		t.Fatalf("List: want 2 entries, got %d", len(list))
+	}
+	checkString("List[0]", list[0].str(), "a")
+	checkString("List[1].key", list[1].get("key").str(), "b")
+	checkString("List[1].other", list[1].get("other").str(), "c")
+	if nested := list[1].get("nested").sequence; len(nested) != 1 || nested[0].str() != "d" {
+		t.Errorf("List[1].nested: want [d], got %#v", nested)
+	}
+	if last := root.get("Last"); last == nil || last.scalar != nil {
+		t.Errorf("Last: want an empty node, got %#v", last)
+	}
+	if root.get("Ignored") != nil {
+		t.Errorf("Ignored: want the second document to be ignored")
+	}
+}
+
+func TestParseYamlErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{name: "unterminated", data: "Key: 'value\n"},
+		{name: "bad escape", data: "Key: \"\\q\"\n"},
+		{name: "short escape", data: "Key: \"\\u12\"\n"},
+		{name: "bad indentation", data: "Key: a\n  Other: b\n"},
+		{name: "not a mapping", data: "Key: a\nvalue\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := parseYaml(tc.data); err == nil {
+				t.Errorf("want an error")
+			}
+		})
+	}
+}
+
+func TestQuoteYaml(t *testing.T) {
+	for _, s := range []string{"", "plain", "it's", "line\nbreak", "tab\t", "#include <foo>", ": -"} {
+		root, err := parseYaml("Key: " + quoteYaml(s) + "\n")
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if got := root.get("Key").str(); got != s {
+			t.Errorf("%q: round trip gave %q", s, got)
+		}
+	}
+}
@@ -117,7 +117,11 @@ import (
 // Mismatch between build-time and run-time CLC is reported in logcat during boot (search with
 // `logcat | grep -E 'ClassLoaderContext [a-z ]+ mismatch'`. Mismatch is bad for performance, as it
 // forces the library/app to either be dexopted, or to run without any optimizations (e.g. the app's
-// code may need to be extracted in memory from the APK, a very expensive operation).
+// code may need to be extracted in memory from the APK, a very expensive operation). To find where
+// the CLCs diverge, run `dexpreopt_gen --explain_class_loader_context --module <dexpreopt.config>
+// --package_manager_state <file> --package <package>` with the output of
+// `adb shell dumpsys package dexopt`. It prints the build-time CLC with the module that provides
+// each library and the reason why it is in the CLC.
 //
 // A <uses-library> can be either optional or required. From dexpreopt standpoint, required library
 // must be present at build time (its absence is a build error). An optional library may be either
@@ -204,6 +208,15 @@ type ClassLoaderContext struct {
 
 	// Nested sub-CLC for dependencies.
 	Subcontexts []*ClassLoaderContext
+
+	// The module that provides the library (it may differ from the name of the library, e.g. for
+	// modules with `provides_uses_lib` property). Only used to explain the CLC.
+	Module string
+
+	// Why the library is in the CLC, e.g. "uses_libs of foo" or "libs dependency of foo". It names
+	// the module that added the library, as the CLC is shared with the modules that the library is
+	// propagated to. Only used to explain the CLC.
+	Reason string
 }
 
 // excludeLibs excludes the libraries from this ClassLoaderContext.
@@ -286,7 +299,8 @@ const AnySdkVersion int = android.FutureApiLevelInt
 
 // Add class loader context for the given library to the map entry for the given SDK version.
 func (clcMap ClassLoaderContextMap) addContext(ctx android.ModuleInstallPathContext, sdkVer int, lib string,
-	optional bool, hostPath, installPath android.Path, nestedClcMap ClassLoaderContextMap) error {
+	optional bool, hostPath, installPath android.Path, nestedClcMap ClassLoaderContextMap,
+	module, reason string) error {
 
 	// For prebuilts, library should have the same name as the source module.
 	lib = android.RemoveOptionalPrebuiltPrefix(lib)
@@ -338,6 +352,8 @@ func (clcMap ClassLoaderContextMap) addContext(ctx android.ModuleInstallPathCont
 		Host:        hostPath,
 		Device:      devicePath,
 		Subcontexts: subcontexts,
+		Module:      module,
+		Reason:      reason,
 	})
 	return nil
 }
@@ -349,7 +365,16 @@ func (clcMap ClassLoaderContextMap) addContext(ctx android.ModuleInstallPathCont
 func (clcMap ClassLoaderContextMap) AddContext(ctx android.ModuleInstallPathContext, sdkVer int,
 	lib string, optional bool, hostPath, installPath android.Path, nestedClcMap ClassLoaderContextMap) {
 
-	err := clcMap.addContext(ctx, sdkVer, lib, optional, hostPath, installPath, nestedClcMap)
+	clcMap.AddContextWithReason(ctx, sdkVer, lib, optional, hostPath, installPath, nestedClcMap, "", "")
+}
+
+// Same as AddContext, but also records the module that provides the library and the reason why
+// it is in the CLC, so that the CLC can be explained when it doesn't match the run-time CLC.
+func (clcMap ClassLoaderContextMap) AddContextWithReason(ctx android.ModuleInstallPathContext, sdkVer int,
+	lib string, optional bool, hostPath, installPath android.Path, nestedClcMap ClassLoaderContextMap,
+	module, reason string) {
+
+	err := clcMap.addContext(ctx, sdkVer, lib, optional, hostPath, installPath, nestedClcMap, module, reason)
 	if err != nil {
 		ctx.ModuleErrorf(err.Error())
 	}
@@ -420,6 +445,11 @@ func (clcMap ClassLoaderContextMap) Dump() string {
 
 func (clcMap ClassLoaderContextMap) DumpForFlag() string {
 	jsonCLC := toJsonClassLoaderContext(clcMap)
+	// The modules and the reasons are only used to explain the CLC. Don't pass them to
+	// construct_context.py, so that they don't affect the dexpreopt command.
+	for _, clcs := range jsonCLC {
+		clearJsonClassLoaderContextReasons(clcs)
+	}
 	bytes, err := json.Marshal(jsonCLC)
 	if err != nil {
 		panic(err)
@@ -585,11 +615,22 @@ type jsonClassLoaderContext struct {
 	Host        string
 	Device      string
 	Subcontexts []*jsonClassLoaderContext
+	Module      string `json:",omitempty"`
+	Reason      string `json:",omitempty"`
 }
 
 // A map from SDK version (represented with a JSON string) to JSON CLCs.
 type jsonClassLoaderContextMap map[string][]*jsonClassLoaderContext
 
+// Recursive helper for DumpForFlag that clears the modules and the reasons of JSON CLCs.
+func clearJsonClassLoaderContextReasons(jClcs []*jsonClassLoaderContext) {
+	for _, clc := range jClcs {
+		clc.Module = ""
+		clc.Reason = ""
+		clearJsonClassLoaderContextReasons(clc.Subcontexts)
+	}
+}
+
 // Convert JSON CLC map to Soong represenation.
 func fromJsonClassLoaderContext(ctx android.PathContext, jClcMap jsonClassLoaderContextMap) ClassLoaderContextMap {
 	clcMap := make(ClassLoaderContextMap)
@@ -617,6 +658,8 @@ func fromJsonClassLoaderContextRec(ctx android.PathContext, jClcs []*jsonClassLo
 			Host:        constructPath(ctx, clc.Host),
 			Device:      clc.Device,
 			Subcontexts: fromJsonClassLoaderContextRec(ctx, clc.Subcontexts),
+			Module:      clc.Module,
+			Reason:      clc.Reason,
 		})
 	}
 	return clcs
@@ -652,6 +695,8 @@ func toJsonClassLoaderContextRec(clcs []*ClassLoaderContext) []*jsonClassLoaderC
 			Host:        host,
 			Device:      clc.Device,
 			Subcontexts: toJsonClassLoaderContextRec(clc.Subcontexts),
+			Module:      clc.Module,
+			Reason:      clc.Reason,
 		}
 	}
 	return jClcs
@@ -0,0 +1,315 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dexpreopt
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"android/soong/android"
+)
+
+// ExplainedClassLoaderContext is a library in the CLC of a library/app, together with the
+// information on whether it is passed to dex2oat for a given targetSdkVersion. It mirrors the
+// filtering done by construct_context.py at Ninja time.
+type ExplainedClassLoaderContext struct {
+	Clc *ClassLoaderContext
+
+	// SDK version of the conditional CLC that the library is in, or AnySdkVersion.
+	SdkVersion int
+
+	// Why the library is not passed to dex2oat, or an empty string if it is.
+	Skipped string
+
+	Subcontexts []*ExplainedClassLoaderContext
+}
+
+// ExplainClassLoaderContext returns the libraries of the CLC in the order in which they are passed
+// to dex2oat for the given targetSdkVersion. Optional libraries are only passed to dex2oat if they
+// are in productPackages, unless productPackages is nil.
+func ExplainClassLoaderContext(clcMap ClassLoaderContextMap, targetSdkVersion int,
+	productPackages []string) []*ExplainedClassLoaderContext {
+
+	// Conditional CLC comes first in descending order of SDK versions, followed by the
+	// unconditional CLC (see construct_context.py).
+	var sdkVers []int
+	for sdkVer := range clcMap {
+		if sdkVer != AnySdkVersion {
+			sdkVers = append(sdkVers, sdkVer)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(sdkVers)))
+	if _, ok := clcMap[AnySdkVersion]; ok {
+		sdkVers = append(sdkVers, AnySdkVersion)
+	}
+
+	var ret []*ExplainedClassLoaderContext
+	for _, sdkVer := range sdkVers {
+		skipped := ""
+		if sdkVer != AnySdkVersion && sdkVer <= targetSdkVersion {
+			skipped = fmt.Sprintf("compatibility library is only needed for targetSdkVersion < %d", sdkVer)
+		}
+		ret = append(ret, explainClassLoaderContextRec(clcMap[sdkVer], sdkVer, skipped, productPackages)...)
+	}
+	return ret
+}
+
+// Helper function for ExplainClassLoaderContext() that handles recursion.
+func explainClassLoaderContextRec(clcs []*ClassLoaderContext, sdkVer int, skipped string,
+	productPackages []string) []*ExplainedClassLoaderContext {
+
+	ret := make([]*ExplainedClassLoaderContext, 0, len(clcs))
+	for _, clc := range clcs {
+		e := &ExplainedClassLoaderContext{Clc: clc, SdkVersion: sdkVer, Skipped: skipped}
+		if e.Skipped == "" && clc.Optional && productPackages != nil && !android.InList(clc.Name, productPackages) {
+			e.Skipped = "optional library is not in PRODUCT_PACKAGES"
+		}
+		if e.Skipped == "" {
+			e.Subcontexts = explainClassLoaderContextRec(clc.Subcontexts, AnySdkVersion, "", productPackages)
+		}
+		ret = append(ret, e)
+	}
+	return ret
+}
+
+// passedToDex2oat returns the libraries in the list that are passed to dex2oat.
+func passedToDex2oat(clcs []*ExplainedClassLoaderContext) []*ExplainedClassLoaderContext {
+	var ret []*ExplainedClassLoaderContext
+	for _, e := range clcs {
+		if e.Skipped == "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// String returns a description of the library with the module that provides it and the reason
+// why it is in the CLC.
+func (e *ExplainedClassLoaderContext) String() string {
+	var details []string
+	if e.Clc.Optional {
+		details = append(details, "optional")
+	}
+	if e.Clc.Module != "" && e.Clc.Module != e.Clc.Name {
+		details = append(details, "module "+e.Clc.Module)
+	}
+	if e.Clc.Reason != "" {
+		details = append(details, e.Clc.Reason)
+	}
+	if e.SdkVersion != AnySdkVersion {
+		details = append(details, fmt.Sprintf("conditional for targetSdkVersion < %d", e.SdkVersion))
+	}
+	s := e.Clc.Name + " " + e.Clc.Device
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+// WriteClassLoaderContextExplanation writes the CLC tree with the module that provides each
+// library and the reason why it is in the CLC.
+func WriteClassLoaderContextExplanation(w io.Writer, clcs []*ExplainedClassLoaderContext) {
+	fmt.Fprintln(w, StoredClassLoaderContext(clcs))
+	writeClassLoaderContextExplanationRec(w, clcs, "  ")
+}
+
+// Helper function for WriteClassLoaderContextExplanation() that handles recursion.
+func writeClassLoaderContextExplanationRec(w io.Writer, clcs []*ExplainedClassLoaderContext, indent string) {
+	for _, e := range clcs {
+		if e.Skipped != "" {
+			fmt.Fprintf(w, "%s%s: SKIPPED, %s\n", indent, e, e.Skipped)
+		} else {
+			fmt.Fprintf(w, "%s%s\n", indent, e)
+		}
+		writeClassLoaderContextExplanationRec(w, e.Subcontexts, indent+"  ")
+	}
+}
+
+// StoredClassLoaderContext returns the on-device CLC string for the libraries, in the format of
+// dex2oat --stored-class-loader-context.
+func StoredClassLoaderContext(clcs []*ExplainedClassLoaderContext) string {
+	return "PCL[]" + storedSharedLibraries(clcs)
+}
+
+// Helper function for StoredClassLoaderContext() that handles recursion.
+func storedSharedLibraries(clcs []*ExplainedClassLoaderContext) string {
+	var libs []string
+	for _, e := range passedToDex2oat(clcs) {
+		libs = append(libs, "PCL["+e.Clc.Device+"]"+storedSharedLibraries(e.Subcontexts))
+	}
+	if len(libs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(libs, "#") + "}"
+}
+
+// classLoaderSpec is a class loader in a CLC string, e.g. PCL[a.jar:b.jar]{PCL[c.jar]#PCL[d.jar]}.
+type classLoaderSpec struct {
+	// Class loader type, e.g. PCL for PathClassLoader or DLC for DelegateLastClassLoader.
+	Type string
+
+	// Dex file locations without checksums.
+	Classpath []string
+
+	// Each shared library is a chain of class loaders separated with ';'.
+	SharedLibraries [][]*classLoaderSpec
+}
+
+func (s *classLoaderSpec) String() string {
+	return s.Type + "[" + strings.Join(s.Classpath, ":") + "]"
+}
+
+// parseClassLoaderContextString parses a CLC string in the format used by dex2oat and by the
+// PackageManager, returning the class loader chain.
+func parseClassLoaderContextString(s string) ([]*classLoaderSpec, error) {
+	p := &clcParser{s: s}
+	chain, err := p.chain()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(s) {
+		return nil, fmt.Errorf("unexpected %q at offset %d of class loader context %q", s[p.pos:], p.pos, s)
+	}
+	return chain, nil
+}
+
+type clcParser struct {
+	s   string
+	pos int
+}
+
+func (p *clcParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at offset %d of class loader context %q", fmt.Sprintf(format, args...), p.pos, p.s)
+}
+
+func (p *clcParser) chain() ([]*classLoaderSpec, error) {
+	var ret []*classLoaderSpec
+	for {
+		spec, err := p.spec()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, spec)
+		if p.pos >= len(p.s) || p.s[p.pos] != ';' {
+			return ret, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *clcParser) spec() (*classLoaderSpec, error) {
+	open := strings.IndexByte(p.s[p.pos:], '[')
+	if open < 0 {
+		return nil, p.errorf("expected class loader type")
+	}
+	spec := &classLoaderSpec{Type: p.s[p.pos : p.pos+open]}
+	p.pos += open + 1
+
+	end := strings.IndexByte(p.s[p.pos:], ']')
+	if end < 0 {
+		return nil, p.errorf("missing ']'")
+	}
+	if classpath := p.s[p.pos : p.pos+end]; classpath != "" {
+		for _, dex := range strings.Split(classpath, ":") {
+			// Stored CLC in OAT files has checksums, e.g. /system/framework/foo.jar*1234.
+			dex, _, _ = strings.Cut(dex, "*")
+			spec.Classpath = append(spec.Classpath, dex)
+		}
+	}
+	p.pos += end + 1
+
+	if p.pos < len(p.s) && p.s[p.pos] == '{' {
+		p.pos++
+		for {
+			lib, err := p.chain()
+			if err != nil {
+				return nil, err
+			}
+			spec.SharedLibraries = append(spec.SharedLibraries, lib)
+			if p.pos >= len(p.s) {
+				return nil, p.errorf("missing '}'")
+			}
+			c := p.s[p.pos]
+			p.pos++
+			if c == '}' {
+				break
+			} else if c != '#' {
+				return nil, p.errorf("expected '#' or '}'")
+			}
+		}
+	}
+	return spec, nil
+}
+
+// CompareClassLoaderContext compares the libraries of the build-time CLC that are passed to
+// dex2oat with a run-time CLC string from the PackageManager, and returns the places where they
+// diverge, in the order in which the CLC is checked on device. The classpath of the top-level
+// class loader is not compared, as it contains the library/app itself on device.
+func CompareClassLoaderContext(clcs []*ExplainedClassLoaderContext, deviceClc string) ([]string, error) {
+	chain, err := parseClassLoaderContextString(deviceClc)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	if len(chain) != 1 {
+		diffs = append(diffs, fmt.Sprintf("expected 1 class loader, found %d: %s", len(chain), deviceClc))
+	}
+	if chain[0].Type != "PCL" {
+		diffs = append(diffs, fmt.Sprintf("expected top-level class loader PCL, found %s", chain[0].Type))
+	}
+	compareSharedLibraries("top level", passedToDex2oat(clcs), chain[0].SharedLibraries, &diffs)
+	return diffs, nil
+}
+
+// Helper function for CompareClassLoaderContext() that handles recursion.
+func compareSharedLibraries(where string, expected []*ExplainedClassLoaderContext,
+	actual [][]*classLoaderSpec, diffs *[]string) {
+
+	indexOf := func(device string) int {
+		for i, lib := range actual {
+			if lib[0].String() == "PCL["+device+"]" {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		if i >= len(actual) {
+			*diffs = append(*diffs, fmt.Sprintf("%s, library #%d: expected %s, but the library is missing on device",
+				where, i+1, expected[i]))
+			continue
+		}
+		found := actual[i][0].String()
+		if i >= len(expected) {
+			*diffs = append(*diffs, fmt.Sprintf("%s, library #%d: unexpected %s on device", where, i+1, found))
+			continue
+		}
+		e := expected[i]
+		if found != "PCL["+e.Clc.Device+"]" || len(actual[i]) != 1 {
+			diff := fmt.Sprintf("%s, library #%d: expected %s, found %s", where, i+1, e, found)
+			if j := indexOf(e.Clc.Device); j >= 0 && j != i {
+				diff += fmt.Sprintf(" (%s is library #%d on device)", e.Clc.Name, j+1)
+			}
+			*diffs = append(*diffs, diff)
+			continue
+		}
+		compareSharedLibraries(fmt.Sprintf("%s > %s", where, e.Clc.Name), passedToDex2oat(e.Subcontexts),
+			actual[i][0].SharedLibraries, diffs)
+	}
+}
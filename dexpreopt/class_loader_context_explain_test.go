@@ -0,0 +1,153 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dexpreopt
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func testExplainedClassLoaderContext() []*ExplainedClassLoaderContext {
+	ctx := testContext()
+
+	m1 := make(ClassLoaderContextMap)
+	m1.AddContextWithReason(ctx, AnySdkVersion, "d", false, buildPath(ctx, "d"), installPath(ctx, "d"), nil,
+		"d", "libs dependency of c-impl")
+
+	m := make(ClassLoaderContextMap)
+	m.AddContextWithReason(ctx, 28, "a", true, buildPath(ctx, "a"), installPath(ctx, "a"), nil,
+		"a", "compatibility library of foo")
+	m.AddContextWithReason(ctx, 30, "b", false, buildPath(ctx, "b"), installPath(ctx, "b"), nil,
+		"b", "compatibility library of foo")
+	m.AddContextWithReason(ctx, AnySdkVersion, "c", false, buildPath(ctx, "c"), installPath(ctx, "c"), m1,
+		"c-impl", "uses_libs of foo")
+	m.AddContextWithReason(ctx, AnySdkVersion, "e", true, buildPath(ctx, "e"), installPath(ctx, "e"), nil,
+		"e", "optional_uses_libs of foo")
+
+	return ExplainClassLoaderContext(m, 29, []string{"a", "c", "d"})
+}
+
+func TestExplainClassLoaderContext(t *testing.T) {
+	clcs := testExplainedClassLoaderContext()
+
+	android.AssertStringEquals(t, "stored CLC",
+		"PCL[]{PCL[/system/b.jar]#PCL[/system/c.jar]{PCL[/system/d.jar]}}", StoredClassLoaderContext(clcs))
+
+	buf := &strings.Builder{}
+	WriteClassLoaderContextExplanation(buf, clcs)
+	android.AssertStringEquals(t, "explanation", strings.TrimLeft(`
+PCL[]{PCL[/system/b.jar]#PCL[/system/c.jar]{PCL[/system/d.jar]}}
+  b /system/b.jar (compatibility library of foo, conditional for targetSdkVersion < 30)
+  a /system/a.jar (optional, compatibility library of foo, conditional for targetSdkVersion < 28): SKIPPED, compatibility library is only needed for targetSdkVersion < 28
+  c /system/c.jar (module c-impl, uses_libs of foo)
+    d /system/d.jar (libs dependency of c-impl)
+  e /system/e.jar (optional, optional_uses_libs of foo): SKIPPED, optional library is not in PRODUCT_PACKAGES
+`, "\n"), buf.String())
+}
+
+func TestCompareClassLoaderContext(t *testing.T) {
+	clcs := testExplainedClassLoaderContext()
+
+	testCases := []struct {
+		name      string
+		deviceClc string
+		diffs     []string
+	}{
+		{
+			name:      "match",
+			deviceClc: "PCL[/data/app/foo/base.apk*1234]{PCL[/system/b.jar*1]#PCL[/system/c.jar*2]{PCL[/system/d.jar*3]}}",
+		},
+		{
+			name:      "order",
+			deviceClc: "PCL[]{PCL[/system/c.jar]{PCL[/system/d.jar]}#PCL[/system/b.jar]}",
+			diffs: []string{
+				"top level, library #1: expected b /system/b.jar (compatibility library, conditional for targetSdkVersion < 30), found PCL[/system/c.jar] (b is library #2 on device)",
+				"top level, library #2: expected c /system/c.jar (module c-impl, uses_libs), found PCL[/system/b.jar] (c is library #1 on device)",
+			},
+		},
+		{
+			name:      "missing nested library",
+			deviceClc: "PCL[]{PCL[/system/b.jar]#PCL[/system/c.jar]}",
+			diffs: []string{
+				"top level > c, library #1: expected d /system/d.jar (libs dependency), but the library is missing on device",
+			},
+		},
+		{
+			name:      "unexpected library",
+			deviceClc: "PCL[]{PCL[/system/b.jar]#PCL[/system/c.jar]{PCL[/system/d.jar]}#PCL[/system/e.jar]}",
+			diffs: []string{
+				"top level, library #3: unexpected PCL[/system/e.jar] on device",
+			},
+		},
+		{
+			name:      "chain",
+			deviceClc: "PCL[];PCL[/system/foo.jar]{PCL[/system/b.jar]#PCL[/system/c.jar]{PCL[/system/d.jar]}}",
+			diffs: []string{
+				"expected 1 class loader, found 2: PCL[];PCL[/system/foo.jar]{PCL[/system/b.jar]#PCL[/system/c.jar]{PCL[/system/d.jar]}}",
+				"top level, library #1: expected b /system/b.jar (compatibility library, conditional for targetSdkVersion < 30), but the library is missing on device",
+				"top level, library #2: expected c /system/c.jar (module c-impl, uses_libs), but the library is missing on device",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diffs, err := CompareClassLoaderContext(clcs, tc.deviceClc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			android.AssertDeepEquals(t, "diffs", tc.diffs, diffs)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := CompareClassLoaderContext(clcs, "PCL[/system/a.jar]{PCL[/system/b.jar]")
+		checkError(t, err, `missing '}'`)
+	})
+}
+
+// Test that the modules and the reasons are written to the module config, but not passed to
+// construct_context.py.
+func TestCLCReasons(t *testing.T) {
+	ctx := testContext()
+	m1 := make(ClassLoaderContextMap)
+	m1.AddContextWithReason(ctx, AnySdkVersion, "a", false, buildPath(ctx, "a"), installPath(ctx, "a"), nil,
+		"a-impl", "libs dependency of b")
+
+	m := make(ClassLoaderContextMap)
+	m.AddContextMap(m1, "b")
+	android.AssertStringEquals(t, "output CLCM ", `{
+  "any": [
+    {
+      "Name": "a",
+      "Optional": false,
+      "Host": "out/soong/a.jar",
+      "Device": "/system/a.jar",
+      "Subcontexts": [],
+      "Module": "a-impl",
+      "Reason": "libs dependency of b"
+    }
+  ]
+}`, m.Dump())
+	android.AssertStringEquals(t, "CLC flag",
+		`'{"any":[{"Name":"a","Optional":false,"Host":"out/soong/a.jar","Device":"/system/a.jar","Subcontexts":[]}]}'`,
+		m.DumpForFlag())
+
+	// The CLC of the dependency is shared, not copied.
+	if m[AnySdkVersion][0] != m1[AnySdkVersion][0] {
+		t.Errorf("the CLC of the dependency is copied instead of shared")
+	}
+}
@@ -231,7 +231,7 @@ func TestCLCNestedConditional(t *testing.T) {
 	m1 := make(ClassLoaderContextMap)
 	m1.AddContext(ctx, 42, "a", optional, buildPath(ctx, "a"), installPath(ctx, "a"), nil)
 	m := make(ClassLoaderContextMap)
-	err := m.addContext(ctx, AnySdkVersion, "b", optional, buildPath(ctx, "b"), installPath(ctx, "b"), m1)
+	err := m.addContext(ctx, AnySdkVersion, "b", optional, buildPath(ctx, "b"), installPath(ctx, "b"), m1, "", "")
 	checkError(t, err, "nested class loader context shouldn't have conditional part")
 }
 
@@ -0,0 +1,129 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+// explainClassLoaderContext writes the class loader context of the module with the module that
+// provides each library and the reason why it is there. If pmStatePath is set, it also compares
+// the class loader context with the one from the PackageManager state and writes where they
+// diverge. It returns false if they diverge.
+func explainClassLoaderContext(w io.Writer, ctx android.PathContext, moduleConfigPath, productPackagesPath string,
+	targetSdkVersion int, pmStatePath, packageName string) (bool, error) {
+
+	moduleConfigData, err := os.ReadFile(moduleConfigPath)
+	if err != nil {
+		return false, err
+	}
+	module, err := dexpreopt.ParseModuleConfig(ctx, moduleConfigData)
+	if err != nil {
+		return false, fmt.Errorf("error parsing module config %q: %w", moduleConfigPath, err)
+	}
+
+	var productPackages []string
+	if productPackagesPath != "" {
+		data, err := os.ReadFile(productPackagesPath)
+		if err != nil {
+			return false, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				productPackages = append(productPackages, line)
+			}
+		}
+	}
+
+	clcs := dexpreopt.ExplainClassLoaderContext(module.ClassLoaderContexts, targetSdkVersion, productPackages)
+	fmt.Fprintf(w, "Class loader context of %s:\n", module.Name)
+	dexpreopt.WriteClassLoaderContextExplanation(w, clcs)
+
+	if pmStatePath == "" {
+		return true, nil
+	}
+
+	pmState, err := os.ReadFile(pmStatePath)
+	if err != nil {
+		return false, err
+	}
+	deviceClc, err := findClassLoaderContext(pmState, packageName)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", pmStatePath, err)
+	}
+	diffs, err := dexpreopt.CompareClassLoaderContext(clcs, deviceClc)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Fprintf(w, "\nClass loader context from the PackageManager:\n%s\n", deviceClc)
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "\nThe class loader contexts match.")
+		return true, nil
+	}
+	fmt.Fprintln(w, "\nThe class loader contexts diverge:")
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "  %s\n", diff)
+	}
+	return false, nil
+}
+
+// findClassLoaderContext returns the class loader context of a package from a PackageManager
+// state file. The file is either the output of `adb shell dumpsys package dexopt`, where the
+// "class loader context:" lines follow the "[<package>]" line of the package, or a file with
+// just the class loader context.
+func findClassLoaderContext(data []byte, packageName string) (string, error) {
+	isClc := func(s string) bool {
+		return strings.HasPrefix(s, "PCL[") || strings.HasPrefix(s, "DLC[") || strings.HasPrefix(s, "IMC[")
+	}
+
+	inPackage := packageName == ""
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		// Package headers are package names in brackets, unlike lines like "[location is ...]".
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.Contains(line, " ") {
+			inPackage = packageName == "" || line == "["+packageName+"]"
+			continue
+		}
+		if !inPackage {
+			continue
+		}
+		if isClc(line) {
+			return line, nil
+		}
+		if i := strings.Index(strings.ToLower(line), "class loader context:"); i >= 0 {
+			if clc := strings.TrimSpace(line[i+len("class loader context:"):]); isClc(clc) {
+				return clc, nil
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	if packageName != "" {
+		return "", fmt.Errorf("no class loader context for package %q", packageName)
+	}
+	return "", fmt.Errorf("no class loader context")
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+const dumpsysDexopt = `Dexopt state:
+  [com.example.foo]
+    path: /data/app/~~abc/com.example.foo-1/base.apk
+      arm64: [status=speed-profile] [reason=install]
+        [location is /data/app/~~abc/com.example.foo-1/oat/arm64/base.odex]
+    class loader context: PCL[]{PCL[/system/framework/android.test.base.jar]}
+  [com.example.bar]
+    path: /data/app/~~def/com.example.bar-1/base.apk
+      arm64: [status=verify] [reason=install]
+    class loader context: PCL[]{PCL[/system/framework/org.apache.http.legacy.jar]}
+`
+
+func TestFindClassLoaderContext(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        string
+		packageName string
+		want        string
+		wantErr     string
+	}{
+		{
+			name:        "dumpsys",
+			data:        dumpsysDexopt,
+			packageName: "com.example.bar",
+			want:        "PCL[]{PCL[/system/framework/org.apache.http.legacy.jar]}",
+		},
+		{
+			name: "dumpsys without package",
+			data: dumpsysDexopt,
+			want: "PCL[]{PCL[/system/framework/android.test.base.jar]}",
+		},
+		{
+			name:        "missing package",
+			data:        dumpsysDexopt,
+			packageName: "com.example.baz",
+			wantErr:     `no class loader context for package "com.example.baz"`,
+		},
+		{
+			name: "class loader context only",
+			data: "\nPCL[]{PCL[/system/framework/foo.jar]}\n",
+			want: "PCL[]{PCL[/system/framework/foo.jar]}",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := findClassLoaderContext([]byte(tc.data), tc.packageName)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
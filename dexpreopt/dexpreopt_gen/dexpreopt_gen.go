@@ -44,6 +44,17 @@ var (
 	// basePath indicates the path where target_files.zip is extracted.
 	basePath            = flag.String("base_path", ".", "base path where images and tools are extracted")
 	productPackagesPath = flag.String("product_packages", "", "path to product_packages.txt")
+
+	// explainClassLoaderContext prints the class loader context of the module with the reason for
+	// each library instead of writing the dexpreopt script, and compares it with the class loader
+	// context from the PackageManager if packageManagerState is set.
+	explainClassLoaderContextFlag = flag.Bool("explain_class_loader_context", false,
+		"print the class loader context of the module with the reason for each library")
+	targetSdkVersion = flag.Int("target_sdk_version", dexpreopt.AnySdkVersion,
+		"targetSdkVersion of the app, used with --explain_class_loader_context")
+	packageManagerState = flag.String("package_manager_state", "",
+		"file with the class loader context from the PackageManager (e.g. the output of `adb shell dumpsys package dexopt`) to compare with, used with --explain_class_loader_context")
+	packageName = flag.String("package", "", "package name of the app in --package_manager_state")
 )
 
 type builderContext struct {
@@ -72,6 +83,23 @@ func main() {
 		usage("unrecognized argument " + flag.Arg(0))
 	}
 
+	if *explainClassLoaderContextFlag {
+		if *moduleConfigPath == "" {
+			usage("--module configuration file is required")
+		}
+		ctx := &builderContext{android.NullConfig(*outDir, *outDir)}
+		match, err := explainClassLoaderContext(os.Stdout, ctx, *moduleConfigPath, *productPackagesPath,
+			*targetSdkVersion, *packageManagerState, *packageName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(2)
+		}
+		if !match {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if *dexpreoptScriptPath == "" {
 		usage("path to output dexpreopt script is required")
 	}
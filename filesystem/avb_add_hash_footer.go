@@ -57,7 +57,8 @@ type avbAddHashFooterProperties struct {
 	// Size of the partition. Defaults to dynamically calculating the size.
 	Partition_size *int64
 
-	// Path to the private key that avbtool will use to sign this image.
+	// Path to the private key that avbtool will use to sign this image. "external:<name>"
+	// references an external_signer module that avbtool will sign with instead.
 	Private_key *string `android:"path"`
 
 	// Algorithm that avbtool will use to sign this image. Default is SHA256_RSA4096.
@@ -85,6 +86,10 @@ func avbAddHashFooterFactory() android.Module {
 	return module
 }
 
+func (a *avbAddHashFooter) DepsMutator(ctx android.BottomUpMutatorContext) {
+	android.AddExternalSignerDependency(ctx, a.properties.Private_key)
+}
+
 func (a *avbAddHashFooter) installFileName() string {
 	return proptools.StringDefault(a.properties.Filename, a.BaseModuleName()+".img")
 }
@@ -112,8 +117,7 @@ func (a *avbAddHashFooter) GenerateAndroidBuildActions(ctx android.ModuleContext
 		cmd.FlagWithArg("--partition_size ", strconv.Itoa(partition_size))
 	}
 
-	key := android.PathForModuleSrc(ctx, proptools.String(a.properties.Private_key))
-	cmd.FlagWithInput("--key ", key)
+	addAvbKey(ctx, cmd, a.properties.Private_key)
 
 	algorithm := proptools.StringDefault(a.properties.Algorithm, "SHA256_RSA4096")
 	cmd.FlagWithArg("--algorithm ", algorithm)
@@ -149,6 +153,20 @@ func (a *avbAddHashFooter) GenerateAndroidBuildActions(ctx android.ModuleContext
 	ctx.InstallFile(a.installDir, a.installFileName(), a.output)
 }
 
+// Adds the flags with which avbtool signs with either the private key file or the external signer
+// that the private_key property references.
+func addAvbKey(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, privateKey *string) {
+	if android.ExternalSignerName(privateKey) == "" {
+		cmd.FlagWithInput("--key ", android.PathForModuleSrc(ctx, proptools.String(privateKey)))
+		return
+	}
+	if info := android.ExternalSignerFromDeps(ctx, "private_key", true); info != nil {
+		cmd.FlagWithInput("--key ", info.PublicKey).
+			Implicit(info.Tool).
+			Text(info.AvbtoolFlags())
+	}
+}
+
 func addAvbProp(ctx android.ModuleContext, cmd *android.RuleBuilderCommand, prop avbProp) {
 	name := proptools.String(prop.Name)
 	value := proptools.String(prop.Value)
@@ -25,10 +25,6 @@ import (
 	"android/soong/android"
 )
 
-func init() {
-	android.RegisterModuleType("bootimg", bootimgFactory)
-}
-
 type bootimg struct {
 	android.ModuleBase
 
@@ -51,6 +47,11 @@ type bootimgProperties struct {
 	// Path to the device tree blob (DTB) prebuilt file to add to this boot image
 	Dtb_prebuilt *string `android:"arch_variant,path"`
 
+	// Paths to device tree blob (DTB) prebuilt files that are concatenated, after dtb_prebuilt
+	// if set, into the DTB of this boot image. The overlays of the DTB are not in the boot image
+	// since header version 3, see dtboimg.
+	Dtb_prebuilts []string `android:"arch_variant,path"`
+
 	// Header version number. Must be set to one of the version numbers that are currently
 	// supported. Refer to
 	// https://source.android.com/devices/bootloader/boot-image-header
@@ -67,6 +68,15 @@ type bootimgProperties struct {
 	// and `header_version` is greater than or equal to 4.
 	Bootconfig *string `android:"arch_variant,path"`
 
+	// Vendor ramdisk fragments to add to this image, in addition to ramdisk_module. This can be
+	// set only when `vendor_boot` is true and `header_version` is greater than or equal to 4.
+	Vendor_ramdisk_fragments []vendorRamdiskFragmentProperties
+
+	// When set to true, the image is unpacked with unpack_bootimg and repacked with mkbootimg,
+	// whenever it is built, and the build fails if the result differs from the image. Default is
+	// true.
+	Verify_round_trip *bool
+
 	// When set to true, sign the image with avbtool. Default is false.
 	Use_avb *bool
 
@@ -81,6 +91,19 @@ type bootimgProperties struct {
 	Avb_algorithm *string
 }
 
+type vendorRamdiskFragmentProperties struct {
+	// Name of the fragment in the vendor ramdisk table. Must be unique in this image.
+	Name *string
+
+	// Type of the fragment. One of "none", "platform", "recovery" or "dlkm". Default is "none".
+	Type *string
+
+	// Filesystem module of type cpio or compressed_cpio that is used as the fragment
+	Ramdisk_module *string
+}
+
+var vendorRamdiskFragmentTypes = []string{"none", "platform", "recovery", "dlkm"}
+
 // bootimg is the image for the boot partition. It consists of header, kernel, ramdisk, and dtb.
 func bootimgFactory() android.Module {
 	module := &bootimg{}
@@ -95,12 +118,18 @@ type bootimgDep struct {
 }
 
 var bootimgRamdiskDep = bootimgDep{kind: "ramdisk"}
+var bootimgVendorRamdiskFragmentDep = bootimgDep{kind: "vendor_ramdisk_fragment"}
 
 func (b *bootimg) DepsMutator(ctx android.BottomUpMutatorContext) {
 	ramdisk := proptools.String(b.properties.Ramdisk_module)
 	if ramdisk != "" {
 		ctx.AddDependency(ctx.Module(), bootimgRamdiskDep, ramdisk)
 	}
+	for _, fragment := range b.properties.Vendor_ramdisk_fragments {
+		if ramdisk := proptools.String(fragment.Ramdisk_module); ramdisk != "" {
+			ctx.AddDependency(ctx.Module(), bootimgVendorRamdiskFragmentDep, ramdisk)
+		}
+	}
 }
 
 func (b *bootimg) installFileName() string {
@@ -144,9 +173,7 @@ func (b *bootimg) buildBootImage(ctx android.ModuleContext, vendor bool) android
 		cmd.FlagWithInput("--kernel ", android.PathForModuleSrc(ctx, kernel))
 	}
 
-	dtbName := proptools.String(b.properties.Dtb_prebuilt)
-	if dtbName != "" {
-		dtb := android.PathForModuleSrc(ctx, dtbName)
+	if dtb := b.buildDtb(ctx); dtb != nil {
 		cmd.FlagWithInput("--dtb ", dtb)
 	}
 
@@ -190,6 +217,20 @@ func (b *bootimg) buildBootImage(ctx android.ModuleContext, vendor bool) android
 		}
 	}
 
+	if len(b.properties.Vendor_ramdisk_fragments) > 0 {
+		if !vendor {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments", "requires vendor_boot: true")
+			return output
+		}
+		if verNum < 4 {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments", "requires header_version: 4 or later")
+			return output
+		}
+		if !b.addVendorRamdiskFragments(ctx, cmd) {
+			return output
+		}
+	}
+
 	bootconfig := proptools.String(b.properties.Bootconfig)
 	if bootconfig != "" {
 		if !vendor {
@@ -209,10 +250,119 @@ func (b *bootimg) buildBootImage(ctx android.ModuleContext, vendor bool) android
 	}
 	cmd.FlagWithOutput(flag, output)
 
+	if proptools.BoolDefault(b.properties.Verify_round_trip, true) {
+		cmd.Validation(b.verifyRoundTrip(ctx, output, vendor))
+	}
+
 	builder.Build("build_bootimg", fmt.Sprintf("Creating %s", b.BaseModuleName()))
 	return output
 }
 
+// Returns the DTB of this image, which is dtb_prebuilt followed by dtb_prebuilts. Returns nil if
+// there is no DTB.
+func (b *bootimg) buildDtb(ctx android.ModuleContext) android.Path {
+	var dtbs android.Paths
+	if dtbName := proptools.String(b.properties.Dtb_prebuilt); dtbName != "" {
+		dtbs = append(dtbs, android.PathForModuleSrc(ctx, dtbName))
+	}
+	dtbs = append(dtbs, android.PathsForModuleSrc(ctx, b.properties.Dtb_prebuilts)...)
+
+	switch len(dtbs) {
+	case 0:
+		return nil
+	case 1:
+		return dtbs[0]
+	}
+	dtb := android.PathForModuleOut(ctx, "dtb.img")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        android.Cat,
+		Description: "concatenate DTBs",
+		Inputs:      dtbs,
+		Output:      dtb,
+	})
+	return dtb
+}
+
+// Adds the vendor ramdisk fragments to the mkbootimg command. Returns false if there was an error.
+func (b *bootimg) addVendorRamdiskFragments(ctx android.ModuleContext, cmd *android.RuleBuilderCommand) bool {
+	names := make(map[string]bool)
+	for _, fragment := range b.properties.Vendor_ramdisk_fragments {
+		name := proptools.String(fragment.Name)
+		if name == "" {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments.name", "must be set")
+			return false
+		}
+		if names[name] {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments.name", "%q already exists", name)
+			return false
+		}
+		names[name] = true
+
+		fragmentType := proptools.StringDefault(fragment.Type, "none")
+		if !android.InList(fragmentType, vendorRamdiskFragmentTypes) {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments.type", "%q is not one of %q", fragmentType,
+				vendorRamdiskFragmentTypes)
+			return false
+		}
+
+		ramdiskName := proptools.String(fragment.Ramdisk_module)
+		if ramdiskName == "" {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments.ramdisk_module", "must be set")
+			return false
+		}
+		ramdisk := ctx.GetDirectDepWithTag(ramdiskName, bootimgVendorRamdiskFragmentDep)
+		filesystem, ok := ramdisk.(*filesystem)
+		if !ok {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments.ramdisk_module", "%q is not android_filesystem module", ramdiskName)
+			return false
+		}
+		if t := filesystem.fsType(ctx); t != cpioType && t != compressedCpioType {
+			ctx.PropertyErrorf("vendor_ramdisk_fragments.ramdisk_module", "%q must be of type cpio or compressed_cpio", ramdiskName)
+			return false
+		}
+
+		cmd.FlagWithArg("--ramdisk_type ", fragmentType)
+		cmd.FlagWithArg("--ramdisk_name ", proptools.ShellEscape(name))
+		cmd.FlagWithInput("--vendor_ramdisk_fragment ", filesystem.OutputPath())
+	}
+	return true
+}
+
+// Unpacks the image with unpack_bootimg, repacks it with the mkbootimg arguments that
+// unpack_bootimg reports, and checks that the result is identical to the image. The arguments are
+// NUL separated and passed to mkbootimg with xargs, so that they are not evaluated by the shell.
+// Returns a timestamp file that is created when the check passes.
+func (b *bootimg) verifyRoundTrip(ctx android.ModuleContext, image android.Path, vendor bool) android.Path {
+	unpackDir := android.PathForModuleOut(ctx, "round_trip", "unpacked")
+	mkbootimgArgs := android.PathForModuleOut(ctx, "round_trip", "mkbootimg_args.txt")
+	repacked := android.PathForModuleOut(ctx, "round_trip", b.installFileName())
+	timestamp := android.PathForModuleOut(ctx, "round_trip", "verified.timestamp")
+
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().Text("rm -rf").Text(unpackDir.String())
+	builder.Command().BuiltTool("unpack_bootimg").
+		FlagWithInput("--boot_img ", image).
+		FlagWithArg("--out ", unpackDir.String()).
+		Flag("--format=mkbootimg").
+		Flag("--null").
+		Text(">").Output(mkbootimgArgs)
+
+	flag := "--output "
+	if vendor {
+		flag = "--vendor_boot "
+	}
+	builder.Command().
+		Text("xargs -0 -x").
+		BuiltTool("mkbootimg").
+		FlagWithOutput(flag, repacked).
+		Text("<").Input(mkbootimgArgs)
+	builder.Command().Text("cmp").Input(image).Text(repacked.String())
+	builder.Command().Text("touch").Output(timestamp)
+
+	builder.Build("verify_bootimg_round_trip", fmt.Sprintf("Verifying unpack/repack round trip of %s", b.BaseModuleName()))
+	return timestamp
+}
+
 func (b *bootimg) signImage(ctx android.ModuleContext, unsignedImage android.OutputPath) android.OutputPath {
 	propFile, toolDeps := b.buildPropFile(ctx)
 
@@ -0,0 +1,117 @@
+// Copyright (C) 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"fmt"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+type dtboimg struct {
+	android.ModuleBase
+
+	properties dtboimgProperties
+
+	output     android.OutputPath
+	installDir android.InstallPath
+}
+
+type dtboimgProperties struct {
+	// Set the name of the output. Defaults to <module_name>.img.
+	Stem *string
+
+	// Paths to the device tree blob overlay (DTBO) prebuilt files to add to this image, in order.
+	Dtbo_prebuilts []string `android:"arch_variant,path"`
+
+	// Page size of the image. Default is the one of mkdtboimg, 2048.
+	Page_size *int64
+}
+
+// dtboimg is the image for the dtbo partition, which has the device tree blob overlays that the
+// bootloader applies to the DTB of the vendor_boot image. Refer to
+// https://source.android.com/docs/core/architecture/dto/partitions
+func dtboimgFactory() android.Module {
+	module := &dtboimg{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
+	return module
+}
+
+func (d *dtboimg) installFileName() string {
+	return proptools.StringDefault(d.properties.Stem, d.BaseModuleName()+".img")
+}
+
+func (d *dtboimg) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	dtbos := android.PathsForModuleSrc(ctx, d.properties.Dtbo_prebuilts)
+	if len(dtbos) == 0 {
+		ctx.PropertyErrorf("dtbo_prebuilts", "must not be empty")
+		return
+	}
+
+	d.output = android.PathForModuleOut(ctx, d.installFileName()).OutputPath
+	builder := android.NewRuleBuilder(pctx, ctx)
+	cmd := builder.Command().BuiltTool("mkdtboimg").Text("create").Output(d.output)
+	if d.properties.Page_size != nil {
+		if *d.properties.Page_size <= 0 {
+			ctx.PropertyErrorf("page_size", "must be a positive number")
+			return
+		}
+		cmd.Flag(fmt.Sprintf("--page_size=%d", *d.properties.Page_size))
+	}
+	cmd.Inputs(dtbos)
+	builder.Build("build_dtboimg", fmt.Sprintf("Creating %s", d.BaseModuleName()))
+
+	d.installDir = android.PathForModuleInstall(ctx, "etc")
+	ctx.InstallFile(d.installDir, d.installFileName(), d.output)
+}
+
+var _ android.AndroidMkEntriesProvider = (*dtboimg)(nil)
+
+// Implements android.AndroidMkEntriesProvider
+func (d *dtboimg) AndroidMkEntries() []android.AndroidMkEntries {
+	return []android.AndroidMkEntries{{
+		Class:      "ETC",
+		OutputFile: android.OptionalPathForPath(d.output),
+		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
+				entries.SetString("LOCAL_MODULE_PATH", d.installDir.String())
+				entries.SetString("LOCAL_INSTALLED_MODULE_STEM", d.installFileName())
+			},
+		},
+	}}
+}
+
+var _ Filesystem = (*dtboimg)(nil)
+
+func (d *dtboimg) OutputPath() android.Path {
+	return d.output
+}
+
+func (d *dtboimg) SignedOutputPath() android.Path {
+	return nil
+}
+
+var _ android.OutputFileProducer = (*dtboimg)(nil)
+
+// Implements android.OutputFileProducer
+func (d *dtboimg) OutputFiles(tag string) (android.Paths, error) {
+	if tag == "" {
+		return []android.Path{d.output}, nil
+	}
+	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+}
@@ -0,0 +1,184 @@
+// Copyright (C) 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/linkerconfig"
+)
+
+// elfDepsNamespace is the linker configuration of a partition or an APEX, as understood by
+// check_elf_deps.
+type elfDepsNamespace struct {
+	Name        string   `json:"name"`
+	ProvideLibs []string `json:"provideLibs"`
+	RequireLibs []string `json:"requireLibs"`
+
+	// linker.config.json whose provideLibs and requireLibs are added to the namespace
+	linkerConfigSrc android.Path
+}
+
+type elfDepsEntry struct {
+	Partition string `json:"partition"`
+	Path      string `json:"path"`
+	File      string `json:"file,omitempty"`
+	Module    string `json:"module,omitempty"`
+}
+
+type elfDepsConfig struct {
+	Entries    []elfDepsEntry     `json:"entries"`
+	Namespaces []elfDepsNamespace `json:"namespaces"`
+}
+
+// ApexNamespaceInfo is provided by APEXes so that the images that install them can check the
+// native dependencies of their payload.
+type ApexNamespaceInfo struct {
+	// Name of the APEX, which is the first component of the paths of its files in the "apex"
+	// partition
+	Name string
+
+	// Libraries that the APEX provides to and requires from other namespaces, i.e.
+	// provideNativeLibs and requireNativeLibs of its apex_manifest
+	ProvideLibs []string
+	RequireLibs []string
+
+	// The source linker configuration file in JSON of the APEX, if any
+	LinkerConfigSrc android.Path
+}
+
+var ApexNamespaceInfoProvider = blueprint.NewProvider(ApexNamespaceInfo{})
+
+func (f *filesystem) shouldCheckElfDeps() bool {
+	return proptools.Bool(f.properties.Check_elf_deps) || proptools.Bool(f.properties.Check_linker_config)
+}
+
+// Returns the namespaces configured by the linker_config modules installed in this filesystem.
+// The linker_config modules of APEXes are not installed by themselves, and are returned by
+// apexNamespaces instead.
+func (f *filesystem) linkerConfigModuleNamespaces(ctx android.ModuleContext, specs map[string]android.PackagingSpec) []elfDepsNamespace {
+	var ret []elfDepsNamespace
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		if !ctx.OtherModuleHasProvider(child, linkerconfig.LinkerConfigInfoProvider) {
+			return true
+		}
+		info := ctx.OtherModuleProvider(child, linkerconfig.LinkerConfigInfoProvider).(linkerconfig.LinkerConfigInfo)
+		for _, ps := range child.PackagingSpecs() {
+			if _, ok := specs[ps.RelPathInPackage()]; ok && ps.Partition() != "apex" {
+				ret = append(ret, elfDepsNamespace{Name: ps.Partition(), linkerConfigSrc: info.Src})
+			}
+		}
+		return true
+	})
+	return ret
+}
+
+// Returns the namespaces of the APEXes installed in this filesystem.
+func (f *filesystem) apexNamespaces(ctx android.ModuleContext, specs map[string]android.PackagingSpec) []elfDepsNamespace {
+	namespaces := make(map[string]elfDepsNamespace)
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		if !ctx.OtherModuleHasProvider(child, ApexNamespaceInfoProvider) {
+			return true
+		}
+		info := ctx.OtherModuleProvider(child, ApexNamespaceInfoProvider).(ApexNamespaceInfo)
+		for _, ps := range child.PackagingSpecs() {
+			if _, ok := specs[ps.RelPathInPackage()]; ok && ps.Partition() == "apex" {
+				namespaces[info.Name] = elfDepsNamespace{
+					Name:            info.Name,
+					ProvideLibs:     info.ProvideLibs,
+					RequireLibs:     info.RequireLibs,
+					linkerConfigSrc: info.LinkerConfigSrc,
+				}
+				break
+			}
+		}
+		return true
+	})
+	var ret []elfDepsNamespace
+	for _, name := range android.SortedKeys(namespaces) {
+		ret = append(ret, namespaces[name])
+	}
+	return ret
+}
+
+// Creates a rule that checks that the DT_NEEDED entries of the ELF files in this filesystem,
+// including the ones in APEXes, resolve to libraries that are visible under the linker namespace
+// configuration, and that executables have no undefined symbols. With check_linker_config, the
+// linker configuration itself is also checked against the contents. The namespace graph is
+// rendered in the DOT language. Returns the report, which is expected to be used as a validation
+// of the image.
+func (f *filesystem) checkElfDeps(ctx android.ModuleContext, specs map[string]android.PackagingSpec) android.Path {
+	// The files of the APEXes are installed in the "apex" fake partition, which may have been
+	// discarded from specs by filterPackagingSpecs. They are checked nonetheless, as the image
+	// activates the APEXes.
+	allSpecs := f.PackagingBase.GatherPackagingSpecs(ctx)
+	var config elfDepsConfig
+	var inputs android.Paths
+	for _, k := range android.SortedKeys(allSpecs) {
+		ps := allSpecs[k]
+		if _, ok := specs[k]; !ok && ps.Partition() != "apex" {
+			continue
+		}
+		e := elfDepsEntry{
+			Partition: ps.Partition(),
+			Path:      ps.RelPathInPackage(),
+			Module:    ps.Owner(),
+		}
+		if ps.SrcPath() != nil {
+			e.File = ps.SrcPath().String()
+			inputs = append(inputs, ps.SrcPath())
+		}
+		config.Entries = append(config.Entries, e)
+	}
+	if f.linkerConfigNamespaces != nil {
+		config.Namespaces = f.linkerConfigNamespaces(ctx)
+	}
+	config.Namespaces = append(config.Namespaces, f.linkerConfigModuleNamespaces(ctx, specs)...)
+	config.Namespaces = append(config.Namespaces, f.apexNamespaces(ctx, allSpecs)...)
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		ctx.ModuleErrorf("failed to marshal native dependency check config: %s", err)
+		return nil
+	}
+	configFile := android.PathForModuleOut(ctx, "elf_deps", "config.json")
+	android.WriteFileRule(ctx, configFile, string(configJson))
+
+	report := android.PathForModuleOut(ctx, "elf_deps", "report.txt")
+	graph := android.PathForModuleOut(ctx, "elf_deps", "linker_namespaces.dot")
+	builder := android.NewRuleBuilder(pctx, ctx)
+	cmd := builder.Command().
+		BuiltTool("check_elf_deps").
+		FlagWithInput("--config ", configFile).
+		Implicits(android.FirstUniquePaths(inputs))
+	for _, ns := range config.Namespaces {
+		if ns.linkerConfigSrc != nil {
+			cmd.FlagWithInput("--linker_config "+ns.Name+"=", ns.linkerConfigSrc)
+		}
+	}
+	if proptools.Bool(f.properties.Check_linker_config) {
+		cmd.Flag("--check_linker_config")
+	}
+	cmd.FlagWithOutput("--dot ", graph)
+	cmd.FlagWithOutput("--output ", report)
+	builder.Build("check_elf_deps", fmt.Sprintf("Checking native dependencies of %s", f.BaseModuleName()))
+	f.linkerNamespacesGraph = graph
+	return report
+}
@@ -39,6 +39,10 @@ func registerBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("avb_add_hash_footer_defaults", avbAddHashFooterDefaultsFactory)
 	ctx.RegisterModuleType("avb_gen_vbmeta_image", avbGenVbmetaImageFactory)
 	ctx.RegisterModuleType("avb_gen_vbmeta_image_defaults", avbGenVbmetaImageDefaultsFactory)
+	ctx.RegisterModuleType("bootimg", bootimgFactory)
+	ctx.RegisterModuleType("dtboimg", dtboimgFactory)
+	ctx.RegisterModuleType("logical_partition", logicalPartitionFactory)
+	ctx.RegisterParallelSingletonType("partition_budgets", partitionBudgetsSingletonFactory)
 }
 
 type filesystem struct {
@@ -53,9 +57,20 @@ type filesystem struct {
 	// Function that filters PackagingSpecs returned by PackagingBase.GatherPackagingSpecs()
 	filterPackagingSpecs func(specs map[string]android.PackagingSpec)
 
+	// Function that returns the linker configuration of the partitions and APEXes in this
+	// filesystem. Used when checking the native dependencies.
+	linkerConfigNamespaces func(ctx android.ModuleContext) []elfDepsNamespace
+
 	output     android.OutputPath
 	installDir android.InstallPath
 
+	// Listing of the contents of the image. See buildManifest.
+	manifest android.OutputPath
+
+	// Graph of the linker namespaces in the DOT language. Nil if the native dependencies are not
+	// checked.
+	linkerNamespacesGraph android.Path
+
 	// For testing. Keeps the result of CopyDepsToZip()
 	entries []string
 }
@@ -109,6 +124,17 @@ type filesystemProperties struct {
 
 	// Mount point for this image. Default is "/"
 	Mount_point *string
+
+	// When set to true, check that the shared libraries needed by each ELF file in this image,
+	// including the ones in APEXes, are installed and visible under the linker namespace
+	// configuration, and that executables have no undefined symbols. Default is false.
+	Check_elf_deps *bool
+
+	// When set to true, also check that the libraries in provideLibs of the linker configurations
+	// of this image, including the ones installed by linker_config modules, are installed by the
+	// owning partition or APEX, and that every library in requireLibs is provided by another one.
+	// Implies check_elf_deps. Default is false.
+	Check_linker_config *bool
 }
 
 // android_filesystem packages a set of modules and their transitive dependencies into a filesystem
@@ -167,18 +193,30 @@ func (f *filesystem) installFileName() string {
 
 var pctx = android.NewPackageContext("android/soong/filesystem")
 
+// FilesystemInfo is provided by filesystem modules so that the modules that assemble them (e.g.
+// logical_partition) can reason about their contents before the images are built.
+type FilesystemInfo struct {
+	// Items packaged into the image, keyed by their path relative to Base_dir
+	Specs map[string]android.PackagingSpec
+}
+
+var FilesystemInfoProvider = blueprint.NewProvider(FilesystemInfo{})
+
 func (f *filesystem) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	specs := f.gatherFilteredPackagingSpecs(ctx)
 	switch f.fsType(ctx) {
 	case ext4Type:
-		f.output = f.buildImageUsingBuildImage(ctx)
+		f.output = f.buildImageUsingBuildImage(ctx, specs)
 	case compressedCpioType:
-		f.output = f.buildCpioImage(ctx, true)
+		f.output = f.buildCpioImage(ctx, specs, true)
 	case cpioType:
-		f.output = f.buildCpioImage(ctx, false)
+		f.output = f.buildCpioImage(ctx, specs, false)
 	default:
 		return
 	}
 
+	ctx.SetProvider(FilesystemInfoProvider, FilesystemInfo{Specs: specs})
+
 	f.installDir = android.PathForModuleInstall(ctx, "etc")
 	ctx.InstallFile(f.installDir, f.installFileName(), f.output)
 }
@@ -251,9 +289,9 @@ func (f *filesystem) buildRootZip(ctx android.ModuleContext) android.OutputPath
 	return zipOut
 }
 
-func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext) android.OutputPath {
+func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext, specs map[string]android.PackagingSpec) android.OutputPath {
 	depsZipFile := android.PathForModuleOut(ctx, "deps.zip").OutputPath
-	f.entries = f.CopyDepsToZip(ctx, f.gatherFilteredPackagingSpecs(ctx), depsZipFile)
+	f.entries = f.CopyDepsToZip(ctx, specs, depsZipFile)
 
 	builder := android.NewRuleBuilder(pctx, ctx)
 	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
@@ -280,14 +318,24 @@ func (f *filesystem) buildImageUsingBuildImage(ctx android.ModuleContext) androi
 		BuiltTool("host_init_verifier").
 		FlagWithArg("--out_system=", rootDir.String()+"/system")
 
-	propFile, toolDeps := f.buildPropFile(ctx)
+	var fileContexts android.Path
+	if proptools.String(f.properties.File_contexts) != "" {
+		fileContexts = f.buildFileContexts(ctx)
+	}
+
+	propFile, toolDeps := f.buildPropFile(ctx, fileContexts)
 	output := android.PathForModuleOut(ctx, f.installFileName()).OutputPath
-	builder.Command().BuiltTool("build_image").
+	cmd := builder.Command().BuiltTool("build_image").
 		Text(rootDir.String()). // input directory
 		Input(propFile).
 		Implicits(toolDeps).
 		Output(output).
 		Text(rootDir.String()) // directory where to find fs_config_files|dirs
+	if f.shouldCheckElfDeps() {
+		cmd.Validation(f.checkElfDeps(ctx, specs))
+	}
+
+	f.manifest = f.buildManifest(ctx, builder, rootDir, specs, fileContexts)
 
 	// rootDir is not deleted. Might be useful for quick inspection.
 	builder.Build("build_filesystem_image", fmt.Sprintf("Creating filesystem %s", f.BaseModuleName()))
@@ -310,7 +358,7 @@ func (f *filesystem) salt() string {
 	return sha1sum(f.entries)
 }
 
-func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.OutputPath, toolDeps android.Paths) {
+func (f *filesystem) buildPropFile(ctx android.ModuleContext, fileContexts android.Path) (propFile android.OutputPath, toolDeps android.Paths) {
 	type prop struct {
 		name  string
 		value string
@@ -365,8 +413,8 @@ func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.
 		addStr("avb_salt", f.salt())
 	}
 
-	if proptools.String(f.properties.File_contexts) != "" {
-		addPath("selinux_fc", f.buildFileContexts(ctx))
+	if fileContexts != nil {
+		addPath("selinux_fc", fileContexts)
 	}
 	if timestamp := proptools.String(f.properties.Fake_timestamp); timestamp != "" {
 		addStr("timestamp", timestamp)
@@ -388,7 +436,7 @@ func (f *filesystem) buildPropFile(ctx android.ModuleContext) (propFile android.
 	return propFile, deps
 }
 
-func (f *filesystem) buildCpioImage(ctx android.ModuleContext, compressed bool) android.OutputPath {
+func (f *filesystem) buildCpioImage(ctx android.ModuleContext, specs map[string]android.PackagingSpec, compressed bool) android.OutputPath {
 	if proptools.Bool(f.properties.Use_avb) {
 		ctx.PropertyErrorf("use_avb", "signing compresed cpio image using avbtool is not supported."+
 			"Consider adding this to bootimg module and signing the entire boot image.")
@@ -399,7 +447,7 @@ func (f *filesystem) buildCpioImage(ctx android.ModuleContext, compressed bool)
 	}
 
 	depsZipFile := android.PathForModuleOut(ctx, "deps.zip").OutputPath
-	f.entries = f.CopyDepsToZip(ctx, f.gatherFilteredPackagingSpecs(ctx), depsZipFile)
+	f.entries = f.CopyDepsToZip(ctx, specs, depsZipFile)
 
 	builder := android.NewRuleBuilder(pctx, ctx)
 	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
@@ -432,6 +480,11 @@ func (f *filesystem) buildCpioImage(ctx android.ModuleContext, compressed bool)
 	} else {
 		cmd.Text(">").Output(output)
 	}
+	if f.shouldCheckElfDeps() {
+		cmd.Validation(f.checkElfDeps(ctx, specs))
+	}
+
+	f.manifest = f.buildManifest(ctx, builder, rootDir, specs, nil)
 
 	// rootDir is not deleted. Might be useful for quick inspection.
 	builder.Build("build_cpio_image", fmt.Sprintf("Creating filesystem %s", f.BaseModuleName()))
@@ -459,8 +512,15 @@ var _ android.OutputFileProducer = (*filesystem)(nil)
 
 // Implements android.OutputFileProducer
 func (f *filesystem) OutputFiles(tag string) (android.Paths, error) {
-	if tag == "" {
+	switch tag {
+	case "":
 		return []android.Path{f.output}, nil
+	case ".manifest":
+		return []android.Path{f.manifest}, nil
+	case ".linker_namespaces.dot":
+		if f.linkerNamespacesGraph != nil {
+			return []android.Path{f.linkerNamespacesGraph}, nil
+		}
 	}
 	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 }
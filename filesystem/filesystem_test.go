@@ -21,6 +21,7 @@ import (
 	"android/soong/android"
 	"android/soong/cc"
 	"android/soong/etc"
+	"android/soong/linkerconfig"
 
 	"github.com/google/blueprint/proptools"
 )
@@ -33,6 +34,7 @@ var fixture = android.GroupFixturePreparers(
 	android.PrepareForIntegrationTestWithAndroid,
 	etc.PrepareForTestWithPrebuiltEtc,
 	cc.PrepareForIntegrationTestWithCc,
+	linkerconfig.PrepareForTestWithLinkerConfig,
 	PrepareForTestWithFilesystemBuildComponents,
 )
 
@@ -193,6 +195,49 @@ func TestAvbAddHashFooter(t *testing.T) {
 		cmd, "--include_descriptors_from_image ")
 }
 
+func TestAvbAddHashFooterWithExternalSigner(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		avb_add_hash_footer {
+			name: "myfooter",
+			src: "input.img",
+			private_key: "external:release_signer",
+			salt: "1111",
+		}
+
+		prebuilt_build_tool {
+			name: "stub_signer",
+			src: "stub_signer.sh",
+		}
+
+		external_signer {
+			name: "release_signer",
+			tool: "stub_signer",
+			public_key: "release.pem",
+		}
+	`)
+	rule := result.ModuleForTests("myfooter", "android_arm64_armv8-a").Rule("avbAddHashFooter")
+	android.AssertStringDoesContain(t, "the public key of the signer should be used",
+		rule.RuleParams.Command, "--key release.pem")
+	android.AssertStringDoesContain(t, "the signer should be used as the signing helper",
+		rule.RuleParams.Command, "--signing_helper_with_files out/soong/.intermediates/stub_signer/linux_glibc_x86_64/stub_signer")
+}
+
+func TestVbmetaExternalSignerMustSignAvbImages(t *testing.T) {
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`private_key: external_signer "release_signer" must set tool and public_key to sign AVB images`)).
+		RunTestWithBp(t, `
+			vbmeta {
+				name: "myvbmeta",
+				private_key: "external:release_signer",
+			}
+
+			external_signer {
+				name: "release_signer",
+				public_key: "release.pem",
+			}
+		`)
+}
+
 func TestFileSystemShouldInstallCoreVariantIfTargetBuildAppsIsSet(t *testing.T) {
 	context := android.GroupFixturePreparers(
 		fixture,
@@ -282,3 +327,329 @@ func TestFileSystemWithCoverageVariants(t *testing.T) {
 		t.Error("prebuilt should use cov variant of filesystem")
 	}
 }
+
+func TestFileSystemManifest(t *testing.T) {
+	f := android.GroupFixturePreparers(fixture, android.FixtureRegisterWithContext(registerComponent))
+	result := f.RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			base_dir: "system",
+			file_contexts: "file_contexts",
+			multilib: {
+				common: {
+					deps: ["foo"],
+				},
+			},
+		}
+		component {
+			name: "foo",
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	owners := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("manifest_owners.txt"))
+	android.AssertStringEquals(t, "owners", "system/components/foo\tfoo\n", owners)
+
+	cmd := module.Output("myfilesystem.manifest.json").RuleParams.Command
+	android.AssertStringDoesContain(t, "manifest should be labeled using file_contexts",
+		cmd, "/file_contexts.bin > ")
+	android.AssertStringDoesContain(t, "manifest should attribute extra files to the filesystem",
+		cmd, "--default_owner myfilesystem")
+
+	outputs, err := module.Module().(*filesystem).OutputFiles(".manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "manifest output files",
+		[]string{"out/soong/.intermediates/myfilesystem/android_common/myfilesystem.manifest.json"}, outputs)
+}
+
+func TestLogicalPartitionBudget(t *testing.T) {
+	f := android.GroupFixturePreparers(fixture, android.FixtureRegisterWithContext(registerComponent))
+	result := f.RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			multilib: {
+				common: {
+					deps: ["foo"],
+				},
+			},
+		}
+		component {
+			name: "foo",
+		}
+		logical_partition {
+			name: "mysuper",
+			size: "8388608",
+			groups: [
+				{
+					name: "mygroup",
+					size: "4194304",
+					partitions: [
+						{
+							name: "system",
+							filesystem: ":myfilesystem",
+							budget: "1048576",
+						},
+					],
+				},
+			],
+		}
+	`)
+
+	module := result.ModuleForTests("mysuper", "android_arm64_armv8-a")
+
+	entries := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("budget_entries.txt"))
+	android.AssertStringDoesContain(t, "budget entries should attribute files to modules",
+		entries, "system\tfoo\t")
+
+	config := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("budget_config.json"))
+	android.AssertStringDoesContain(t, "partition budget", config,
+		`{"name":"system","group":"mygroup","budget":1048576}`)
+	android.AssertStringDoesContain(t, "group budget defaults to group size", config,
+		`{"name":"mygroup","budget":4194304}`)
+	android.AssertStringDoesContain(t, "device budget", config, `"device_budget":8388608`)
+
+	lpmake := module.Rule("build_logical_partition")
+	android.AssertStringDoesContain(t, "partition size should be checked against budget",
+		lpmake.RuleParams.Command, "-gt 1048576")
+	android.AssertPathsRelativeToTopEquals(t, "lpmake should be validated by the budget report",
+		[]string{"out/soong/partition_budgets/mysuper-budget_report.json"}, lpmake.Validations)
+}
+
+func TestLogicalPartitionInvalidBudget(t *testing.T) {
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`default_group.budget: must be a positive number`)).
+		RunTestWithBp(t, `
+			logical_partition {
+				name: "mysuper",
+				size: "auto",
+				default_group: [
+					{
+						name: "system",
+						filesystem: "system.img",
+						budget: "1M",
+					},
+				],
+			}
+		`)
+
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`groups.partitions.budget: must be a positive number`)).
+		RunTestWithBp(t, `
+			logical_partition {
+				name: "mysuper",
+				size: "8388608",
+				groups: [
+					{
+						name: "mygroup",
+						size: "4194304",
+						partitions: [
+							{
+								name: "system",
+								filesystem: "system.img",
+								budget: "0",
+							},
+						],
+					},
+				],
+			}
+		`)
+}
+
+func TestVendorBootImageWithRamdiskFragments(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		android_filesystem {
+			name: "vendor_ramdisk",
+			type: "compressed_cpio",
+		}
+
+		android_filesystem {
+			name: "dlkm_ramdisk",
+			type: "compressed_cpio",
+		}
+
+		bootimg {
+			name: "vendor_boot",
+			vendor_boot: true,
+			header_version: "4",
+			ramdisk_module: "vendor_ramdisk",
+			dtb_prebuilts: ["a.dtb", "b.dtb"],
+			bootconfig: "bootconfig.txt",
+			vendor_ramdisk_fragments: [
+				{
+					name: "dlkm",
+					type: "dlkm",
+					ramdisk_module: "dlkm_ramdisk",
+				},
+			],
+			verify_round_trip: true,
+		}
+	`)
+
+	module := result.ModuleForTests("vendor_boot", "android_arm64_armv8-a")
+	cmd := module.Rule("build_bootimg").RuleParams.Command
+	android.AssertStringDoesContain(t, "vendor ramdisk",
+		cmd, "--vendor_ramdisk out/soong/.intermediates/vendor_ramdisk/android_common/vendor_ramdisk.img")
+	android.AssertStringDoesContain(t, "vendor ramdisk fragment",
+		cmd, "--ramdisk_type dlkm --ramdisk_name dlkm --vendor_ramdisk_fragment out/soong/.intermediates/dlkm_ramdisk/android_common/dlkm_ramdisk.img")
+	android.AssertStringDoesContain(t, "concatenated dtb",
+		cmd, "--dtb out/soong/.intermediates/vendor_boot/android_arm64_armv8-a/dtb.img")
+	android.AssertStringDoesContain(t, "bootconfig", cmd, "--vendor_bootconfig bootconfig.txt")
+
+	dtb := module.Output("dtb.img")
+	android.AssertPathsRelativeToTopEquals(t, "dtbs", []string{"a.dtb", "b.dtb"}, dtb.Inputs)
+
+	verify := module.Rule("verify_bootimg_round_trip").RuleParams.Command
+	android.AssertStringDoesContain(t, "unpack_bootimg", verify, "--format=mkbootimg --null")
+	android.AssertStringDoesContain(t, "mkbootimg arguments should not be evaluated by the shell",
+		verify, "xargs -0 -x ")
+	android.AssertStringDoesNotContain(t, "mkbootimg arguments should not be evaluated by the shell",
+		verify, "eval")
+	android.AssertStringDoesContain(t, "repacked image should be compared", verify, "cmp ")
+}
+
+func TestBootImageRoundTripIsVerifiedByDefault(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		bootimg {
+			name: "boot",
+			kernel_prebuilt: "kernel",
+			header_version: "4",
+		}
+
+		bootimg {
+			name: "unverified_boot",
+			kernel_prebuilt: "kernel",
+			header_version: "4",
+			verify_round_trip: false,
+		}
+	`)
+
+	boot := result.ModuleForTests("boot", "android_arm64_armv8-a").Rule("build_bootimg")
+	android.AssertPathsRelativeToTopEquals(t, "round trip should be verified when the image is built",
+		[]string{"out/soong/.intermediates/boot/android_arm64_armv8-a/round_trip/verified.timestamp"},
+		boot.Validations)
+
+	unverified := result.ModuleForTests("unverified_boot", "android_arm64_armv8-a")
+	android.AssertPathsRelativeToTopEquals(t, "round trip verification can be disabled",
+		nil, unverified.Rule("build_bootimg").Validations)
+	if unverified.MaybeRule("verify_bootimg_round_trip").Rule != nil {
+		t.Errorf("round trip verification is disabled but the rule is created")
+	}
+}
+
+func TestDtboImage(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		dtboimg {
+			name: "dtbo",
+			dtbo_prebuilts: ["a.dtbo", "b.dtbo"],
+			page_size: 4096,
+		}
+	`)
+
+	cmd := result.ModuleForTests("dtbo", "android_arm64_armv8-a").Rule("build_dtboimg").RuleParams.Command
+	android.AssertStringDoesContain(t, "mkdtboimg",
+		cmd, "mkdtboimg create out/soong/.intermediates/dtbo/android_arm64_armv8-a/dtbo.img --page_size=4096 a.dtbo b.dtbo")
+}
+
+func TestVendorRamdiskFragmentsRequireHeaderVersion4(t *testing.T) {
+	fixture.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`vendor_ramdisk_fragments: requires header_version: 4 or later`)).
+		RunTestWithBp(t, `
+			android_filesystem {
+				name: "dlkm_ramdisk",
+				type: "compressed_cpio",
+			}
+
+			bootimg {
+				name: "vendor_boot",
+				vendor_boot: true,
+				header_version: "3",
+				vendor_ramdisk_fragments: [
+					{
+						name: "dlkm",
+						type: "dlkm",
+						ramdisk_module: "dlkm_ramdisk",
+					},
+				],
+			}
+		`)
+}
+
+func TestFileSystemCheckElfDeps(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		android_system_image {
+			name: "myfilesystem",
+			deps: [
+				"libfoo",
+				"libbar",
+			],
+			linker_config_src: "linker.config.json",
+			check_elf_deps: true,
+		}
+
+		cc_library {
+			name: "libfoo",
+			stubs: {
+				symbol_file: "libfoo.map.txt",
+			},
+		}
+
+		cc_library {
+			name: "libbar",
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	config := android.ContentFromFileRuleForTests(t, result.TestContext, module.Output("elf_deps/config.json"))
+	android.AssertStringDoesContain(t, "stub libraries should be provided by system",
+		config, `{"name":"system","provideLibs":["libfoo.so"],"requireLibs":null}`)
+	android.AssertStringDoesContain(t, "libbar should be checked",
+		config, `{"partition":"system","path":"lib64/libbar.so","file":"out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/libbar.so","module":"libbar"}`)
+
+	check := module.Rule("check_elf_deps")
+	android.AssertStringDoesContain(t, "linker config of system should be used",
+		check.RuleParams.Command, "--linker_config system=linker.config.json")
+
+	image := module.Rule("build_filesystem_image")
+	android.AssertPathsRelativeToTopEquals(t, "image should be validated by the check",
+		[]string{"out/soong/.intermediates/myfilesystem/android_common/elf_deps/report.txt"}, image.Validations)
+}
+
+func TestFileSystemCheckLinkerConfig(t *testing.T) {
+	result := fixture.RunTestWithBp(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			partition_name: "vendor",
+			deps: [
+				"vendor_linker_config",
+				"libbar",
+			],
+			check_linker_config: true,
+		}
+
+		linker_config {
+			name: "vendor_linker_config",
+			src: "vendor_linker_config.json",
+			vendor: true,
+		}
+
+		cc_library {
+			name: "libbar",
+			vendor: true,
+		}
+	`)
+
+	module := result.ModuleForTests("myfilesystem", "android_common")
+	check := module.Rule("check_elf_deps")
+	android.AssertStringDoesContain(t, "linker config of the linker_config module should be used",
+		check.RuleParams.Command, "--linker_config vendor=vendor_linker_config.json")
+	android.AssertStringDoesContain(t, "linker config should be checked",
+		check.RuleParams.Command, "--check_linker_config")
+	android.AssertStringDoesContain(t, "namespace graph should be generated",
+		check.RuleParams.Command, "--dot out/soong/.intermediates/myfilesystem/android_common/elf_deps/linker_namespaces.dot")
+
+	image := module.Rule("build_filesystem_image")
+	android.AssertPathsRelativeToTopEquals(t, "image should be validated by the check",
+		[]string{"out/soong/.intermediates/myfilesystem/android_common/elf_deps/report.txt"}, image.Validations)
+}
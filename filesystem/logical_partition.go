@@ -23,10 +23,6 @@ import (
 	"android/soong/android"
 )
 
-func init() {
-	android.RegisterModuleType("logical_partition", logicalPartitionFactory)
-}
-
 type logicalPartition struct {
 	android.ModuleBase
 
@@ -34,6 +30,9 @@ type logicalPartition struct {
 
 	output     android.OutputPath
 	installDir android.InstallPath
+
+	// Projected sizes of the partitions, groups and the whole image. See buildBudgetReport.
+	budgetReport android.OptionalPath
 }
 
 type logicalPartitionProperties struct {
@@ -54,6 +53,10 @@ type logicalPartitionProperties struct {
 
 	// Whether the output is a sparse image or not. Default is false.
 	Sparse *bool
+
+	// Number of the largest modules to report for each partition and group when the budget is
+	// exceeded. Default is 10.
+	Budget_report_top_modules *int64
 }
 
 type groupProperties struct {
@@ -63,6 +66,9 @@ type groupProperties struct {
 	// Size of the partition group
 	Size *string
 
+	// Maximum projected size of the partitions in this group, in bytes. Defaults to size.
+	Budget *string
+
 	// List of logical partitions in this group
 	Partitions []partitionProperties
 }
@@ -73,6 +79,11 @@ type partitionProperties struct {
 
 	// Filesystem that is placed on the partition
 	Filesystem *string `android:"path"`
+
+	// Maximum size of the partition, in bytes. When the filesystem is an android_filesystem
+	// module, the size of the partition is projected from its contents and checked before the
+	// image is created. The size of the created filesystem image is checked as well.
+	Budget *string
 }
 
 // logical_partition is a partition image which has one or more logical partitions in it.
@@ -94,6 +105,8 @@ func (l *logicalPartition) installFileName() string {
 func (l *logicalPartition) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	builder := android.NewRuleBuilder(pctx, ctx)
 
+	budgets := l.partitionBudgets(ctx)
+
 	// Sparse the filesystem images and calculate their sizes
 	sparseImages := make(map[string]android.OutputPath)
 	sparseImageSizes := make(map[string]android.OutputPath)
@@ -104,6 +117,9 @@ func (l *logicalPartition) GenerateAndroidBuildActions(ctx android.ModuleContext
 			pName := proptools.String(part.Name)
 			sparseImages[pName] = sparseImg
 			sparseImageSizes[pName] = sizeTxt
+			if budget := budgets[pName]; budget > 0 {
+				checkPartitionSize(builder, pName, sizeTxt, budget)
+			}
 		}
 	}
 
@@ -115,6 +131,11 @@ func (l *logicalPartition) GenerateAndroidBuildActions(ctx android.ModuleContext
 
 	cmd := builder.Command().BuiltTool("lpmake")
 
+	l.budgetReport = l.buildBudgetReport(ctx, budgets)
+	if l.budgetReport.Valid() {
+		cmd.Validation(l.budgetReport.Path())
+	}
+
 	size := proptools.String(l.properties.Size)
 	if size == "" {
 		ctx.PropertyErrorf("size", "must be set")
@@ -0,0 +1,78 @@
+// Copyright (C) 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"path/filepath"
+	"strings"
+
+	"android/soong/android"
+
+	"github.com/google/blueprint/proptools"
+)
+
+func (f *filesystem) manifestFileName() string {
+	return f.BaseModuleName() + ".manifest.json"
+}
+
+// Writes the list of files in the image, each with the name of the module that installed it. Files
+// that are not from the `deps` property (e.g. dirs, symlinks and extra files) are not listed and
+// are attributed to this module.
+func (f *filesystem) buildOwnersFile(ctx android.ModuleContext, specs map[string]android.PackagingSpec) android.OutputPath {
+	depsBase := proptools.StringDefault(f.properties.Base_dir, ".")
+	var lines []string
+	for _, k := range android.SortedKeys(specs) {
+		ps := specs[k]
+		lines = append(lines, filepath.Join(depsBase, ps.RelPathInPackage())+"\t"+ps.Owner())
+	}
+	owners := android.PathForModuleOut(ctx, "manifest_owners.txt").OutputPath
+	android.WriteFileRule(ctx, owners, strings.Join(lines, "\n"))
+	return owners
+}
+
+// Adds commands to the builder to create the manifest of the image staged in rootDir. The
+// manifest lists path, mode, uid, gid, SELinux label, size, sha256 and the source module of each
+// entry of the image. fileContexts is the compiled file_contexts, and can be nil.
+func (f *filesystem) buildManifest(ctx android.ModuleContext, builder *android.RuleBuilder, rootDir android.OutputPath,
+	specs map[string]android.PackagingSpec, fileContexts android.Path) android.OutputPath {
+
+	owners := f.buildOwnersFile(ctx, specs)
+
+	// List all entries relative to rootDir, with a trailing slash for directories as fs_config
+	// expects.
+	fsConfigOut := android.PathForModuleOut(ctx, "manifest_fs_config.txt").OutputPath
+	cmd := builder.Command().
+		Textf("(cd %s && find . -type d | sed 's,$,/,' && find . ! -type d)", rootDir).
+		Text("| cut -c 3- | grep -v '^$' | sort |").
+		BuiltTool("fs_config").
+		Flag("-C").
+		FlagWithArg("-D ", rootDir.String())
+	if fileContexts != nil {
+		cmd.FlagWithInput("-S ", fileContexts)
+	}
+	cmd.Text(">").Output(fsConfigOut)
+
+	output := android.PathForModuleOut(ctx, f.manifestFileName()).OutputPath
+	builder.Command().
+		BuiltTool("fs_manifest").
+		Text("create").
+		FlagWithArg("--root ", rootDir.String()).
+		FlagWithInput("--fs_config ", fsConfigOut).
+		FlagWithInput("--owners ", owners).
+		FlagWithArg("--default_owner ", ctx.ModuleName()).
+		FlagWithOutput("--output ", output)
+
+	return output
+}
@@ -0,0 +1,202 @@
+// Copyright (C) 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// Files are rounded up to the block size when projecting the size of a partition.
+const budgetBlockSize = 4096
+
+// Directory in the soong output directory of the budget reports, which soong_ui uploads with the
+// build metrics. Must match PartitionBudgetsDir in ui/build/config.go.
+const partitionBudgetsDir = "partition_budgets"
+
+// partitionBudgetConfig is the input of `fs_manifest budget`.
+type partitionBudgetConfig struct {
+	BlockSize    int64                 `json:"block_size"`
+	TopModules   int64                 `json:"top_modules"`
+	Partitions   []partitionBudgetItem `json:"partitions"`
+	Groups       []partitionBudgetItem `json:"groups"`
+	DeviceBudget int64                 `json:"device_budget"`
+}
+
+type partitionBudgetItem struct {
+	Name   string `json:"name"`
+	Group  string `json:"group,omitempty"`
+	Budget int64  `json:"budget"`
+}
+
+func parseBudget(ctx android.ModuleContext, property string, value *string) int64 {
+	if value == nil {
+		return 0
+	}
+	budget, err := strconv.ParseInt(*value, 10, 64)
+	if err != nil || budget <= 0 {
+		ctx.PropertyErrorf(property, "must be a positive number")
+		return 0
+	}
+	return budget
+}
+
+// Returns the budget of each partition. Partitions without a budget are not in the map.
+func (l *logicalPartition) partitionBudgets(ctx android.ModuleContext) map[string]int64 {
+	ret := make(map[string]int64)
+	addPartitions := func(partitions []partitionProperties, property string) {
+		for _, part := range partitions {
+			if budget := parseBudget(ctx, property, part.Budget); budget > 0 {
+				ret[proptools.String(part.Name)] = budget
+			}
+		}
+	}
+	addPartitions(l.properties.Default_group, "default_group.budget")
+	for _, group := range l.properties.Groups {
+		addPartitions(group.Partitions, "groups.partitions.budget")
+	}
+	return ret
+}
+
+// Adds a command that fails when the size of the created filesystem image exceeds the budget.
+func checkPartitionSize(builder *android.RuleBuilder, name string, sizeTxt android.OutputPath, budget int64) {
+	builder.Command().Textf(`if [ "$(cat %s)" -gt %d ]; then `+
+		`echo "partition %s is $(cat %s) bytes, over its budget of %d bytes" >&2; exit 1; fi`,
+		sizeTxt, budget, name, sizeTxt, budget)
+}
+
+// Creates a rule that projects the sizes of the partitions, the groups and the whole image from
+// the contents of the android_filesystem modules, before the images are built. The rule fails
+// when a budget is exceeded, listing the modules that contribute the most. The report is also
+// used to track the headroom over time: it's written to partitionBudgetsDir, from which soong_ui
+// uploads it with the build metrics. Returns an invalid path if no partition is built from an
+// android_filesystem module.
+func (l *logicalPartition) buildBudgetReport(ctx android.ModuleContext, budgets map[string]int64) android.OptionalPath {
+	config := partitionBudgetConfig{
+		BlockSize:  budgetBlockSize,
+		TopModules: int64(proptools.IntDefault(l.properties.Budget_report_top_modules, 10)),
+	}
+	if size, err := strconv.ParseInt(proptools.String(l.properties.Size), 10, 64); err == nil {
+		config.DeviceBudget = size
+	}
+
+	var entries []string
+	var inputs android.Paths
+	addPartitions := func(partitions []partitionProperties, group string) {
+		for _, part := range partitions {
+			pName := proptools.String(part.Name)
+			config.Partitions = append(config.Partitions, partitionBudgetItem{
+				Name:   pName,
+				Group:  group,
+				Budget: budgets[pName],
+			})
+
+			module, tag := android.SrcIsModuleWithTag(proptools.String(part.Filesystem))
+			if module == "" {
+				continue
+			}
+			dep := android.GetModuleFromPathDep(ctx, module, tag)
+			if dep == nil || !ctx.OtherModuleHasProvider(dep, FilesystemInfoProvider) {
+				continue
+			}
+			info := ctx.OtherModuleProvider(dep, FilesystemInfoProvider).(FilesystemInfo)
+			for _, k := range android.SortedKeys(info.Specs) {
+				ps := info.Specs[k]
+				if ps.SrcPath() == nil {
+					continue // symlinks
+				}
+				entries = append(entries, pName+"\t"+ps.Owner()+"\t"+ps.SrcPath().String())
+				inputs = append(inputs, ps.SrcPath())
+			}
+		}
+	}
+
+	addPartitions(l.properties.Default_group, "default")
+	for _, group := range l.properties.Groups {
+		gName := proptools.String(group.Name)
+		gBudget := parseBudget(ctx, "groups.budget", group.Budget)
+		if gBudget == 0 {
+			gBudget, _ = strconv.ParseInt(proptools.String(group.Size), 10, 64)
+		}
+		config.Groups = append(config.Groups, partitionBudgetItem{Name: gName, Budget: gBudget})
+		addPartitions(group.Partitions, gName)
+	}
+
+	if len(inputs) == 0 {
+		return android.OptionalPath{}
+	}
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		ctx.ModuleErrorf("failed to marshal partition budgets: %s", err)
+		return android.OptionalPath{}
+	}
+	configFile := android.PathForModuleOut(ctx, "budget_config.json")
+	android.WriteFileRule(ctx, configFile, string(configJson))
+	entriesFile := android.PathForModuleOut(ctx, "budget_entries.txt")
+	android.WriteFileRule(ctx, entriesFile, strings.Join(entries, "\n"))
+
+	report := android.PathForOutput(ctx, partitionBudgetsDir, ctx.ModuleName()+"-budget_report.json")
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().
+		BuiltTool("fs_manifest").
+		Text("budget").
+		FlagWithInput("--config ", configFile).
+		FlagWithInput("--entries ", entriesFile).
+		Implicits(android.FirstUniquePaths(inputs)).
+		FlagWithOutput("--output ", report)
+	builder.Build("check_partition_budgets", fmt.Sprintf("Checking partition budgets of %s", l.BaseModuleName()))
+
+	return android.OptionalPathForPath(report)
+}
+
+type partitionBudgetReport struct {
+	name   string
+	report android.Path
+}
+
+// partitionBudgetsSingleton dists the budget reports of all logical_partition modules, in addition
+// to their upload with the build metrics, so that the headroom of each partition can be tracked
+// across builds.
+type partitionBudgetsSingleton struct {
+	reports []partitionBudgetReport
+}
+
+func partitionBudgetsSingletonFactory() android.Singleton {
+	return &partitionBudgetsSingleton{}
+}
+
+func (s *partitionBudgetsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	ctx.VisitAllModules(func(m android.Module) {
+		if l, ok := m.(*logicalPartition); ok && l.budgetReport.Valid() {
+			s.reports = append(s.reports, partitionBudgetReport{
+				name:   ctx.ModuleName(m),
+				report: l.budgetReport.Path(),
+			})
+		}
+	})
+}
+
+func (s *partitionBudgetsSingleton) MakeVars(ctx android.MakeVarsContext) {
+	for _, r := range s.reports {
+		ctx.DistForGoalWithFilename("checkbuild", r.report, r.name+"-budget_report.json")
+	}
+}
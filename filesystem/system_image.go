@@ -38,6 +38,7 @@ func systemImageFactory() android.Module {
 	module.AddProperties(&module.properties)
 	module.filesystem.buildExtraFiles = module.buildExtraFiles
 	module.filesystem.filterPackagingSpecs = module.filterPackagingSpecs
+	module.filesystem.linkerConfigNamespaces = module.linkerConfigNamespaces
 	initFilesystemModule(&module.filesystem)
 	return module
 }
@@ -52,7 +53,14 @@ func (s *systemImage) buildLinkerConfigFile(ctx android.ModuleContext, root andr
 	input := android.PathForModuleSrc(ctx, android.String(s.properties.Linker_config_src))
 	output := root.Join(ctx, "system", "etc", "linker.config.pb")
 
-	// we need "Module"s for packaging items
+	builder := android.NewRuleBuilder(pctx, ctx)
+	linkerconfig.BuildLinkerConfig(ctx, builder, input, s.packagedModules(ctx), output)
+	builder.Build("conv_linker_config", "Generate linker config protobuf "+output.String())
+	return output
+}
+
+// Returns the modules that install the items packaged in this image.
+func (s *systemImage) packagedModules(ctx android.ModuleContext) []android.Module {
 	var otherModules []android.Module
 	deps := s.gatherFilteredPackagingSpecs(ctx)
 	ctx.WalkDeps(func(child, parent android.Module) bool {
@@ -63,11 +71,16 @@ func (s *systemImage) buildLinkerConfigFile(ctx android.ModuleContext, root andr
 		}
 		return true
 	})
+	return otherModules
+}
 
-	builder := android.NewRuleBuilder(pctx, ctx)
-	linkerconfig.BuildLinkerConfig(ctx, builder, input, otherModules, output)
-	builder.Build("conv_linker_config", "Generate linker config protobuf "+output.String())
-	return output
+// The system partition provides the stub libraries, in addition to what is in the linker config.
+func (s *systemImage) linkerConfigNamespaces(ctx android.ModuleContext) []elfDepsNamespace {
+	return []elfDepsNamespace{{
+		Name:            "system",
+		ProvideLibs:     linkerconfig.StubLibraries(s.packagedModules(ctx)),
+		linkerConfigSrc: android.PathForModuleSrc(ctx, android.String(s.properties.Linker_config_src)),
+	}}
 }
 
 // Filter the result of GatherPackagingSpecs to discard items targeting outside "system" partition.
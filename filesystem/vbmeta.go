@@ -44,7 +44,8 @@ type vbmetaProperties struct {
 	// Set the name of the output. Defaults to <module_name>.img.
 	Stem *string
 
-	// Path to the private key that avbtool will use to sign this vbmeta image.
+	// Path to the private key that avbtool will use to sign this vbmeta image. "external:<name>"
+	// references an external_signer module that avbtool will sign with instead.
 	Private_key *string `android:"path"`
 
 	// Algorithm that avbtool will use to sign this vbmeta image. Default is SHA256_RSA4096.
@@ -111,6 +112,7 @@ var vbmetaPartitionDep = vbmetaDep{kind: "partition"}
 
 func (v *vbmeta) DepsMutator(ctx android.BottomUpMutatorContext) {
 	ctx.AddDependency(ctx.Module(), vbmetaPartitionDep, v.properties.Partitions...)
+	android.AddExternalSignerDependency(ctx, v.properties.Private_key)
 }
 
 func (v *vbmeta) installFileName() string {
@@ -132,8 +134,7 @@ func (v *vbmeta) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	builder := android.NewRuleBuilder(pctx, ctx)
 	cmd := builder.Command().BuiltTool("avbtool").Text("make_vbmeta_image")
 
-	key := android.PathForModuleSrc(ctx, proptools.String(v.properties.Private_key))
-	cmd.FlagWithInput("--key ", key)
+	addAvbKey(ctx, cmd, v.properties.Private_key)
 
 	algorithm := proptools.StringDefault(v.properties.Algorithm, "SHA256_RSA4096")
 	cmd.FlagWithArg("--algorithm ", algorithm)
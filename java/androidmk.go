@@ -459,6 +459,7 @@ func (app *AndroidApp) AndroidMkEntries() []android.AndroidMkEntries {
 				}
 
 				entries.SetOptionalPaths("LOCAL_SOONG_LINT_REPORTS", app.linter.reports)
+				app.apkBreakdown.androidMkEntries(entries)
 
 				if app.Name() != "framework-res" {
 					// TODO(b/311155208): The container here should be system.
@@ -714,6 +715,7 @@ func (a *AndroidAppImport) AndroidMkEntries() []android.AndroidMkEntries {
 				if Bool(a.properties.Export_package_resources) {
 					entries.SetPath("LOCAL_SOONG_RESOURCE_EXPORT_PACKAGE", a.outputFile)
 				}
+				a.apkBreakdown.androidMkEntries(entries)
 				// TODO(b/289117800): LOCAL_ACONFIG_FILES for prebuilts
 			},
 		},
@@ -0,0 +1,82 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"strconv"
+
+	"android/soong/android"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type apkBreakdownProperties struct {
+	// If set, write a JSON report of the contents of the APK: dex class and method counts per
+	// package, resources by type and configuration, native libraries per ABI and assets, with
+	// their compressed and uncompressed sizes. Default is false.
+	Apk_breakdown *bool
+
+	// Maximum size of the APK in bytes, which must be positive. The build fails if the APK is
+	// larger. Implies apk_breakdown: true.
+	Apk_size_budget *int64
+}
+
+// apkBreakdown creates the report of the contents of the APK of android_app and
+// android_app_import modules.
+type apkBreakdown struct {
+	properties apkBreakdownProperties
+
+	report android.OptionalPath
+}
+
+func (b *apkBreakdown) enabled() bool {
+	return proptools.Bool(b.properties.Apk_breakdown) || b.properties.Apk_size_budget != nil
+}
+
+// buildReport creates the report of the contents of apk if it's enabled, and checks that apk is
+// within its size budget.
+func (b *apkBreakdown) buildReport(ctx android.ModuleContext, apk android.Path) {
+	if !b.enabled() {
+		return
+	}
+
+	budget := proptools.Int(b.properties.Apk_size_budget)
+	if b.properties.Apk_size_budget != nil && budget <= 0 {
+		ctx.PropertyErrorf("apk_size_budget", "must be a positive number, got %d", budget)
+		return
+	}
+
+	report := android.PathForModuleOut(ctx, "apk_breakdown", apk.Base()+".json")
+	builder := android.NewRuleBuilder(pctx, ctx)
+	cmd := builder.Command().
+		BuiltTool("apk_breakdown").
+		FlagWithOutput("--output ", report)
+	if budget > 0 {
+		cmd.FlagWithArg("--size_budget ", strconv.Itoa(budget))
+	}
+	cmd.Input(apk)
+	builder.Build("apk_breakdown", "apk breakdown "+apk.Base())
+
+	ctx.CheckbuildFile(report)
+	b.report = android.OptionalPathForPath(report)
+}
+
+// androidMkEntries makes Make build the report along with the APK so that the size budget is
+// enforced.
+func (b *apkBreakdown) androidMkEntries(entries *android.AndroidMkEntries) {
+	if b.report.Valid() {
+		entries.AddStrings("LOCAL_ADDITIONAL_DEPENDENCIES", b.report.String())
+	}
+}
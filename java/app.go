@@ -215,6 +215,8 @@ type AndroidApp struct {
 	javaApiUsedByOutputFile android.ModuleOutPath
 
 	privAppAllowlist android.OptionalPath
+
+	apkBreakdown apkBreakdown
 }
 
 func (a *AndroidApp) IsInstallable() bool {
@@ -246,6 +248,9 @@ var _ AndroidLibraryDependency = (*AndroidApp)(nil)
 type Certificate struct {
 	Pem, Key  android.Path
 	presigned bool
+
+	// When set, the private key is held by this signer and Key is nil.
+	Signer *android.ExternalSignerInfo
 }
 
 var PresignedCertificate = Certificate{presigned: true}
@@ -853,6 +858,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 
 	CreateAndSignAppPackage(ctx, packageFile, packageResources, jniJarFile, dexJarFile, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion)
 	a.outputFile = packageFile
+	a.apkBreakdown.buildReport(ctx, a.outputFile)
 	if v4SigningRequested {
 		a.extraOutputFiles = append(a.extraOutputFiles, v4SignatureFile)
 	}
@@ -1109,6 +1115,8 @@ func (a *AndroidApp) OutputFiles(tag string) (android.Paths, error) {
 		return []android.Path{a.exportPackage}, nil
 	case ".manifest.xml":
 		return []android.Path{a.aapt.manifestPath}, nil
+	case ".apk_breakdown.json":
+		return a.apkBreakdown.report.AsPaths(), nil
 	}
 	return a.Library.OutputFiles(tag)
 }
@@ -1162,7 +1170,8 @@ func AndroidAppFactory() android.Module {
 	module.AddProperties(
 		&module.aaptProperties,
 		&module.appProperties,
-		&module.overridableAppProperties)
+		&module.overridableAppProperties,
+		&module.apkBreakdown.properties)
 
 	module.usesLibrary.enforce = true
 
@@ -1446,6 +1455,8 @@ type AndroidAppCertificate struct {
 
 type AndroidAppCertificateProperties struct {
 	// Name of the certificate files.  Extensions .x509.pem and .pk8 will be added to the name.
+	// "external:<name>" references an external_signer module that holds the private key and
+	// provides the certificate instead, for builds that must not have private key files.
 	Certificate *string
 }
 
@@ -1458,7 +1469,18 @@ func AndroidAppCertificateFactory() android.Module {
 	return module
 }
 
+func (c *AndroidAppCertificate) DepsMutator(ctx android.BottomUpMutatorContext) {
+	android.AddExternalSignerDependency(ctx, c.properties.Certificate)
+}
+
 func (c *AndroidAppCertificate) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if android.ExternalSignerName(c.properties.Certificate) != "" {
+		if signer := android.ExternalSignerFromDeps(ctx, "certificate", false); signer != nil {
+			c.Certificate = Certificate{Pem: signer.Certificate, Signer: signer}
+		}
+		return
+	}
+
 	cert := String(c.properties.Certificate)
 	c.Certificate = Certificate{
 		Pem: android.PathForModuleSrc(ctx, cert+".x509.pem"),
@@ -1638,6 +1660,17 @@ func (u *usesLibrary) classLoaderContextForUsesLibDeps(ctx android.ModuleContext
 		}
 
 		if lib, ok := m.(UsesLibraryDependency); ok {
+			// The CLC may be shared with the modules that depend on this one, so the reason names
+			// this module.
+			reason := "implementation of an SDK library in libs"
+			if tag.sdkVersion != dexpreopt.AnySdkVersion {
+				reason = "compatibility library"
+			} else if android.InList(dep, u.usesLibraryProperties.Uses_libs) {
+				reason = "uses_libs"
+			} else if android.InList(dep, u.usesLibraryProperties.Optional_uses_libs) {
+				reason = "optional_uses_libs"
+			}
+			reason += " of " + ctx.ModuleName()
 			libName := dep
 			if ulib, ok := m.(ProvidesUsesLib); ok && ulib.ProvidesUsesLib() != nil {
 				libName = *ulib.ProvidesUsesLib()
@@ -1647,9 +1680,9 @@ func (u *usesLibrary) classLoaderContextForUsesLibDeps(ctx android.ModuleContext
 				replaceInList(u.usesLibraryProperties.Uses_libs, dep, libName)
 				replaceInList(u.usesLibraryProperties.Optional_uses_libs, dep, libName)
 			}
-			clcMap.AddContext(ctx, tag.sdkVersion, libName, tag.optional,
+			clcMap.AddContextWithReason(ctx, tag.sdkVersion, libName, tag.optional,
 				lib.DexJarBuildPath().PathOrNil(), lib.DexJarInstallPath(),
-				lib.ClassLoaderContexts())
+				lib.ClassLoaderContexts(), dep, reason)
 		} else if ctx.Config().AllowMissingDependencies() {
 			ctx.AddMissingDependencies([]string{dep})
 		} else {
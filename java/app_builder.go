@@ -78,12 +78,32 @@ func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, u
 
 	var certificateArgs []string
 	var deps android.Paths
+	var flags []string
+	var signer *android.ExternalSignerInfo
 	for _, c := range certificates {
+		if c.Signer != nil {
+			// signapk loads all private keys from the same key store, so keys held by an external
+			// signer can't be mixed with key files or with keys of other signers.
+			if signer == nil && len(certificateArgs) == 0 {
+				signer = c.Signer
+				flags = append(flags, signer.SignapkFlags...)
+				deps = append(deps, signer.SignapkDeps...)
+			} else if signer == nil || signer.Name != c.Signer.Name {
+				ctx.ModuleErrorf("can't sign with external signer %q together with other keys", c.Signer.Name)
+				return
+			}
+			certificateArgs = append(certificateArgs, c.Pem.String(), c.Signer.KeyAlias)
+			deps = append(deps, c.Pem)
+			continue
+		}
+		if signer != nil {
+			ctx.ModuleErrorf("can't sign with external signer %q together with other keys", signer.Name)
+			return
+		}
 		certificateArgs = append(certificateArgs, c.Pem.String(), c.Key.String())
 		deps = append(deps, c.Pem, c.Key)
 	}
 	outputFiles := android.WritablePaths{signedApk}
-	var flags []string
 	if v4SignatureFile != nil {
 		outputFiles = append(outputFiles, v4SignatureFile)
 		flags = append(flags, "--enable-v4")
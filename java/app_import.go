@@ -86,6 +86,8 @@ type AndroidAppImport struct {
 	hideApexVariantFromMake bool
 
 	provenanceMetaDataFile android.OutputPath
+
+	apkBreakdown apkBreakdown
 }
 
 type AndroidAppImportProperties struct {
@@ -372,6 +374,8 @@ func (a *AndroidAppImport) generateAndroidBuildActions(ctx android.ModuleContext
 
 	// TODO: Optionally compress the output apk.
 
+	a.apkBreakdown.buildReport(ctx, a.outputFile)
+
 	if apexInfo.IsForPlatform() {
 		a.installPath = ctx.InstallFile(installDir, apkFilename, a.outputFile)
 		artifactPath := android.PathForModuleSrc(ctx, *a.properties.Apk)
@@ -421,6 +425,8 @@ func (a *AndroidAppImport) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case "":
 		return []android.Path{a.outputFile}, nil
+	case ".apk_breakdown.json":
+		return a.apkBreakdown.report.AsPaths(), nil
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -538,6 +544,7 @@ func AndroidAppImportFactory() android.Module {
 	module.AddProperties(&module.properties)
 	module.AddProperties(&module.dexpreoptProperties)
 	module.AddProperties(&module.usesLibrary.usesLibraryProperties)
+	module.AddProperties(&module.apkBreakdown.properties)
 	module.populateAllVariantStructs()
 	android.AddLoadHook(module, func(ctx android.LoadHookContext) {
 		module.processVariants(ctx)
@@ -765,3 +765,21 @@ func TestAppImportMissingCertificateAllowMissingDependencies(t *testing.T) {
 	}
 	android.AssertStringDoesContain(t, "expected error rule message", fooApk.Args["error"], "missing dependencies: missing_certificate\n")
 }
+
+func TestAndroidAppImportApkBreakdown(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app_import {
+			name: "foo",
+			apk: "prebuilts/apk/app.apk",
+			presigned: true,
+			apk_breakdown: true,
+		}
+		`)
+
+	variant := ctx.ModuleForTests("foo", "android_common")
+	breakdown := variant.Output("apk_breakdown/foo.apk.json")
+	android.AssertStringDoesContain(t, "apk_breakdown command", breakdown.RuleParams.Command,
+		"apk_breakdown --output out/soong/.intermediates/foo/android_common/apk_breakdown/foo.apk.json "+
+			"out/soong/.intermediates/foo/android_common/zip-aligned/foo.apk")
+	android.AssertStringDoesNotContain(t, "apk_breakdown command", breakdown.RuleParams.Command, "--size_budget")
+}
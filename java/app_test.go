@@ -2334,6 +2334,77 @@ func TestCertificates(t *testing.T) {
 	}
 }
 
+func TestCertificateWithExternalSigner(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			certificate: ":release_certificate",
+			sdk_version: "current",
+		}
+
+		android_app_certificate {
+			name: "release_certificate",
+			certificate: "external:release_signer",
+		}
+
+		external_signer {
+			name: "release_signer",
+			certificate: "release.x509.pem",
+			provider_class: "sun.security.pkcs11.SunPKCS11",
+			provider_arg: "pkcs11.cfg",
+			key_alias: "release",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	certificate := foo.Module().(*AndroidApp).certificate
+	android.AssertPathRelativeToTopEquals(t, "certificates pem", "release.x509.pem", certificate.Pem)
+	if certificate.Key != nil {
+		t.Errorf("expected no private key file, got %q", certificate.Key)
+	}
+
+	signapk := foo.Output("foo.apk")
+	android.AssertStringEquals(t, "certificates flags", "release.x509.pem release", signapk.Args["certificates"])
+	android.AssertStringEquals(t, "signing flags",
+		"-providerClass sun.security.pkcs11.SunPKCS11 -providerArg pkcs11.cfg -loadPrivateKeysFromKeyStore PKCS11",
+		signapk.Args["flags"])
+	android.AssertPathsRelativeToTopEquals(t, "implicits",
+		[]string{"pkcs11.cfg", "release.x509.pem"}, signapk.Implicits)
+}
+
+func TestCertificateWithExternalSignerMixedWithKeyFiles(t *testing.T) {
+	android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`can't sign with external signer "release_signer" together with other keys`)).
+		RunTestWithBp(t, `
+			android_app {
+				name: "foo",
+				srcs: ["a.java"],
+				certificate: ":release_certificate",
+				additional_certificates: [":other_certificate"],
+				sdk_version: "current",
+			}
+
+			android_app_certificate {
+				name: "release_certificate",
+				certificate: "external:release_signer",
+			}
+
+			android_app_certificate {
+				name: "other_certificate",
+				certificate: "cert/other",
+			}
+
+			external_signer {
+				name: "release_signer",
+				certificate: "release.x509.pem",
+				provider_class: "sun.security.pkcs11.SunPKCS11",
+			}
+		`)
+}
+
 func TestRequestV4SigningFlag(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -4378,3 +4449,53 @@ func TestAppFlagsPackages(t *testing.T) {
 		"--feature-flags @out/soong/.intermediates/bar/intermediate.txt --feature-flags @out/soong/.intermediates/baz/intermediate.txt",
 	)
 }
+
+func TestAppApkBreakdown(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			apk_size_budget: 1048576,
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	breakdown := foo.Output("apk_breakdown/foo.apk.json")
+	android.AssertStringDoesContain(t, "apk_breakdown command", breakdown.RuleParams.Command,
+		"apk_breakdown --output out/soong/.intermediates/foo/android_common/apk_breakdown/foo.apk.json "+
+			"--size_budget 1048576 out/soong/.intermediates/foo/android_common/foo.apk")
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, foo.Module())[0]
+	android.AssertStringListContains(t, "LOCAL_ADDITIONAL_DEPENDENCIES", entries.EntryMap["LOCAL_ADDITIONAL_DEPENDENCIES"],
+		"out/soong/.intermediates/foo/android_common/apk_breakdown/foo.apk.json")
+
+	bar := result.ModuleForTests("bar", "android_common")
+	if bar.MaybeOutput("apk_breakdown/bar.apk.json").Rule != nil {
+		t.Errorf("expected no apk breakdown for an app without apk_breakdown or apk_size_budget")
+	}
+}
+
+func TestAppInvalidApkSizeBudget(t *testing.T) {
+	for _, budget := range []string{"0", "-1"} {
+		t.Run(budget, func(t *testing.T) {
+			PrepareForTestWithJavaDefaultModules.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+					`apk_size_budget: must be a positive number, got `+budget)).
+				RunTestWithBp(t, `
+					android_app {
+						name: "foo",
+						srcs: ["a.java"],
+						sdk_version: "current",
+						apk_size_budget: `+budget+`,
+					}
+				`)
+		})
+	}
+}
@@ -506,6 +506,9 @@ type Module struct {
 
 	annoSrcJars android.Paths
 
+	// The SDK that the module is compiled against, for the IDE workspace.
+	ideSdk ideSdk
+
 	// output file name based on Stem property.
 	// This should be set in every ModuleWithStem's GenerateAndroidBuildActions
 	// or the module should override Stem().
@@ -1072,6 +1075,17 @@ func (j *Module) compile(ctx android.ModuleContext, extraSrcJars, extraClasspath
 	deps := j.collectDeps(ctx)
 	flags := j.collectBuilderFlags(ctx, deps)
 
+	// Record the SDK that the module is compiled against for the IDE workspace, see ide_workspace.go.
+	j.ideSdk = ideSdk{sdkVersion: j.SdkVersion(ctx).Raw}
+	ctx.VisitDirectDepsWithTag(systemModulesTag, func(module android.Module) {
+		if sm, ok := module.(SystemModulesProvider); ok {
+			j.ideSdk.systemModules = ctx.OtherModuleName(module)
+			j.ideSdk.jars = append(j.ideSdk.jars, sm.HeaderJars()...)
+		}
+	})
+	j.ideSdk.jars = append(j.ideSdk.jars, flags.bootClasspath...)
+	j.ideSdk.jars = append(j.ideSdk.jars, flags.java9Classpath...)
+
 	if flags.javaVersion.usesJavaModules() {
 		j.properties.Srcs = append(j.properties.Srcs, j.properties.Openjdk9.Srcs...)
 	} else if len(j.properties.Openjdk9.Javacflags) > 0 {
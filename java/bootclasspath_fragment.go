@@ -219,6 +219,19 @@ type HiddenAPIPackageProperties struct {
 		// avoided where possible. Specifying package_prefixes and split_packages allows those
 		// implementation details to be excluded from the snapshot.
 		Split_packages []string
+
+		// Path to a checked-in copy of the all-flags.csv file generated by this
+		// bootclasspath_fragment.
+		//
+		// If specified then the build fails if the flags of any member are loosened relative to the
+		// baseline, e.g. if a member moves from blocked to unsupported or is added with a
+		// max-target-* flag, unless the member is listed in one of the loosened_flags_allowlist
+		// files. All the changes can be seen by building <name>-hiddenapi-flags-diff.
+		Flags_baseline *string `android:"path"`
+
+		// Files listing the dex signatures of the members whose flags may be loosened relative to
+		// flags_baseline, one per line.
+		Loosened_flags_allowlist []string `android:"path"`
 	}
 }
 
@@ -647,6 +660,9 @@ func (b *BootclasspathFragmentModule) createHiddenAPIFlagInput(ctx android.Modul
 	// Populate with package rules from the properties.
 	input.extractPackageRulesFromProperties(&b.sourceOnlyProperties.HiddenAPIPackageProperties)
 
+	// Populate with the baseline against which the generated flags are checked.
+	input.extractFlagsBaselineFromProperties(ctx, &b.sourceOnlyProperties.HiddenAPIPackageProperties)
+
 	input.gatherPropertyInfo(ctx, contents)
 
 	// Add the stub dex jars from this module's fragment dependencies.
@@ -521,3 +521,47 @@ func TestBootclasspathFragment_Test(t *testing.T) {
 	fragment = result.Module("a_test_fragment", "android_common").(*BootclasspathFragmentModule)
 	android.AssertBoolEquals(t, "is a test fragment by type", true, fragment.isTestFragment())
 }
+
+func TestBootclasspathFragment_HiddenAPIFlagsBaseline(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForTestWithBootclasspathFragment,
+		PrepareForTestWithJavaSdkLibraryFiles,
+		FixtureWithLastReleaseApis("mysdklibrary"),
+		android.MockFS{
+			"all-flags-baseline.csv":     nil,
+			"loosened-flags-allowed.txt": nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		bootclasspath_fragment {
+			name: "myfragment",
+			contents: ["mysdklibrary"],
+			hidden_api: {
+				split_packages: [],
+				flags_baseline: "all-flags-baseline.csv",
+				loosened_flags_allowlist: ["loosened-flags-allowed.txt"],
+			},
+		}
+
+		java_sdk_library {
+			name: "mysdklibrary",
+			srcs: ["a.java"],
+			shared_library: false,
+			public: {enabled: true},
+			system: {enabled: true},
+		}
+	`)
+
+	fragment := result.ModuleForTests("myfragment", "android_common")
+
+	diff := fragment.Output("modular-hiddenapi/flags-diff.txt")
+	android.AssertStringDoesContain(t, "flags diff command", android.StringRelativeToTop(result.Config, diff.RuleParams.Command),
+		"hiddenapi_flags_diff --fragment myfragment --allowlist loosened-flags-allowed.txt --error_on_loosening"+
+			" --output out/soong/.intermediates/myfragment/android_common/modular-hiddenapi/flags-diff.txt"+
+			" all-flags-baseline.csv out/soong/.intermediates/myfragment/android_common/modular-hiddenapi/all-flags.csv")
+
+	// The check fails the build as it is a validation of the rule that generates all-flags.csv.
+	allFlags := fragment.Output("modular-hiddenapi/all-flags.csv")
+	android.AssertPathsRelativeToTopEquals(t, "all flags validations",
+		[]string{"out/soong/.intermediates/myfragment/android_common/modular-hiddenapi/flags-diff.txt"},
+		allFlags.Validations)
+}
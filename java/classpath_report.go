@@ -0,0 +1,212 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+// Build rules to report the final order of the BOOTCLASSPATH, SYSTEMSERVERCLASSPATH and standalone
+// system_server jars, along with the classpath fragment, APEX and product variable that contribute
+// each jar, and to check the classpaths for duplicate classes and split packages.
+//
+// The classpath_report tool does the actual work, this singleton only describes the classpaths in
+// a JSON file that is passed to it.
+
+func init() {
+	RegisterClasspathReportComponents(android.InitRegistrationContext)
+}
+
+func RegisterClasspathReportComponents(ctx android.RegistrationContext) {
+	ctx.RegisterParallelSingletonType("classpath_report", classpathReportSingletonFactory)
+}
+
+var PrepareForTestWithClasspathReport = android.FixtureRegisterWithContext(RegisterClasspathReportComponents)
+
+func classpathReportSingletonFactory() android.Singleton {
+	return &classpathReportSingleton{}
+}
+
+type classpathReportSingleton struct{}
+
+// classpathReportConfig is the JSON file read by cmd/classpath_report.
+type classpathReportConfig struct {
+	Classpaths []*classpathReportClasspath
+}
+
+type classpathReportClasspath struct {
+	Name       string
+	Parents    []string `json:",omitempty"`
+	Standalone bool     `json:",omitempty"`
+	Jars       []*classpathReportJar
+}
+
+type classpathReportJar struct {
+	Name     string
+	Apex     string
+	Path     string
+	Fragment string
+	Variable string
+	DexJar   string `json:",omitempty"`
+}
+
+// classpathReportJarList is a list of configured jars and the product variable that configures them.
+type classpathReportJarList struct {
+	jars     android.ConfiguredJarList
+	variable string
+}
+
+func (s *classpathReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	global := dexpreopt.GetGlobalConfig(ctx)
+
+	// Map each apex:jar pair to the classpath fragment that adds it to the classpath on device.
+	fragments := map[classpathType]map[string]string{}
+	ctx.VisitAllModules(func(module android.Module) {
+		c, ok := module.(classpathFragment)
+		if !ok || !isActiveModule(module) {
+			return
+		}
+		info, ok := ctx.ModuleProvider(module, ClasspathFragmentProtoContentInfoProvider).(ClasspathFragmentProtoContentInfo)
+		if !ok || !info.ClasspathFragmentProtoGenerated {
+			return
+		}
+		classpath := c.classpathFragmentBase().classpathType
+		if fragments[classpath] == nil {
+			fragments[classpath] = map[string]string{}
+		}
+		contents := info.ClasspathFragmentProtoContents
+		for i := 0; i < contents.Len(); i++ {
+			fragments[classpath][contents.Apex(i)+":"+contents.Jar(i)] = android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+		}
+	})
+
+	// The ART jars are configured with ART_APEX_JARS but are part of PRODUCT_BOOT_JARS.
+	artJars := global.ArtApexJars
+	bootJars := global.BootJars.RemoveList(artJars)
+
+	config := classpathReportConfig{}
+	addClasspath := func(classpath classpathType, parents []classpathType, defaultFragment string, lists ...classpathReportJarList) {
+		cp := &classpathReportClasspath{
+			Name:       classpath.String(),
+			Standalone: classpath == STANDALONE_SYSTEMSERVER_JARS,
+		}
+		for _, parent := range parents {
+			cp.Parents = append(cp.Parents, parent.String())
+		}
+		for _, list := range lists {
+			paths := list.jars.DevicePaths(ctx.Config(), android.Android)
+			for i := 0; i < list.jars.Len(); i++ {
+				apex, name := list.jars.Apex(i), list.jars.Jar(i)
+				fragment, ok := fragments[classpath][apex+":"+name]
+				if !ok {
+					fragment = defaultFragment
+				}
+				cp.Jars = append(cp.Jars, &classpathReportJar{
+					Name:     name,
+					Apex:     apex,
+					Path:     paths[i],
+					Fragment: fragment,
+					Variable: list.variable,
+				})
+			}
+		}
+		config.Classpaths = append(config.Classpaths, cp)
+	}
+
+	// The jars in global.BootJars are in the order in which they are on the BOOTCLASSPATH, with the
+	// ART jars first.
+	addClasspath(BOOTCLASSPATH, nil, "platform-bootclasspath",
+		classpathReportJarList{artJars, "ART_APEX_JARS"},
+		classpathReportJarList{bootJars, "PRODUCT_BOOT_JARS"},
+		classpathReportJarList{global.ApexBootJars, "PRODUCT_APEX_BOOT_JARS"})
+	addClasspath(SYSTEMSERVERCLASSPATH, []classpathType{BOOTCLASSPATH}, "platform-systemserverclasspath",
+		classpathReportJarList{global.SystemServerJars, "PRODUCT_SYSTEM_SERVER_JARS"},
+		classpathReportJarList{global.ApexSystemServerJars, "PRODUCT_APEX_SYSTEM_SERVER_JARS"})
+	addClasspath(STANDALONE_SYSTEMSERVER_JARS, []classpathType{BOOTCLASSPATH, SYSTEMSERVERCLASSPATH}, "platform-systemserverclasspath",
+		classpathReportJarList{global.StandaloneSystemServerJars, "PRODUCT_STANDALONE_SYSTEM_SERVER_JARS"},
+		classpathReportJarList{global.ApexStandaloneSystemServerJars, "PRODUCT_APEX_STANDALONE_SYSTEM_SERVER_JARS"})
+
+	// Find the dex jars of the configured jars, so that the tool can read their classes.
+	var dexJars android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if !isActiveModule(module) {
+			return
+		}
+		j, ok := module.(interface{ DexJarBuildPath() OptionalDexJarPath })
+		if !ok {
+			return
+		}
+		if _, ok := module.(android.ApexModule); !ok {
+			return
+		}
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName(module))
+		apexInfo := ctx.ModuleProvider(module, android.ApexInfoProvider).(android.ApexInfo)
+		for _, cp := range config.Classpaths {
+			for _, jar := range cp.Jars {
+				if jar.Name != name || jar.DexJar != "" {
+					continue
+				}
+				if android.IsConfiguredJarForPlatform(jar.Apex) {
+					if len(apexInfo.InApexVariants) != 0 {
+						continue
+					}
+				} else if !apexInfo.InApexVariant(jar.Apex) {
+					continue
+				}
+				if dexJar := j.DexJarBuildPath(); dexJar.Valid() {
+					jar.DexJar = dexJar.Path().String()
+					dexJars = append(dexJars, dexJar.Path())
+				}
+			}
+		}
+	})
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal the classpath report config: %s", err)
+		return
+	}
+	configFile := android.PathForOutput(ctx, "classpath-report", "classpaths.json")
+	android.WriteFileRule(ctx, configFile, string(data))
+
+	report := android.PathForOutput(ctx, "classpath-report", "classpath-report.txt")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("classpath_report").
+		FlagWithInput("--config ", configFile).
+		FlagWithOutput("--output ", report).
+		Implicits(dexJars)
+	rule.Build("classpath_report", "classpath report")
+	ctx.Phony("classpath-report", report)
+
+	// The check-classpaths phony target fails the build if there are conflicts that are not in the
+	// allowlist.
+	allowlist := android.PathForSource(ctx, "build/soong/scripts/check_classpaths/allowed_conflicts.txt")
+	timestamp := android.PathForOutput(ctx, "classpath-report", "check-classpaths.stamp")
+	rule = android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("classpath_report").
+		FlagWithInput("--config ", configFile).
+		FlagWithInput("--allowlist ", allowlist).
+		Flag("--error_on_conflicts").
+		FlagWithOutput("--output ", timestamp.ReplaceExtension(ctx, "txt")).
+		Implicits(dexJars).
+		Text("&& touch").Output(timestamp)
+	rule.Build("check_classpaths", "check classpaths")
+	ctx.Phony("check-classpaths", timestamp)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+func TestClasspathReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForTestWithPlatformBootclasspath,
+		PrepareForTestWithClasspathReport,
+		FixtureConfigureBootJars("platform:foo"),
+		dexpreopt.FixtureSetSystemServerJars("platform:bar"),
+		android.FixtureAddFile("build/soong/scripts/check_classpaths/allowed_conflicts.txt", nil),
+	).RunTestWithBp(t, `
+		platform_bootclasspath {
+			name: "platform-bootclasspath",
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+			installable: true,
+		}
+	`)
+
+	singleton := result.SingletonForTests("classpath_report")
+
+	config := android.ContentFromFileRuleForTests(t, result.TestContext, singleton.Output("classpath-report/classpaths.json"))
+	config = android.StringRelativeToTop(result.Config, config)
+	android.AssertStringDoesContain(t, "BOOTCLASSPATH jar", config, `{
+          "Name": "foo",
+          "Apex": "platform",
+          "Path": "/system/framework/foo.jar",
+          "Fragment": "platform-bootclasspath",
+          "Variable": "PRODUCT_BOOT_JARS",
+          "DexJar": "out/soong/.intermediates/foo/android_common/`)
+	android.AssertStringDoesContain(t, "SYSTEMSERVERCLASSPATH jar", config, `{
+          "Name": "bar",
+          "Apex": "platform",
+          "Path": "/system/framework/bar.jar",
+          "Fragment": "platform-systemserverclasspath",
+          "Variable": "PRODUCT_SYSTEM_SERVER_JARS",`)
+	android.AssertStringDoesContain(t, "SYSTEMSERVERCLASSPATH parents", config, `"Name": "SYSTEMSERVERCLASSPATH",
+      "Parents": [
+        "BOOTCLASSPATH"
+      ],`)
+
+	report := singleton.Output("classpath-report/classpath-report.txt")
+	android.AssertStringDoesContain(t, "report command", android.StringRelativeToTop(result.Config, report.RuleParams.Command),
+		"--config out/soong/classpath-report/classpaths.json --output out/soong/classpath-report/classpath-report.txt")
+
+	check := singleton.Output("classpath-report/check-classpaths.stamp")
+	android.AssertStringDoesContain(t, "check command", android.StringRelativeToTop(result.Config, check.RuleParams.Command),
+		"--allowlist build/soong/scripts/check_classpaths/allowed_conflicts.txt --error_on_conflicts")
+}
@@ -817,6 +817,12 @@ type HiddenAPIFlagInput struct {
 	// RemovedTxtFiles is the list of removed.txt files provided by java_sdk_library modules that are
 	// specified in the bootclasspath_fragment's stub_libs and contents properties.
 	RemovedTxtFiles android.Paths
+
+	// See HiddenAPIPackageProperties.Flags_baseline
+	FlagsBaseline android.OptionalPath
+
+	// See HiddenAPIPackageProperties.Loosened_flags_allowlist
+	LoosenedFlagsAllowlist android.Paths
 }
 
 // newHiddenAPIFlagInput creates a new initialized HiddenAPIFlagInput struct.
@@ -831,6 +837,13 @@ func newHiddenAPIFlagInput() HiddenAPIFlagInput {
 	return input
 }
 
+// extractFlagsBaselineFromProperties extracts the baseline flags file and the allowlist of members
+// whose flags may be loosened from the supplied properties and stores them in this struct.
+func (i *HiddenAPIFlagInput) extractFlagsBaselineFromProperties(ctx android.ModuleContext, p *HiddenAPIPackageProperties) {
+	i.FlagsBaseline = android.OptionalPathForModuleSrc(ctx, p.Hidden_api.Flags_baseline)
+	i.LoosenedFlagsAllowlist = android.PathsForModuleSrc(ctx, p.Hidden_api.Loosened_flags_allowlist)
+}
+
 // gatherStubLibInfo gathers information from the stub libs needed by hidden API processing from the
 // dependencies added in hiddenAPIAddStubLibDependencies.
 //
@@ -977,7 +990,8 @@ func pathForValidation(ctx android.PathContext, path android.WritablePath) andro
 // the annotationFlags.
 func buildRuleToGenerateHiddenApiFlags(ctx android.BuilderContext, name, desc string,
 	outputPath android.WritablePath, baseFlagsPath android.Path, annotationFlagPaths android.Paths,
-	flagFilesByCategory FlagFilesByCategory, flagSubsets SignatureCsvSubsets, generatedRemovedDexSignatures android.OptionalPath) {
+	flagFilesByCategory FlagFilesByCategory, flagSubsets SignatureCsvSubsets, generatedRemovedDexSignatures android.OptionalPath,
+	validations android.Paths) {
 
 	// Create the rule that will generate the flag files.
 	tempPath := tempPathForRestat(ctx, outputPath)
@@ -1017,6 +1031,9 @@ func buildRuleToGenerateHiddenApiFlags(ctx android.BuilderContext, name, desc st
 		command.Validation(validFile)
 	}
 
+	// Add any other checks of the generated flag file, e.g. against a baseline.
+	command.Validations(validations)
+
 	rule.Build(name, desc)
 }
 
@@ -1144,6 +1161,34 @@ func buildRuleValidateOverlappingCsvFiles(ctx android.BuilderContext, name strin
 	return validFile
 }
 
+// buildRuleToDiffHiddenAPIFlagsAgainstBaseline creates a rule that compares the all-flags.csv file
+// of a bootclasspath_fragment with a checked-in baseline and fails if the flags of any member that
+// is not in the allowlist are loosened.
+//
+// The report of all the changes can be built with the <fragment>-hiddenapi-flags-diff target, or
+// with the hiddenapi-flags-diff target for all the fragments that specify a baseline.
+func buildRuleToDiffHiddenAPIFlagsAgainstBaseline(ctx android.ModuleContext, hiddenApiSubDir string,
+	allFlagsCSV android.Path, baseline android.Path, allowlist android.Paths) android.WritablePath {
+	name := android.RemoveOptionalPrebuiltPrefix(ctx.ModuleName())
+	report := android.PathForModuleOut(ctx, hiddenApiSubDir, "flags-diff.txt")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("hiddenapi_flags_diff").
+		FlagWithArg("--fragment ", name).
+		FlagForEachInput("--allowlist ", allowlist).
+		Flag("--error_on_loosening").
+		FlagWithOutput("--output ", report).
+		Input(baseline).
+		Input(allFlagsCSV)
+	rule.Build("modularHiddenApiFlagsDiff", "modular hiddenapi flags diff")
+
+	ctx.Phony(name+"-hiddenapi-flags-diff", report)
+	ctx.Phony("hiddenapi-flags-diff", report)
+
+	return report
+}
+
 // hiddenAPIFlagRulesForBootclasspathFragment will generate all the flags for a fragment of the
 // bootclasspath.
 //
@@ -1191,7 +1236,17 @@ func hiddenAPIFlagRulesForBootclasspathFragment(ctx android.ModuleContext, bootD
 	// Generate the all-flags.csv which are the flags that will, in future, be encoded into the dex
 	// files.
 	allFlagsCSV := android.PathForModuleOut(ctx, hiddenApiSubDir, "all-flags.csv")
-	buildRuleToGenerateHiddenApiFlags(ctx, "modularHiddenApiAllFlags"+suffix, "modular hiddenapi all flags"+suffix, allFlagsCSV, stubFlagsCSV, android.Paths{annotationFlagsCSV}, input.FlagFilesByCategory, nil, removedDexSignatures)
+
+	// If a baseline is specified then check the all-flags.csv file against it. The flags generated
+	// for the sdk snapshot are a subset of the flags of the fragment so there is no need to check
+	// them too.
+	var validations android.Paths
+	if suffix == "" && input.FlagsBaseline.Valid() {
+		validations = append(validations, buildRuleToDiffHiddenAPIFlagsAgainstBaseline(ctx, hiddenApiSubDir,
+			allFlagsCSV, input.FlagsBaseline.Path(), input.LoosenedFlagsAllowlist))
+	}
+
+	buildRuleToGenerateHiddenApiFlags(ctx, "modularHiddenApiAllFlags"+suffix, "modular hiddenapi all flags"+suffix, allFlagsCSV, stubFlagsCSV, android.Paths{annotationFlagsCSV}, input.FlagFilesByCategory, nil, removedDexSignatures, validations)
 
 	// Generate the filtered-stub-flags.csv file which contains the filtered stub flags that will be
 	// compared against the monolithic stub flags.
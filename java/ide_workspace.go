@@ -0,0 +1,414 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This singleton writes IntelliJ module (.iml) files for the java modules of the IDE workspace,
+// along with the .idea/modules.xml file that lists them, so that the workspace directory can be
+// opened as an IntelliJ or Android Studio project. See android/ide_workspace.go.
+//
+// The srcjars of the generated sources of a module, e.g. from genrules, aidl, proto and aconfig,
+// and of its annotation processors are unpacked by the ide-workspace target into directories that
+// are marked as generated source roots. The SDK and system modules that a module is compiled
+// against are defined as project libraries in .idea/libraries.
+
+func init() {
+	android.RegisterParallelSingletonType("java_ide_workspace", javaIdeWorkspaceSingletonFactory)
+}
+
+func javaIdeWorkspaceSingletonFactory() android.Singleton {
+	return &javaIdeWorkspaceSingleton{}
+}
+
+type javaIdeWorkspaceSingleton struct{}
+
+const (
+	imlModulesDir     = "modules"
+	imlGeneratedDir   = "gen"
+	imlSrcJarsDir     = "srcjars"
+	imlAnnotationsDir = "annotations"
+)
+
+var unpackIdeSrcJars = pctx.AndroidStaticRule("unpackIdeSrcJars",
+	blueprint.RuleParams{
+		Command:     `${config.ZipSyncCmd} -d $outDir -l $out -f "*.java" -f "*.kt" $in`,
+		CommandDeps: []string{"${config.ZipSyncCmd}"},
+	}, "outDir")
+
+// ideSdk is the SDK and system modules that a module is compiled against.
+type ideSdk struct {
+	// The sdk_version of the module, e.g. current or system_current, or empty for the platform.
+	sdkVersion string
+
+	// The name of the system modules of the module, if any.
+	systemModules string
+
+	// The jars of the SDK, i.e. of the system modules and the bootclasspath.
+	jars android.Paths
+}
+
+// libraryName returns the name of the project library of the SDK.
+func (s ideSdk) libraryName() string {
+	name := "sdk_platform"
+	if s.sdkVersion != "" {
+		name = "sdk_" + s.sdkVersion
+	}
+	if s.systemModules != "" {
+		name += "_" + s.systemModules
+	}
+	return name
+}
+
+// ideWorkspaceInfo is the information about a java module that the IDE workspace needs in
+// addition to android.IdeInfo.
+type ideWorkspaceInfo struct {
+	sdk ideSdk
+
+	// The srcjars of the generated sources, excluding those of the annotation processors.
+	srcJars android.Paths
+
+	// The srcjars of the sources generated by the annotation processors.
+	annoSrcJars android.Paths
+}
+
+type ideWorkspaceInfoProvider interface {
+	ideWorkspaceInfo() ideWorkspaceInfo
+}
+
+func (j *Module) ideWorkspaceInfo() ideWorkspaceInfo {
+	return ideWorkspaceInfo{
+		sdk:         j.ideSdk,
+		srcJars:     j.compiledSrcJars,
+		annoSrcJars: j.annoSrcJars,
+	}
+}
+
+type imlModule struct {
+	XMLName   xml.Name     `xml:"module"`
+	Type      string       `xml:"type,attr"`
+	Version   string       `xml:"version,attr"`
+	Component imlComponent `xml:"component"`
+}
+
+type imlComponent struct {
+	Name                  string          `xml:"name,attr"`
+	InheritCompilerOutput bool            `xml:"inherit-compiler-output,attr"`
+	ExcludeOutput         struct{}        `xml:"exclude-output"`
+	Contents              []imlContent    `xml:"content"`
+	OrderEntries          []imlOrderEntry `xml:"orderEntry"`
+}
+
+type imlContent struct {
+	Url           string            `xml:"url,attr"`
+	SourceFolders []imlSourceFolder `xml:"sourceFolder"`
+}
+
+type imlSourceFolder struct {
+	Url           string `xml:"url,attr"`
+	IsTestSource  bool   `xml:"isTestSource,attr"`
+	PackagePrefix string `xml:"packagePrefix,attr,omitempty"`
+	Generated     bool   `xml:"generated,attr,omitempty"`
+}
+
+type imlOrderEntry struct {
+	Type       string      `xml:"type,attr"`
+	ForTests   *bool       `xml:"forTests,attr,omitempty"`
+	ModuleName string      `xml:"module-name,attr,omitempty"`
+	Name       string      `xml:"name,attr,omitempty"`
+	Level      string      `xml:"level,attr,omitempty"`
+	Library    *imlLibrary `xml:"library,omitempty"`
+}
+
+type imlLibrary struct {
+	Name    string    `xml:"name,attr,omitempty"`
+	Classes []imlRoot `xml:"CLASSES>root"`
+	Javadoc struct{}  `xml:"JAVADOC"`
+	Sources []imlRoot `xml:"SOURCES>root"`
+}
+
+type ideaLibraryTable struct {
+	XMLName xml.Name   `xml:"component"`
+	Name    string     `xml:"name,attr"`
+	Library imlLibrary `xml:"library"`
+}
+
+type imlRoot struct {
+	Url string `xml:"url,attr"`
+}
+
+type ideaModules struct {
+	XMLName   xml.Name `xml:"project"`
+	Version   string   `xml:"version,attr"`
+	Component struct {
+		Name    string            `xml:"name,attr"`
+		Modules []ideaModuleEntry `xml:"modules>module"`
+	} `xml:"component"`
+}
+
+type ideaModuleEntry struct {
+	FileUrl  string `xml:"fileurl,attr"`
+	FilePath string `xml:"filepath,attr"`
+}
+
+func (j *javaIdeWorkspaceSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	scope := android.IdeWorkspaceScopeFor(ctx)
+	if scope == nil {
+		return
+	}
+
+	// Collect the IDE info of the java modules in scope, merging the variants of each module as
+	// java/jdeps.go does.
+	moduleInfos := make(map[string]android.IdeInfo)
+	moduleDirs := make(map[string]string)
+	workspaceInfos := make(map[string]ideWorkspaceInfo)
+	ctx.VisitAllModules(func(module android.Module) {
+		if !scope.Contains(module) || !android.IsModulePreferred(module) {
+			return
+		}
+		ideInfoProvider, ok := module.(android.IDEInfo)
+		if !ok {
+			return
+		}
+		name := ideInfoProvider.BaseModuleName()
+		if ideModuleNameProvider, ok := module.(android.IDECustomizedModuleName); ok {
+			name = ideModuleNameProvider.IDECustomizedModuleName()
+		}
+		dpInfo := moduleInfos[name]
+		ideInfoProvider.IDEInfo(&dpInfo)
+		moduleInfos[name] = dpInfo
+		moduleDirs[name] = ctx.ModuleDir(module)
+
+		// The generated sources of each variant are in different srcjars that contain the same files,
+		// so only those of the first variant are used.
+		if provider, ok := module.(ideWorkspaceInfoProvider); ok {
+			if _, ok := workspaceInfos[name]; !ok {
+				workspaceInfos[name] = provider.ideWorkspaceInfo()
+			}
+		}
+	})
+
+	topDir := android.AbsSrcDirForExistingUseCases()
+	outDir := android.PathForOutput(ctx).String() + "/"
+	fileUrl := func(path string) string {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(topDir, path)
+		}
+		return "file://" + path
+	}
+	jarUrl := func(path string) string {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(topDir, path)
+		}
+		return "jar://" + path + "!/"
+	}
+
+	// The source roots depend on the package declarations of the sources, so soong_build has to
+	// rerun when they change.
+	var readSrcs []string
+
+	var files android.WritablePaths
+	sdkLibraries := make(map[string]ideSdk)
+	modules := ideaModules{Version: "4"}
+	modules.Component.Name = "ProjectModuleManager"
+	falseValue := false
+	for _, name := range android.SortedKeys(moduleInfos) {
+		dpInfo := moduleInfos[name]
+
+		content := imlContent{Url: fileUrl(moduleDirs[name])}
+		roots := make(map[string]string)
+		for _, src := range android.FirstUniqueStrings(dpInfo.Srcs) {
+			// Generated sources are not in the source tree.
+			if strings.HasPrefix(src, outDir) {
+				continue
+			}
+			root, packagePrefix := javaSourceRoot(topDir, src)
+			readSrcs = append(readSrcs, src)
+			if _, ok := roots[root]; !ok {
+				roots[root] = packagePrefix
+			}
+		}
+		for _, root := range android.SortedKeys(roots) {
+			content.SourceFolders = append(content.SourceFolders, imlSourceFolder{
+				Url:           fileUrl(root),
+				PackagePrefix: roots[root],
+			})
+		}
+		contents := []imlContent{content}
+
+		info := workspaceInfos[name]
+		annoSrcJars := make(map[string]bool)
+		for _, srcJar := range info.annoSrcJars {
+			annoSrcJars[srcJar.String()] = true
+		}
+		var srcJars android.Paths
+		for _, srcJar := range info.srcJars {
+			if !annoSrcJars[srcJar.String()] {
+				srcJars = append(srcJars, srcJar)
+			}
+		}
+		for _, gen := range []struct {
+			dir     string
+			srcJars android.Paths
+		}{
+			{imlSrcJarsDir, srcJars},
+			{imlAnnotationsDir, info.annoSrcJars},
+		} {
+			if len(gen.srcJars) == 0 {
+				continue
+			}
+			dir := android.PathForIdeWorkspace(ctx, imlGeneratedDir, name, gen.dir)
+			list := android.PathForIdeWorkspace(ctx, imlGeneratedDir, name, gen.dir+".list")
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        unpackIdeSrcJars,
+				Description: "unpack IDE srcjars " + name,
+				Inputs:      android.FirstUniquePaths(gen.srcJars),
+				Output:      list,
+				Args:        map[string]string{"outDir": dir.String()},
+			})
+			ctx.Phony(android.IdeWorkspacePhony, list)
+			contents = append(contents, imlContent{
+				Url:           fileUrl(dir.String()),
+				SourceFolders: []imlSourceFolder{{Url: fileUrl(dir.String()), Generated: true}},
+			})
+		}
+
+		component := imlComponent{
+			Name:                  "NewModuleRootManager",
+			InheritCompilerOutput: true,
+			Contents:              contents,
+			OrderEntries: []imlOrderEntry{
+				{Type: "inheritedJdk"},
+				{Type: "sourceFolder", ForTests: &falseValue},
+			},
+		}
+		if len(info.sdk.jars) > 0 {
+			libraryName := info.sdk.libraryName()
+			if _, ok := sdkLibraries[libraryName]; !ok {
+				sdkLibraries[libraryName] = info.sdk
+			}
+			component.OrderEntries = append(component.OrderEntries,
+				imlOrderEntry{Type: "library", Name: libraryName, Level: "project"})
+		}
+		for _, dep := range android.FirstUniqueStrings(dpInfo.Deps) {
+			if _, ok := moduleInfos[dep]; ok && dep != name {
+				component.OrderEntries = append(component.OrderEntries, imlOrderEntry{Type: "module", ModuleName: dep})
+			}
+		}
+		if jars := android.FirstUniqueStrings(dpInfo.Jars); len(jars) > 0 {
+			library := &imlLibrary{}
+			for _, jar := range jars {
+				library.Classes = append(library.Classes, imlRoot{Url: jarUrl(jar)})
+			}
+			component.OrderEntries = append(component.OrderEntries, imlOrderEntry{Type: "module-library", Library: library})
+		}
+
+		imlFile := android.PathForIdeWorkspace(ctx, imlModulesDir, name+".iml")
+		if err := writeIdeaXml(imlFile, imlModule{Type: "JAVA_MODULE", Version: "4", Component: component}); err != nil {
+			ctx.Errorf("%s", err)
+			return
+		}
+		files = append(files, imlFile)
+
+		modules.Component.Modules = append(modules.Component.Modules, ideaModuleEntry{
+			FileUrl:  "file://$PROJECT_DIR$/" + imlModulesDir + "/" + name + ".iml",
+			FilePath: "$PROJECT_DIR$/" + imlModulesDir + "/" + name + ".iml",
+		})
+	}
+
+	for _, libraryName := range android.SortedKeys(sdkLibraries) {
+		library := imlLibrary{Name: libraryName}
+		for _, jar := range android.FirstUniquePaths(sdkLibraries[libraryName].jars) {
+			library.Classes = append(library.Classes, imlRoot{Url: jarUrl(jar.String())})
+		}
+		libraryFile := android.PathForIdeWorkspace(ctx, ".idea", "libraries", ideaLibraryFileName(libraryName)+".xml")
+		if err := writeIdeaXml(libraryFile, ideaLibraryTable{Name: "libraryTable", Library: library}); err != nil {
+			ctx.Errorf("%s", err)
+			return
+		}
+		files = append(files, libraryFile)
+	}
+
+	modulesFile := android.PathForIdeWorkspace(ctx, ".idea", "modules.xml")
+	if err := writeIdeaXml(modulesFile, modules); err != nil {
+		ctx.Errorf("%s", err)
+		return
+	}
+	files = append(files, modulesFile)
+
+	ctx.AddNinjaFileDeps(readSrcs...)
+
+	for _, file := range files {
+		// This is necessary to satisfy the dangling rules check as this file is written by Soong
+		// rather than a rule.
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   android.Touch,
+			Output: file,
+		})
+		ctx.Phony(android.IdeWorkspacePhony, file)
+	}
+}
+
+func writeIdeaXml(path android.WritablePath, v interface{}) error {
+	buf, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf = append([]byte(xml.Header), buf...)
+	return android.WriteFileToOutputDir(path, append(buf, '\n'), 0666)
+}
+
+var ideaLibraryFileNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ideaLibraryFileName returns the name that IntelliJ uses for the file of a project library.
+func ideaLibraryFileName(libraryName string) string {
+	return ideaLibraryFileNameRegexp.ReplaceAllString(libraryName, "_")
+}
+
+var javaPackageRegexp = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)`)
+
+// javaSourceRoot returns the source root of a java or kotlin source file, i.e. the directory in
+// which the directories of its package start, and the package prefix to use when its directory
+// does not match its package.
+func javaSourceRoot(topDir, src string) (string, string) {
+	dir := filepath.Dir(src)
+	data, err := os.ReadFile(filepath.Join(topDir, src))
+	if err != nil {
+		return dir, ""
+	}
+	match := javaPackageRegexp.FindSubmatch(data)
+	if match == nil {
+		return dir, ""
+	}
+	pkg := string(match[1])
+	pkgDir := strings.ReplaceAll(pkg, ".", "/")
+	if dir == pkgDir {
+		return ".", ""
+	}
+	if strings.HasSuffix(dir, "/"+pkgDir) {
+		return strings.TrimSuffix(dir, "/"+pkgDir), ""
+	}
+	return dir, pkg
+}
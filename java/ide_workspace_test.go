@@ -0,0 +1,133 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestIdeWorkspaceImlFiles(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterParallelSingletonType("java_ide_workspace", javaIdeWorkspaceSingletonFactory)
+		}),
+		android.FixtureMergeEnv(map[string]string{android.EnvVariableIdeWorkspaceModules: "foo"}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["foo/src/a.java"],
+			libs: ["bar"],
+			sdk_version: "none",
+			system_modules: "none",
+		}
+
+		java_import {
+			name: "bar",
+			jars: ["bar/bar.jar"],
+			sdk_version: "none",
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["baz/b.java"],
+			sdk_version: "none",
+			system_modules: "none",
+		}
+	`)
+
+	workspaceDir := filepath.Join(result.Config.SoongOutDir(), android.IdeWorkspaceDir)
+	readFile := func(path string) string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(workspaceDir, path))
+		if err != nil {
+			t.Fatalf("%s has not been generated: %s", path, err)
+		}
+		return string(content)
+	}
+
+	foo := readFile("modules/foo.iml")
+	android.AssertStringDoesContain(t, "foo source folder", foo, `<sourceFolder url="file://`)
+	android.AssertStringDoesContain(t, "foo source folder", foo, `/foo/src" isTestSource="false">`)
+	android.AssertStringDoesContain(t, "foo depends on bar", foo, `<orderEntry type="module" module-name="bar">`)
+
+	bar := readFile("modules/bar.iml")
+	android.AssertStringDoesContain(t, "bar jar", bar, `/bar/bar.jar!/">`)
+
+	if _, err := os.Stat(filepath.Join(workspaceDir, "modules", "baz.iml")); err == nil {
+		t.Errorf("baz is not in the IDE workspace but has a .iml file")
+	}
+
+	if !android.InList("foo/src/a.java", result.NinjaDeps) {
+		t.Errorf("the source roots depend on foo/src/a.java but it is not a ninja dep of soong_build")
+	}
+
+	modules := readFile(".idea/modules.xml")
+	android.AssertStringDoesContain(t, "modules.xml", modules,
+		`<module fileurl="file://$PROJECT_DIR$/modules/bar.iml" filepath="$PROJECT_DIR$/modules/bar.iml"></module>
+      <module fileurl="file://$PROJECT_DIR$/modules/foo.iml" filepath="$PROJECT_DIR$/modules/foo.iml"></module>`)
+}
+
+func TestIdeWorkspaceGeneratedSourcesAndSdk(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterParallelSingletonType("java_ide_workspace", javaIdeWorkspaceSingletonFactory)
+		}),
+		android.FixtureMergeEnv(map[string]string{android.EnvVariableIdeWorkspaceModules: "qux"}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "qux",
+			srcs: [
+				"qux/src/Q.kt",
+				":qux-gen",
+			],
+			sdk_version: "current",
+		}
+
+		genrule {
+			name: "qux-gen",
+			out: ["qux.srcjar"],
+			cmd: "touch $(out)",
+		}
+	`)
+
+	workspaceDir := filepath.Join(result.Config.SoongOutDir(), android.IdeWorkspaceDir)
+	readFile := func(path string) string {
+		t.Helper()
+		content, err := os.ReadFile(filepath.Join(workspaceDir, path))
+		if err != nil {
+			t.Fatalf("%s has not been generated: %s", path, err)
+		}
+		return string(content)
+	}
+
+	qux := readFile("modules/qux.iml")
+	android.AssertStringDoesContain(t, "kotlin source root", qux, `/qux/src" isTestSource="false">`)
+	android.AssertStringDoesContain(t, "unpacked srcjars", qux, `/gen/qux/srcjars" isTestSource="false" generated="true">`)
+	android.AssertStringDoesContain(t, "sdk library", qux,
+		`<orderEntry type="library" name="sdk_current_core-public-stubs-system-modules" level="project">`)
+
+	unpack := result.SingletonForTests("java_ide_workspace").Description("unpack IDE srcjars qux")
+	android.AssertStringDoesContain(t, "unpacked srcjar", unpack.Inputs.Strings()[0], "qux.srcjar")
+
+	sdk := readFile(".idea/libraries/sdk_current_core_public_stubs_system_modules.xml")
+	android.AssertStringDoesContain(t, "sdk library name", sdk, `<library name="sdk_current_core-public-stubs-system-modules">`)
+	android.AssertStringDoesContain(t, "sdk jars", sdk, "android_stubs_current")
+}
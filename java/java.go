@@ -2769,11 +2769,14 @@ func addCLCFromDep(ctx android.ModuleContext, depModule android.Module,
 		sdkLib = ulib.ProvidesUsesLib()
 	}
 
+	var reason string
 	depTag := ctx.OtherModuleDependencyTag(depModule)
 	if IsLibDepTag(depTag) {
 		// Ok, propagate <uses-library> through non-static library dependencies.
+		reason = "libs dependency"
 	} else if tag, ok := depTag.(usesLibraryDependencyTag); ok && tag.sdkVersion == dexpreopt.AnySdkVersion {
 		// Ok, propagate <uses-library> through non-compatibility <uses-library> dependencies.
+		reason = "<uses-library> dependency"
 	} else if depTag == staticLibTag {
 		// Propagate <uses-library> through static library dependencies, unless it is a component
 		// library (such as stubs). Component libraries have a dependency on their SDK library,
@@ -2781,6 +2784,7 @@ func addCLCFromDep(ctx android.ModuleContext, depModule android.Module,
 		if sdkLib != nil {
 			return
 		}
+		reason = "static_libs dependency"
 	} else {
 		// Don't propagate <uses-library> for other dependency tags.
 		return
@@ -2790,9 +2794,12 @@ func addCLCFromDep(ctx android.ModuleContext, depModule android.Module,
 	// and its CLC should be added as subtree of that node. Otherwise the library is not a
 	// <uses_library> and should not be added to CLC, but the transitive <uses-library> dependencies
 	// from its CLC should be added to the current CLC.
+	// The CLC may be shared with the modules that depend on this one, so the reason names this
+	// module.
 	if sdkLib != nil {
-		clcMap.AddContext(ctx, dexpreopt.AnySdkVersion, *sdkLib, false,
-			dep.DexJarBuildPath().PathOrNil(), dep.DexJarInstallPath(), dep.ClassLoaderContexts())
+		clcMap.AddContextWithReason(ctx, dexpreopt.AnySdkVersion, *sdkLib, false,
+			dep.DexJarBuildPath().PathOrNil(), dep.DexJarInstallPath(), dep.ClassLoaderContexts(),
+			depName, reason+" of "+ctx.ModuleName())
 	} else {
 		clcMap.AddContextMap(dep.ClassLoaderContexts(), depName)
 	}
@@ -58,6 +58,10 @@ type platformBootclasspathModule struct {
 	// Path to the monolithic hiddenapi-unsupported.csv file.
 	hiddenAPIMetadataCSV android.OutputPath
 
+	// Path to a zip file containing the hidden API flags of each fragment and of the whole
+	// bootclasspath.
+	hiddenAPIFlagsByFragmentZip android.WritablePath
+
 	// Path to a srcjar containing all the transitive sources of the bootclasspath.
 	srcjar android.OutputPath
 }
@@ -98,6 +102,8 @@ func (b *platformBootclasspathModule) OutputFiles(tag string) (android.Paths, er
 		return android.Paths{b.hiddenAPIIndexCSV}, nil
 	case "hiddenapi-metadata.csv":
 		return android.Paths{b.hiddenAPIMetadataCSV}, nil
+	case "hiddenapi-flags-by-fragment.zip":
+		return android.Paths{b.hiddenAPIFlagsByFragmentZip}, nil
 	case ".srcjar":
 		return android.Paths{b.srcjar}, nil
 	}
@@ -287,6 +293,7 @@ func (b *platformBootclasspathModule) generateHiddenAPIBuildActions(ctx android.
 	b.hiddenAPIFlagsCSV = hiddenAPISingletonPaths(ctx).flags
 	b.hiddenAPIIndexCSV = hiddenAPISingletonPaths(ctx).index
 	b.hiddenAPIMetadataCSV = hiddenAPISingletonPaths(ctx).metadata
+	b.hiddenAPIFlagsByFragmentZip = android.PathForModuleOut(ctx, "hiddenapi-monolithic", "hiddenapi-flags-by-fragment.zip")
 
 	bootDexJarByModule := extractBootDexJarsFromModules(ctx, modules)
 
@@ -294,7 +301,7 @@ func (b *platformBootclasspathModule) generateHiddenAPIBuildActions(ctx android.
 	// optimization that can be used to reduce the incremental build time but as its name suggests it
 	// can be unsafe to use, e.g. when the changes affect anything that goes on the bootclasspath.
 	if ctx.Config().DisableHiddenApiChecks() {
-		paths := android.OutputPaths{b.hiddenAPIFlagsCSV, b.hiddenAPIIndexCSV, b.hiddenAPIMetadataCSV}
+		paths := android.WritablePaths{b.hiddenAPIFlagsCSV, b.hiddenAPIIndexCSV, b.hiddenAPIMetadataCSV, b.hiddenAPIFlagsByFragmentZip}
 		for _, path := range paths {
 			ctx.Build(pctx, android.BuildParams{
 				Rule:   android.Touch,
@@ -338,7 +345,12 @@ func (b *platformBootclasspathModule) generateHiddenAPIBuildActions(ctx android.
 	allAnnotationFlagFiles := android.Paths{annotationFlags}
 	allAnnotationFlagFiles = append(allAnnotationFlagFiles, monolithicInfo.AnnotationFlagsPaths...)
 	allFlags := hiddenAPISingletonPaths(ctx).flags
-	buildRuleToGenerateHiddenApiFlags(ctx, "hiddenAPIFlagsFile", "monolithic hidden API flags", allFlags, stubFlags, allAnnotationFlagFiles, monolithicInfo.FlagsFilesByCategory, monolithicInfo.FlagSubsets, android.OptionalPath{})
+	buildRuleToGenerateHiddenApiFlags(ctx, "hiddenAPIFlagsFile", "monolithic hidden API flags", allFlags, stubFlags, allAnnotationFlagFiles, monolithicInfo.FlagsFilesByCategory, monolithicInfo.FlagSubsets, android.OptionalPath{}, nil)
+
+	// Generate the hiddenapi-flags-by-fragment.zip file that contains the flags of each fragment and
+	// of the whole bootclasspath, so that hiddenapi_flags_diff can compare them between builds. It
+	// can be distributed using the hiddenapi-flags-by-fragment.zip tag.
+	b.buildRuleZipHiddenAPIFlagsByFragment(ctx, fragments, allFlags, b.hiddenAPIFlagsByFragmentZip)
 
 	// Generate an intermediate monolithic hiddenapi-metadata.csv file directly from the annotations
 	// in the source code.
@@ -393,6 +405,32 @@ func (b *platformBootclasspathModule) createAndProvideMonolithicHiddenAPIInfo(ct
 	return monolithicInfo
 }
 
+// buildRuleZipHiddenAPIFlagsByFragment creates a rule to zip the all-flags.csv file of each fragment
+// as <fragment>/all-flags.csv and the monolithic flags file as platform/hiddenapi-flags.csv.
+func (b *platformBootclasspathModule) buildRuleZipHiddenAPIFlagsByFragment(ctx android.ModuleContext, fragments []android.Module, allFlags android.Path, outputPath android.WritablePath) {
+	rule := android.NewRuleBuilder(pctx, ctx)
+	command := rule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", outputPath)
+
+	seen := map[string]bool{}
+	for _, fragment := range fragments {
+		if !ctx.OtherModuleHasProvider(fragment, HiddenAPIInfoProvider) {
+			continue
+		}
+		info := ctx.OtherModuleProvider(fragment, HiddenAPIInfoProvider).(HiddenAPIInfo)
+		name := android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(fragment))
+		if info.AllFlagsPath == nil || seen[name] {
+			continue
+		}
+		seen[name] = true
+		command.FlagWithArg("-e ", name+"/all-flags.csv").FlagWithInput("-f ", info.AllFlagsPath)
+	}
+	command.FlagWithArg("-e ", "platform/hiddenapi-flags.csv").FlagWithInput("-f ", allFlags)
+
+	rule.Build("hiddenAPIFlagsByFragmentZip", "hidden API flags by fragment zip")
+}
+
 func (b *platformBootclasspathModule) buildRuleMergeCSV(ctx android.ModuleContext, desc string, inputPaths android.Paths, outputPath android.WritablePath) {
 	rule := android.NewRuleBuilder(pctx, ctx)
 	rule.Command().
@@ -385,4 +385,18 @@ func TestPlatformBootclasspath_HiddenAPIMonolithicFiles(t *testing.T) {
 	CheckHiddenAPIRuleInputs(t, "monolithic index", `
 		out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/index-from-classes.csv
 	`, rule)
+
+	// Check the zip of the flags by fragment, which only has the monolithic flags as there are no
+	// fragments.
+	rule = platformBootclasspath.Output("hiddenapi-monolithic/hiddenapi-flags-by-fragment.zip")
+	android.AssertStringDoesContain(t, "flags by fragment zip command",
+		android.StringRelativeToTop(result.Config, rule.RuleParams.Command),
+		"-e platform/hiddenapi-flags.csv -f out/soong/hiddenapi/hiddenapi-flags.csv")
+	outputFiles, err := platformBootclasspath.Module().(*platformBootclasspathModule).OutputFiles("hiddenapi-flags-by-fragment.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	android.AssertPathsRelativeToTopEquals(t, "flags by fragment zip output file",
+		[]string{"out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/hiddenapi-flags-by-fragment.zip"},
+		outputFiles)
 }
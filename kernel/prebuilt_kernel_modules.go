@@ -17,7 +17,6 @@ package kernel
 import (
 	"fmt"
 	"path/filepath"
-	"strings"
 
 	"android/soong/android"
 	_ "android/soong/cc/config"
@@ -41,6 +40,9 @@ type prebuiltKernelModules struct {
 	properties prebuiltKernelModulesProperties
 
 	installDir android.InstallPath
+
+	// The unstripped kernel module files
+	modules android.Paths
 }
 
 type prebuiltKernelModulesProperties struct {
@@ -53,11 +55,39 @@ type prebuiltKernelModulesProperties struct {
 
 	// Whether this module is directly installable to one of the partitions. Default is true
 	Installable *bool
+
+	// Other prebuilt_kernel_modules modules whose kernel modules are loaded before these, e.g. the
+	// GKI modules that vendor modules depend on.
+	Kernel_module_deps []string
+
+	// Module.symvers or symbol lists of the kernel image. When set, the symbols that the kernel
+	// modules use must be exported by the kernel image or by one of the kernel modules.
+	Kernel_symbol_list []string `android:"path"`
+
+	// Names of the kernel modules that must not be loaded. They are listed in modules.blocklist
+	// and left out of modules.load.
+	Blocklist []string
+
+	// Whether the kernel modules must be signed. Default is false.
+	Require_signature *bool
+
+	// Whether to fail the build if the kernel modules can't be loaded, e.g. if a module they
+	// depend on is missing or their vermagic doesn't match kernel_version. Default is false, in
+	// which case the problems are printed as warnings.
+	Enforce_checks *bool
 }
 
+type kernelModuleDepTag struct {
+	blueprint.BaseDependencyTag
+}
+
+var kernelModuleDep = kernelModuleDepTag{}
+
 // prebuilt_kernel_modules installs a set of prebuilt kernel module files to the correct directory.
-// In addition, this module builds modules.load, modules.dep, modules.softdep and modules.alias
-// using depmod and installs them as well.
+// In addition, this module builds modules.dep, modules.softdep and modules.alias using depmod and
+// installs them as well. The kernel modules are checked to be loadable: their dependencies and
+// symbols must be available and their vermagic must match kernel_version. Problems are warnings
+// unless enforce_checks is set. modules.load lists them so that dependencies are loaded first.
 func prebuiltKernelModulesFactory() android.Module {
 	module := &prebuiltKernelModules{}
 	module.AddProperties(&module.properties)
@@ -74,7 +104,7 @@ func (pkm *prebuiltKernelModules) KernelVersion() string {
 }
 
 func (pkm *prebuiltKernelModules) DepsMutator(ctx android.BottomUpMutatorContext) {
-	// do nothing
+	ctx.AddDependency(ctx.Module(), kernelModuleDep, pkm.properties.Kernel_module_deps...)
 }
 
 func (pkm *prebuiltKernelModules) GenerateAndroidBuildActions(ctx android.ModuleContext) {
@@ -82,7 +112,9 @@ func (pkm *prebuiltKernelModules) GenerateAndroidBuildActions(ctx android.Module
 		pkm.SkipInstall()
 	}
 	modules := android.PathsForModuleSrc(ctx, pkm.properties.Srcs)
+	pkm.modules = modules
 
+	modulesLoad, modulesBlocklist := pkm.checkKernelModules(ctx, modules)
 	depmodOut := runDepmod(ctx, modules)
 	strippedModules := stripDebugSymbols(ctx, modules)
 
@@ -94,7 +126,10 @@ func (pkm *prebuiltKernelModules) GenerateAndroidBuildActions(ctx android.Module
 	for _, m := range strippedModules {
 		ctx.InstallFile(installDir, filepath.Base(m.String()), m)
 	}
-	ctx.InstallFile(installDir, "modules.load", depmodOut.modulesLoad)
+	ctx.InstallFile(installDir, "modules.load", modulesLoad)
+	if modulesBlocklist.Valid() {
+		ctx.InstallFile(installDir, "modules.blocklist", modulesBlocklist.Path())
+	}
 	ctx.InstallFile(installDir, "modules.dep", depmodOut.modulesDep)
 	ctx.InstallFile(installDir, "modules.softdep", depmodOut.modulesSoftdep)
 	ctx.InstallFile(installDir, "modules.alias", depmodOut.modulesAlias)
@@ -130,8 +165,56 @@ func stripDebugSymbols(ctx android.ModuleContext, modules android.Paths) android
 	return outputs
 }
 
+// Creates a rule that checks that the kernel modules can be loaded and generates modules.load, in
+// which the dependencies come first, and modules.blocklist if any module is blocklisted.
+func (pkm *prebuiltKernelModules) checkKernelModules(ctx android.ModuleContext, modules android.Paths) (android.Path, android.OptionalPath) {
+	modulesLoad := android.PathForModuleOut(ctx, "modules.load")
+	report := android.PathForModuleOut(ctx, "check_kernel_modules.txt")
+
+	builder := android.NewRuleBuilder(pctx, ctx)
+	cmd := builder.Command().
+		BuiltTool("check_kernel_modules").
+		FlagWithOutput("--modules_load ", modulesLoad).
+		FlagWithOutput("--output ", report)
+	if pkm.KernelVersion() != "" {
+		cmd.FlagWithArg("--kernel_version ", pkm.KernelVersion())
+	}
+	if proptools.Bool(pkm.properties.Require_signature) {
+		cmd.Flag("--require_signature")
+	}
+	if proptools.Bool(pkm.properties.Enforce_checks) {
+		cmd.Flag("--enforce")
+	}
+	for _, symbols := range android.PathsForModuleSrc(ctx, pkm.properties.Kernel_symbol_list) {
+		cmd.FlagWithInput("--kernel_symbols ", symbols)
+	}
+	ctx.VisitDirectDepsWithTag(kernelModuleDep, func(m android.Module) {
+		dep, ok := m.(*prebuiltKernelModules)
+		if !ok {
+			ctx.PropertyErrorf("kernel_module_deps", "%q is not a prebuilt_kernel_modules module", ctx.OtherModuleName(m))
+			return
+		}
+		for _, ko := range dep.modules {
+			cmd.FlagWithInput("--dep ", ko)
+		}
+	})
+
+	var modulesBlocklist android.OptionalPath
+	if len(pkm.properties.Blocklist) > 0 {
+		blocklist := android.PathForModuleOut(ctx, "modules.blocklist")
+		for _, name := range pkm.properties.Blocklist {
+			cmd.FlagWithArg("--blocklist ", name)
+		}
+		cmd.FlagWithOutput("--modules_blocklist ", blocklist)
+		modulesBlocklist = android.OptionalPathForPath(blocklist)
+	}
+	cmd.Inputs(modules)
+
+	builder.Build("check_kernel_modules", fmt.Sprintf("Checking kernel modules of %s", ctx.ModuleName()))
+	return modulesLoad, modulesBlocklist
+}
+
 type depmodOutputs struct {
-	modulesLoad    android.OutputPath
 	modulesDep     android.OutputPath
 	modulesSoftdep android.OutputPath
 	modulesAlias   android.OutputPath
@@ -151,17 +234,6 @@ func runDepmod(ctx android.ModuleContext, modules android.Paths) depmodOutputs {
 		builder.Command().Text("cp").Input(m).Text(modulesDir.String())
 	}
 
-	// Enumerate modules to load
-	modulesLoad := modulesDir.Join(ctx, "modules.load")
-	var basenames []string
-	for _, m := range modules {
-		basenames = append(basenames, filepath.Base(m.String()))
-	}
-	builder.Command().
-		Text("echo").Flag("\"" + strings.Join(basenames, " ") + "\"").
-		Text("|").Text("tr").Flag("\" \"").Flag("\"\\n\"").
-		Text(">").Output(modulesLoad)
-
 	// Run depmod to build modules.dep/softdep/alias files
 	modulesDep := modulesDir.Join(ctx, "modules.dep")
 	modulesSoftdep := modulesDir.Join(ctx, "modules.softdep")
@@ -176,5 +248,5 @@ func runDepmod(ctx android.ModuleContext, modules android.Paths) depmodOutputs {
 
 	builder.Build("depmod", fmt.Sprintf("depmod %s", ctx.ModuleName()))
 
-	return depmodOutputs{modulesLoad, modulesDep, modulesSoftdep, modulesAlias}
+	return depmodOutputs{modulesDep, modulesSoftdep, modulesAlias}
 }
@@ -57,6 +57,63 @@ func TestKernelModulesFilelist(t *testing.T) {
 	android.AssertDeepEquals(t, "foo packaging specs", expected, actual)
 }
 
+func TestKernelModulesCheck(t *testing.T) {
+	ctx := android.GroupFixturePreparers(
+		cc.PrepareForTestWithCcDefaultModules,
+		android.FixtureRegisterWithContext(registerKernelBuildComponents),
+		android.MockFS{
+			"depmod.cpp":     nil,
+			"gki/gki.ko":     nil,
+			"vendor/mod1.ko": nil,
+			"vendor/mod2.ko": nil,
+			"Module.symvers": nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		prebuilt_kernel_modules {
+			name: "gki",
+			srcs: ["gki/gki.ko"],
+			kernel_version: "5.10",
+		}
+
+		prebuilt_kernel_modules {
+			name: "vendor",
+			srcs: ["vendor/*.ko"],
+			kernel_version: "5.10",
+			kernel_module_deps: ["gki"],
+			kernel_symbol_list: ["Module.symvers"],
+			blocklist: ["mod2"],
+			require_signature: true,
+			enforce_checks: true,
+		}
+	`)
+
+	vendor := ctx.ModuleForTests("vendor", "android_arm64_armv8-a")
+	cmd := vendor.Rule("check_kernel_modules").RuleParams.Command
+	for _, flag := range []string{
+		"--modules_load out/soong/.intermediates/vendor/android_arm64_armv8-a/modules.load",
+		"--kernel_version 5.10",
+		"--require_signature",
+		"--enforce",
+		"--kernel_symbols Module.symvers",
+		"--dep gki/gki.ko",
+		"--blocklist mod2",
+		"--modules_blocklist out/soong/.intermediates/vendor/android_arm64_armv8-a/modules.blocklist",
+		"vendor/mod1.ko vendor/mod2.ko",
+	} {
+		android.AssertStringDoesContain(t, "check_kernel_modules command", cmd, flag)
+	}
+
+	gkiCmd := ctx.ModuleForTests("gki", "android_arm64_armv8-a").Rule("check_kernel_modules").RuleParams.Command
+	android.AssertStringDoesNotContain(t, "checks are only enforced when requested", gkiCmd, "--enforce")
+
+	var actual []string
+	for _, ps := range vendor.Module().PackagingSpecs() {
+		actual = append(actual, ps.RelPathInPackage())
+	}
+	android.AssertStringListContains(t, "modules.blocklist should be installed",
+		actual, "lib/modules/5.10/modules.blocklist")
+}
+
 func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
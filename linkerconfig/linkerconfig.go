@@ -19,6 +19,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
@@ -39,6 +40,8 @@ func registerLinkerConfigBuildComponent(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("linker_config", LinkerConfigFactory)
 }
 
+var PrepareForTestWithLinkerConfig = android.FixtureRegisterWithContext(registerLinkerConfigBuildComponent)
+
 type linkerConfigProperties struct {
 	// source linker configuration property file
 	Src *string `android:"path"`
@@ -84,6 +87,15 @@ func (l *linkerConfig) OutputFiles(tag string) (android.Paths, error) {
 	}
 }
 
+// LinkerConfigInfo is provided by linker_config modules so that the images that install them can
+// check the configuration against their contents.
+type LinkerConfigInfo struct {
+	// The source linker configuration file in JSON
+	Src android.Path
+}
+
+var LinkerConfigInfoProvider = blueprint.NewProvider(LinkerConfigInfo{})
+
 func (l *linkerConfig) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	input := android.PathForModuleSrc(ctx, android.String(l.properties.Src))
 	output := android.PathForModuleOut(ctx, "linker.config.pb").OutputPath
@@ -98,6 +110,8 @@ func (l *linkerConfig) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		l.SkipInstall()
 	}
 	ctx.InstallFile(l.installDirPath, l.outputFilePath.Base(), l.outputFilePath)
+
+	ctx.SetProvider(LinkerConfigInfoProvider, LinkerConfigInfo{Src: input})
 }
 
 func BuildLinkerConfig(ctx android.ModuleContext, builder *android.RuleBuilder,
@@ -112,16 +126,7 @@ func BuildLinkerConfig(ctx android.ModuleContext, builder *android.RuleBuilder,
 		FlagWithOutput("-o ", interimOutput)
 
 	// Secondly, if there's provideLibs gathered from otherModules, append them
-	var provideLibs []string
-	for _, m := range otherModules {
-		if c, ok := m.(*cc.Module); ok && cc.IsStubTarget(c) {
-			for _, ps := range c.PackagingSpecs() {
-				provideLibs = append(provideLibs, ps.FileName())
-			}
-		}
-	}
-	provideLibs = android.FirstUniqueStrings(provideLibs)
-	sort.Strings(provideLibs)
+	provideLibs := StubLibraries(otherModules)
 	if len(provideLibs) > 0 {
 		builder.Command().
 			BuiltTool("conv_linker_config").
@@ -138,6 +143,22 @@ func BuildLinkerConfig(ctx android.ModuleContext, builder *android.RuleBuilder,
 	builder.DeleteTemporaryFiles()
 }
 
+// StubLibraries returns the sorted file names of the stub libraries among the given modules. They
+// are added to provideLibs of the linker config.
+func StubLibraries(otherModules []android.Module) []string {
+	var provideLibs []string
+	for _, m := range otherModules {
+		if c, ok := m.(*cc.Module); ok && cc.IsStubTarget(c) {
+			for _, ps := range c.PackagingSpecs() {
+				provideLibs = append(provideLibs, ps.FileName())
+			}
+		}
+	}
+	provideLibs = android.FirstUniqueStrings(provideLibs)
+	sort.Strings(provideLibs)
+	return provideLibs
+}
+
 // linker_config generates protobuf file from json file. This protobuf file will be used from
 // linkerconfig while generating ld.config.txt. Format of this file can be found from
 // https://android.googlesource.com/platform/system/linkerconfig/+/master/README.md
@@ -216,26 +216,12 @@ func rustEnvVars(ctx ModuleContext, deps PathDeps) []string {
 	envVars = append(envVars, "ANDROID_RUST_VERSION="+config.GetRustVersion(ctx))
 
 	if ctx.RustModule().compiler.cargoEnvCompat() {
+		binName := ""
 		if bin, ok := ctx.RustModule().compiler.(*binaryDecorator); ok {
-			envVars = append(envVars, "CARGO_BIN_NAME="+bin.getStem(ctx))
-		}
-		envVars = append(envVars, "CARGO_CRATE_NAME="+ctx.RustModule().CrateName())
-		envVars = append(envVars, "CARGO_PKG_NAME="+ctx.RustModule().CrateName())
-		pkgVersion := ctx.RustModule().compiler.cargoPkgVersion()
-		if pkgVersion != "" {
-			envVars = append(envVars, "CARGO_PKG_VERSION="+pkgVersion)
-
-			// Ensure the version is in the form of "x.y.z" (approximately semver compliant).
-			//
-			// For our purposes, we don't care to enforce that these are integers since they may
-			// include other characters at times (e.g. sometimes the patch version is more than an integer).
-			if strings.Count(pkgVersion, ".") == 2 {
-				var semver_parts = strings.Split(pkgVersion, ".")
-				envVars = append(envVars, "CARGO_PKG_VERSION_MAJOR="+semver_parts[0])
-				envVars = append(envVars, "CARGO_PKG_VERSION_MINOR="+semver_parts[1])
-				envVars = append(envVars, "CARGO_PKG_VERSION_PATCH="+semver_parts[2])
-			}
+			binName = bin.getStem(ctx)
 		}
+		envVars = append(envVars, cargoEnvVars(ctx.RustModule().CrateName(),
+			ctx.RustModule().compiler.cargoPkgVersion(), binName)...)
 	}
 
 	if ctx.Darwin() {
@@ -245,6 +231,32 @@ func rustEnvVars(ctx ModuleContext, deps PathDeps) []string {
 	return envVars
 }
 
+// cargoEnvVars returns the Cargo environment variables of a crate in the KEY=VALUE form. binName
+// is the stem of the binary for binary crates, and empty otherwise.
+func cargoEnvVars(crateName, pkgVersion, binName string) []string {
+	var envVars []string
+	if binName != "" {
+		envVars = append(envVars, "CARGO_BIN_NAME="+binName)
+	}
+	envVars = append(envVars, "CARGO_CRATE_NAME="+crateName)
+	envVars = append(envVars, "CARGO_PKG_NAME="+crateName)
+	if pkgVersion != "" {
+		envVars = append(envVars, "CARGO_PKG_VERSION="+pkgVersion)
+
+		// Ensure the version is in the form of "x.y.z" (approximately semver compliant).
+		//
+		// For our purposes, we don't care to enforce that these are integers since they may
+		// include other characters at times (e.g. sometimes the patch version is more than an integer).
+		if strings.Count(pkgVersion, ".") == 2 {
+			var semver_parts = strings.Split(pkgVersion, ".")
+			envVars = append(envVars, "CARGO_PKG_VERSION_MAJOR="+semver_parts[0])
+			envVars = append(envVars, "CARGO_PKG_VERSION_MINOR="+semver_parts[1])
+			envVars = append(envVars, "CARGO_PKG_VERSION_PATCH="+semver_parts[2])
+		}
+	}
+	return envVars
+}
+
 func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, flags Flags,
 	outputFile android.WritablePath, crateType string) buildOutput {
 
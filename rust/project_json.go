@@ -17,8 +17,12 @@ package rust
 import (
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"android/soong/android"
+	"android/soong/rust/config"
 )
 
 // This singleton collects Rust crate definitions and generates a JSON file
@@ -28,11 +32,22 @@ import (
 // For example,
 //
 //   $ SOONG_GEN_RUST_PROJECT=1 m nothing
+//
+// Each crate comes from a single variant of its module, the one for
+// SOONG_GEN_RUST_PROJECT_ARCH if set or the primary device arch otherwise,
+// preferring device variants over host variants.
+//
+// It also writes a rust-project.json file limited to the crates of the IDE
+// workspace when one is requested, see android/ide_workspace.go.
 
 const (
 	// Environment variables used to control the behavior of this singleton.
 	envVariableCollectRustDeps = "SOONG_GEN_RUST_PROJECT"
+	envVariableRustProjectArch = "SOONG_GEN_RUST_PROJECT_ARCH"
 	rustProjectJsonFileName    = "rust-project.json"
+
+	// Directory of the sources of the standard library in the prebuilt toolchain.
+	rustSysrootSrcDir = "lib/rustlib/src/rust/library"
 )
 
 // The format of rust-project.json is not yet finalized. A current description is available at:
@@ -44,29 +59,46 @@ type rustProjectDep struct {
 }
 
 type rustProjectCrate struct {
-	DisplayName string            `json:"display_name"`
-	RootModule  string            `json:"root_module"`
-	Edition     string            `json:"edition,omitempty"`
-	Deps        []rustProjectDep  `json:"deps"`
-	Cfg         []string          `json:"cfg"`
-	Env         map[string]string `json:"env"`
-	ProcMacro   bool              `json:"is_proc_macro"`
+	DisplayName        string             `json:"display_name"`
+	RootModule         string             `json:"root_module"`
+	Edition            string             `json:"edition,omitempty"`
+	Deps               []rustProjectDep   `json:"deps"`
+	Cfg                []string           `json:"cfg"`
+	Target             string             `json:"target,omitempty"`
+	Env                map[string]string  `json:"env"`
+	ProcMacro          bool               `json:"is_proc_macro"`
+	ProcMacroDylibPath string             `json:"proc_macro_dylib_path,omitempty"`
+	Source             *rustProjectSource `json:"source,omitempty"`
+}
+
+// rustProjectSource lists the directories of the files of a crate, which is needed when they are
+// not all under the directory of its root module, e.g. for generated sources.
+type rustProjectSource struct {
+	IncludeDirs []string `json:"include_dirs"`
+	ExcludeDirs []string `json:"exclude_dirs"`
 }
 
 type rustProjectJson struct {
-	Crates []rustProjectCrate `json:"crates"`
+	Sysroot    string             `json:"sysroot,omitempty"`
+	SysrootSrc string             `json:"sysroot_src,omitempty"`
+	Crates     []rustProjectCrate `json:"crates"`
 }
 
 // crateInfo is used during the processing to keep track of the known crates.
 type crateInfo struct {
-	Idx    int            // Index of the crate in rustProjectJson.Crates slice.
-	Deps   map[string]int // The keys are the module names and not the crate names.
-	Device bool           // True if the crate at idx was a device crate
+	Idx  int            // Index of the crate in rustProjectJson.Crates slice.
+	Deps map[string]int // The keys are the module names and not the crate names.
+	Rank int            // How well the variant of the crate at idx matches, see variantRank.
 }
 
 type projectGeneratorSingleton struct {
 	project     rustProjectJson
 	knownCrates map[string]crateInfo // Keys are module names.
+	arch        string               // The arch of the preferred variants.
+
+	// Whether rust-analyzer loads the standard library from the sources of the prebuilt toolchain,
+	// in which case the in-tree sysroot crates are not added.
+	useSysrootSrc bool
 }
 
 func rustProjectGeneratorSingleton() android.Singleton {
@@ -88,7 +120,7 @@ func (singleton *projectGeneratorSingleton) mergeDependencies(ctx android.Single
 			return
 		}
 		// Skip unsupported modules.
-		rChild, ok := isModuleSupported(ctx, child)
+		rChild, ok := singleton.isModuleSupported(ctx, child)
 		if !ok {
 			return
 		}
@@ -114,7 +146,7 @@ func (singleton *projectGeneratorSingleton) mergeDependencies(ctx android.Single
 
 // isModuleSupported returns the RustModule if the module
 // should be considered for inclusion in rust-project.json.
-func isModuleSupported(ctx android.SingletonContext, module android.Module) (*Module, bool) {
+func (singleton *projectGeneratorSingleton) isModuleSupported(ctx android.SingletonContext, module android.Module) (*Module, bool) {
 	rModule, ok := module.(*Module)
 	if !ok {
 		return nil, false
@@ -122,6 +154,10 @@ func isModuleSupported(ctx android.SingletonContext, module android.Module) (*Mo
 	if !rModule.Enabled() {
 		return nil, false
 	}
+	// The standard library crates are loaded by rust-analyzer from sysroot_src.
+	if lib, ok := rModule.compiler.(libraryInterface); ok && lib.sysroot() && singleton.useSysrootSrc {
+		return nil, false
+	}
 	return rModule, true
 }
 
@@ -142,12 +178,47 @@ func (singleton *projectGeneratorSingleton) addCrate(ctx android.SingletonContex
 		Edition:     rModule.compiler.edition(),
 		Deps:        make([]rustProjectDep, 0),
 		Cfg:         make([]string, 0),
+		Target:      config.FindToolchain(rModule.Os(), rModule.Arch()).RustTriple(),
 		Env:         make(map[string]string),
 		ProcMacro:   procMacro,
 	}
 
+	// rust-analyzer loads the proc macros from the host dylib built for the crate.
+	if procMacro && rModule.compiler.unstrippedOutputFilePath() != nil {
+		crate.ProcMacroDylibPath = absProjectPath(rModule.compiler.unstrippedOutputFilePath().String())
+	}
+
+	var includeDirs []string
 	if rModule.compiler.cargoOutDir().Valid() {
-		crate.Env["OUT_DIR"] = rModule.compiler.cargoOutDir().String()
+		// The OUT_DIR is used by include!(concat!(env!("OUT_DIR"), ...)), which is relative to the
+		// including file, so it must be absolute.
+		outDir := absProjectPath(rModule.compiler.cargoOutDir().String())
+		crate.Env["OUT_DIR"] = outDir
+		includeDirs = append(includeDirs, outDir)
+	}
+	if rModule.sourceProvider != nil {
+		// The sources generated by rust_bindgen and rust_protobuf are in the output directory.
+		for _, src := range rModule.sourceProvider.Srcs() {
+			includeDirs = append(includeDirs, absProjectPath(filepath.Dir(src.String())))
+		}
+	}
+	if len(includeDirs) > 0 {
+		crate.Source = &rustProjectSource{
+			IncludeDirs: android.FirstUniqueStrings(append([]string{absProjectPath(filepath.Dir(rootModule.String()))}, includeDirs...)),
+			ExcludeDirs: make([]string, 0),
+		}
+	}
+
+	if rModule.compiler.cargoEnvCompat() {
+		binName := ""
+		if _, ok := rModule.compiler.(*binaryDecorator); ok && rModule.compiler.unstrippedOutputFilePath() != nil {
+			out := rModule.compiler.unstrippedOutputFilePath()
+			binName = strings.TrimSuffix(out.Base(), out.Ext())
+		}
+		for _, env := range cargoEnvVars(rModule.CrateName(), rModule.compiler.cargoPkgVersion(), binName) {
+			key, value, _ := strings.Cut(env, "=")
+			crate.Env[key] = value
+		}
 	}
 
 	for _, feature := range rModule.compiler.features() {
@@ -164,7 +235,7 @@ func (singleton *projectGeneratorSingleton) addCrate(ctx android.SingletonContex
 		idx = len(singleton.project.Crates)
 		singleton.project.Crates = append(singleton.project.Crates, crate)
 	}
-	singleton.knownCrates[rModule.Name()] = crateInfo{Idx: idx, Deps: deps, Device: rModule.Device()}
+	singleton.knownCrates[rModule.Name()] = crateInfo{Idx: idx, Deps: deps, Rank: singleton.variantRank(rModule)}
 	return idx, true
 }
 
@@ -172,14 +243,14 @@ func (singleton *projectGeneratorSingleton) addCrate(ctx android.SingletonContex
 // It visits the dependencies of the module depth-first so the dependency ID can be added to the current module. If the
 // current module is already in singleton.knownCrates, its dependencies are merged.
 func (singleton *projectGeneratorSingleton) appendCrateAndDependencies(ctx android.SingletonContext, module android.Module) {
-	rModule, ok := isModuleSupported(ctx, module)
+	rModule, ok := singleton.isModuleSupported(ctx, module)
 	if !ok {
 		return
 	}
 	// If we have seen this crate already; merge any new dependencies.
 	if cInfo, ok := singleton.knownCrates[module.Name()]; ok {
-		// If we have a new device variant, override the old one
-		if !cInfo.Device && rModule.Device() {
+		// If we have a better matching variant, override the old one
+		if singleton.variantRank(rModule) > cInfo.Rank {
 			singleton.addCrate(ctx, rModule, cInfo.Deps)
 			return
 		}
@@ -192,22 +263,88 @@ func (singleton *projectGeneratorSingleton) appendCrateAndDependencies(ctx andro
 }
 
 func (singleton *projectGeneratorSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if scope := android.IdeWorkspaceScopeFor(ctx); scope != nil {
+		path := android.PathForIdeWorkspace(ctx, rustProjectJsonFileName)
+		singleton.generateProject(ctx, scope, path)
+
+		// This is necessary to satisfy the dangling rules check as this file is written by Soong rather than a rule.
+		ctx.Build(pctx, android.BuildParams{
+			Rule:   android.Touch,
+			Output: path,
+		})
+		ctx.Phony(android.IdeWorkspacePhony, path)
+	}
+
 	if !ctx.Config().IsEnvTrue(envVariableCollectRustDeps) {
 		return
 	}
 
+	singleton.generateProject(ctx, nil, android.PathForOutput(ctx, rustProjectJsonFileName))
+}
+
+// generateProject writes a rust-project.json file with the crates of all the modules, or of the
+// modules in scope if it is not nil, see android/ide_workspace.go.
+func (singleton *projectGeneratorSingleton) generateProject(ctx android.SingletonContext,
+	scope *android.IdeWorkspaceScope, path android.WritablePath) {
+
+	singleton.project = rustProjectJson{}
 	singleton.knownCrates = make(map[string]crateInfo)
+	singleton.arch = ctx.Config().Getenv(envVariableRustProjectArch)
+	if singleton.arch == "" && len(ctx.Config().Targets[android.Android]) > 0 {
+		singleton.arch = ctx.Config().Targets[android.Android][0].Arch.ArchType.Name
+	}
+
+	// Point rust-analyzer at the sources of the standard library of the prebuilt toolchain if it
+	// has them. Otherwise the in-tree sysroot crates are added like any other crate.
+	singleton.useSysrootSrc = false
+	if rustPath, err := ctx.Eval(pctx, "${config.RustPath}"); err == nil {
+		sysrootSrc := filepath.Join(rustPath, rustSysrootSrcDir)
+		if filepath.IsAbs(sysrootSrc) {
+			_, err := os.Stat(sysrootSrc)
+			singleton.useSysrootSrc = err == nil
+		} else {
+			singleton.useSysrootSrc = android.ExistentPathForSource(ctx, sysrootSrc).Valid()
+		}
+		if singleton.useSysrootSrc {
+			singleton.project.Sysroot = absProjectPath(rustPath)
+			singleton.project.SysrootSrc = absProjectPath(sysrootSrc)
+		}
+	}
 	ctx.VisitAllModules(func(module android.Module) {
+		if scope != nil && !scope.Contains(module) {
+			return
+		}
 		singleton.appendCrateAndDependencies(ctx, module)
 	})
 
-	path := android.PathForOutput(ctx, rustProjectJsonFileName)
 	err := createJsonFile(singleton.project, path)
 	if err != nil {
 		ctx.Errorf(err.Error())
 	}
 }
 
+// variantRank returns how well the variant of rModule matches the preferred variant; higher is
+// better. The arch matters most, then device variants are preferred over host variants.
+func (singleton *projectGeneratorSingleton) variantRank(rModule *Module) int {
+	rank := 0
+	if rModule.Arch().ArchType.Name == singleton.arch {
+		rank += 2
+	}
+	if rModule.Device() {
+		rank += 1
+	}
+	return rank
+}
+
+// absProjectPath returns path, which is relative to the top of the source tree if not absolute, as
+// an absolute path.
+func absProjectPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(android.AbsSrcDirForExistingUseCases(), path)
+}
+
 func createJsonFile(project rustProjectJson, rustProjectPath android.WritablePath) error {
 	buf, err := json.MarshalIndent(project, "", "  ")
 	if err != nil {
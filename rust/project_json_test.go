@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/rust/config"
 )
 
 // testProjectJson run the generation of rust-project.json. It returns the raw
@@ -349,3 +350,152 @@ func TestProjectJsonMultiVersion(t *testing.T) {
 	}
 	t.Errorf("libb crate has not been found: %v", crates)
 }
+
+func TestProjectJsonIdeWorkspace(t *testing.T) {
+	bp := `
+	rust_library {
+		name: "liba",
+		srcs: ["a/src/lib.rs"],
+		crate_name: "a"
+	}
+	rust_library {
+		name: "libb",
+		srcs: ["b/src/lib.rs"],
+		crate_name: "b",
+		rustlibs: ["liba"],
+	}
+	rust_library {
+		name: "libc",
+		srcs: ["c/src/lib.rs"],
+		crate_name: "c",
+	}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{android.EnvVariableIdeWorkspaceModules: "libb"}),
+	).RunTestWithBp(t, bp)
+
+	content, err := ioutil.ReadFile(filepath.Join(result.Config.SoongOutDir(), android.IdeWorkspaceDir, rustProjectJsonFileName))
+	if err != nil {
+		t.Fatalf("rust-project.json has not been generated in the IDE workspace: %s", err)
+	}
+	crates := validateJsonCrates(t, content)
+	rootModules := make(map[string]bool)
+	for _, c := range crates {
+		crate := validateCrate(t, c)
+		rootModules[crate["root_module"].(string)] = true
+	}
+	android.AssertBoolEquals(t, "liba is in the IDE workspace", true, rootModules["a/src/lib.rs"])
+	android.AssertBoolEquals(t, "libb is in the IDE workspace", true, rootModules["b/src/lib.rs"])
+	android.AssertBoolEquals(t, "libc is in the IDE workspace", false, rootModules["c/src/lib.rs"])
+
+	// The whole tree rust-project.json is only generated when requested.
+	if _, err := ioutil.ReadFile(filepath.Join(result.Config.SoongOutDir(), rustProjectJsonFileName)); err == nil {
+		t.Errorf("rust-project.json should not have been generated")
+	}
+}
+
+func TestProjectJsonEnvAndSources(t *testing.T) {
+	bp := `
+	rust_proc_macro {
+		name: "libproc_macro",
+		srcs: ["a/src/lib.rs"],
+		crate_name: "proc_macro"
+	}
+	rust_library {
+		name: "librust",
+		srcs: ["b/src/lib.rs"],
+		crate_name: "rust",
+		cargo_env_compat: true,
+		cargo_pkg_version: "1.2.3",
+		proc_macros: ["libproc_macro"],
+		rlibs: ["libbindings"],
+	}
+	rust_bindgen {
+		name: "libbindings",
+		crate_name: "bindings",
+		source_stem: "bindings",
+		wrapper_src: "src/any.h",
+	}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_GEN_RUST_PROJECT":      "1",
+			"SOONG_GEN_RUST_PROJECT_ARCH": "arm",
+		}),
+	).RunTestWithBp(t, bp)
+
+	content, err := ioutil.ReadFile(filepath.Join(result.Config.SoongOutDir(), rustProjectJsonFileName))
+	if err != nil {
+		t.Fatalf("rust-project.json has not been generated: %s", err)
+	}
+	var project rustProjectJson
+	if err := json.Unmarshal(content, &project); err != nil {
+		t.Fatalf("Unable to parse the rust-project.json as JSON: %v", err)
+	}
+	android.AssertStringEquals(t, "no sysroot_src without the sources of the prebuilt toolchain", "", project.SysrootSrc)
+
+	crates := make(map[string]rustProjectCrate)
+	for _, crate := range project.Crates {
+		crates[crate.DisplayName] = crate
+	}
+	if _, ok := crates["libstd"]; !ok {
+		t.Errorf("the in-tree libstd should be a crate without sysroot_src")
+	}
+
+	procMacro := crates["libproc_macro"]
+	android.AssertStringDoesContain(t, "proc macro dylib", procMacro.ProcMacroDylibPath, "libproc_macro.so")
+
+	rust := crates["librust"]
+	android.AssertStringEquals(t, "CARGO_PKG_NAME", "rust", rust.Env["CARGO_PKG_NAME"])
+	android.AssertStringEquals(t, "CARGO_PKG_VERSION_MINOR", "2", rust.Env["CARGO_PKG_VERSION_MINOR"])
+	android.AssertBoolEquals(t, "absolute OUT_DIR", true, filepath.IsAbs(rust.Env["OUT_DIR"]))
+	android.AssertStringEquals(t, "target of the selected arch", "armv7-linux-androideabi", rust.Target)
+
+	bindings := crates["libbindings"]
+	android.AssertStringDoesContain(t, "bindgen output of the selected arch", bindings.RootModule, "android_arm_")
+	if bindings.Source == nil {
+		t.Fatalf("libbindings does not list the directories of its generated sources: %v", bindings)
+	}
+	android.AssertStringListContains(t, "bindgen output directory", bindings.Source.IncludeDirs,
+		filepath.Dir(absProjectPath(bindings.RootModule)))
+}
+
+func TestProjectJsonSysrootSrc(t *testing.T) {
+	bp := `
+	rust_library {
+		name: "liba",
+		srcs: ["a/src/lib.rs"],
+		crate_name: "a"
+	}
+	`
+	sysroot := filepath.Join("prebuilts/rust/linux-x86", config.RustDefaultVersion)
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureAddFile(filepath.Join(sysroot, rustSysrootSrcDir, "std/src/lib.rs"), nil),
+		android.FixtureMergeEnv(map[string]string{"SOONG_GEN_RUST_PROJECT": "1"}),
+	).RunTestWithBp(t, bp)
+
+	content, err := ioutil.ReadFile(filepath.Join(result.Config.SoongOutDir(), rustProjectJsonFileName))
+	if err != nil {
+		t.Fatalf("rust-project.json has not been generated: %s", err)
+	}
+	var project rustProjectJson
+	if err := json.Unmarshal(content, &project); err != nil {
+		t.Fatalf("Unable to parse the rust-project.json as JSON: %v", err)
+	}
+	android.AssertStringEquals(t, "sysroot", absProjectPath(sysroot), project.Sysroot)
+	android.AssertStringEquals(t, "sysroot_src", absProjectPath(filepath.Join(sysroot, rustSysrootSrcDir)), project.SysrootSrc)
+
+	for _, crate := range project.Crates {
+		if crate.DisplayName == "libstd" {
+			t.Errorf("the in-tree libstd should not be a crate with sysroot_src")
+		}
+		for _, dep := range crate.Deps {
+			if dep.Name == "std" {
+				t.Errorf("%s should not depend on the in-tree libstd with sysroot_src", crate.DisplayName)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sizediff compares two listings of the files of an image, keyed by path, for the tools
+// that report how the contents and size of an image changed between builds.
+package sizediff
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Change is an entry that is in both listings but differs between them.
+type Change[T any] struct {
+	Old, New T
+
+	// Human readable description of what changed, e.g. "size", "module".
+	What []string
+}
+
+// Diff is the difference between two listings.
+type Diff[T any] struct {
+	Added, Removed []T
+	Changed        []Change[T]
+}
+
+// Empty returns true if the listings have the same entries.
+func (d Diff[T]) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffEntries compares the entries of two listings by the path returned by path. changed returns
+// what differs between the old and the new entry for the same path, nothing if they are the same.
+// The added, removed and changed entries are sorted by path.
+func DiffEntries[T any](oldEntries, newEntries []T, path func(T) string,
+	changed func(old, new T) []string) Diff[T] {
+
+	var d Diff[T]
+	oldByPath := make(map[string]T)
+	for _, e := range oldEntries {
+		oldByPath[path(e)] = e
+	}
+	newByPath := make(map[string]T)
+	for _, e := range newEntries {
+		newByPath[path(e)] = e
+	}
+
+	for _, o := range oldEntries {
+		n, ok := newByPath[path(o)]
+		if !ok {
+			d.Removed = append(d.Removed, o)
+		} else if what := changed(o, n); len(what) > 0 {
+			d.Changed = append(d.Changed, Change[T]{Old: o, New: n, What: what})
+		}
+	}
+	for _, n := range newEntries {
+		if _, ok := oldByPath[path(n)]; !ok {
+			d.Added = append(d.Added, n)
+		}
+	}
+
+	sort.Slice(d.Added, func(i, j int) bool { return path(d.Added[i]) < path(d.Added[j]) })
+	sort.Slice(d.Removed, func(i, j int) bool { return path(d.Removed[i]) < path(d.Removed[j]) })
+	sort.Slice(d.Changed, func(i, j int) bool { return path(d.Changed[i].New) < path(d.Changed[j].New) })
+	return d
+}
+
+// PrintTotalSize prints the total size of the old and the new listing.
+func PrintTotalSize(w io.Writer, oldSize, newSize int64) {
+	fmt.Fprintf(w, "Total size: %d -> %d (%+d)\n", oldSize, newSize, newSize-oldSize)
+}
+
+// PrintSection prints a section with the given title, calling print for each of the items. It
+// prints nothing if there are no items.
+func PrintSection[T any](w io.Writer, title string, items []T, print func(w io.Writer, item T)) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, item := range items {
+		print(w, item)
+	}
+}
+
+// ErrorOnGrowthFlag adds the --error_on_growth flag to flags. Its value is negative when the flag
+// isn't set.
+func ErrorOnGrowthFlag(flags *flag.FlagSet) *int64 {
+	return flags.Int64("error_on_growth", -1,
+		"exit with an error if the total size grows by more than this many bytes")
+}
+
+// CheckGrowth returns an error if the total size grew by more than allowed bytes. A negative
+// allowed growth disables the check.
+func CheckGrowth(oldSize, newSize, allowed int64) error {
+	if growth := newSize - oldSize; allowed >= 0 && growth > allowed {
+		return fmt.Errorf("total size grew by %d bytes, more than the allowed %d bytes", growth, allowed)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sizediff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type entry struct {
+	path string
+	size int64
+}
+
+func TestDiffEntries(t *testing.T) {
+	oldEntries := []entry{{"c", 1}, {"a", 1}, {"b", 2}}
+	newEntries := []entry{{"d", 4}, {"b", 3}, {"a", 1}}
+
+	d := DiffEntries(oldEntries, newEntries, func(e entry) string { return e.path },
+		func(o, n entry) []string {
+			if o.size != n.size {
+				return []string{"size"}
+			}
+			return nil
+		})
+
+	want := Diff[entry]{
+		Added:   []entry{{"d", 4}},
+		Removed: []entry{{"c", 1}},
+		Changed: []Change[entry]{{Old: entry{"b", 2}, New: entry{"b", 3}, What: []string{"size"}}},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("want %+v, got %+v", want, d)
+	}
+	if d.Empty() {
+		t.Errorf("want non-empty diff")
+	}
+}
+
+func TestPrintSection(t *testing.T) {
+	var buf bytes.Buffer
+	print := func(w io.Writer, e entry) { fmt.Fprintf(w, "  %s\n", e.path) }
+	PrintSection(&buf, "Empty", nil, print)
+	PrintSection(&buf, "Added", []entry{{"a", 1}, {"b", 2}}, print)
+	if want := "\nAdded:\n  a\n  b\n"; buf.String() != want {
+		t.Errorf("want %q, got %q", want, buf.String())
+	}
+}
+
+func TestCheckGrowth(t *testing.T) {
+	testCases := []struct {
+		oldSize, newSize, allowed int64
+		wantErr                   bool
+	}{
+		{100, 200, -1, false},
+		{100, 200, 100, false},
+		{100, 201, 100, true},
+		{200, 100, 0, false},
+	}
+	for _, tc := range testCases {
+		if err := CheckGrowth(tc.oldSize, tc.newSize, tc.allowed); (err != nil) != tc.wantErr {
+			t.Errorf("CheckGrowth(%d, %d, %d) = %v, want error %v", tc.oldSize, tc.newSize, tc.allowed,
+				err, tc.wantErr)
+		}
+	}
+}
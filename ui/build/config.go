@@ -1522,6 +1522,12 @@ func (c *configImpl) SoongBuildMetrics() string {
 	return filepath.Join(c.LogsDir(), "soong_build_metrics.pb")
 }
 
+// PartitionBudgetsDir is the directory of the partition budget reports of the logical_partition
+// modules. Must match partitionBudgetsDir in filesystem/partition_budget.go.
+func (c *configImpl) PartitionBudgetsDir() string {
+	return filepath.Join(c.SoongOutDir(), "partition_budgets")
+}
+
 func (c *configImpl) ProductOut() string {
 	return filepath.Join(c.OutDir(), "target", "product", c.TargetDevice())
 }
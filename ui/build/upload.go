@@ -78,6 +78,20 @@ func pruneMetricsFiles(paths []string) []string {
 // and the uploader is then executed in the background to allow the user/system
 // to continue working. Soong communicates to the uploader through the
 // upload_proto raw protobuf file.
+// PartitionBudgetReports returns the partition budget reports that were generated after
+// buildStarted, so that the headroom of the partitions is uploaded with the metrics of the builds
+// that change it. Reports of logical partitions that were not rebuilt are left out, as they are
+// either unchanged or stale.
+func PartitionBudgetReports(config Config, buildStarted time.Time) []string {
+	var reports []string
+	for _, report := range pruneMetricsFiles([]string{config.PartitionBudgetsDir()}) {
+		if fi, err := os.Stat(report); err == nil && fi.ModTime().After(buildStarted) {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
 func UploadMetrics(ctx Context, config Config, simpleOutput bool, buildStarted time.Time, paths ...string) {
 	ctx.BeginTrace(metrics.RunSetupTool, "upload_metrics")
 	defer ctx.EndTrace()
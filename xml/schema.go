@@ -0,0 +1,89 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xml
+
+import (
+	"fmt"
+
+	"android/soong/android"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+type xmlSchemaProperties struct {
+	// The schema file. Its extension, .dtd, .xsd or .rng, selects how xml files are validated.
+	Src *string `android:"path"`
+
+	// Other schema files that are included or imported by src.
+	Srcs []string `android:"path"`
+}
+
+type xmlSchema struct {
+	android.ModuleBase
+
+	properties xmlSchemaProperties
+
+	schema android.Path
+}
+
+// XmlSchemaInfo is the schema of an xml_schema module.
+type XmlSchemaInfo struct {
+	// The schema that xml files are validated against
+	Schema android.Path
+
+	// The files that the schema includes or imports
+	Deps android.Paths
+}
+
+var XmlSchemaInfoProvider = blueprint.NewProvider(XmlSchemaInfo{})
+
+func (s *xmlSchema) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if s.properties.Src == nil {
+		ctx.PropertyErrorf("src", "missing schema file")
+		return
+	}
+	s.schema = android.PathForModuleSrc(ctx, proptools.String(s.properties.Src))
+	if _, ok := xmllintRules[s.schema.Ext()]; !ok {
+		ctx.PropertyErrorf("src", "not supported extension: %q", s.schema.Ext())
+		return
+	}
+
+	ctx.SetProvider(XmlSchemaInfoProvider, XmlSchemaInfo{
+		Schema: s.schema,
+		Deps:   android.PathsForModuleSrc(ctx, s.properties.Srcs),
+	})
+}
+
+func (s *xmlSchema) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case "":
+		return android.PathsIfNonNil(s.schema), nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+var _ android.OutputFileProducer = (*xmlSchema)(nil)
+
+// xml_schema is a DTD, XSD or RELAX NG schema that can be shared by many prebuilt_etc_xml modules
+// by referencing it with schema: ":<name>". Files that the schema includes or imports are listed
+// in srcs so that the xml files are validated again when they change.
+func XmlSchemaFactory() android.Module {
+	module := &xmlSchema{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidModule(module)
+	return module
+}
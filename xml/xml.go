@@ -44,6 +44,14 @@ var (
 		},
 		"xsd")
 
+	xmllintRng = pctx.AndroidStaticRule("xmllint-rng",
+		blueprint.RuleParams{
+			Command:     `$XmlLintCmd --relaxng $rng $in > /dev/null && touch -a $out`,
+			CommandDeps: []string{"$XmlLintCmd"},
+			Restat:      true,
+		},
+		"rng")
+
 	xmllintMinimal = pctx.AndroidStaticRule("xmllint-minimal",
 		blueprint.RuleParams{
 			Command:     `$XmlLintCmd $in > /dev/null && touch -a $out`,
@@ -52,6 +60,17 @@ var (
 		})
 )
 
+// xmllintRules are the rules that validate xml files against a schema, and the name of the
+// argument that is set to the schema, by the extension of the schema.
+var xmllintRules = map[string]struct {
+	rule blueprint.Rule
+	arg  string
+}{
+	".dtd": {xmllintDtd, "dtd"},
+	".xsd": {xmllintXsd, "xsd"},
+	".rng": {xmllintRng, "rng"},
+}
+
 func init() {
 	registerXmlBuildComponents(android.InitRegistrationContext)
 	pctx.HostBinToolVariable("XmlLintCmd", "xmllint")
@@ -59,10 +78,12 @@ func init() {
 
 func registerXmlBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("prebuilt_etc_xml", PrebuiltEtcXmlFactory)
+	ctx.RegisterModuleType("xml_schema", XmlSchemaFactory)
 }
 
 type prebuiltEtcXmlProperties struct {
-	// Optional DTD that will be used to validate the xml file.
+	// Optional DTD, XSD or RELAX NG schema that will be used to validate the xml file, selected by
+	// its extension. Can also be a reference to an xml_schema module.
 	Schema *string `android:"path"`
 }
 
@@ -80,34 +101,24 @@ func (p *prebuiltEtcXml) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	p.PrebuiltEtc.GenerateAndroidBuildActions(ctx)
 
 	if p.properties.Schema != nil {
-		schema := android.PathForModuleSrc(ctx, proptools.String(p.properties.Schema))
+		schema, schemaDeps := p.schema(ctx)
+		if schema == nil {
+			return
+		}
 
-		switch schema.Ext() {
-		case ".dtd":
+		if r, ok := xmllintRules[schema.Ext()]; ok {
 			ctx.Build(pctx, android.BuildParams{
-				Rule:        xmllintDtd,
-				Description: "xmllint-dtd",
+				Rule:        r.rule,
+				Description: "xmllint-" + r.arg,
 				Input:       p.PrebuiltEtc.SourceFilePath(ctx),
 				Output:      p.timestampFilePath(ctx),
 				Implicit:    schema,
+				Implicits:   schemaDeps,
 				Args: map[string]string{
-					"dtd": schema.String(),
+					r.arg: schema.String(),
 				},
 			})
-			break
-		case ".xsd":
-			ctx.Build(pctx, android.BuildParams{
-				Rule:        xmllintXsd,
-				Description: "xmllint-xsd",
-				Input:       p.PrebuiltEtc.SourceFilePath(ctx),
-				Output:      p.timestampFilePath(ctx),
-				Implicit:    schema,
-				Args: map[string]string{
-					"xsd": schema.String(),
-				},
-			})
-			break
-		default:
+		} else {
 			ctx.PropertyErrorf("schema", "not supported extension: %q", schema.Ext())
 		}
 	} else {
@@ -123,6 +134,25 @@ func (p *prebuiltEtcXml) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	p.SetAdditionalDependencies([]android.Path{p.timestampFilePath(ctx)})
 }
 
+// schema returns the schema to validate the xml file against, and the files that it includes or
+// imports when it's an xml_schema module.
+func (p *prebuiltEtcXml) schema(ctx android.ModuleContext) (android.Path, android.Paths) {
+	if module, tag := android.SrcIsModuleWithTag(proptools.String(p.properties.Schema)); module != "" {
+		dep := android.GetModuleFromPathDep(ctx, module, tag)
+		if dep != nil && ctx.OtherModuleHasProvider(dep, XmlSchemaInfoProvider) {
+			info := ctx.OtherModuleProvider(dep, XmlSchemaInfoProvider).(XmlSchemaInfo)
+			return info.Schema, info.Deps
+		}
+	}
+
+	schemas := android.PathsForModuleSrc(ctx, []string{proptools.String(p.properties.Schema)})
+	if len(schemas) != 1 {
+		ctx.PropertyErrorf("schema", "expected a single schema file, got %d", len(schemas))
+		return nil, nil
+	}
+	return schemas[0], nil
+}
+
 func PrebuiltEtcXmlFactory() android.Module {
 	module := &prebuiltEtcXml{}
 	module.AddProperties(&module.properties)
@@ -33,6 +33,13 @@ func testXml(t *testing.T, bp string) *android.TestResult {
 		"bar.xml": nil,
 		"bar.xsd": nil,
 		"baz.xml": nil,
+		"qux.xml": nil,
+		"qux.rng": nil,
+
+		"permissions/a.xml":           nil,
+		"permissions/b.xml":           nil,
+		"permissions/permissions.xsd": nil,
+		"permissions/common.xsd":      nil,
 	}
 
 	return android.GroupFixturePreparers(
@@ -61,6 +68,11 @@ func TestPrebuiltEtcXml(t *testing.T) {
 			name: "baz.xml",
 			src: "baz.xml",
 		}
+		prebuilt_etc_xml {
+			name: "qux.xml",
+			src: "qux.xml",
+			schema: "qux.rng",
+		}
 	`)
 
 	for _, tc := range []struct {
@@ -69,6 +81,7 @@ func TestPrebuiltEtcXml(t *testing.T) {
 		{rule: "xmllint-dtd", input: "foo.xml", schemaType: "dtd", schema: "foo.dtd"},
 		{rule: "xmllint-xsd", input: "bar.xml", schemaType: "xsd", schema: "bar.xsd"},
 		{rule: "xmllint-minimal", input: "baz.xml"},
+		{rule: "xmllint-rng", input: "qux.xml", schemaType: "rng", schema: "qux.rng"},
 	} {
 		t.Run(tc.schemaType, func(t *testing.T) {
 			rule := result.ModuleForTests(tc.input, "android_arm64_armv8-a").Rule(tc.rule)
@@ -82,3 +95,46 @@ func TestPrebuiltEtcXml(t *testing.T) {
 	m := result.ModuleForTests("foo.xml", "android_arm64_armv8-a").Module().(*prebuiltEtcXml)
 	android.AssertPathRelativeToTopEquals(t, "installDir", "out/soong/target/product/test_device/system/etc", m.InstallDirPath())
 }
+
+func TestPrebuiltEtcXmlWithSchemaModule(t *testing.T) {
+	result := testXml(t, `
+		xml_schema {
+			name: "permissions_schema",
+			src: "permissions/permissions.xsd",
+			srcs: ["permissions/common.xsd"],
+		}
+		prebuilt_etc_xml {
+			name: "a.xml",
+			src: "permissions/a.xml",
+			schema: ":permissions_schema",
+		}
+		prebuilt_etc_xml {
+			name: "b.xml",
+			src: "permissions/b.xml",
+			schema: ":permissions_schema",
+		}
+	`)
+
+	for _, name := range []string{"a.xml", "b.xml"} {
+		rule := result.ModuleForTests(name, "android_arm64_armv8-a").Rule("xmllint-xsd")
+		android.AssertStringEquals(t, "schema", "permissions/permissions.xsd", rule.Args["xsd"])
+		android.AssertPathRelativeToTopEquals(t, "implicit", "permissions/permissions.xsd", rule.Implicit)
+		android.AssertPathsRelativeToTopEquals(t, "implicits", []string{"permissions/common.xsd"}, rule.Implicits)
+	}
+}
+
+func TestXmlSchemaUnsupportedExtension(t *testing.T) {
+	android.GroupFixturePreparers(
+		android.PrepareForTestWithArchMutator,
+		etc.PrepareForTestWithPrebuiltEtc,
+		PreparerForTestWithXmlBuildComponents,
+		android.FixtureAddFile("schema.txt", nil),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`src: not supported extension: ".txt"`)).
+		RunTestWithBp(t, `
+			xml_schema {
+				name: "schema",
+				src: "schema.txt",
+			}
+		`)
+}